@@ -6,13 +6,17 @@ import (
 	"text/tabwriter"
 	"time"
 
+	"github.com/energietransitie/needforheat-server-api/handlers"
 	"github.com/energietransitie/needforheat-server-api/needforheat/admin"
+	"github.com/energietransitie/needforheat-server-api/needforheat/authorization"
 	"github.com/spf13/cobra"
 )
 
 var (
-	nameFlag   string
-	expiryFlag string
+	nameFlag        string
+	expiryFlag      string
+	scopeFlag       string
+	campaignIDsFlag []uint
 )
 
 func init() {
@@ -57,12 +61,25 @@ func init() {
 	}
 	adminExpiryCmd.Flags().StringVarP(&expiryFlag, "expiry", "e", "", "Expiration date (yyyy-mm-dd) (at 00:00 UTC) of the admin")
 
+	adminCampaignTokenCmd := &cobra.Command{
+		Use:   "campaign-token",
+		Short: "Create a token of an admin that is limited to campaigns",
+		Long: "Create a token of an admin that is limited to campaigns.\n" +
+			"The token can only be used for the routes of these campaigns, like setting their retention policy.\n" +
+			"It is invalidated when the admin is reactivated.",
+		RunE: handleCreateCampaignTokenAdmin,
+	}
+	adminCampaignTokenCmd.Flags().StringVarP(&nameFlag, "name", "n", "", "Name of the admin")
+	adminCampaignTokenCmd.Flags().UintSliceVarP(&campaignIDsFlag, "campaign", "c", nil, "ID of a campaign the token can be used for (can be repeated or comma separated)")
+	adminCampaignTokenCmd.Flags().StringVarP(&scopeFlag, "scope", "s", string(authorization.ScopeCampaignAdmin), "Scope of the token: campaign:admin or campaign:read")
+
 	adminCmd.AddCommand(
 		adminListCmd,
 		adminCreateCmd,
 		adminDeleteCmd,
 		adminReactivateCmd,
 		adminExpiryCmd,
+		adminCampaignTokenCmd,
 	)
 
 	rootCmd.AddCommand(adminCmd)
@@ -197,3 +214,25 @@ func handleSetExpiryAdmin(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Admin \"%s\" expiry set to %s.\n", admin.Name, admin.Expiry.String())
 	return nil
 }
+
+func handleCreateCampaignTokenAdmin(cmd *cobra.Command, args []string) error {
+	tokenArgs := handlers.CampaignTokenArgs{
+		Name:        nameFlag,
+		Scope:       authorization.Scope(scopeFlag),
+		CampaignIDs: campaignIDsFlag,
+	}
+
+	client, err := getRPCClient()
+	if err != nil {
+		return err
+	}
+
+	var authToken string
+	err = client.Call("AdminHandler.CreateCampaignToken", tokenArgs, &authToken)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Token of admin \"%s\" with scope %s created for campaigns %v. Authorization token: %s\n", tokenArgs.Name, tokenArgs.Scope, tokenArgs.CampaignIDs, authToken)
+	return nil
+}
@@ -33,7 +33,18 @@ func init() {
 	cloudfeedDownloadCmd.Flags().StringVarP(&startPeriodFlag, "start", "s", "", "Start period (yyyy-mm-dd)")
 	cloudfeedDownloadCmd.Flags().StringVarP(&endPeriodFlag, "end", "e", "", "End period (yyyy-mm-dd)")
 
+	cloudfeedBackfillCmd := &cobra.Command{
+		Use:   "backfill",
+		Short: "Detect and download missing data from a cloud feed",
+		RunE:  handleCloudFeedBackfill,
+	}
+	cloudfeedBackfillCmd.Flags().UintVarP(&accountIDFlag, "account-id", "a", 0, "Account ID")
+	cloudfeedBackfillCmd.Flags().UintVarP(&cloudFeedIDFlag, "cloud-feed-id", "c", 0, "Cloud feed ID")
+	cloudfeedBackfillCmd.Flags().StringVarP(&startPeriodFlag, "start", "s", "", "Start period (yyyy-mm-dd)")
+	cloudfeedBackfillCmd.Flags().StringVarP(&endPeriodFlag, "end", "e", "", "End period (yyyy-mm-dd)")
+
 	cloudfeedCmd.AddCommand(cloudfeedDownloadCmd)
+	cloudfeedCmd.AddCommand(cloudfeedBackfillCmd)
 
 	rootCmd.AddCommand(cloudfeedCmd)
 }
@@ -84,3 +95,49 @@ func handleCloudFeedDownload(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func handleCloudFeedBackfill(cmd *cobra.Command, args []string) error {
+	if accountIDFlag == 0 {
+		return errors.New("account ID is required")
+	}
+	if cloudFeedIDFlag == 0 {
+		return errors.New("cloud feed ID is required")
+	}
+	if startPeriodFlag == "" {
+		return errors.New("start period is required")
+	}
+	if endPeriodFlag == "" {
+		endPeriodFlag = time.Now().Format("2006-01-02")
+	}
+
+	startPeriod, err := time.Parse("2006-01-02", startPeriodFlag)
+	if err != nil {
+		return err
+	}
+	endPeriod, err := time.Parse("2006-01-02", endPeriodFlag)
+	if err != nil {
+		return err
+	}
+
+	backfillArgs := handlers.DownloadArgs{
+		AccountID:   accountIDFlag,
+		CloudFeedID: cloudFeedIDFlag,
+		StartPeriod: needforheat.Time(startPeriod),
+		EndPeriod:   needforheat.Time(endPeriod),
+	}
+
+	client, err := getRPCClient()
+	if err != nil {
+		return err
+	}
+
+	var reply string
+	err = client.Call("CloudFeedHandler.Backfill", backfillArgs, &reply)
+	if err != nil {
+		return err
+	}
+
+	cmd.Println(reply)
+
+	return nil
+}
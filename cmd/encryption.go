@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	"github.com/energietransitie/needforheat-server-api/internal/encryption"
+	"github.com/energietransitie/needforheat-server-api/repositories"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// Path of the keyring used to encrypt secrets in the database.
+	encryptionKeyringPath = "./data/encryption_keys.pem"
+)
+
+func init() {
+	encryptionCmd := &cobra.Command{
+		Use:   "encryption",
+		Short: "Manage encryption of secrets in the database",
+		Long: "Manage encryption of secrets in the database.\n" +
+			"These commands connect to the database directly using NFH_DSN, so the server does not have to be running.",
+		Run: printUsage,
+	}
+
+	encryptionRotateCmd := &cobra.Command{
+		Use:   "rotate",
+		Short: "Generate a new encryption key and re-encrypt all secrets with it",
+		RunE:  handleEncryptionRotate,
+	}
+
+	encryptionReEncryptCmd := &cobra.Command{
+		Use:   "reencrypt",
+		Short: "Re-encrypt all secrets that are not encrypted with the newest key",
+		RunE:  handleEncryptionReEncrypt,
+	}
+
+	encryptionCmd.AddCommand(
+		encryptionRotateCmd,
+		encryptionReEncryptCmd,
+	)
+
+	rootCmd.AddCommand(encryptionCmd)
+}
+
+func handleEncryptionRotate(cmd *cobra.Command, args []string) error {
+	keyring, err := encryption.LoadKeyringFromFile(encryptionKeyringPath)
+	if err != nil {
+		return err
+	}
+
+	id, err := keyring.Rotate()
+	if err != nil {
+		return err
+	}
+
+	cmd.Println("Generated new encryption key", id)
+
+	return reEncryptSecrets(cmd, keyring)
+}
+
+func handleEncryptionReEncrypt(cmd *cobra.Command, args []string) error {
+	keyring, err := encryption.LoadKeyringFromFile(encryptionKeyringPath)
+	if err != nil {
+		return err
+	}
+
+	return reEncryptSecrets(cmd, keyring)
+}
+
+// Re-encrypt all secrets in the database with the primary key of keyring.
+func reEncryptSecrets(cmd *cobra.Command, keyring *encryption.Keyring) error {
+	dsn, ok := os.LookupEnv("NFH_DSN")
+	if !ok {
+		return errors.New("NFH_DSN was not set")
+	}
+
+	db, err := repositories.NewDatabaseConnection(dsn)
+	if err != nil {
+		return err
+	}
+
+	encryption.SetKeyring(keyring)
+
+	count, err := repositories.ReEncryptSecrets(db, keyring)
+	if err != nil {
+		return err
+	}
+
+	cmd.Println("Re-encrypted", count, "secrets with encryption key", keyring.PrimaryKeyID())
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat/export"
+	"github.com/energietransitie/needforheat-server-api/repositories"
+	"github.com/energietransitie/needforheat-server-api/services"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// Path of the key used to pseudonymise accounts in exports.
+	pseudonymKeyPath = "./data/pseudonym.key"
+)
+
+var (
+	exportCampaignIDFlag uint
+	exportFormatFlag     string
+	exportStartFlag      string
+	exportEndFlag        string
+	exportPropertyFlag   []string
+	exportOutputFlag     string
+)
+
+func init() {
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the measurements of a campaign",
+		Long: "Export the measurements of a campaign in long format, with pseudonymised accounts.\n" +
+			"This command connects to the database directly using NFH_DSN, so the server does not have to be running.",
+		RunE: handleExport,
+	}
+	exportCmd.Flags().UintVarP(&exportCampaignIDFlag, "campaign-id", "c", 0, "Campaign ID")
+	exportCmd.Flags().StringVarP(&exportFormatFlag, "format", "f", string(export.CSV), "Format (csv or parquet)")
+	exportCmd.Flags().StringVarP(&exportStartFlag, "start", "s", "", "Only export measurements at or after start (unix timestamp, yyyy-mm-dd or RFC 3339)")
+	exportCmd.Flags().StringVarP(&exportEndFlag, "end", "e", "", "Only export measurements at or before end (unix timestamp, yyyy-mm-dd or RFC 3339)")
+	exportCmd.Flags().StringSliceVarP(&exportPropertyFlag, "property", "p", nil, "Only export measurements of these property names")
+	exportCmd.Flags().StringVarP(&exportOutputFlag, "output", "o", "", "Output file (default stdout)")
+
+	rootCmd.AddCommand(exportCmd)
+}
+
+func handleExport(cmd *cobra.Command, args []string) error {
+	if exportCampaignIDFlag == 0 {
+		return errors.New("campaign ID is required")
+	}
+
+	format, err := export.ParseFormat(exportFormatFlag)
+	if err != nil {
+		return err
+	}
+
+	filter, err := export.MakeFilter(exportCampaignIDFlag, exportStartFlag, exportEndFlag, exportPropertyFlag)
+	if err != nil {
+		return err
+	}
+
+	dsn, ok := os.LookupEnv("NFH_DSN")
+	if !ok {
+		return errors.New("NFH_DSN was not set")
+	}
+
+	db, err := repositories.NewDatabaseConnection(dsn)
+	if err != nil {
+		return err
+	}
+
+	pseudonymizer, err := export.LoadPseudonymizerFromFile(pseudonymKeyPath)
+	if err != nil {
+		return err
+	}
+
+	exportService := services.NewExportService(repositories.NewExportRepository(db), repositories.NewCampaignRepository(db), pseudonymizer)
+
+	var w io.Writer = cmd.OutOrStdout()
+	if exportOutputFlag != "" {
+		file, err := os.Create(exportOutputFlag)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	count, err := exportService.Export(cmd.Context(), filter, format, w)
+	if err != nil {
+		return err
+	}
+
+	cmd.PrintErrln("Exported", count, "measurements")
+	return nil
+}
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat/researcher"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	researcherCmd := &cobra.Command{
+		Use:   "researcher",
+		Short: "Manage researchers",
+		Long: "Manage researchers.\n" +
+			"Researchers have read-only access to the accounts, devices, energy queries and measurements of the campaigns they are bound to.",
+		Run: printUsage,
+	}
+
+	researcherListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List all researchers",
+		RunE:  handleListResearchers,
+	}
+
+	researcherCreateCmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create a new researcher",
+		RunE:  handleCreateResearcher,
+	}
+	researcherCreateCmd.Flags().StringVarP(&nameFlag, "name", "n", "", "Name of the researcher")
+	researcherCreateCmd.Flags().UintSliceVarP(&campaignIDsFlag, "campaign", "c", nil, "ID of a campaign the researcher can read (can be repeated or comma separated)")
+	researcherCreateCmd.Flags().StringVarP(&expiryFlag, "expiry", "e", "", "Expiration date (yyyy-mm-dd) (at 00:00 UTC) of the researcher")
+
+	researcherDeleteCmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete a researcher",
+		RunE:  handleDeleteResearcher,
+	}
+	researcherDeleteCmd.Flags().StringVarP(&nameFlag, "name", "n", "", "Name of the researcher")
+
+	researcherReactivateCmd := &cobra.Command{
+		Use:   "reactivate",
+		Short: "Reactivate a researcher",
+		RunE:  handleReactivateResearcher,
+	}
+	researcherReactivateCmd.Flags().StringVarP(&nameFlag, "name", "n", "", "Name of the researcher")
+
+	researcherExpiryCmd := &cobra.Command{
+		Use:   "expiry",
+		Short: "Set expiry date of a researcher",
+		RunE:  handleSetExpiryResearcher,
+	}
+	researcherExpiryCmd.Flags().StringVarP(&nameFlag, "name", "n", "", "Name of the researcher")
+	researcherExpiryCmd.Flags().StringVarP(&expiryFlag, "expiry", "e", "", "Expiration date (yyyy-mm-dd) (at 00:00 UTC) of the researcher")
+
+	researcherCmd.AddCommand(
+		researcherListCmd,
+		researcherCreateCmd,
+		researcherDeleteCmd,
+		researcherReactivateCmd,
+		researcherExpiryCmd,
+	)
+
+	rootCmd.AddCommand(researcherCmd)
+}
+
+func handleListResearchers(cmd *cobra.Command, args []string) error {
+	var researchers []researcher.Researcher
+
+	client, err := getRPCClient()
+	if err != nil {
+		return err
+	}
+
+	err = client.Call("ResearcherHandler.List", 0, &researchers)
+	if err != nil {
+		return err
+	}
+
+	w := new(tabwriter.Writer)
+
+	w.Init(cmd.OutOrStdout(), 4, 4, 4, ' ', 0)
+
+	defer w.Flush()
+
+	fmt.Fprintf(w, "ID\tName\tCampaigns\tActivated at\tExpires at\n")
+
+	timeFormat := "2006-01-02 15:04:05 MST"
+	for _, researcher := range researchers {
+		campaignIDs := make([]string, 0, len(researcher.CampaignIDs))
+		for _, campaignID := range researcher.CampaignIDs {
+			campaignIDs = append(campaignIDs, strconv.FormatUint(uint64(campaignID), 10))
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t\n", researcher.ID, researcher.Name, strings.Join(campaignIDs, ","), researcher.ActivatedAt.Format(timeFormat), researcher.Expiry.Format(timeFormat))
+	}
+
+	return nil
+}
+
+func handleCreateResearcher(cmd *cobra.Command, args []string) error {
+	var expiry time.Time
+	if expiryFlag != "" {
+		var err error
+		expiry, err = time.Parse("2006-01-02", expiryFlag)
+		if err != nil {
+			return err
+		}
+	}
+
+	researcher := researcher.Researcher{
+		Name:        nameFlag,
+		CampaignIDs: campaignIDsFlag,
+		Expiry:      expiry,
+	}
+
+	client, err := getRPCClient()
+	if err != nil {
+		return err
+	}
+
+	var authToken string
+	err = client.Call("ResearcherHandler.Create", researcher, &authToken)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Researcher \"%s\" created. Authorization token: %s\n", researcher.Name, authToken)
+	return nil
+}
+
+func handleDeleteResearcher(cmd *cobra.Command, args []string) error {
+	researcher := researcher.Researcher{
+		Name: nameFlag,
+	}
+
+	client, err := getRPCClient()
+	if err != nil {
+		return err
+	}
+
+	err = client.Call("ResearcherHandler.Delete", researcher, nil)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Researcher \"%s\" deleted.\n", researcher.Name)
+	return nil
+}
+
+func handleReactivateResearcher(cmd *cobra.Command, args []string) error {
+	researcher := researcher.Researcher{
+		Name: nameFlag,
+	}
+
+	client, err := getRPCClient()
+	if err != nil {
+		return err
+	}
+
+	err = client.Call("ResearcherHandler.Reactivate", researcher, &researcher)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Researcher \"%s\" reactivated. All tokens before %s are now invalidated. New authorization token: %s\n", researcher.Name, researcher.ActivatedAt.String(), researcher.AuthorizationToken)
+	return nil
+}
+
+func handleSetExpiryResearcher(cmd *cobra.Command, args []string) error {
+	var expiry time.Time
+	if expiryFlag != "" {
+		var err error
+		expiry, err = time.Parse("2006-01-02", expiryFlag)
+		if err != nil {
+			return err
+		}
+	}
+
+	researcher := researcher.Researcher{
+		Name:   nameFlag,
+		Expiry: expiry,
+	}
+
+	client, err := getRPCClient()
+	if err != nil {
+		return err
+	}
+
+	err = client.Call("ResearcherHandler.SetExpiry", researcher, &researcher)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Researcher \"%s\" expiry set to %s.\n", researcher.Name, researcher.Expiry.String())
+	return nil
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"github.com/energietransitie/needforheat-server-api/handlers"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// Directory raw measurements are archived in before they are removed.
+	archiveDir = "./data/archive"
+)
+
+var (
+	retentionCampaignIDFlag uint
+)
+
+func init() {
+	retentionCmd := &cobra.Command{
+		Use:   "retention",
+		Short: "Enforce measurement retention policies",
+		Run:   printUsage,
+	}
+
+	retentionDryRunCmd := &cobra.Command{
+		Use:   "dry-run",
+		Short: "Report which measurements would be removed by the retention policies",
+		RunE:  handleRetentionDryRun,
+	}
+	retentionDryRunCmd.Flags().UintVarP(&retentionCampaignIDFlag, "campaign-id", "c", 0, "Only check the policy of this campaign")
+
+	retentionRunCmd := &cobra.Command{
+		Use:   "run",
+		Short: "Enforce the retention policies now, instead of waiting for the schedule",
+		RunE:  handleRetentionRun,
+	}
+	retentionRunCmd.Flags().UintVarP(&retentionCampaignIDFlag, "campaign-id", "c", 0, "Only enforce the policy of this campaign")
+
+	retentionCmd.AddCommand(retentionDryRunCmd)
+	retentionCmd.AddCommand(retentionRunCmd)
+
+	rootCmd.AddCommand(retentionCmd)
+}
+
+func handleRetentionDryRun(cmd *cobra.Command, args []string) error {
+	return enforceRetention(cmd, true)
+}
+
+func handleRetentionRun(cmd *cobra.Command, args []string) error {
+	return enforceRetention(cmd, false)
+}
+
+func enforceRetention(cmd *cobra.Command, dryRun bool) error {
+	enforceArgs := handlers.EnforceArgs{
+		CampaignID: retentionCampaignIDFlag,
+		DryRun:     dryRun,
+	}
+
+	client, err := getRPCClient()
+	if err != nil {
+		return err
+	}
+
+	var reply string
+	err = client.Call("RetentionHandler.Enforce", enforceArgs, &reply)
+	if err != nil {
+		return err
+	}
+
+	cmd.Println(reply)
+
+	return nil
+}
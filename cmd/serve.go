@@ -12,9 +12,15 @@ import (
 	"time"
 
 	"github.com/energietransitie/needforheat-server-api/handlers"
+	"github.com/energietransitie/needforheat-server-api/internal/encryption"
+	"github.com/energietransitie/needforheat-server-api/internal/schedule"
 	"github.com/energietransitie/needforheat-server-api/needforheat/authorization"
+	"github.com/energietransitie/needforheat-server-api/needforheat/export"
+	"github.com/energietransitie/needforheat-server-api/needforheat/upload"
 	"github.com/energietransitie/needforheat-server-api/repositories"
 	"github.com/energietransitie/needforheat-server-api/services"
+	"github.com/energietransitie/needforheat-server-api/services/cloudfeeds"
+	"github.com/energietransitie/needforheat-server-api/services/cloudfeeds/enelogic"
 	"github.com/energietransitie/needforheat-server-api/swaggerdocs"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -36,6 +42,9 @@ func init() {
 const (
 	shutdownTimeout    = 30 * time.Second
 	preRenewalDuration = 12 * time.Hour
+
+	// Path the cloud feed providers redirect to after a participant authorized a cloud feed.
+	cloudFeedCallbackPath = "/cloud_feed/callback"
 )
 
 func handleServe(cmd *cobra.Command, args []string) error {
@@ -49,6 +58,19 @@ func handleServe(cmd *cobra.Command, args []string) error {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
+	// Keyring used to encrypt secrets in the database.
+	keyring, err := encryption.LoadKeyringFromFile(encryptionKeyringPath)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	encryption.SetKeyring(keyring)
+
+	// Key used to pseudonymise accounts in exports.
+	pseudonymizer, err := export.LoadPseudonymizerFromFile(pseudonymKeyPath)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
 	dbCtx, dbCancel := context.WithTimeout(ctx, 10*time.Second)
 	defer dbCancel()
 
@@ -58,8 +80,19 @@ func handleServe(cmd *cobra.Command, args []string) error {
 		logrus.Fatal(err)
 	}
 
+	// Encrypt secrets that were stored before they were encrypted.
+	count, err := repositories.ReEncryptSecrets(db, keyring)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	if count > 0 {
+		logrus.Infoln("encrypted", count, "secrets in the database")
+	}
+
 	//Important services for admin and auth
-	authService, err := services.NewAuthorizationServiceFromFile("./data/key.pem")
+	tokenRepository := repositories.NewTokenRepository(db)
+	refreshTokenRepository := repositories.NewRefreshTokenRepository(db)
+	authService, err := services.NewAuthorizationServiceFromFile("./data/key.pem", tokenRepository, refreshTokenRepository, config.tokenLifetimes)
 	if err != nil {
 		logrus.Fatal(err)
 	}
@@ -72,15 +105,25 @@ func handleServe(cmd *cobra.Command, args []string) error {
 	adminService := services.NewAdminService(adminRepository, authService)
 	adminHandler := handlers.NewAdminHandler(adminService)
 
-	adminAuth := authHandler.Middleware(authorization.AdminToken)
-	accountActivationAuth := authHandler.Middleware(authorization.AccountActivationToken)
-	accountAuth := authHandler.Middleware(authorization.AccountToken)
-	deviceORaccountAuth := authHandler.DoubleMiddleware(authorization.DeviceToken, authorization.AccountToken)
+	researcherRepository, err := repositories.NewResearcherRepository("./data/researchers.db")
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	adminAuth := authHandler.RequireScopes(authorization.ScopeAdmin)
+	campaignAdminAuth := authHandler.RequireScopes(authorization.ScopeCampaignAdmin)
+	campaignReadAuth := authHandler.RequireScopes(authorization.ScopeCampaignRead)
+	accountActivationAuth := authHandler.RequireScopes(authorization.ScopeAccountActivate)
+	accountAuth := authHandler.RequireScopes(authorization.ScopeAccount)
+	measurementsReadAuth := authHandler.RequireScopes(authorization.ScopeMeasurementsRead)
+	uploadAuth := authHandler.RequireScopes(authorization.ScopeUploadWrite)
 
 	//Repositories
 	appRepository := repositories.NewAppRepository(db)
 	cloudFeedTypeRepository := repositories.NewCloudFeedTypeRepository(db)
 	cloudFeedRepository := repositories.NewCloudFeedRepository(db)
+	cloudFeedRunRepository := repositories.NewCloudFeedRunRepository(db)
+	cloudFeedAuthorizationRepository := repositories.NewCloudFeedAuthorizationRepository(db)
 	campaignRepository := repositories.NewCampaignRepository(db)
 	propertyRepository := repositories.NewPropertyRepository(db)
 	uploadRepository := repositories.NewUploadRepository(db)
@@ -92,6 +135,13 @@ func handleServe(cmd *cobra.Command, args []string) error {
 	energyQueryRepository := repositories.NewEnergyQueryRepository(db)
 	energyQueryTypeRepository := repositories.NewEnergyQueryTypeRepository(db)
 	apiKeyRepository := repositories.NewAPIKeyRepository(db)
+	exportRepository := repositories.NewExportRepository(db)
+	retentionRepository := repositories.NewRetentionRepository(db)
+	latestValueRepository := repositories.NewLatestValueRepository(db)
+
+	//Cloud feed providers
+	cloudFeedProviders := cloudfeeds.NewRegistry()
+	cloudFeedProviders.Register(enelogic.Name, enelogic.NewProvider(enelogic.NewClient(), enelogic.BaseURL), enelogic.Workers)
 
 	//Services
 	appService := services.NewAppService(appRepository)
@@ -107,12 +157,15 @@ func handleServe(cmd *cobra.Command, args []string) error {
 	)
 	dataSourceListService := services.NewDataSourceListService(dataSourceListRepository, dataSourceTypeService)
 	campaignService := services.NewCampaignService(campaignRepository, appService, dataSourceListService)
-	uploadService := services.NewUploadService(uploadRepository, deviceRepository, propertyService)
-	cloudFeedService := services.NewCloudFeedService(cloudFeedRepository, cloudFeedTypeRepository, uploadService)
-	accountService := services.NewAccountService(accountRepository, authService, appService, campaignService, cloudFeedService, dataSourceTypeService)
+	uploadService := services.NewUploadService(uploadRepository, deviceRepository, latestValueRepository, propertyService, deviceTypeService, config.conflictPolicy, config.clockSettings)
+	cloudFeedService := services.NewCloudFeedService(cloudFeedRepository, cloudFeedTypeRepository, cloudFeedRunRepository, cloudFeedAuthorizationRepository, uploadService, authService, cloudFeedProviders, config.BaseURL+cloudFeedCallbackPath)
+	accountService := services.NewAccountService(accountRepository, authService, appService, campaignService, cloudFeedService, dataSourceTypeService, uploadService)
 	energyQueryService := services.NewEnergyQueryService(energyQueryRepository, authService, energyQueryTypeService, accountService, uploadService)
-	deviceService := services.NewDeviceService(deviceRepository, authService, deviceTypeService, accountService, uploadService)
+	deviceService := services.NewDeviceService(deviceRepository, authService, deviceTypeService, accountService, uploadService, propertyService)
 	apiKeyService := services.NewAPIKeyService(apiKeyRepository)
+	exportService := services.NewExportService(exportRepository, campaignRepository, pseudonymizer)
+	retentionService := services.NewRetentionService(retentionRepository, campaignRepository, exportService, archiveDir)
+	researcherService := services.NewResearcherService(researcherRepository, authService, campaignService)
 
 	//Handlers
 	appHandler := handlers.NewAppHandler(appService)
@@ -128,46 +181,91 @@ func handleServe(cmd *cobra.Command, args []string) error {
 	energyQueryHandler := handlers.NewEnergyQueryHandler(energyQueryService)
 	energyQueryTypeHandler := handlers.NewEnergyQueryTypeHandler(energyQueryTypeService)
 	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	exportHandler := handlers.NewExportHandler(exportService)
+	propertyHandler := handlers.NewPropertyHandler(propertyService)
+	retentionHandler := handlers.NewRetentionHandler(retentionService)
+	tokenHandler := handlers.NewTokenHandler(authService)
+	keyHandler := handlers.NewKeyHandler(authService)
+	researcherHandler := handlers.NewResearcherHandler(researcherService)
+
+	// Campaign data can be read by admins and by researchers bound to the campaign.
+	adminORresearcher := handlers.RequirePrincipal(map[authorization.AuthKind]func(next handlers.Handler) handlers.Handler{
+		authorization.AdminToken:      adminHandler.Middleware,
+		authorization.ResearcherToken: researcherHandler.Middleware,
+	})
 
 	go cloudFeedService.RefreshTokensInBackground(ctx, preRenewalDuration)
-	go cloudFeedService.DownloadInBackground(ctx, config.downloadStartTime)
+	go cloudFeedService.DownloadInBackground(ctx, config.downloadSchedule)
+	go retentionService.EnforceInBackground(ctx, config.retentionSchedule)
 
 	//Router
 	r := chi.NewRouter()
 
-	r.Use(middleware.Timeout(time.Second * 30))
+	r.Use(handlers.Timeout(time.Second * 30))
 	r.Use(middleware.Heartbeat("/healthcheck")) // Endpoint for health check.
 	r.Use(middleware.RequestLogger(&middleware.DefaultLogFormatter{Logger: logrus.StandardLogger()}))
 
 	r.Method("POST", "/app", adminAuth(adminHandler.Middleware(appHandler.Create))) // POST on /app.
 
-	r.Method("POST", "/cloud_feed_type", adminAuth(adminHandler.Middleware(cloudFeedTypeHandler.Create))) // POST on /cloud_feed.
+	r.Method("POST", "/cloud_feed_type", adminAuth(adminHandler.Middleware(cloudFeedTypeHandler.Create)))                    // POST on /cloud_feed.
+	r.Method("PATCH", "/cloud_feed_type/{id}", adminAuth(adminHandler.Middleware(cloudFeedTypeHandler.SetDownloadSchedule))) // PATCH on /cloud_feed_type/{id}.
+
+	r.Method("GET", "/cloud_feed_run", adminAuth(adminHandler.Middleware(cloudFeedHandler.GetAllRuns))) // GET on /cloud_feed_run.
+	r.Method("GET", cloudFeedCallbackPath, handlers.Handler(cloudFeedHandler.Callback))                 // GET on /cloud_feed/callback.
 
-	r.Method("POST", "/campaign", adminAuth(adminHandler.Middleware(campaignHandler.Create))) // POST on /campaign.
+	r.Method("POST", "/campaign", adminAuth(adminHandler.Middleware(campaignHandler.Create)))                                      // POST on /campaign.
+	r.Method("GET", "/campaign/{campaign_id}/export", campaignReadAuth(adminORresearcher(exportHandler.Export)))                   // GET on /campaign/{campaign_id}/export.
+	r.Method("GET", "/campaign/{campaign_id}/accounts", campaignReadAuth(adminORresearcher(exportHandler.GetAccounts)))            // GET on /campaign/{campaign_id}/accounts.
+	r.Method("GET", "/campaign/{campaign_id}/devices", campaignReadAuth(adminORresearcher(exportHandler.GetDevices)))              // GET on /campaign/{campaign_id}/devices.
+	r.Method("GET", "/campaign/{campaign_id}/energy_queries", campaignReadAuth(adminORresearcher(exportHandler.GetEnergyQueries))) // GET on /campaign/{campaign_id}/energy_queries.
+	r.Method("GET", "/campaign/{campaign_id}/retention", campaignReadAuth(adminORresearcher(retentionHandler.Get)))                // GET on /campaign/{campaign_id}/retention.
+	r.Method("PUT", "/campaign/{campaign_id}/retention", campaignAdminAuth(adminHandler.Middleware(retentionHandler.Set)))         // PUT on /campaign/{campaign_id}/retention.
+	r.Method("DELETE", "/campaign/{campaign_id}/retention", campaignAdminAuth(adminHandler.Middleware(retentionHandler.Delete)))   // DELETE on /campaign/{campaign_id}/retention.
 
 	r.Route("/account", func(r chi.Router) {
 		r.Method("POST", "/", adminAuth(adminHandler.Middleware(accountHandler.Create))) // POST on /account.
 		r.Method("POST", "/activate", accountActivationAuth(accountHandler.Activate))    // POST on /account/activate.
+		r.Method("POST", "/token/refresh", handlers.Handler(tokenHandler.Refresh))       // POST on /account/token/refresh.
 
 		r.Route("/{account_id}", func(r chi.Router) {
-			r.Method("GET", "/", accountAuth(accountHandler.GetAccountByID))                     // GET on /account/{account_id}.
-			r.Method("POST", "/cloud_feed", accountAuth(cloudFeedHandler.Create))                // POST on /account/{account_id}/cloud_feed_auth.
-			r.Method("GET", "/cloud_feed", accountAuth(accountHandler.GetCloudFeedAuthStatuses)) // GET on /account/{account_id}/cloud_feed_auth.
+			r.Method("GET", "/", accountAuth(accountHandler.GetAccountByID))                                              // GET on /account/{account_id}.
+			r.Method("GET", "/latest", measurementsReadAuth(accountHandler.GetAccountLatest))                             // GET on /account/{account_id}/latest.
+			r.Method("GET", "/token", accountAuth(tokenHandler.GetAll))                                                   // GET on /account/{account_id}/token.
+			r.Method("DELETE", "/token/{token_id}", accountAuth(tokenHandler.Revoke))                                     // DELETE on /account/{account_id}/token/{token_id}.
+			r.Method("POST", "/cloud_feed", accountAuth(cloudFeedHandler.Create))                                         // POST on /account/{account_id}/cloud_feed_auth.
+			r.Method("GET", "/cloud_feed", accountAuth(accountHandler.GetCloudFeedAuthStatuses))                          // GET on /account/{account_id}/cloud_feed_auth.
+			r.Method("GET", "/cloud_feed/{id}/runs", accountAuth(cloudFeedHandler.GetRuns))                               // GET on /account/{account_id}/cloud_feed/{id}/runs.
+			r.Method("GET", "/cloud_feed/{cloud_feed_type_id}/authorize", accountAuth(accountHandler.AuthorizeCloudFeed)) // GET on /account/{account_id}/cloud_feed/{cloud_feed_type_id}/authorize.
+			r.Method("PUT", "/cloud_feed/{cloud_feed_type_id}", accountAuth(cloudFeedHandler.Reauthorize))                // PUT on /account/{account_id}/cloud_feed/{cloud_feed_type_id}.
+			r.Method("DELETE", "/cloud_feed/{cloud_feed_type_id}", accountAuth(cloudFeedHandler.Disconnect))              // DELETE on /account/{account_id}/cloud_feed/{cloud_feed_type_id}.
 		})
 	})
 
-	r.Method("POST", "/device_type", adminAuth(adminHandler.Middleware(deviceTypeHandler.Create))) // POST on /device_type.
+	r.Method("POST", "/device_type", adminAuth(adminHandler.Middleware(deviceTypeHandler.Create)))       // POST on /device_type.
+	r.Method("PATCH", "/device_type/{id}", adminAuth(adminHandler.Middleware(deviceTypeHandler.Update))) // PATCH on /device_type/{id}.
 
 	r.Route("/device", func(r chi.Router) {
-		r.Method("POST", "/", accountAuth(deviceHandler.Create))                                         // POST on /device.
-		r.Method("POST", "/activate", handlers.Handler(deviceHandler.Activate))                          // POST on /device/activate.
-		r.Method("GET", "/{device_name}", accountAuth(deviceHandler.GetDeviceByName))                    // GET on /device/{device_name}.
-		r.Method("GET", "/all", accountAuth(deviceHandler.GetDevicesByAccount))                          // GET on /device/all.
-		r.Method("GET", "/{device_name}/measurements", accountAuth(deviceHandler.GetDeviceMeasurements)) // GET on /device/{device_name}/measurements.
-		r.Method("GET", "/{device_name}/properties", accountAuth(deviceHandler.GetDeviceProperties))     // GET on /device/{device_name}/properties.
+		r.Method("POST", "/", accountAuth(deviceHandler.Create))                                                  // POST on /device.
+		r.Method("POST", "/activate", handlers.Handler(deviceHandler.Activate))                                   // POST on /device/activate.
+		r.Method("GET", "/{device_name}", accountAuth(deviceHandler.GetDeviceByName))                             // GET on /device/{device_name}.
+		r.Method("GET", "/all", accountAuth(deviceHandler.GetDevicesByAccount))                                   // GET on /device/all.
+		r.Method("GET", "/{device_name}/measurements", measurementsReadAuth(deviceHandler.GetDeviceMeasurements)) // GET on /device/{device_name}/measurements.
+		r.Method("GET", "/{device_name}/properties", measurementsReadAuth(deviceHandler.GetDeviceProperties))     // GET on /device/{device_name}/properties.
+		r.Method("GET", "/{device_name}/latest", measurementsReadAuth(deviceHandler.GetDeviceLatest))             // GET on /device/{device_name}/latest.
+		r.Method("GET", "/{device_name}/clock", accountAuth(deviceHandler.GetDeviceClock))                        // GET on /device/{device_name}/clock.
+		r.Method("PUT", "/{device_name}/clock", accountAuth(deviceHandler.SetDeviceClock))                        // PUT on /device/{device_name}/clock.
+	})
+
+	r.Route("/property", func(r chi.Router) {
+		r.Method("GET", "/", adminAuth(adminHandler.Middleware(propertyHandler.GetAll)))             // GET on /property.
+		r.Method("GET", "/{id}", adminAuth(adminHandler.Middleware(propertyHandler.Get)))            // GET on /property/{id}.
+		r.Method("PATCH", "/{id}", adminAuth(adminHandler.Middleware(propertyHandler.Update)))       // PATCH on /property/{id}.
+		r.Method("POST", "/{id}/rename", adminAuth(adminHandler.Middleware(propertyHandler.Rename))) // POST on /property/{id}/rename.
+		r.Method("POST", "/{id}/merge", adminAuth(adminHandler.Middleware(propertyHandler.Merge)))   // POST on /property/{id}/merge.
 	})
 
-	r.Method("POST", "/upload", deviceORaccountAuth(uploadHandler.Create)) // POST on /upload.
+	r.Method("POST", "/upload", uploadAuth(uploadHandler.Create))            // POST on /upload.
+	r.Method("POST", "/upload/batch", uploadAuth(uploadHandler.CreateBatch)) // POST on /upload/batch.
 
 	r.Method("POST", "/data_source_list", adminAuth(dataSourceListHandler.Create)) // POST on /data_source_list
 	r.Method("POST", "/data_source_type", adminAuth(dataSourceTypeHandler.Create)) // POST on /data_source_type
@@ -175,18 +273,21 @@ func handleServe(cmd *cobra.Command, args []string) error {
 	r.Method("POST", "/energy_query_type", adminAuth(adminHandler.Middleware(energyQueryTypeHandler.Create))) // POST on /energy_query_type
 
 	r.Route("/energy_query", func(r chi.Router) {
-		r.Method("POST", "/", accountAuth(energyQueryHandler.Create))                                                    // POST on /energy_query.
-		r.Method("GET", "/{energy_query_type}", accountAuth(energyQueryHandler.GetEnergyQueryByName))                    // GET on /energy_query/{energy_query_type}.
-		r.Method("GET", "/all", accountAuth(energyQueryHandler.GetEnergyQueriesByAccount))                               // GET on /energy_query/all.
-		r.Method("GET", "/{energy_query_type}/measurements", accountAuth(energyQueryHandler.GetEnergyQueryMeasurements)) // GET on /energy_query/{energy_query_type}/measurements.
-		r.Method("GET", "/{energy_query_type}/properties", accountAuth(energyQueryHandler.GetEnergyQueryProperties))     // GET on /energy_query/{energy_query_type}/properties.
+		r.Method("POST", "/", accountAuth(energyQueryHandler.Create))                                                             // POST on /energy_query.
+		r.Method("GET", "/{energy_query_type}", accountAuth(energyQueryHandler.GetEnergyQueryByName))                             // GET on /energy_query/{energy_query_type}.
+		r.Method("GET", "/all", accountAuth(energyQueryHandler.GetEnergyQueriesByAccount))                                        // GET on /energy_query/all.
+		r.Method("GET", "/{energy_query_type}/measurements", measurementsReadAuth(energyQueryHandler.GetEnergyQueryMeasurements)) // GET on /energy_query/{energy_query_type}/measurements.
+		r.Method("GET", "/{energy_query_type}/properties", measurementsReadAuth(energyQueryHandler.GetEnergyQueryProperties))     // GET on /energy_query/{energy_query_type}/properties.
+		r.Method("GET", "/{energy_query_type}/latest", measurementsReadAuth(energyQueryHandler.GetEnergyQueryLatest))             // GET on /energy_query/{energy_query_type}/latest.
 	})
 
 	r.Method("GET", "/api_key/{api_name}", accountAuth(apiKeyHandler.GetAPIKey)) // GET on /api_key/{api_name}
 
+	r.Method("GET", "/.well-known/jwks.json", handlers.Handler(keyHandler.JWKS)) // GET on /.well-known/jwks.json
+
 	setupSwaggerDocs(r, config.BaseURL)
 
-	go setupRPCHandler(adminHandler, cloudFeedHandler)
+	go setupRPCHandler(adminHandler, researcherHandler, cloudFeedHandler, retentionHandler, keyHandler)
 
 	server := &http.Server{
 		Addr:    ":8080",
@@ -201,14 +302,29 @@ func handleServe(cmd *cobra.Command, args []string) error {
 }
 
 const (
-	day                 = time.Hour * 24
 	defaultDownloadTime = "04h00s"
+
+	defaultConflictPolicy = upload.ConflictPolicyKeepFirst
+
+	defaultClockPolicy        = upload.ClockPolicyNone
+	defaultClockSkewThreshold = "5m"
+
+	defaultRetentionSchedule = "0 3 * * *"
+
+	defaultAccessTokenLifetime  = "15m"
+	defaultRefreshTokenLifetime = "2160h"
 )
 
 type Configuration struct {
-	DatabaseDSN       string
-	BaseURL           string
-	downloadStartTime time.Time
+	DatabaseDSN      string
+	BaseURL          string
+	downloadSchedule schedule.Schedule
+	conflictPolicy   upload.ConflictPolicy
+	clockSettings    upload.ClockSettings
+	// Schedule on which the retention policies of campaigns are enforced.
+	retentionSchedule schedule.Schedule
+	// Lifetimes of the access and refresh tokens of account sessions.
+	tokenLifetimes services.TokenLifetimes
 }
 
 func getConfiguration() Configuration {
@@ -233,17 +349,85 @@ func getConfiguration() Configuration {
 		logrus.Fatal(err)
 	}
 
-	downloadStartTime := time.Now().Truncate(day)
-	downloadStartTime = downloadStartTime.Add(duration)
-	// If time is in the past, add 1 day.
-	if downloadStartTime.Before(time.Now()) {
-		downloadStartTime = downloadStartTime.Add(day)
+	// Cloud feed types without their own schedule are downloaded daily at the download time.
+	downloadSchedule := schedule.Daily(duration)
+
+	conflictPolicyName, ok := os.LookupEnv("NFH_MEASUREMENT_CONFLICT_POLICY")
+	if !ok {
+		conflictPolicyName = string(defaultConflictPolicy)
+	}
+
+	conflictPolicy, err := upload.ParseConflictPolicy(conflictPolicyName)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	clockPolicyName, ok := os.LookupEnv("NFH_CLOCK_POLICY")
+	if !ok {
+		clockPolicyName = string(defaultClockPolicy)
+	}
+
+	clockPolicy, err := upload.ParseClockPolicy(clockPolicyName)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	clockSkewThreshold, ok := os.LookupEnv("NFH_CLOCK_SKEW_THRESHOLD")
+	if !ok {
+		clockSkewThreshold = defaultClockSkewThreshold
+	}
+
+	thresholdDuration, err := time.ParseDuration(clockSkewThreshold)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	threshold := int64(thresholdDuration.Seconds())
+
+	retentionScheduleExpr, ok := os.LookupEnv("NFH_RETENTION_SCHEDULE")
+	if !ok {
+		retentionScheduleExpr = defaultRetentionSchedule
+	}
+
+	retentionSchedule, err := schedule.Parse(retentionScheduleExpr)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	accessTokenLifetime, ok := os.LookupEnv("NFH_ACCESS_TOKEN_LIFETIME")
+	if !ok {
+		accessTokenLifetime = defaultAccessTokenLifetime
+	}
+
+	accessDuration, err := time.ParseDuration(accessTokenLifetime)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	refreshTokenLifetime, ok := os.LookupEnv("NFH_REFRESH_TOKEN_LIFETIME")
+	if !ok {
+		refreshTokenLifetime = defaultRefreshTokenLifetime
+	}
+
+	refreshDuration, err := time.ParseDuration(refreshTokenLifetime)
+	if err != nil {
+		logrus.Fatal(err)
 	}
 
 	return Configuration{
-		DatabaseDSN:       dsn,
-		BaseURL:           baseURL,
-		downloadStartTime: downloadStartTime,
+		DatabaseDSN:      dsn,
+		BaseURL:          baseURL,
+		downloadSchedule: downloadSchedule,
+		conflictPolicy:   conflictPolicy,
+		clockSettings: upload.ClockSettings{
+			Policy:    clockPolicy,
+			Threshold: &threshold,
+		},
+		retentionSchedule: retentionSchedule,
+		tokenLifetimes: services.TokenLifetimes{
+			Access:  accessDuration,
+			Refresh: refreshDuration,
+		},
 	}
 }
 
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var (
+	signingKeyIDFlag string
+)
+
+func init() {
+	signingKeyCmd := &cobra.Command{
+		Use:   "signing-key",
+		Short: "Manage the keys used to sign tokens",
+		Long: "Manage the keys used to sign tokens.\n" +
+			"The newest key signs new tokens. Retired keys are only used to verify tokens that were signed with them.\n" +
+			"The public keys are published on /.well-known/jwks.json.",
+		Run: printUsage,
+	}
+
+	signingKeyListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List all signing keys",
+		RunE:  handleListSigningKeys,
+	}
+
+	signingKeyRotateCmd := &cobra.Command{
+		Use:   "rotate",
+		Short: "Generate a new signing key and retire the current one",
+		RunE:  handleRotateSigningKey,
+	}
+
+	signingKeyRemoveCmd := &cobra.Command{
+		Use:   "remove",
+		Short: "Remove a retired signing key, so tokens signed with it can not be used anymore",
+		RunE:  handleRemoveSigningKey,
+	}
+	signingKeyRemoveCmd.Flags().StringVarP(&signingKeyIDFlag, "id", "i", "", "ID of the signing key")
+
+	signingKeyCmd.AddCommand(
+		signingKeyListCmd,
+		signingKeyRotateCmd,
+		signingKeyRemoveCmd,
+	)
+
+	rootCmd.AddCommand(signingKeyCmd)
+}
+
+func handleListSigningKeys(cmd *cobra.Command, args []string) error {
+	return callSigningKeyRPC(cmd, "KeyHandler.List", 0)
+}
+
+func handleRotateSigningKey(cmd *cobra.Command, args []string) error {
+	return callSigningKeyRPC(cmd, "KeyHandler.Rotate", 0)
+}
+
+func handleRemoveSigningKey(cmd *cobra.Command, args []string) error {
+	return callSigningKeyRPC(cmd, "KeyHandler.Remove", signingKeyIDFlag)
+}
+
+func callSigningKeyRPC(cmd *cobra.Command, method string, args any) error {
+	client, err := getRPCClient()
+	if err != nil {
+		return err
+	}
+
+	var reply string
+	err = client.Call(method, args, &reply)
+	if err != nil {
+		return err
+	}
+
+	cmd.Println(reply)
+
+	return nil
+}
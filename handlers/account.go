@@ -117,6 +117,35 @@ func (h *AccountHandler) GetAccountByID(w http.ResponseWriter, r *http.Request)
 	return nil
 }
 
+// Handle API endpoint for getting the latest value of each property of the devices and energy queries of an account.
+func (h *AccountHandler) GetAccountLatest(w http.ResponseWriter, r *http.Request) error {
+	accountID, err := strconv.ParseUint(chi.URLParam(r, "account_id"), 10, 64)
+	if err != nil {
+		return NewHandlerError(err, "account_id not a number", http.StatusBadRequest)
+	}
+
+	auth, ok := r.Context().Value(AuthorizationCtxKey).(*authorization.Authorization)
+	if !ok {
+		return InternalServerError(nil).WithMessage("failed when getting authentication context value")
+	}
+
+	if auth.ID != uint(accountID) {
+		return NewHandlerError(nil, "id does not correspond to auth", http.StatusForbidden).WithMessage("request was made for another account's latest values")
+	}
+
+	snapshots, err := h.accountService.GetSnapshots(auth.ID)
+	if err != nil {
+		return InternalServerError(err).WithMessage("failed when getting latest values")
+	}
+
+	err = json.NewEncoder(w).Encode(&snapshots)
+	if err != nil {
+		return InternalServerError(err).WithLevel(logrus.ErrorLevel)
+	}
+
+	return nil
+}
+
 // Handle API endpoint for getting connected cloud feed auths.
 func (h *AccountHandler) GetCloudFeedAuthStatuses(w http.ResponseWriter, r *http.Request) error {
 	auth, ok := r.Context().Value(AuthorizationCtxKey).(*authorization.Authorization)
@@ -136,3 +165,48 @@ func (h *AccountHandler) GetCloudFeedAuthStatuses(w http.ResponseWriter, r *http
 
 	return nil
 }
+
+// Handle API endpoint for starting the authorization of a cloud feed.
+// The response contains the URL the participant should open to grant access at the provider.
+func (h *AccountHandler) AuthorizeCloudFeed(w http.ResponseWriter, r *http.Request) error {
+	accountID, err := strconv.ParseUint(chi.URLParam(r, "account_id"), 10, 64)
+	if err != nil {
+		return NewHandlerError(err, "account_id not a number", http.StatusBadRequest)
+	}
+
+	cloudFeedTypeID, err := strconv.ParseUint(chi.URLParam(r, "cloud_feed_type_id"), 10, 64)
+	if err != nil {
+		return NewHandlerError(err, "cloud_feed_type_id not a number", http.StatusBadRequest)
+	}
+
+	auth, ok := r.Context().Value(AuthorizationCtxKey).(*authorization.Authorization)
+	if !ok {
+		return InternalServerError(nil).WithMessage("failed when getting authentication context value")
+	}
+
+	if auth.ID != uint(accountID) {
+		return NewHandlerError(nil, "id does not correspond to auth", http.StatusForbidden).WithMessage("request was made to authorize another account's cloud feed")
+	}
+
+	authorizationURL, err := h.accountService.AuthorizeCloudFeed(auth.ID, uint(cloudFeedTypeID))
+	if err != nil {
+		if helpers.IsMySQLRecordNotFoundError(err) {
+			return NewHandlerError(err, "not found", http.StatusNotFound).WithLevel(logrus.InfoLevel)
+		}
+
+		return InternalServerError(err).WithMessage("failed when starting cloud feed authorization")
+	}
+
+	response := struct {
+		AuthorizationURL string `json:"authorization_url"`
+	}{
+		AuthorizationURL: authorizationURL,
+	}
+
+	err = json.NewEncoder(w).Encode(&response)
+	if err != nil {
+		return InternalServerError(err).WithLevel(logrus.ErrorLevel)
+	}
+
+	return nil
+}
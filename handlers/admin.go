@@ -71,6 +71,22 @@ func (h *AdminHandler) SetExpiry(admin admin.Admin, reply *admin.Admin) error {
 	return nil
 }
 
+type CampaignTokenArgs struct {
+	Name        string
+	Scope       authorization.Scope
+	CampaignIDs []uint
+}
+
+func (h *AdminHandler) CreateCampaignToken(args CampaignTokenArgs, token *string) error {
+	authToken, err := h.service.CreateCampaignToken(admin.Admin{Name: args.Name}, args.Scope, args.CampaignIDs)
+	if err != nil {
+		return err
+	}
+
+	*token = authToken
+	return nil
+}
+
 // HTTP middleware to check if admin in admin auth token is valid.
 func (h *AdminHandler) Middleware(next Handler) Handler {
 	return func(w http.ResponseWriter, r *http.Request) error {
@@ -2,12 +2,17 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/energietransitie/needforheat-server-api/needforheat/authorization"
+	"github.com/energietransitie/needforheat-server-api/needforheat/token"
 	"github.com/energietransitie/needforheat-server-api/services"
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus"
 )
 
 // A Contextkey is the type for a context key.
@@ -28,31 +33,29 @@ func NewAuthorizationHandler(service *services.AuthorizationService) *Authorizat
 	}
 }
 
-func (h *AuthorizationHandler) Middleware(kind authorization.AuthKind) func(next Handler) Handler {
+// Middleware that only allows requests with a token that has all required scopes.
+// Scopes that are limited to a campaign only grant access to routes with that campaign_id.
+func (h *AuthorizationHandler) RequireScopes(scopes ...authorization.Scope) func(next Handler) Handler {
 	return func(next Handler) Handler {
 		return func(w http.ResponseWriter, r *http.Request) error {
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
-				return NewHandlerError(nil, "unauthorized", http.StatusUnauthorized).WithMessage("authorization header not present")
-			}
-
-			splitHeader := strings.Split(authHeader, "Bearer ")
-			if len(splitHeader) != 2 {
-				return NewHandlerError(nil, "unauthorized", http.StatusUnauthorized).WithMessage("authorization malformed")
-			}
-
-			authHeader = splitHeader[1]
-			if authHeader == "" {
-				return NewHandlerError(nil, "unauthorized", http.StatusUnauthorized).WithMessage("authorization malformed")
+			auth, err := h.authenticate(r)
+			if err != nil {
+				return err
 			}
 
-			auth, err := h.service.ParseTokenToAuthorization(authHeader)
-			if err != nil {
-				return NewHandlerError(err, "unauthorized", http.StatusUnauthorized).WithMessage(fmt.Sprintf("error when parsing token: %s", err.Error()))
+			var campaignID uint
+			if param := chi.URLParam(r, "campaign_id"); param != "" {
+				id, err := strconv.ParseUint(param, 10, 64)
+				if err != nil {
+					return NewHandlerError(err, "campaign_id not a number", http.StatusBadRequest)
+				}
+				campaignID = uint(id)
 			}
 
-			if !auth.IsKind(kind) {
-				return NewHandlerError(nil, "unauthorized", http.StatusUnauthorized).WithMessage("incorrect authorization kind was used to access route")
+			for _, scope := range scopes {
+				if !auth.HasScope(scope, campaignID) {
+					return NewHandlerError(nil, "forbidden", http.StatusForbidden).WithMessage(fmt.Sprintf("%s %d does not have scope %s to access route", auth.Kind, auth.ID, scope))
+				}
 			}
 
 			// Add the value of audience to the HTTP context with key AuthenticatedID.
@@ -64,38 +67,72 @@ func (h *AuthorizationHandler) Middleware(kind authorization.AuthKind) func(next
 	}
 }
 
-func (h *AuthorizationHandler) DoubleMiddleware(kind1, kind2 authorization.AuthKind) func(next Handler) Handler {
+// Middleware that verifies the principal of a token with the middleware for its kind,
+// like [AdminHandler.Middleware] for admin tokens. Tokens of other kinds are forbidden.
+// It should be used after [AuthorizationHandler.RequireScopes].
+func RequirePrincipal(middlewares map[authorization.AuthKind]func(next Handler) Handler) func(next Handler) Handler {
 	return func(next Handler) Handler {
+		// Create the middleware of each kind once, instead of on every request.
+		handlers := make(map[authorization.AuthKind]Handler, len(middlewares))
+		for kind, middleware := range middlewares {
+			handlers[kind] = middleware(next)
+		}
+
 		return func(w http.ResponseWriter, r *http.Request) error {
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
-				return NewHandlerError(nil, "unauthorized", http.StatusUnauthorized).WithMessage("authorization header not present")
+			auth, ok := r.Context().Value(AuthorizationCtxKey).(*authorization.Authorization)
+			if !ok {
+				return NewHandlerError(nil, "unauthorized", http.StatusUnauthorized).WithMessage("failed when getting authentication context value")
 			}
 
-			splitHeader := strings.Split(authHeader, "Bearer ")
-			if len(splitHeader) != 2 {
-				return NewHandlerError(nil, "unauthorized", http.StatusUnauthorized).WithMessage("authorization malformed")
+			handler, ok := handlers[auth.Kind]
+			if !ok {
+				return NewHandlerError(nil, "forbidden", http.StatusForbidden).WithMessage(fmt.Sprintf("%s %d can not access route", auth.Kind, auth.ID))
 			}
 
-			authHeader = splitHeader[1]
-			if authHeader == "" {
-				return NewHandlerError(nil, "unauthorized", http.StatusUnauthorized).WithMessage("authorization malformed")
-			}
+			return handler(w, r)
+		}
+	}
+}
 
-			auth, err := h.service.ParseTokenToAuthorization(authHeader)
-			if err != nil {
-				return NewHandlerError(err, "unauthorized", http.StatusUnauthorized).WithMessage(fmt.Sprintf("error when parsing token: %s", err.Error()))
-			}
+// Get the authorization of the bearer token of a request.
+func (h *AuthorizationHandler) authenticate(r *http.Request) (*authorization.Authorization, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return nil, NewHandlerError(nil, "unauthorized", http.StatusUnauthorized).WithMessage("authorization header not present")
+	}
 
-			if !auth.IsKind(kind1) && !auth.IsKind(kind2) {
-				return NewHandlerError(nil, "unauthorized", http.StatusUnauthorized).WithMessage("incorrect authorization kind was used to access route")
-			}
+	splitHeader := strings.Split(authHeader, "Bearer ")
+	if len(splitHeader) != 2 {
+		return nil, NewHandlerError(nil, "unauthorized", http.StatusUnauthorized).WithMessage("authorization malformed")
+	}
 
-			// Add the value of audience to the HTTP context with key AuthenticatedID.
-			authCtx := context.WithValue(r.Context(), AuthorizationCtxKey, auth)
-			r = r.WithContext(authCtx)
+	authHeader = splitHeader[1]
+	if authHeader == "" {
+		return nil, NewHandlerError(nil, "unauthorized", http.StatusUnauthorized).WithMessage("authorization malformed")
+	}
 
-			return next(w, r)
+	auth, err := h.service.ParseTokenToAuthorization(authHeader)
+	if err != nil {
+		return nil, NewHandlerError(err, "unauthorized", http.StatusUnauthorized).WithMessage(fmt.Sprintf("error when parsing token: %s", err.Error()))
+	}
+
+	err = h.checkRevocation(authHeader, auth)
+	if err != nil {
+		return nil, err
+	}
+
+	return auth, nil
+}
+
+// Check that the token was not revoked.
+func (h *AuthorizationHandler) checkRevocation(tokenString string, auth *authorization.Authorization) error {
+	err := h.service.CheckRevocation(tokenString, auth)
+	if err != nil {
+		if errors.Is(err, token.ErrRevoked) || errors.Is(err, token.ErrUnknown) {
+			return NewHandlerError(err, "unauthorized", http.StatusUnauthorized).WithMessage(fmt.Sprintf("%s %d used a token that can not be used: %s", auth.Kind, auth.ID, err)).WithLevel(logrus.WarnLevel)
 		}
+		return InternalServerError(err).WithMessage("failed when checking token revocation")
 	}
+
+	return nil
 }
@@ -0,0 +1,387 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat/admin"
+	"github.com/energietransitie/needforheat-server-api/needforheat/authorization"
+	"github.com/energietransitie/needforheat-server-api/needforheat/campaign"
+	"github.com/energietransitie/needforheat-server-api/needforheat/researcher"
+	"github.com/energietransitie/needforheat-server-api/needforheat/retention"
+	"github.com/energietransitie/needforheat-server-api/services"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// fakeAdminRepository stores admins in memory.
+type fakeAdminRepository struct {
+	admin.AdminRepository
+
+	admins []admin.Admin
+}
+
+func (r *fakeAdminRepository) Find(a admin.Admin) (admin.Admin, error) {
+	for _, stored := range r.admins {
+		if (a.ID != 0 && stored.ID == a.ID) || (a.ID == 0 && stored.Name == a.Name) {
+			return stored, nil
+		}
+	}
+
+	return admin.Admin{}, gorm.ErrRecordNotFound
+}
+
+func (r *fakeAdminRepository) Create(a admin.Admin) (admin.Admin, error) {
+	a.ID = uint(len(r.admins) + 1)
+	r.admins = append(r.admins, a)
+	return a, nil
+}
+
+// fakeResearcherRepository stores researchers in memory.
+type fakeResearcherRepository struct {
+	researcher.ResearcherRepository
+
+	researchers []researcher.Researcher
+}
+
+func (r *fakeResearcherRepository) Find(res researcher.Researcher) (researcher.Researcher, error) {
+	for _, stored := range r.researchers {
+		if stored.ID == res.ID {
+			return stored, nil
+		}
+	}
+
+	return researcher.Researcher{}, gorm.ErrRecordNotFound
+}
+
+func (r *fakeResearcherRepository) Create(res researcher.Researcher) (researcher.Researcher, error) {
+	res.ID = uint(len(r.researchers) + 1)
+	r.researchers = append(r.researchers, res)
+	return res, nil
+}
+
+// fakeCampaignRepository has campaigns 1 and 2.
+type fakeCampaignRepository struct {
+	campaign.CampaignRepository
+}
+
+func (r *fakeCampaignRepository) Find(c campaign.Campaign) (campaign.Campaign, error) {
+	if c.ID != 1 && c.ID != 2 {
+		return campaign.Campaign{}, gorm.ErrRecordNotFound
+	}
+
+	return c, nil
+}
+
+// fakeRetentionRepository stores retention policies in memory.
+type fakeRetentionRepository struct {
+	retention.RetentionRepository
+
+	policies map[uint]retention.Policy
+}
+
+func (r *fakeRetentionRepository) Find(campaignID uint) (retention.Policy, error) {
+	policy, ok := r.policies[campaignID]
+	if !ok {
+		return retention.Policy{}, gorm.ErrRecordNotFound
+	}
+
+	return policy, nil
+}
+
+func (r *fakeRetentionRepository) Save(policy retention.Policy) (retention.Policy, error) {
+	r.policies[policy.CampaignID] = policy
+	return policy, nil
+}
+
+func (r *fakeRetentionRepository) Delete(campaignID uint) error {
+	delete(r.policies, campaignID)
+	return nil
+}
+
+// newAuthorizationService creates an AuthorizationService with a new signing key.
+// It can only be used for tokens that are not tracked, like admin tokens.
+func newAuthorizationService(t *testing.T) *services.AuthorizationService {
+	t.Helper()
+
+	key, err := authorization.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := authorization.NewKeySet(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return services.NewAuthorizationService(keys, nil, nil, services.TokenLifetimes{})
+}
+
+// setupAdminRouter creates a router with the retention routes and an admin route,
+// protected like in the server. Campaigns 1 and 2 have a retention policy.
+func setupAdminRouter(t *testing.T, authService *services.AuthorizationService, adminService *services.AdminService, researcherService *services.ResearcherService) (http.Handler, *fakeRetentionRepository) {
+	t.Helper()
+
+	retentionRepository := &fakeRetentionRepository{
+		policies: map[uint]retention.Policy{
+			1: {CampaignID: 1, RawDays: 90},
+			2: {CampaignID: 2, RawDays: 90},
+		},
+	}
+
+	retentionHandler := NewRetentionHandler(services.NewRetentionService(retentionRepository, &fakeCampaignRepository{}, nil, t.TempDir()))
+	adminHandler := NewAdminHandler(adminService)
+	researcherHandler := NewResearcherHandler(researcherService)
+	authHandler := NewAuthorizationHandler(authService)
+
+	adminAuth := authHandler.RequireScopes(authorization.ScopeAdmin)
+	campaignAdminAuth := authHandler.RequireScopes(authorization.ScopeCampaignAdmin)
+	campaignReadAuth := authHandler.RequireScopes(authorization.ScopeCampaignRead)
+
+	adminORresearcher := RequirePrincipal(map[authorization.AuthKind]func(next Handler) Handler{
+		authorization.AdminToken:      adminHandler.Middleware,
+		authorization.ResearcherToken: researcherHandler.Middleware,
+	})
+
+	r := chi.NewRouter()
+	r.Method("GET", "/campaign/{campaign_id}/retention", campaignReadAuth(adminORresearcher(retentionHandler.Get)))
+	r.Method("PUT", "/campaign/{campaign_id}/retention", campaignAdminAuth(adminHandler.Middleware(retentionHandler.Set)))
+	r.Method("DELETE", "/campaign/{campaign_id}/retention", campaignAdminAuth(adminHandler.Middleware(retentionHandler.Delete)))
+	r.Method("GET", "/admin", adminAuth(adminHandler.Middleware(func(w http.ResponseWriter, r *http.Request) error {
+		return nil
+	})))
+
+	return r, retentionRepository
+}
+
+// doRequest sends a request to handler with token as bearer token.
+func doRequest(handler http.Handler, method string, target string, body string, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+token)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestCampaignAdminToken(t *testing.T) {
+	authService := newAuthorizationService(t)
+	adminService := services.NewAdminService(&fakeAdminRepository{}, authService)
+
+	a, err := adminService.Create("alice", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	campaignAdminToken, err := adminService.CreateCampaignToken(admin.Admin{Name: "alice"}, authorization.ScopeCampaignAdmin, []uint{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	campaignReadToken, err := adminService.CreateCampaignToken(admin.Admin{Name: "alice"}, authorization.ScopeCampaignRead, []uint{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router, retentionRepository := setupAdminRouter(t, authService, adminService, nil)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		token  string
+
+		wantCode int
+	}{
+		{
+			name:     "campaign admin token sets retention of its campaign",
+			method:   http.MethodPut,
+			target:   "/campaign/1/retention",
+			token:    campaignAdminToken,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "campaign admin token deletes retention of its campaign",
+			method:   http.MethodDelete,
+			target:   "/campaign/1/retention",
+			token:    campaignAdminToken,
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:     "campaign admin token can not set retention of another campaign",
+			method:   http.MethodPut,
+			target:   "/campaign/2/retention",
+			token:    campaignAdminToken,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "campaign admin token can not use admin routes",
+			method:   http.MethodGet,
+			target:   "/admin",
+			token:    campaignAdminToken,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "campaign read token can not set retention",
+			method:   http.MethodPut,
+			target:   "/campaign/1/retention",
+			token:    campaignReadToken,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "admin token sets retention of any campaign",
+			method:   http.MethodPut,
+			target:   "/campaign/2/retention",
+			token:    a.AuthorizationToken,
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doRequest(router, tt.method, tt.target, `{"raw_days": 30}`, tt.token)
+			if w.Code != tt.wantCode {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+		})
+	}
+
+	if _, ok := retentionRepository.policies[1]; ok {
+		t.Error("policy of campaign 1 was not deleted")
+	}
+
+	if policy := retentionRepository.policies[2]; policy.RawDays != 30 {
+		t.Error("policy of campaign 2 was not set by the admin token")
+	}
+}
+
+func TestCampaignAdminTokenOfUnknownAdmin(t *testing.T) {
+	authService := newAuthorizationService(t)
+	adminService := services.NewAdminService(&fakeAdminRepository{}, authService)
+
+	// A signed token with the scope, but without an admin, is rejected by the admin middleware.
+	token, err := authService.CreateToken(authorization.AdminToken, 1, time.Now().Add(time.Hour), authorization.ScopeCampaignAdmin.ForCampaign(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router, _ := setupAdminRouter(t, authService, adminService, nil)
+
+	w := doRequest(router, http.MethodPut, "/campaign/1/retention", `{"raw_days": 30}`, token)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("got status %d, want %d: %s", w.Code, http.StatusForbidden, w.Body.String())
+	}
+}
+
+func TestCreateCampaignToken(t *testing.T) {
+	authService := newAuthorizationService(t)
+	adminService := services.NewAdminService(&fakeAdminRepository{}, authService)
+
+	_, err := adminService.Create("alice", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = adminService.CreateCampaignToken(admin.Admin{Name: "alice"}, authorization.ScopeAdmin, []uint{1})
+	if err != services.ErrInvalidCampaignScope {
+		t.Errorf("got error %v for admin scope, want %v", err, services.ErrInvalidCampaignScope)
+	}
+
+	_, err = adminService.CreateCampaignToken(admin.Admin{Name: "alice"}, authorization.ScopeCampaignAdmin, nil)
+	if err != services.ErrNoTokenCampaigns {
+		t.Errorf("got error %v without campaigns, want %v", err, services.ErrNoTokenCampaigns)
+	}
+
+	_, err = adminService.CreateCampaignToken(admin.Admin{Name: "bob"}, authorization.ScopeCampaignAdmin, []uint{1})
+	if err != gorm.ErrRecordNotFound {
+		t.Errorf("got error %v for unknown admin, want %v", err, gorm.ErrRecordNotFound)
+	}
+}
+
+func TestResearcherToken(t *testing.T) {
+	authService := newAuthorizationService(t)
+	adminService := services.NewAdminService(&fakeAdminRepository{}, authService)
+	campaignService := services.NewCampaignService(&fakeCampaignRepository{}, nil, nil)
+	researcherService := services.NewResearcherService(&fakeResearcherRepository{}, authService, campaignService)
+
+	res, err := researcherService.Create("bob", []uint{1}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A token that was issued for a campaign the researcher is not bound to (anymore).
+	staleToken, err := authService.CreateToken(authorization.ResearcherToken, res.ID, time.Now().Add(time.Hour), authorization.ScopeCampaignRead.ForCampaign(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router, retentionRepository := setupAdminRouter(t, authService, adminService, researcherService)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		token  string
+
+		wantCode int
+	}{
+		{
+			name:     "researcher reads retention of its campaign",
+			method:   http.MethodGet,
+			target:   "/campaign/1/retention",
+			token:    res.AuthorizationToken,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "researcher can not read retention of another campaign",
+			method:   http.MethodGet,
+			target:   "/campaign/2/retention",
+			token:    res.AuthorizationToken,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "token with a campaign the researcher is not bound to",
+			method:   http.MethodGet,
+			target:   "/campaign/2/retention",
+			token:    staleToken,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "researcher can not set retention of its campaign",
+			method:   http.MethodPut,
+			target:   "/campaign/1/retention",
+			token:    res.AuthorizationToken,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "researcher can not delete retention of its campaign",
+			method:   http.MethodDelete,
+			target:   "/campaign/1/retention",
+			token:    res.AuthorizationToken,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "researcher can not use admin routes",
+			method:   http.MethodGet,
+			target:   "/admin",
+			token:    res.AuthorizationToken,
+			wantCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doRequest(router, tt.method, tt.target, `{"raw_days": 7}`, tt.token)
+			if w.Code != tt.wantCode {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+		})
+	}
+
+	if policy := retentionRepository.policies[1]; policy.RawDays != 90 {
+		t.Errorf("retention policy of campaign 1 was changed to %d raw days", policy.RawDays)
+	}
+}
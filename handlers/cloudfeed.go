@@ -3,13 +3,20 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/energietransitie/needforheat-server-api/internal/helpers"
 	"github.com/energietransitie/needforheat-server-api/needforheat"
 	"github.com/energietransitie/needforheat-server-api/needforheat/authorization"
 	"github.com/energietransitie/needforheat-server-api/needforheat/cloudfeed"
 	"github.com/energietransitie/needforheat-server-api/services"
+	"github.com/go-chi/chi/v5"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/oauth2"
 )
@@ -54,6 +61,224 @@ func (h *CloudFeedHandler) Create(w http.ResponseWriter, r *http.Request) error
 	return nil
 }
 
+// Handle API endpoint for authorizing an existing cloud feed again.
+// The tokens of the cloud feed are replaced, so it keeps its device and measurements.
+func (h *CloudFeedHandler) Reauthorize(w http.ResponseWriter, r *http.Request) error {
+	accountID, cloudFeedTypeID, err := parseCloudFeedParams(r)
+	if err != nil {
+		return err
+	}
+
+	var request cloudfeed.CloudFeed
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		return NewHandlerError(err, "bad request", http.StatusBadRequest).WithLevel(logrus.ErrorLevel)
+	}
+
+	_, err = h.service.Reauthorize(r.Context(), accountID, cloudFeedTypeID, request.AuthGrantToken)
+	if err != nil {
+		if helpers.IsMySQLRecordNotFoundError(err) {
+			return NewHandlerError(err, "not found", http.StatusNotFound).WithLevel(logrus.InfoLevel)
+		}
+
+		if _, ok := err.(*oauth2.RetrieveError); ok {
+			return NewHandlerError(err, "invalid auth code exchange", http.StatusBadRequest)
+		}
+
+		return InternalServerError(err).WithMessage("failed when authorizing cloud feed again")
+	}
+
+	return nil
+}
+
+// Handle API endpoint for disconnecting a cloud feed.
+// Set query parameter purge to true to also delete the measurements of its device.
+func (h *CloudFeedHandler) Disconnect(w http.ResponseWriter, r *http.Request) error {
+	accountID, cloudFeedTypeID, err := parseCloudFeedParams(r)
+	if err != nil {
+		return err
+	}
+
+	purge := r.URL.Query().Get("purge") == "true"
+
+	err = h.service.Disconnect(r.Context(), accountID, cloudFeedTypeID, purge)
+	if err != nil {
+		if helpers.IsMySQLRecordNotFoundError(err) {
+			return NewHandlerError(err, "not found", http.StatusNotFound).WithLevel(logrus.InfoLevel)
+		}
+
+		return InternalServerError(err).WithMessage("failed when disconnecting cloud feed")
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// Parse the account_id and cloud_feed_type_id URL parameters,
+// and check that the account_id corresponds to the authorization of the request.
+func parseCloudFeedParams(r *http.Request) (uint, uint, error) {
+	accountID, err := strconv.ParseUint(chi.URLParam(r, "account_id"), 10, 64)
+	if err != nil {
+		return 0, 0, NewHandlerError(err, "account_id not a number", http.StatusBadRequest)
+	}
+
+	cloudFeedTypeID, err := strconv.ParseUint(chi.URLParam(r, "cloud_feed_type_id"), 10, 64)
+	if err != nil {
+		return 0, 0, NewHandlerError(err, "cloud_feed_type_id not a number", http.StatusBadRequest)
+	}
+
+	auth, ok := r.Context().Value(AuthorizationCtxKey).(*authorization.Authorization)
+	if !ok {
+		return 0, 0, InternalServerError(nil).WithMessage("failed when getting authentication context value")
+	}
+
+	if auth.ID != uint(accountID) {
+		return 0, 0, NewHandlerError(nil, "id does not correspond to auth", http.StatusForbidden).WithMessage("request was made for another account's cloud feed")
+	}
+
+	return uint(accountID), uint(cloudFeedTypeID), nil
+}
+
+// Handle the callback of a provider after a participant authorized a cloud feed.
+// The participant is redirected to the app, with the result in the query parameters.
+func (h *CloudFeedHandler) Callback(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+
+	// The provider sets error if the participant denied access.
+	code := query.Get("code")
+	providerError := query.Get("error")
+	if providerError != "" {
+		code = ""
+	}
+
+	pending, err := h.service.CompleteAuthorization(r.Context(), query.Get("state"), code)
+	if errors.Is(err, services.ErrInvalidState) {
+		return NewHandlerError(err, "invalid state", http.StatusBadRequest).WithLevel(logrus.InfoLevel)
+	}
+
+	if err == nil && providerError == "" && code == "" {
+		providerError = "missing code"
+	}
+
+	if err != nil {
+		logrus.Warnln("failed to complete cloud feed authorization for account", pending.AccountID, ":", err)
+
+		switch {
+		case errors.Is(err, services.ErrAuthorizationExpired):
+			providerError = "expired"
+		case helpers.IsMySQLDuplicateError(err):
+			providerError = "duplicate"
+		default:
+			providerError = "exchange failed"
+		}
+	}
+
+	redirectURL, err := url.Parse(pending.RedirectURL)
+	if err != nil || pending.RedirectURL == "" {
+		return NewHandlerError(err, "invalid redirect url", http.StatusInternalServerError).WithMessage("app has no valid oauth redirect url")
+	}
+
+	redirectQuery := redirectURL.Query()
+	redirectQuery.Set("cloud_feed_type_id", strconv.FormatUint(uint64(pending.CloudFeedTypeID), 10))
+	if providerError != "" {
+		redirectQuery.Set("status", "failed")
+		redirectQuery.Set("error", providerError)
+	} else {
+		redirectQuery.Set("status", "connected")
+	}
+	redirectURL.RawQuery = redirectQuery.Encode()
+
+	http.Redirect(w, r, redirectURL.String(), http.StatusSeeOther)
+	return nil
+}
+
+const (
+	// Default number of runs returned when no limit is specified.
+	defaultRunsLimit = 50
+	// Maximum number of runs returned.
+	maxRunsLimit = 1000
+)
+
+var errInvalidLimit = fmt.Errorf("limit should be a number between 1 and %d", maxRunsLimit)
+
+// Handle API endpoint for getting the download runs of a cloud feed.
+func (h *CloudFeedHandler) GetRuns(w http.ResponseWriter, r *http.Request) error {
+	accountID, err := strconv.ParseUint(chi.URLParam(r, "account_id"), 10, 64)
+	if err != nil {
+		return NewHandlerError(err, "account_id not a number", http.StatusBadRequest)
+	}
+
+	cloudFeedTypeID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return NewHandlerError(err, "id not a number", http.StatusBadRequest)
+	}
+
+	auth, ok := r.Context().Value(AuthorizationCtxKey).(*authorization.Authorization)
+	if !ok {
+		return NewHandlerError(nil, "internal server error", http.StatusInternalServerError).WithMessage("failed when getting authentication context value")
+	}
+
+	if auth.ID != uint(accountID) {
+		return NewHandlerError(nil, "id does not correspond to auth", http.StatusForbidden).WithMessage("request was made for another account's cloud feed runs")
+	}
+
+	limit, err := parseLimit(r, defaultRunsLimit)
+	if err != nil {
+		return NewHandlerError(err, err.Error(), http.StatusBadRequest)
+	}
+
+	runs, err := h.service.GetRuns(uint(accountID), uint(cloudFeedTypeID), limit)
+	if err != nil {
+		return InternalServerError(err).WithMessage("failed when getting cloud feed runs")
+	}
+
+	err = json.NewEncoder(w).Encode(&runs)
+	if err != nil {
+		return InternalServerError(err).WithLevel(logrus.ErrorLevel)
+	}
+
+	return nil
+}
+
+// Handle API endpoint for getting the download runs of all cloud feeds.
+// Set query parameter failed to true to only get failed runs.
+func (h *CloudFeedHandler) GetAllRuns(w http.ResponseWriter, r *http.Request) error {
+	limit, err := parseLimit(r, defaultRunsLimit)
+	if err != nil {
+		return NewHandlerError(err, err.Error(), http.StatusBadRequest)
+	}
+
+	failedOnly := r.URL.Query().Get("failed") == "true"
+
+	runs, err := h.service.GetAllRuns(failedOnly, limit)
+	if err != nil {
+		return InternalServerError(err).WithMessage("failed when getting cloud feed runs")
+	}
+
+	err = json.NewEncoder(w).Encode(&runs)
+	if err != nil {
+		return InternalServerError(err).WithLevel(logrus.ErrorLevel)
+	}
+
+	return nil
+}
+
+// Parse the limit query parameter. If it is not set, defaultLimit is returned.
+// The limit should be between 1 and maxRunsLimit.
+func parseLimit(r *http.Request, defaultLimit int) (int, error) {
+	limitParam := r.URL.Query().Get("limit")
+	if limitParam == "" {
+		return defaultLimit, nil
+	}
+
+	limit, err := strconv.Atoi(limitParam)
+	if err != nil || limit < 1 || limit > maxRunsLimit {
+		return 0, errInvalidLimit
+	}
+
+	return limit, nil
+}
+
 type DownloadArgs struct {
 	AccountID   uint
 	CloudFeedID uint
@@ -77,3 +302,26 @@ func (h *CloudFeedHandler) Download(args DownloadArgs, reply *string) error {
 
 	return nil
 }
+
+// Handle RPC endpoint for backfilling gaps in the data from a cloud feed.
+func (h *CloudFeedHandler) Backfill(args DownloadArgs, reply *string) error {
+	cfa, err := h.service.Find(cloudfeed.CloudFeed{AccountID: args.AccountID, CloudFeedTypeID: args.CloudFeedID})
+	if err != nil {
+		return err
+	}
+
+	gaps, err := h.service.Backfill(context.Background(), cfa, args.StartPeriod, args.EndPeriod)
+	if err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Backfilled %d gaps in data from cloud feed. Check server logs for more information.", len(gaps))
+	for _, gap := range gaps {
+		fmt.Fprintf(&sb, "\n%s - %s", gap.Start.Format(time.DateOnly), gap.End.Format(time.DateOnly))
+	}
+
+	*reply = sb.String()
+
+	return nil
+}
@@ -2,11 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/energietransitie/needforheat-server-api/internal/helpers"
+	"github.com/energietransitie/needforheat-server-api/internal/schedule"
 	"github.com/energietransitie/needforheat-server-api/needforheat/cloudfeedtype"
 	"github.com/energietransitie/needforheat-server-api/services"
+	"github.com/go-chi/chi/v5"
 	"github.com/sirupsen/logrus"
 )
 
@@ -29,12 +33,16 @@ func (h *CloudFeedTypeHandler) Create(w http.ResponseWriter, r *http.Request) er
 		return NewHandlerError(err, "bad request", http.StatusBadRequest).WithLevel(logrus.ErrorLevel)
 	}
 
-	CloudFeedType, err := h.service.Create(request.Name, request.AuthorizationURL, request.TokenURL, request.ClientID, request.ClientSecret, request.Scope, request.RedirectURL)
+	CloudFeedType, err := h.service.Create(request.Name, request.AuthorizationURL, request.TokenURL, request.ClientID, request.ClientSecret, request.Scope, request.RedirectURL, request.RevocationURL, request.DownloadSchedule)
 	if err != nil {
 		if helpers.IsMySQLDuplicateError(err) {
 			return NewHandlerError(err, "duplicate", http.StatusBadRequest)
 		}
 
+		if errors.Is(err, schedule.ErrInvalidSchedule) {
+			return NewHandlerError(err, "invalid download_schedule", http.StatusBadRequest).WithMessage(err.Error())
+		}
+
 		return NewHandlerError(err, "internal server error", http.StatusInternalServerError)
 	}
 
@@ -45,3 +53,42 @@ func (h *CloudFeedTypeHandler) Create(w http.ResponseWriter, r *http.Request) er
 
 	return nil
 }
+
+// Handle API endpoint for changing the download schedule of a cloud feed type.
+func (h *CloudFeedTypeHandler) SetDownloadSchedule(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return NewHandlerError(err, "id not a number", http.StatusBadRequest)
+	}
+
+	var request struct {
+		DownloadSchedule string `json:"download_schedule"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		return NewHandlerError(err, "bad request", http.StatusBadRequest).WithLevel(logrus.ErrorLevel)
+	}
+
+	cloudFeedType, err := h.service.SetDownloadSchedule(uint(id), request.DownloadSchedule)
+	if err != nil {
+		if helpers.IsMySQLRecordNotFoundError(err) {
+			return NewHandlerError(err, "not found", http.StatusNotFound)
+		}
+
+		if errors.Is(err, schedule.ErrInvalidSchedule) {
+			return NewHandlerError(err, "invalid download_schedule", http.StatusBadRequest).WithMessage(err.Error())
+		}
+
+		return NewHandlerError(err, "internal server error", http.StatusInternalServerError)
+	}
+
+	// The client secret is not needed in the response.
+	cloudFeedType.ClientSecret = ""
+
+	err = json.NewEncoder(w).Encode(&cloudFeedType)
+	if err != nil {
+		return NewHandlerError(err, "internal server error", http.StatusInternalServerError).WithLevel(logrus.ErrorLevel)
+	}
+
+	return nil
+}
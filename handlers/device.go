@@ -4,11 +4,16 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/energietransitie/needforheat-server-api/internal/helpers"
+	"github.com/energietransitie/needforheat-server-api/needforheat"
 	"github.com/energietransitie/needforheat-server-api/needforheat/authorization"
 	"github.com/energietransitie/needforheat-server-api/needforheat/device"
+	"github.com/energietransitie/needforheat-server-api/needforheat/measurement"
+	"github.com/energietransitie/needforheat-server-api/needforheat/upload"
 	"github.com/energietransitie/needforheat-server-api/services"
 	"github.com/go-chi/chi/v5"
 	"github.com/sirupsen/logrus"
@@ -144,7 +149,11 @@ func (h *DeviceHandler) GetDeviceByName(w http.ResponseWriter, r *http.Request)
 	return nil
 }
 
-// Handle API endpoint for getting device measurements
+// Interval used when measurements are aggregated without an interval.
+const defaultAggregationInterval = "1h"
+
+// Handle API endpoint for getting device measurements.
+// Set query parameter aggregate to an aggregate function to aggregate the measurements in intervals.
 func (h *DeviceHandler) GetDeviceMeasurements(w http.ResponseWriter, r *http.Request) error {
 	deviceName := chi.URLParam(r, "device_name")
 
@@ -169,17 +178,45 @@ func (h *DeviceHandler) GetDeviceMeasurements(w http.ResponseWriter, r *http.Req
 		}
 	}
 
-	measurements, err := h.service.GetMeasurementsByDeviceID(device.ID, filters)
-	if err != nil {
-		return NewHandlerError(err, "internal server error", http.StatusInternalServerError).WithMessage("failed when getting measurements")
-	}
+	// Aggregate the measurements in intervals if an aggregate function is set.
+	if function := r.URL.Query().Get("aggregate"); function != "" {
+		interval := r.URL.Query().Get("interval")
+		if interval == "" {
+			interval = defaultAggregationInterval
+		}
 
-	err = json.NewEncoder(w).Encode(&measurements)
-	if err != nil {
-		return NewHandlerError(err, "internal server error", http.StatusInternalServerError).WithLevel(logrus.ErrorLevel)
+		aggregation, err := measurement.ParseAggregation(interval, function)
+		if err != nil {
+			return NewHandlerError(err, "invalid aggregation", http.StatusBadRequest).WithMessage(err.Error())
+		}
+
+		series, err := h.service.GetAggregatedMeasurementsByDeviceID(device.ID, filters, aggregation)
+		if errors.Is(err, measurement.ErrNotNumeric) {
+			return NewHandlerError(err, "invalid aggregation", http.StatusBadRequest).WithMessage(err.Error())
+		}
+		if err != nil {
+			return InternalServerError(err).WithMessage("failed when getting aggregated measurements")
+		}
+
+		err = json.NewEncoder(w).Encode(&series)
+		if err != nil {
+			return InternalServerError(err).WithLevel(logrus.ErrorLevel)
+		}
+
+		return nil
 	}
 
-	return nil
+	return writeMeasurements(w, r, measurementSource{
+		getAll: func() ([]measurement.Measurement, error) {
+			return h.service.GetMeasurementsByDeviceID(device.ID, filters)
+		},
+		getPage: func(page measurement.Page) ([]measurement.Measurement, *measurement.Cursor, error) {
+			return h.service.GetMeasurementsPageByDeviceID(device.ID, filters, page)
+		},
+		stream: func(fn func(measurement.Measurement) error) error {
+			return h.service.StreamMeasurementsByDeviceID(r.Context(), device.ID, filters, fn)
+		},
+	})
 }
 
 // Handle API endpoint for getting device properties
@@ -209,6 +246,110 @@ func (h *DeviceHandler) GetDeviceProperties(w http.ResponseWriter, r *http.Reque
 	return nil
 }
 
+// Handle API endpoint for getting the latest value of each property of a device.
+func (h *DeviceHandler) GetDeviceLatest(w http.ResponseWriter, r *http.Request) error {
+	deviceName := chi.URLParam(r, "device_name")
+
+	auth, ok := r.Context().Value(AuthorizationCtxKey).(*authorization.Authorization)
+	if !ok {
+		return NewHandlerError(nil, "internal server error", http.StatusInternalServerError).WithMessage("failed when getting authentication context value")
+	}
+
+	device, err := h.getDeviceByName(deviceName, auth.ID)
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := h.service.GetSnapshotByDeviceID(device.ID)
+	if err != nil {
+		return InternalServerError(err).WithMessage("failed when getting latest values")
+	}
+
+	err = json.NewEncoder(w).Encode(&snapshot)
+	if err != nil {
+		return InternalServerError(err).WithLevel(logrus.ErrorLevel)
+	}
+
+	return nil
+}
+
+// Number of days of uploads used for the clock health report, if days is not set.
+const defaultClockHealthDays = 30
+
+// Handle API endpoint for setting the clock skew settings of a device.
+func (h *DeviceHandler) SetDeviceClock(w http.ResponseWriter, r *http.Request) error {
+	deviceName := chi.URLParam(r, "device_name")
+
+	auth, ok := r.Context().Value(AuthorizationCtxKey).(*authorization.Authorization)
+	if !ok {
+		return NewHandlerError(nil, "internal server error", http.StatusInternalServerError).WithMessage("failed when getting authentication context value")
+	}
+
+	device, err := h.getDeviceByName(deviceName, auth.ID)
+	if err != nil {
+		return err
+	}
+
+	var request upload.ClockSettings
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		return NewHandlerError(err, "bad request", http.StatusBadRequest).WithLevel(logrus.ErrorLevel)
+	}
+
+	settings, err := h.service.SetClockSettings(device.ID, request)
+	if err != nil {
+		if errors.Is(err, upload.ErrUnknownClockPolicy) || errors.Is(err, upload.ErrInvalidThreshold) {
+			return NewHandlerError(err, err.Error(), http.StatusBadRequest)
+		}
+		return InternalServerError(err).WithMessage("failed when setting clock settings")
+	}
+
+	err = json.NewEncoder(w).Encode(&settings)
+	if err != nil {
+		return InternalServerError(err).WithLevel(logrus.ErrorLevel)
+	}
+
+	return nil
+}
+
+// Handle API endpoint for getting a report of the clock skew of the uploads of a device.
+// Set query parameter days to the number of days of uploads to use.
+func (h *DeviceHandler) GetDeviceClock(w http.ResponseWriter, r *http.Request) error {
+	deviceName := chi.URLParam(r, "device_name")
+
+	auth, ok := r.Context().Value(AuthorizationCtxKey).(*authorization.Authorization)
+	if !ok {
+		return NewHandlerError(nil, "internal server error", http.StatusInternalServerError).WithMessage("failed when getting authentication context value")
+	}
+
+	device, err := h.getDeviceByName(deviceName, auth.ID)
+	if err != nil {
+		return err
+	}
+
+	days := defaultClockHealthDays
+	if value := r.URL.Query().Get("days"); value != "" {
+		days, err = strconv.Atoi(value)
+		if err != nil || days <= 0 {
+			return NewHandlerError(err, "days should be a positive number", http.StatusBadRequest)
+		}
+	}
+
+	since := needforheat.Time(time.Now().AddDate(0, 0, -days))
+
+	health, err := h.service.GetClockHealthByDeviceID(device.ID, since)
+	if err != nil {
+		return InternalServerError(err).WithMessage("failed when getting clock health")
+	}
+
+	err = json.NewEncoder(w).Encode(&health)
+	if err != nil {
+		return InternalServerError(err).WithLevel(logrus.ErrorLevel)
+	}
+
+	return nil
+}
+
 func (h *DeviceHandler) getDeviceByName(deviceName string, accountId uint) (*device.Device, error) {
 	if deviceName == "" {
 		return nil, NewHandlerError(nil, "device_name not specified", http.StatusBadRequest)
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat"
+	"github.com/energietransitie/needforheat-server-api/needforheat/authorization"
+	"github.com/energietransitie/needforheat-server-api/needforheat/device"
+	"github.com/energietransitie/needforheat-server-api/needforheat/measurement"
+	"github.com/energietransitie/needforheat-server-api/needforheat/property"
+	"github.com/energietransitie/needforheat-server-api/needforheat/upload"
+	"github.com/energietransitie/needforheat-server-api/services"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// fakeMeasurementDeviceRepository has device-1 of account 1.
+type fakeMeasurementDeviceRepository struct {
+	device.DeviceRepository
+
+	aggregated bool
+}
+
+func (r *fakeMeasurementDeviceRepository) Find(d device.Device) (device.Device, error) {
+	if d.Name != "device-1" && d.ID != 10 {
+		return device.Device{}, gorm.ErrRecordNotFound
+	}
+
+	return device.Device{ID: 10, Name: "device-1", AccountID: 1}, nil
+}
+
+func (r *fakeMeasurementDeviceRepository) GetAggregatedMeasurements(d device.Device, filters map[string]string, aggregation measurement.Aggregation) ([]measurement.Series, error) {
+	r.aggregated = true
+	return []measurement.Series{}, nil
+}
+
+// fakeLatestUploadRepository only returns the latest upload of a device.
+type fakeLatestUploadRepository struct {
+	upload.UploadRepository
+}
+
+func (r *fakeLatestUploadRepository) GetLatestUploadForDeviceWithID(id uint) (upload.Upload, error) {
+	return upload.Upload{ServerTime: needforheat.Time(time.Now())}, nil
+}
+
+func TestDeviceHandlerGetDeviceMeasurements_aggregate(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+
+		wantCode       int
+		wantAggregated bool
+	}{
+		{
+			name:           "numeric property",
+			target:         "/device/device-1/measurements?aggregate=avg&property=1",
+			wantCode:       http.StatusOK,
+			wantAggregated: true,
+		},
+		{
+			name:     "string property",
+			target:   "/device/device-1/measurements?aggregate=avg&property=2",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:           "all properties, including a string property",
+			target:         "/device/device-1/measurements?aggregate=max",
+			wantCode:       http.StatusOK,
+			wantAggregated: true,
+		},
+		{
+			name:           "unknown property",
+			target:         "/device/device-1/measurements?aggregate=avg&property=3",
+			wantCode:       http.StatusOK,
+			wantAggregated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &fakeMeasurementDeviceRepository{}
+			propertyService := services.NewPropertyService(&fakePropertyRepository{
+				properties: []property.Property{
+					{ID: 1, Name: "temp_in__degC", ValueType: property.Float},
+					{ID: 2, Name: "status", ValueType: property.String},
+				},
+			})
+			uploadService := services.NewUploadService(&fakeLatestUploadRepository{}, repository, nil, nil, nil, upload.ConflictPolicyKeepFirst, upload.ClockSettings{})
+			handler := NewDeviceHandler(services.NewDeviceService(repository, nil, nil, nil, uploadService, propertyService))
+
+			router := chi.NewRouter()
+			router.Method(http.MethodGet, "/device/{device_name}/measurements", Handler(handler.GetDeviceMeasurements))
+
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			r = r.WithContext(context.WithValue(r.Context(), AuthorizationCtxKey, &authorization.Authorization{Kind: authorization.AccountToken, ID: 1}))
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d; want %d (body: %s)", w.Code, tt.wantCode, w.Body)
+			}
+
+			if repository.aggregated != tt.wantAggregated {
+				t.Errorf("aggregated = %t; want %t", repository.aggregated, tt.wantAggregated)
+			}
+		})
+	}
+}
@@ -2,11 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/energietransitie/needforheat-server-api/internal/helpers"
 	"github.com/energietransitie/needforheat-server-api/needforheat/devicetype"
+	"github.com/energietransitie/needforheat-server-api/needforheat/property"
 	"github.com/energietransitie/needforheat-server-api/services"
+	"github.com/go-chi/chi/v5"
 	"github.com/sirupsen/logrus"
 )
 
@@ -45,3 +49,41 @@ func (h *DeviceTypeHandler) Create(w http.ResponseWriter, r *http.Request) error
 
 	return nil
 }
+
+// Handle API endpoint for updating if a device type is strict and the properties it allows.
+func (h *DeviceTypeHandler) Update(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return NewHandlerError(err, "id not a number", http.StatusBadRequest)
+	}
+
+	var request struct {
+		Strict *bool `json:"strict"`
+		// Names of the allowed properties.
+		Properties []string `json:"properties"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		return NewHandlerError(err, "bad request", http.StatusBadRequest).WithLevel(logrus.ErrorLevel)
+	}
+
+	dt, err := h.service.Update(uint(id), request.Strict, request.Properties)
+	if err != nil {
+		if errors.Is(err, property.ErrUnknownName) {
+			return NewHandlerError(err, "unknown property", http.StatusBadRequest).WithMessage(err.Error())
+		}
+
+		if helpers.IsMySQLRecordNotFoundError(err) {
+			return NewHandlerError(err, "not found", http.StatusNotFound)
+		}
+
+		return NewHandlerError(err, "internal server error", http.StatusInternalServerError)
+	}
+
+	err = json.NewEncoder(w).Encode(&dt)
+	if err != nil {
+		return NewHandlerError(err, "internal server error", http.StatusInternalServerError).WithLevel(logrus.ErrorLevel)
+	}
+
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/energietransitie/needforheat-server-api/needforheat/authorization"
 	"github.com/energietransitie/needforheat-server-api/needforheat/energyquery"
 	"github.com/energietransitie/needforheat-server-api/needforheat/energyquerytype"
+	"github.com/energietransitie/needforheat-server-api/needforheat/measurement"
 	"github.com/energietransitie/needforheat-server-api/services"
 	"github.com/go-chi/chi/v5"
 	"github.com/sirupsen/logrus"
@@ -127,17 +128,17 @@ func (h *EnergyQueryHandler) GetEnergyQueryMeasurements(w http.ResponseWriter, r
 		}
 	}
 
-	measurements, err := h.service.GetMeasurementsByEnergyQueryID(EnergyQuery.ID, filters)
-	if err != nil {
-		return NewHandlerError(err, "internal server error", http.StatusInternalServerError).WithMessage("failed when getting measurements")
-	}
-
-	err = json.NewEncoder(w).Encode(&measurements)
-	if err != nil {
-		return NewHandlerError(err, "internal server error", http.StatusInternalServerError).WithLevel(logrus.ErrorLevel)
-	}
-
-	return nil
+	return writeMeasurements(w, r, measurementSource{
+		getAll: func() ([]measurement.Measurement, error) {
+			return h.service.GetMeasurementsByEnergyQueryID(EnergyQuery.ID, filters)
+		},
+		getPage: func(page measurement.Page) ([]measurement.Measurement, *measurement.Cursor, error) {
+			return h.service.GetMeasurementsPageByEnergyQueryID(EnergyQuery.ID, filters, page)
+		},
+		stream: func(fn func(measurement.Measurement) error) error {
+			return h.service.StreamMeasurementsByEnergyQueryID(r.Context(), EnergyQuery.ID, filters, fn)
+		},
+	})
 }
 
 // Handle API endpoint for getting EnergyQuery properties
@@ -172,6 +173,36 @@ func (h *EnergyQueryHandler) GetEnergyQueryProperties(w http.ResponseWriter, r *
 	return nil
 }
 
+// Handle API endpoint for getting the latest value of each property of an EnergyQuery.
+func (h *EnergyQueryHandler) GetEnergyQueryLatest(w http.ResponseWriter, r *http.Request) error {
+	queryType := chi.URLParam(r, "energy_query_type")
+	if queryType == "" {
+		return NewHandlerError(nil, "energy_query_type not specified", http.StatusBadRequest)
+	}
+
+	auth, ok := r.Context().Value(AuthorizationCtxKey).(*authorization.Authorization)
+	if !ok {
+		return InternalServerError(nil).WithMessage("failed when getting authentication context value")
+	}
+
+	energyQuery, err := h.getEnergyQueryByName(energyquerytype.EnergyQueryType{EnergyQueryVariety: queryType}, auth.ID)
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := h.service.GetSnapshotByEnergyQueryID(energyQuery.ID)
+	if err != nil {
+		return InternalServerError(err).WithMessage("failed when getting latest values")
+	}
+
+	err = json.NewEncoder(w).Encode(&snapshot)
+	if err != nil {
+		return InternalServerError(err).WithLevel(logrus.ErrorLevel)
+	}
+
+	return nil
+}
+
 func (h *EnergyQueryHandler) getEnergyQueryByName(energyQueryType energyquerytype.EnergyQueryType, accountId uint) (*energyquery.EnergyQuery, error) {
 
 	EnergyQuery, err := h.service.GetByTypeAndAccount(energyQueryType, accountId)
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/energietransitie/needforheat-server-api/internal/helpers"
+	"github.com/energietransitie/needforheat-server-api/needforheat/export"
+	"github.com/energietransitie/needforheat-server-api/services"
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus"
+)
+
+type ExportHandler struct {
+	service *services.ExportService
+}
+
+// Create a new ExportHandler.
+func NewExportHandler(service *services.ExportService) *ExportHandler {
+	return &ExportHandler{
+		service: service,
+	}
+}
+
+// Handle API endpoint for exporting the measurements of a campaign.
+// The measurements are written while they are read from the database.
+// Parquet files are written in row groups, so only the current row group is kept in memory.
+func (h *ExportHandler) Export(w http.ResponseWriter, r *http.Request) error {
+	campaignID, err := strconv.ParseUint(chi.URLParam(r, "campaign_id"), 10, 64)
+	if err != nil {
+		return NewHandlerError(err, "campaign_id not a number", http.StatusBadRequest)
+	}
+
+	query := r.URL.Query()
+
+	format, err := export.ParseFormat(query.Get("format"))
+	if err != nil {
+		return NewHandlerError(err, "format should be csv or parquet", http.StatusBadRequest)
+	}
+
+	filter, err := export.MakeFilter(uint(campaignID), query.Get("start"), query.Get("end"), query["property"])
+	if err != nil {
+		return NewHandlerError(err, err.Error(), http.StatusBadRequest)
+	}
+
+	// The export is streamed, so it can take longer than the timeout of other requests.
+	stopTimeout(r)
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="campaign_%d.%s"`, campaignID, format))
+
+	cw := &countingWriter{w: w}
+	count, err := h.service.Export(r.Context(), filter, format, cw)
+	if err != nil {
+		if cw.n == 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Del("Content-Disposition")
+
+			if helpers.IsMySQLRecordNotFoundError(err) {
+				return NewHandlerError(err, "campaign not found", http.StatusNotFound)
+			}
+
+			return InternalServerError(err).WithMessage("failed when exporting measurements")
+		}
+
+		// The response was already started, so the error can not be returned to the client.
+		logrus.Warnln("failed when exporting measurements of campaign", campaignID, "after", count, "measurements:", err)
+		panic(http.ErrAbortHandler)
+	}
+
+	logrus.Infoln("exported", count, "measurements of campaign", campaignID)
+	return nil
+}
+
+// Handle API endpoint for getting the accounts of a campaign.
+func (h *ExportHandler) GetAccounts(w http.ResponseWriter, r *http.Request) error {
+	campaignID, err := strconv.ParseUint(chi.URLParam(r, "campaign_id"), 10, 64)
+	if err != nil {
+		return NewHandlerError(err, "campaign_id not a number", http.StatusBadRequest)
+	}
+
+	participants, err := h.service.GetParticipants(uint(campaignID))
+	if err != nil {
+		if helpers.IsMySQLRecordNotFoundError(err) {
+			return NewHandlerError(err, "campaign not found", http.StatusNotFound)
+		}
+		return InternalServerError(err).WithMessage("failed when getting accounts of campaign")
+	}
+
+	err = json.NewEncoder(w).Encode(&participants)
+	if err != nil {
+		return InternalServerError(err).WithLevel(logrus.ErrorLevel)
+	}
+
+	return nil
+}
+
+// Handle API endpoint for getting the devices of a campaign.
+func (h *ExportHandler) GetDevices(w http.ResponseWriter, r *http.Request) error {
+	return h.getInstances(w, r, "device")
+}
+
+// Handle API endpoint for getting the energy queries of a campaign.
+func (h *ExportHandler) GetEnergyQueries(w http.ResponseWriter, r *http.Request) error {
+	return h.getInstances(w, r, "energy_query")
+}
+
+func (h *ExportHandler) getInstances(w http.ResponseWriter, r *http.Request, instanceType string) error {
+	campaignID, err := strconv.ParseUint(chi.URLParam(r, "campaign_id"), 10, 64)
+	if err != nil {
+		return NewHandlerError(err, "campaign_id not a number", http.StatusBadRequest)
+	}
+
+	instances, err := h.service.GetInstances(uint(campaignID), instanceType)
+	if err != nil {
+		if helpers.IsMySQLRecordNotFoundError(err) {
+			return NewHandlerError(err, "campaign not found", http.StatusNotFound)
+		}
+		return InternalServerError(err).WithMessage(fmt.Sprintf("failed when getting %s instances of campaign", instanceType))
+	}
+
+	err = json.NewEncoder(w).Encode(&instances)
+	if err != nil {
+		return InternalServerError(err).WithLevel(logrus.ErrorLevel)
+	}
+
+	return nil
+}
+
+// A countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/energietransitie/needforheat-server-api/services"
+	"github.com/sirupsen/logrus"
+)
+
+// KeyHandler handles the keys used to sign tokens.
+// It can be used in an RPC server to rotate the keys.
+type KeyHandler struct {
+	service *services.AuthorizationService
+}
+
+// Create a new KeyHandler.
+func NewKeyHandler(service *services.AuthorizationService) *KeyHandler {
+	return &KeyHandler{
+		service: service,
+	}
+}
+
+// Handle API endpoint for getting the public keys that can be used to verify tokens.
+func (h *KeyHandler) JWKS(w http.ResponseWriter, r *http.Request) error {
+	jwks, err := h.service.GetJWKS()
+	if err != nil {
+		return InternalServerError(err).WithMessage("failed when getting signing keys")
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	err = json.NewEncoder(w).Encode(&jwks)
+	if err != nil {
+		return InternalServerError(err).WithLevel(logrus.ErrorLevel)
+	}
+
+	return nil
+}
+
+// Handle RPC endpoint for listing the signing keys.
+func (h *KeyHandler) List(_ int, reply *string) error {
+	keys := h.service.GetSigningKeys()
+
+	var sb strings.Builder
+
+	w := tabwriter.NewWriter(&sb, 4, 4, 4, ' ', 0)
+	fmt.Fprintf(w, "ID\tCreated at\tStatus\n")
+
+	timeFormat := "2006-01-02 15:04:05 MST"
+	for i := len(keys) - 1; i >= 0; i-- {
+		createdAt := "unknown"
+		if !keys[i].CreatedAt.IsZero() {
+			createdAt = keys[i].CreatedAt.Format(timeFormat)
+		}
+
+		status := "retired"
+		if i == len(keys)-1 {
+			status = "signing"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\n", keys[i].ID, createdAt, status)
+	}
+
+	err := w.Flush()
+	if err != nil {
+		return err
+	}
+
+	*reply = sb.String()
+	return nil
+}
+
+// Handle RPC endpoint for generating a new signing key.
+func (h *KeyHandler) Rotate(_ int, reply *string) error {
+	key, err := h.service.RotateSigningKey()
+	if err != nil {
+		return err
+	}
+
+	logrus.Infoln("rotated signing key, new key is", key.ID)
+
+	*reply = fmt.Sprintf("Generated new signing key %s. The previous key is retired and only used to verify tokens.", key.ID)
+	return nil
+}
+
+// Handle RPC endpoint for removing a retired signing key.
+func (h *KeyHandler) Remove(id string, reply *string) error {
+	err := h.service.RemoveSigningKey(id)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infoln("removed signing key", id)
+
+	*reply = fmt.Sprintf("Removed signing key %s. Tokens signed with it can not be used anymore.", id)
+	return nil
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat/measurement"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// Content type of newline delimited JSON.
+	ndjsonContentType = "application/x-ndjson"
+
+	// Number of streamed measurements after which the response is flushed.
+	streamFlushInterval = 1000
+)
+
+// Functions to get the measurements of a device or energy query.
+type measurementSource struct {
+	getAll  func() ([]measurement.Measurement, error)
+	getPage func(measurement.Page) ([]measurement.Measurement, *measurement.Cursor, error)
+	stream  func(func(measurement.Measurement) error) error
+}
+
+// Write the measurements of source in the format requested by r:
+//   - As a stream of newline delimited JSON if query parameter format is ndjson,
+//     or if the Accept header is application/x-ndjson.
+//   - As a page if query parameter limit or cursor is set.
+//     The cursor of the next page is set in the X-Next-Cursor header if there are more measurements.
+//   - As a single array of all measurements otherwise.
+func writeMeasurements(w http.ResponseWriter, r *http.Request, source measurementSource) error {
+	if isNDJSONRequest(r) {
+		stopTimeout(r)
+		return streamMeasurements(w, source)
+	}
+
+	query := r.URL.Query()
+	if query.Has("limit") || query.Has("cursor") {
+		page, err := measurement.ParsePage(query.Get("limit"), query.Get("cursor"))
+		if err != nil {
+			return NewHandlerError(err, err.Error(), http.StatusBadRequest)
+		}
+
+		measurements, next, err := source.getPage(page)
+		if err != nil {
+			return InternalServerError(err).WithMessage("failed when getting measurements")
+		}
+
+		if next != nil {
+			w.Header().Set("X-Next-Cursor", next.String())
+		}
+
+		err = json.NewEncoder(w).Encode(&measurements)
+		if err != nil {
+			return InternalServerError(err).WithLevel(logrus.ErrorLevel)
+		}
+
+		return nil
+	}
+
+	measurements, err := source.getAll()
+	if err != nil {
+		return NewHandlerError(err, "internal server error", http.StatusInternalServerError).WithMessage("failed when getting measurements")
+	}
+
+	err = json.NewEncoder(w).Encode(&measurements)
+	if err != nil {
+		return NewHandlerError(err, "internal server error", http.StatusInternalServerError).WithLevel(logrus.ErrorLevel)
+	}
+
+	return nil
+}
+
+// Write the measurements of source as newline delimited JSON, while they are read from the database.
+func streamMeasurements(w http.ResponseWriter, source measurementSource) error {
+	w.Header().Set("Content-Type", ndjsonContentType)
+
+	controller := http.NewResponseController(w)
+	encoder := json.NewEncoder(w)
+
+	count := 0
+	err := source.stream(func(m measurement.Measurement) error {
+		// Encode adds a newline after every measurement.
+		err := encoder.Encode(&m)
+		if err != nil {
+			return err
+		}
+
+		count++
+		if count%streamFlushInterval == 0 {
+			return controller.Flush()
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		if count == 0 {
+			return InternalServerError(err).WithMessage("failed when streaming measurements")
+		}
+
+		// The response was already started, so the error can not be returned to the client.
+		// The client notices the stream ended early, because it is not closed properly.
+		logrus.Warnln("failed when streaming measurements after", count, "measurements:", err)
+		panic(http.ErrAbortHandler)
+	}
+
+	return nil
+}
+
+// Check if r requests measurements as a stream of newline delimited JSON.
+func isNDJSONRequest(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "ndjson" ||
+		strings.Contains(r.Header.Get("Accept"), ndjsonContentType)
+}
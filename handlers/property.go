@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/energietransitie/needforheat-server-api/internal/helpers"
+	"github.com/energietransitie/needforheat-server-api/needforheat/property"
+	"github.com/energietransitie/needforheat-server-api/services"
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// Maximum length of the unit of a property, which is limited by the database column.
+const maxUnitLength = 32
+
+type PropertyHandler struct {
+	service *services.PropertyService
+}
+
+// Create a new PropertyHandler.
+func NewPropertyHandler(service *services.PropertyService) *PropertyHandler {
+	return &PropertyHandler{
+		service: service,
+	}
+}
+
+// Handle API endpoint for getting all properties.
+func (h *PropertyHandler) GetAll(w http.ResponseWriter, r *http.Request) error {
+	properties, err := h.service.GetAll()
+	if err != nil {
+		return InternalServerError(err).WithMessage("failed when getting properties")
+	}
+
+	if properties == nil {
+		properties = make([]property.Property, 0)
+	}
+
+	err = json.NewEncoder(w).Encode(&properties)
+	if err != nil {
+		return InternalServerError(err).WithLevel(logrus.ErrorLevel)
+	}
+
+	return nil
+}
+
+// Handle API endpoint for getting a property with its aliases.
+func (h *PropertyHandler) Get(w http.ResponseWriter, r *http.Request) error {
+	id, err := parsePropertyID(r)
+	if err != nil {
+		return err
+	}
+
+	p, err := h.service.GetByID(id)
+	if err != nil {
+		return propertyError(err)
+	}
+
+	aliases, err := h.service.GetAliases(id)
+	if err != nil {
+		return propertyError(err)
+	}
+
+	response := struct {
+		property.Property
+		Aliases []string `json:"aliases"`
+	}{p, aliases}
+
+	err = json.NewEncoder(w).Encode(&response)
+	if err != nil {
+		return InternalServerError(err).WithLevel(logrus.ErrorLevel)
+	}
+
+	return nil
+}
+
+// Handle API endpoint for updating the metadata of a property.
+func (h *PropertyHandler) Update(w http.ResponseWriter, r *http.Request) error {
+	id, err := parsePropertyID(r)
+	if err != nil {
+		return err
+	}
+
+	var request property.Update
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		return NewHandlerError(err, "bad request", http.StatusBadRequest).WithLevel(logrus.ErrorLevel)
+	}
+
+	if request.Unit != nil && len(*request.Unit) > maxUnitLength {
+		return NewHandlerError(nil, "unit too long", http.StatusBadRequest)
+	}
+
+	p, err := h.service.Update(id, request)
+	if err != nil {
+		return propertyError(err)
+	}
+
+	return writeProperty(w, p)
+}
+
+// Handle API endpoint for renaming a property. The old name is kept as an alias.
+func (h *PropertyHandler) Rename(w http.ResponseWriter, r *http.Request) error {
+	id, err := parsePropertyID(r)
+	if err != nil {
+		return err
+	}
+
+	var request struct {
+		Name string `json:"name"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		return NewHandlerError(err, "bad request", http.StatusBadRequest).WithLevel(logrus.ErrorLevel)
+	}
+
+	p, err := h.service.Rename(id, request.Name)
+	if err != nil {
+		return propertyError(err)
+	}
+
+	return writeProperty(w, p)
+}
+
+// Handle API endpoint for merging a property into another property.
+func (h *PropertyHandler) Merge(w http.ResponseWriter, r *http.Request) error {
+	id, err := parsePropertyID(r)
+	if err != nil {
+		return err
+	}
+
+	var request struct {
+		Into uint `json:"into"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		return NewHandlerError(err, "bad request", http.StatusBadRequest).WithLevel(logrus.ErrorLevel)
+	}
+
+	p, err := h.service.Merge(id, request.Into)
+	if err != nil {
+		return propertyError(err)
+	}
+
+	logrus.Infoln("merged property", id, "into", p.Name)
+	return writeProperty(w, p)
+}
+
+func parsePropertyID(r *http.Request) (uint, error) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return 0, NewHandlerError(err, "id not a number", http.StatusBadRequest)
+	}
+
+	return uint(id), nil
+}
+
+// Create a HandlerError for an error returned by the PropertyService.
+func propertyError(err error) error {
+	switch {
+	case helpers.IsMySQLRecordNotFoundError(err):
+		return NewHandlerError(err, "not found", http.StatusNotFound)
+	case errors.Is(err, property.ErrUnknownValueType):
+		return NewHandlerError(err, "invalid value_type", http.StatusBadRequest).WithMessage(err.Error())
+	case errors.Is(err, property.ErrEmptyName), errors.Is(err, property.ErrMergeIntoItself):
+		return NewHandlerError(err, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, property.ErrNameInUse), helpers.IsMySQLDuplicateError(err):
+		return NewHandlerError(err, "name is already in use", http.StatusConflict)
+	}
+
+	return InternalServerError(err)
+}
+
+func writeProperty(w http.ResponseWriter, p property.Property) error {
+	err := json.NewEncoder(w).Encode(&p)
+	if err != nil {
+		return InternalServerError(err).WithLevel(logrus.ErrorLevel)
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat/authorization"
+	"github.com/energietransitie/needforheat-server-api/needforheat/researcher"
+	"github.com/energietransitie/needforheat-server-api/services"
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// ResearcherHandler can be used in an RPC server.
+// It also has an HTTP middleware to verify researcher tokens with researchers.
+type ResearcherHandler struct {
+	service *services.ResearcherService
+}
+
+func NewResearcherHandler(service *services.ResearcherService) *ResearcherHandler {
+	return &ResearcherHandler{
+		service: service,
+	}
+}
+
+func (h *ResearcherHandler) List(input int, reply *[]researcher.Researcher) error {
+	researchers, err := h.service.GetAll()
+	if err != nil {
+		return err
+	}
+
+	*reply = researchers
+	return nil
+}
+
+func (h *ResearcherHandler) Create(researcher researcher.Researcher, token *string) error {
+	researcher, err := h.service.Create(researcher.Name, researcher.CampaignIDs, researcher.Expiry)
+	if err != nil {
+		return err
+	}
+
+	*token = researcher.AuthorizationToken
+	return nil
+}
+
+func (h *ResearcherHandler) Delete(researcher researcher.Researcher, reply *researcher.Researcher) error {
+	return h.service.Delete(researcher)
+}
+
+func (h *ResearcherHandler) Reactivate(researcher researcher.Researcher, reply *researcher.Researcher) error {
+	researcher, err := h.service.Reactivate(researcher)
+	if err != nil {
+		return err
+	}
+
+	*reply = researcher
+	return nil
+}
+
+func (h *ResearcherHandler) SetExpiry(researcher researcher.Researcher, reply *researcher.Researcher) error {
+	expiry := researcher.Expiry
+
+	researcher.Expiry = time.Time{}
+
+	researcher, err := h.service.SetExpiry(researcher, expiry)
+	if err != nil {
+		return err
+	}
+
+	*reply = researcher
+	return nil
+}
+
+// HTTP middleware to check if researcher in researcher auth token is valid
+// and is bound to the campaign of the request.
+func (h *ResearcherHandler) Middleware(next Handler) Handler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		auth, ok := r.Context().Value(AuthorizationCtxKey).(*authorization.Authorization)
+		if !ok {
+			return NewHandlerError(nil, "unauthorized", http.StatusUnauthorized).WithMessage("failed when getting authentication context value")
+		}
+
+		researcher, err := h.service.Find(researcher.Researcher{ID: auth.ID})
+		if err != nil {
+			return NewHandlerError(err, "forbidden", http.StatusForbidden).WithMessage("failed matching researcher to auth details")
+		}
+
+		if auth.Claims.IssuedAt.Before(researcher.ActivatedAt) {
+			return NewHandlerError(nil, "forbidden", http.StatusForbidden).WithMessage(fmt.Sprintf("researcher \"%s\" tried to use an invalidated token", researcher.Name)).WithLevel(logrus.WarnLevel)
+		}
+
+		if researcher.Expiry.Before(time.Now()) {
+			return NewHandlerError(nil, "forbidden", http.StatusForbidden).WithMessage(fmt.Sprintf("token for expired researcher \"%s\" was used", researcher.Name)).WithLevel(logrus.WarnLevel)
+		}
+
+		campaignID, err := strconv.ParseUint(chi.URLParam(r, "campaign_id"), 10, 64)
+		if err != nil || !researcher.HasCampaign(uint(campaignID)) {
+			return NewHandlerError(err, "forbidden", http.StatusForbidden).WithMessage(fmt.Sprintf("researcher \"%s\" tried to access a campaign they are not bound to", researcher.Name)).WithLevel(logrus.WarnLevel)
+		}
+
+		return next(w, r)
+	}
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/internal/helpers"
+	"github.com/energietransitie/needforheat-server-api/needforheat"
+	"github.com/energietransitie/needforheat-server-api/needforheat/retention"
+	"github.com/energietransitie/needforheat-server-api/services"
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// RetentionHandler handles the retention policies of campaigns.
+// It can be used in an RPC server to enforce the policies.
+type RetentionHandler struct {
+	service *services.RetentionService
+}
+
+// Create a new RetentionHandler.
+func NewRetentionHandler(service *services.RetentionService) *RetentionHandler {
+	return &RetentionHandler{
+		service: service,
+	}
+}
+
+// Handle API endpoint for getting the retention policy of a campaign.
+func (h *RetentionHandler) Get(w http.ResponseWriter, r *http.Request) error {
+	campaignID, err := strconv.ParseUint(chi.URLParam(r, "campaign_id"), 10, 64)
+	if err != nil {
+		return NewHandlerError(err, "campaign_id not a number", http.StatusBadRequest)
+	}
+
+	policy, err := h.service.GetPolicy(uint(campaignID))
+	if err != nil {
+		if helpers.IsMySQLRecordNotFoundError(err) {
+			return NewHandlerError(err, "retention policy not found", http.StatusNotFound)
+		}
+		return InternalServerError(err).WithMessage("failed when getting retention policy")
+	}
+
+	err = json.NewEncoder(w).Encode(&policy)
+	if err != nil {
+		return InternalServerError(err).WithLevel(logrus.ErrorLevel)
+	}
+
+	return nil
+}
+
+// Handle API endpoint for setting the retention policy of a campaign.
+func (h *RetentionHandler) Set(w http.ResponseWriter, r *http.Request) error {
+	campaignID, err := strconv.ParseUint(chi.URLParam(r, "campaign_id"), 10, 64)
+	if err != nil {
+		return NewHandlerError(err, "campaign_id not a number", http.StatusBadRequest)
+	}
+
+	var request retention.Policy
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		return NewHandlerError(err, "bad request", http.StatusBadRequest).WithLevel(logrus.ErrorLevel)
+	}
+
+	policy, err := h.service.SetPolicy(uint(campaignID), request.RawDays, request.DownsampleInterval, request.AggregateDays, request.Archive)
+	if err != nil {
+		if helpers.IsMySQLRecordNotFoundError(err) {
+			return NewHandlerError(err, "campaign not found", http.StatusNotFound)
+		}
+
+		if errors.Is(err, retention.ErrInvalidPolicy) {
+			return NewHandlerError(err, err.Error(), http.StatusBadRequest)
+		}
+
+		return InternalServerError(err).WithMessage("failed when setting retention policy")
+	}
+
+	err = json.NewEncoder(w).Encode(&policy)
+	if err != nil {
+		return InternalServerError(err).WithLevel(logrus.ErrorLevel)
+	}
+
+	return nil
+}
+
+// Handle API endpoint for deleting the retention policy of a campaign.
+func (h *RetentionHandler) Delete(w http.ResponseWriter, r *http.Request) error {
+	campaignID, err := strconv.ParseUint(chi.URLParam(r, "campaign_id"), 10, 64)
+	if err != nil {
+		return NewHandlerError(err, "campaign_id not a number", http.StatusBadRequest)
+	}
+
+	err = h.service.DeletePolicy(uint(campaignID))
+	if err != nil {
+		return InternalServerError(err).WithMessage("failed when deleting retention policy")
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+type EnforceArgs struct {
+	// Only enforce the policy of this campaign, if it is not 0.
+	CampaignID uint
+	DryRun     bool
+}
+
+// Handle RPC endpoint for enforcing retention policies.
+func (h *RetentionHandler) Enforce(args EnforceArgs, reply *string) error {
+	reports, err := h.service.Enforce(args.DryRun, args.CampaignID)
+	if len(reports) == 0 && err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+
+	if args.DryRun {
+		sb.WriteString("Dry run, nothing was removed.\n\n")
+	}
+
+	w := tabwriter.NewWriter(&sb, 4, 4, 4, ' ', 0)
+	fmt.Fprintf(w, "Campaign\tRaw cutoff\tRaw measurements\tAggregates\tAggregate cutoff\tExpired aggregates\tArchive\n")
+
+	for _, report := range reports {
+		name := fmt.Sprintf("%d %s", report.CampaignID, report.CampaignName)
+
+		if report.Skipped != "" {
+			fmt.Fprintf(w, "%s\tskipped: %s\t\t\t\t\t\n", name, report.Skipped)
+			continue
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%d\t%s\n",
+			name,
+			formatCutoff(report.RawCutoff),
+			report.RawMeasurements,
+			report.Aggregates,
+			formatCutoff(report.AggregateCutoff),
+			report.ExpiredAggregates,
+			report.ArchivePath,
+		)
+	}
+
+	w.Flush()
+
+	if err != nil {
+		fmt.Fprintf(&sb, "\nErrors: %s", err)
+	}
+
+	*reply = sb.String()
+
+	return nil
+}
+
+// Format a cutoff, or a dash if it has not passed yet.
+func formatCutoff(cutoff *needforheat.Time) string {
+	if cutoff == nil {
+		return "-"
+	}
+
+	return time.Time(*cutoff).UTC().Format(time.DateOnly)
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Key of the timer of the timeout of a request in its context.
+const timeoutCtxKey contextKey = 1
+
+// Timeout cancels the context of requests after timeout, like the Timeout middleware of chi.
+// Handlers that stream their response, like the export of a campaign, can stop the timeout with
+// [stopTimeout], since they can take longer than timeout without keeping a full result in memory.
+func Timeout(timeout time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithCancelCause(r.Context())
+			timer := time.AfterFunc(timeout, func() {
+				cancel(context.DeadlineExceeded)
+			})
+
+			defer func() {
+				timer.Stop()
+				cancel(nil)
+				if errors.Is(context.Cause(ctx), context.DeadlineExceeded) {
+					w.WriteHeader(http.StatusGatewayTimeout)
+				}
+			}()
+
+			ctx = context.WithValue(ctx, timeoutCtxKey, timer)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Stop the timeout of a request, if it has one that did not pass yet.
+// It should only be used by handlers that stream their response.
+// The context of the request is still canceled when the client disconnects.
+func stopTimeout(r *http.Request) {
+	timer, ok := r.Context().Value(timeoutCtxKey).(*time.Timer)
+	if ok {
+		timer.Stop()
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// slowHandler waits until the request is done or wait has passed.
+// If stream is true, it stops the timeout of the request first.
+func slowHandler(wait time.Duration, stream bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if stream {
+			stopTimeout(r)
+		}
+
+		select {
+		case <-r.Context().Done():
+		case <-time.After(wait):
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+}
+
+func TestTimeout(t *testing.T) {
+	timeout := Timeout(10 * time.Millisecond)
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		target  string
+		accept  string
+
+		wantCode int
+	}{
+		{
+			name:     "slow request times out",
+			handler:  slowHandler(time.Second, false),
+			target:   "/upload",
+			wantCode: http.StatusGatewayTimeout,
+		},
+		{
+			name:     "stream format does not stop timeout of other handlers",
+			handler:  slowHandler(time.Second, false),
+			target:   "/upload?format=ndjson",
+			accept:   ndjsonContentType,
+			wantCode: http.StatusGatewayTimeout,
+		},
+		{
+			name:     "streaming handler stops timeout",
+			handler:  slowHandler(50*time.Millisecond, true),
+			target:   "/campaign/1/export?format=csv",
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+
+			w := httptest.NewRecorder()
+			timeout(tt.handler).ServeHTTP(w, r)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("got status %d, want %d", w.Code, tt.wantCode)
+			}
+		})
+	}
+}
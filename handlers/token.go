@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/energietransitie/needforheat-server-api/internal/helpers"
+	"github.com/energietransitie/needforheat-server-api/needforheat/authorization"
+	"github.com/energietransitie/needforheat-server-api/needforheat/refreshtoken"
+	"github.com/energietransitie/needforheat-server-api/services"
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// TokenHandler handles the tokens of accounts and their devices.
+type TokenHandler struct {
+	service *services.AuthorizationService
+}
+
+// Create a new TokenHandler.
+func NewTokenHandler(service *services.AuthorizationService) *TokenHandler {
+	return &TokenHandler{
+		service: service,
+	}
+}
+
+// Handle API endpoint for listing the tokens of an account and its devices that can still be used.
+func (h *TokenHandler) GetAll(w http.ResponseWriter, r *http.Request) error {
+	accountID, err := h.authorizedAccountID(r)
+	if err != nil {
+		return err
+	}
+
+	tokens, err := h.service.GetActiveTokens(accountID)
+	if err != nil {
+		return InternalServerError(err).WithMessage("failed when getting tokens")
+	}
+
+	err = json.NewEncoder(w).Encode(&tokens)
+	if err != nil {
+		return InternalServerError(err).WithLevel(logrus.ErrorLevel)
+	}
+
+	return nil
+}
+
+// Handle API endpoint for revoking a token of an account or of one of its devices.
+func (h *TokenHandler) Revoke(w http.ResponseWriter, r *http.Request) error {
+	accountID, err := h.authorizedAccountID(r)
+	if err != nil {
+		return err
+	}
+
+	tokenID := chi.URLParam(r, "token_id")
+	if tokenID == "" {
+		return NewHandlerError(nil, "token_id not specified", http.StatusBadRequest)
+	}
+
+	_, err = h.service.RevokeToken(accountID, tokenID)
+	if err != nil {
+		if helpers.IsMySQLRecordNotFoundError(err) {
+			return NewHandlerError(err, "token not found", http.StatusNotFound)
+		}
+		return InternalServerError(err).WithMessage("failed when revoking token")
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Handle API endpoint for continuing a session of an account with a refresh token.
+func (h *TokenHandler) Refresh(w http.ResponseWriter, r *http.Request) error {
+	var request RefreshRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		return NewHandlerError(err, "bad request", http.StatusBadRequest).WithLevel(logrus.ErrorLevel)
+	}
+
+	if request.RefreshToken == "" {
+		return NewHandlerError(nil, "refresh_token not specified", http.StatusBadRequest)
+	}
+
+	session, err := h.service.RefreshSession(request.RefreshToken)
+	if err != nil {
+		if errors.Is(err, refreshtoken.ErrInvalid) || errors.Is(err, refreshtoken.ErrExpired) || errors.Is(err, refreshtoken.ErrReused) {
+			return NewHandlerError(err, "unauthorized", http.StatusUnauthorized).WithMessage(err.Error()).WithLevel(logrus.WarnLevel)
+		}
+		return InternalServerError(err).WithMessage("failed when refreshing session")
+	}
+
+	err = json.NewEncoder(w).Encode(&session)
+	if err != nil {
+		return InternalServerError(err).WithLevel(logrus.ErrorLevel)
+	}
+
+	return nil
+}
+
+// Get the account ID of the request, if it is the authenticated account.
+func (h *TokenHandler) authorizedAccountID(r *http.Request) (uint, error) {
+	accountID, err := strconv.ParseUint(chi.URLParam(r, "account_id"), 10, 64)
+	if err != nil {
+		return 0, NewHandlerError(err, "account_id not a number", http.StatusBadRequest)
+	}
+
+	auth, ok := r.Context().Value(AuthorizationCtxKey).(*authorization.Authorization)
+	if !ok {
+		return 0, InternalServerError(nil).WithMessage("failed when getting authentication context value")
+	}
+
+	if auth.ID != uint(accountID) {
+		return 0, NewHandlerError(nil, "id does not correspond to auth", http.StatusForbidden).WithMessage("request was made for another account's tokens")
+	}
+
+	return auth.ID, nil
+}
@@ -1,16 +1,31 @@
 package handlers
 
 import (
+	"compress/gzip"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
 
+	"github.com/energietransitie/needforheat-server-api/needforheat"
 	"github.com/energietransitie/needforheat-server-api/needforheat/authorization"
+	"github.com/energietransitie/needforheat-server-api/needforheat/devicetype"
+	"github.com/energietransitie/needforheat-server-api/needforheat/measurement"
+	"github.com/energietransitie/needforheat-server-api/needforheat/property"
 	"github.com/energietransitie/needforheat-server-api/needforheat/upload"
 	"github.com/energietransitie/needforheat-server-api/services"
+	"github.com/klauspost/compress/zstd"
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	// Maximum length of an idempotency key, which is limited by the database index.
+	maxIdempotencyKeyLength = 191
+	// Maximum size of a decompressed upload body.
+	maxUploadSize = 64 << 20
+)
+
 type UploadHandler struct {
 	service *services.UploadService
 }
@@ -23,38 +38,222 @@ func NewUploadHandler(service *services.UploadService) *UploadHandler {
 }
 
 // Handle API endpoint for creating a new upload.
+// The body can be compressed with gzip or zstd.
 func (h *UploadHandler) Create(w http.ResponseWriter, r *http.Request) error {
+	auth, err := uploadAuthorization(r)
+	if err != nil {
+		return err
+	}
+
+	body, err := decompressBody(w, r)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
 	var request upload.Upload
-	err := json.NewDecoder(r.Body).Decode(&request)
+	err = json.NewDecoder(body).Decode(&request)
 	if err != nil {
 		return NewHandlerError(err, "bad request", http.StatusBadRequest).WithLevel(logrus.ErrorLevel)
 	}
 
-	auth, ok := r.Context().Value(AuthorizationCtxKey).(*authorization.Authorization)
-	if !ok {
-		return NewHandlerError(err, "unauthorized", http.StatusUnauthorized).WithMessage("failed when getting authentication context value")
+	// The idempotency key can be sent in the header or in the body.
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+	if idempotencyKey == "" {
+		idempotencyKey = request.IdempotencyKey
 	}
 
-	if !auth.IsKind(authorization.DeviceToken) && !auth.IsKind(authorization.AccountToken) {
-		return NewHandlerError(err, "wrong token kind", http.StatusForbidden).WithMessage("wrong token kind was used")
+	u, err := h.create(auth, request.InstanceID, request.InstanceType, request.DeviceTime, request.Measurements, idempotencyKey)
+	if err != nil {
+		return err
 	}
 
-	upload, err := h.service.Create(request.InstanceID, request.InstanceType, request.DeviceTime, request.Measurements)
+	// We don't need to return all measurements in the upload response.
+	u.Measurements = nil
+
+	err = json.NewEncoder(w).Encode(&u)
 	if err != nil {
-		if errors.Is(err, services.ErrEmptyUpload) {
-			return NewHandlerError(err, "empty upload", http.StatusBadRequest)
+		return NewHandlerError(err, "internal server error", http.StatusInternalServerError).WithLevel(logrus.ErrorLevel)
+	}
+
+	return nil
+}
+
+// The result of an upload in a batch.
+type batchResult struct {
+	// Index of the upload in the batch.
+	Index  int            `json:"index"`
+	Status int            `json:"status"`
+	Upload *upload.Upload `json:"upload,omitempty"`
+	Error  string         `json:"error,omitempty"`
+}
+
+// Handle API endpoint for creating multiple uploads at once.
+// The body is a list of uploads, that can be compressed with gzip or zstd.
+// Uploads are created while the body is read, and a result is returned for every upload.
+func (h *UploadHandler) CreateBatch(w http.ResponseWriter, r *http.Request) error {
+	auth, err := uploadAuthorization(r)
+	if err != nil {
+		return err
+	}
+
+	body, err := decompressBody(w, r)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	decoder := json.NewDecoder(body)
+
+	token, err := decoder.Token()
+	if err != nil || token != json.Delim('[') {
+		return NewHandlerError(err, "bad request", http.StatusBadRequest).WithMessage("batch upload is not a list")
+	}
+
+	results := make([]batchResult, 0)
+	created := 0
+
+	for i := 0; decoder.More(); i++ {
+		var request upload.BatchUpload
+		err = decoder.Decode(&request)
+		if err != nil {
+			if i == 0 {
+				return NewHandlerError(err, "bad request", http.StatusBadRequest)
+			}
+
+			// The rest of the body can not be read, so the remaining uploads are not created.
+			results = append(results, batchResult{Index: i, Status: http.StatusBadRequest, Error: err.Error()})
+			break
 		}
 
-		return NewHandlerError(err, "internal server error", http.StatusInternalServerError)
+		results = append(results, h.createBatchUpload(auth, i, request))
+		if results[i].Status == http.StatusOK {
+			created++
+		}
 	}
 
-	// We don't need to return all measurements in the upload response.
-	upload.Measurements = nil
+	logrus.Debugln("created", created, "of", len(results), "uploads in batch")
 
-	err = json.NewEncoder(w).Encode(&upload)
+	err = json.NewEncoder(w).Encode(&results)
 	if err != nil {
-		return NewHandlerError(err, "internal server error", http.StatusInternalServerError).WithLevel(logrus.ErrorLevel)
+		return InternalServerError(err).WithLevel(logrus.ErrorLevel)
 	}
 
 	return nil
 }
+
+// Create an upload in a batch and return its result.
+func (h *UploadHandler) createBatchUpload(auth *authorization.Authorization, index int, request upload.BatchUpload) batchResult {
+	measurements, err := request.AllMeasurements()
+	if err != nil {
+		return batchResult{Index: index, Status: http.StatusBadRequest, Error: err.Error()}
+	}
+
+	u, err := h.create(auth, request.InstanceID, request.InstanceType, request.DeviceTime, measurements, request.IdempotencyKey)
+	if err != nil {
+		var handlerErr *HandlerError
+		if !errors.As(err, &handlerErr) {
+			handlerErr = InternalServerError(err)
+		}
+		handlerErr.Log()
+
+		message := handlerErr.ResponseMessage
+		if handlerErr.ResponseCode < http.StatusInternalServerError && handlerErr.Err != nil {
+			message = handlerErr.Err.Error()
+		}
+
+		return batchResult{Index: index, Status: handlerErr.ResponseCode, Error: message}
+	}
+
+	u.Measurements = nil
+	return batchResult{Index: index, Status: http.StatusOK, Upload: &u}
+}
+
+// Create an upload for the instance, after checking that auth is allowed to upload for it.
+// The returned error is always a *HandlerError.
+func (h *UploadHandler) create(auth *authorization.Authorization, instanceID uint, instanceType upload.InstanceType, deviceTime needforheat.Time, measurements []measurement.Measurement, idempotencyKey string) (upload.Upload, error) {
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		return upload.Upload{}, NewHandlerError(nil, "idempotency key too long", http.StatusBadRequest)
+	}
+
+	instanceID, instanceType, err := h.service.BindInstance(auth, instanceID, instanceType)
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownInstanceType) {
+			return upload.Upload{}, NewHandlerError(err, err.Error(), http.StatusBadRequest)
+		}
+
+		if errors.Is(err, services.ErrInstanceNotOwned) {
+			return upload.Upload{}, NewHandlerError(err, err.Error(), http.StatusForbidden)
+		}
+
+		return upload.Upload{}, NewHandlerError(err, "internal server error", http.StatusInternalServerError)
+	}
+
+	u, err := h.service.Create(instanceID, instanceType, deviceTime, measurements, idempotencyKey)
+	if err != nil {
+		if errors.Is(err, services.ErrEmptyUpload) {
+			return upload.Upload{}, NewHandlerError(err, "empty upload", http.StatusBadRequest)
+		}
+
+		if errors.Is(err, devicetype.ErrPropertyNotAllowed) || errors.Is(err, property.ErrDeprecated) {
+			return upload.Upload{}, NewHandlerError(err, "property not allowed", http.StatusBadRequest).WithMessage(err.Error())
+		}
+
+		if errors.Is(err, property.ErrInvalidValue) {
+			return upload.Upload{}, NewHandlerError(err, "invalid measurement value", http.StatusBadRequest).WithMessage(err.Error())
+		}
+
+		if errors.Is(err, upload.ErrClockSkew) {
+			return upload.Upload{}, NewHandlerError(err, err.Error(), http.StatusBadRequest)
+		}
+
+		if errors.Is(err, upload.ErrMeasurementConflict) {
+			return upload.Upload{}, NewHandlerError(err, "measurement conflict", http.StatusConflict).WithMessage(err.Error())
+		}
+
+		return upload.Upload{}, NewHandlerError(err, "internal server error", http.StatusInternalServerError)
+	}
+
+	return u, nil
+}
+
+// Get the authorization of a device or account that is uploading.
+func uploadAuthorization(r *http.Request) (*authorization.Authorization, error) {
+	auth, ok := r.Context().Value(AuthorizationCtxKey).(*authorization.Authorization)
+	if !ok {
+		return nil, NewHandlerError(nil, "unauthorized", http.StatusUnauthorized).WithMessage("failed when getting authentication context value")
+	}
+
+	if !auth.IsKind(authorization.DeviceToken) && !auth.IsKind(authorization.AccountToken) {
+		return nil, NewHandlerError(nil, "wrong token kind", http.StatusForbidden).WithMessage("wrong token kind was used")
+	}
+
+	return auth, nil
+}
+
+// Get the body of r, decompressed according to its Content-Encoding.
+// The size of the decompressed body is limited to maxUploadSize.
+func decompressBody(w http.ResponseWriter, r *http.Request) (io.ReadCloser, error) {
+	var body io.ReadCloser
+
+	switch encoding := r.Header.Get("Content-Encoding"); encoding {
+	case "", "identity":
+		body = r.Body
+	case "gzip":
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, NewHandlerError(err, "invalid gzip body", http.StatusBadRequest)
+		}
+		body = reader
+	case "zstd":
+		decoder, err := zstd.NewReader(r.Body)
+		if err != nil {
+			return nil, NewHandlerError(err, "invalid zstd body", http.StatusBadRequest)
+		}
+		body = decoder.IOReadCloser()
+	default:
+		return nil, NewHandlerError(nil, "unsupported content encoding", http.StatusUnsupportedMediaType).WithMessage(fmt.Sprintf("unsupported content encoding %q", encoding))
+	}
+
+	return http.MaxBytesReader(w, body, maxUploadSize), nil
+}
@@ -0,0 +1,652 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat"
+	"github.com/energietransitie/needforheat-server-api/needforheat/authorization"
+	"github.com/energietransitie/needforheat-server-api/needforheat/device"
+	"github.com/energietransitie/needforheat-server-api/needforheat/devicetype"
+	"github.com/energietransitie/needforheat-server-api/needforheat/latestvalue"
+	"github.com/energietransitie/needforheat-server-api/needforheat/property"
+	"github.com/energietransitie/needforheat-server-api/needforheat/upload"
+	"github.com/energietransitie/needforheat-server-api/services"
+	"github.com/klauspost/compress/zstd"
+	"gorm.io/gorm"
+)
+
+// An instance that can upload measurements.
+type instance struct {
+	id           uint
+	instanceType upload.InstanceType
+}
+
+// fakeUploadRepository stores uploads in memory.
+// Methods that are not used when creating an upload are not implemented.
+type fakeUploadRepository struct {
+	upload.UploadRepository
+
+	// Account IDs of the instances that exist.
+	owners  map[instance]uint
+	created []upload.Upload
+}
+
+func (r *fakeUploadRepository) Create(u upload.Upload, policy upload.ConflictPolicy) (upload.Upload, error) {
+	u.ID = uint(len(r.created) + 1)
+	r.created = append(r.created, u)
+	return u, nil
+}
+
+func (r *fakeUploadRepository) FindByIdempotencyKey(instanceID uint, instanceType upload.InstanceType, key string) (upload.Upload, error) {
+	return upload.Upload{}, gorm.ErrRecordNotFound
+}
+
+func (r *fakeUploadRepository) FindInstanceAccountID(instanceID uint, instanceType upload.InstanceType) (uint, error) {
+	accountID, ok := r.owners[instance{instanceID, instanceType}]
+	if !ok {
+		return 0, gorm.ErrRecordNotFound
+	}
+
+	return accountID, nil
+}
+
+// fakePropertyRepository stores properties in memory.
+type fakePropertyRepository struct {
+	property.PropertyRepository
+
+	properties []property.Property
+	// IDs of the properties that aliases belong to.
+	aliases map[string]uint
+}
+
+func (r *fakePropertyRepository) Find(p property.Property) (property.Property, error) {
+	for _, stored := range r.properties {
+		if stored.Name == p.Name || (p.Name == "" && stored.ID == p.ID) {
+			return stored, nil
+		}
+	}
+
+	return property.Property{}, gorm.ErrRecordNotFound
+}
+
+func (r *fakePropertyRepository) FindByAlias(name string) (property.Property, error) {
+	id, ok := r.aliases[name]
+	if !ok {
+		return property.Property{}, gorm.ErrRecordNotFound
+	}
+
+	return r.Find(property.Property{ID: id})
+}
+
+func (r *fakePropertyRepository) Create(p property.Property) (property.Property, error) {
+	p.ID = uint(len(r.properties) + 1)
+	r.properties = append(r.properties, p)
+	return p, nil
+}
+
+// fakeDeviceTypeRepository stores the device types of devices in memory.
+// Devices without a device type are not strict.
+type fakeDeviceTypeRepository struct {
+	devicetype.DeviceTypeRepository
+
+	deviceTypes map[uint]devicetype.DeviceType
+}
+
+func (r *fakeDeviceTypeRepository) GetAll() ([]devicetype.DeviceType, error) {
+	return nil, nil
+}
+
+func (r *fakeDeviceTypeRepository) FindByDeviceID(deviceID uint) (devicetype.DeviceType, error) {
+	deviceType, ok := r.deviceTypes[deviceID]
+	if !ok {
+		return devicetype.DeviceType{}, gorm.ErrRecordNotFound
+	}
+
+	return deviceType, nil
+}
+
+// fakeDeviceRepository only stores the clock settings of devices.
+type fakeDeviceRepository struct {
+	device.DeviceRepository
+
+	clocks map[uint]upload.ClockSettings
+}
+
+func (r *fakeDeviceRepository) FindClockSettings(deviceID uint) (upload.ClockSettings, error) {
+	settings, ok := r.clocks[deviceID]
+	if !ok {
+		return upload.ClockSettings{}, gorm.ErrRecordNotFound
+	}
+
+	return settings, nil
+}
+
+// fakeLatestValueRepository stores the latest values in memory.
+type fakeLatestValueRepository struct {
+	latestvalue.LatestValueRepository
+
+	values map[instance]map[uint]latestvalue.LatestValue
+}
+
+func (r *fakeLatestValueRepository) Update(values []latestvalue.LatestValue) error {
+	for _, value := range values {
+		key := instance{value.InstanceID, value.InstanceType}
+		if r.values[key] == nil {
+			r.values[key] = make(map[uint]latestvalue.LatestValue)
+		}
+
+		stored, ok := r.values[key][value.Property.ID]
+		if !ok || !time.Time(value.Time).Before(time.Time(stored.Time)) {
+			r.values[key][value.Property.ID] = value
+		}
+	}
+
+	return nil
+}
+
+// setupUploadHandler creates an UploadHandler with fake repositories.
+// Account 1 owns device 10 and energy query 20. Account 2 owns device 11 and energy query 21.
+// Device 12 shifts and device 13 rejects uploads with a clock skew above a minute.
+// Device 14 has a strict device type that only allows co2__ppm and temp_in__degC.
+func setupUploadHandler(t *testing.T) (*UploadHandler, *fakeUploadRepository, *fakeLatestValueRepository, *fakePropertyRepository) {
+	t.Helper()
+
+	uploadRepository := &fakeUploadRepository{
+		owners: map[instance]uint{
+			{10, upload.Device}:      1,
+			{11, upload.Device}:      2,
+			{20, upload.EnergyQuery}: 1,
+			{21, upload.EnergyQuery}: 2,
+		},
+	}
+
+	// The value type of co2__ppm is confirmed by an admin. Other properties are created with an inferred value type.
+	// temp_in__degC was renamed from room_temp__degC, and humidity__pct is deprecated.
+	deprecatedAt := needforheat.Time(time.Now())
+	propertyRepository := &fakePropertyRepository{
+		properties: []property.Property{
+			{ID: 1, Name: "co2__ppm", ValueType: property.Int, TypeConfirmed: true, Unit: "ppm"},
+			{ID: 2, Name: "temp_in__degC", ValueType: property.Float, Unit: "degC"},
+			{ID: 3, Name: "humidity__pct", ValueType: property.Float, Unit: "pct", DeprecatedAt: &deprecatedAt},
+		},
+		aliases: map[string]uint{
+			"room_temp__degC": 2,
+		},
+	}
+	propertyService := services.NewPropertyService(propertyRepository)
+	deviceTypeService := services.NewDeviceTypeService(&fakeDeviceTypeRepository{
+		deviceTypes: map[uint]devicetype.DeviceType{
+			14: {ID: 1, Name: "strict-sensor", Strict: true, Properties: propertyRepository.properties[:2]},
+		},
+	}, propertyService)
+	threshold := int64(60)
+	deviceRepository := &fakeDeviceRepository{
+		clocks: map[uint]upload.ClockSettings{
+			12: {Policy: upload.ClockPolicyShift, Threshold: &threshold},
+			13: {Policy: upload.ClockPolicyReject},
+		},
+	}
+
+	latestValueRepository := &fakeLatestValueRepository{
+		values: make(map[instance]map[uint]latestvalue.LatestValue),
+	}
+
+	clockSettings := upload.ClockSettings{Policy: upload.ClockPolicyNone, Threshold: &threshold}
+	uploadService := services.NewUploadService(uploadRepository, deviceRepository, latestValueRepository, propertyService, deviceTypeService, upload.ConflictPolicyKeepFirst, clockSettings)
+
+	return NewUploadHandler(uploadService), uploadRepository, latestValueRepository, propertyRepository
+}
+
+// doUpload posts body to the handler, authenticated with auth.
+func doUpload(handler *UploadHandler, auth *authorization.Authorization, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(body))
+	r = r.WithContext(context.WithValue(r.Context(), AuthorizationCtxKey, auth))
+
+	w := httptest.NewRecorder()
+	Handler(handler.Create).ServeHTTP(w, r)
+	return w
+}
+
+const uploadMeasurements = `"measurements": [{"property": {"name": "temp_in__degC"}, "time": 1714229168, "value": "21.5"}]`
+
+func TestUploadHandlerCreate(t *testing.T) {
+	tests := []struct {
+		name string
+		auth *authorization.Authorization
+		body string
+
+		wantCode     int
+		wantInstance instance
+	}{
+		{
+			name:         "device uploads for itself",
+			auth:         &authorization.Authorization{Kind: authorization.DeviceToken, ID: 10},
+			body:         `{"instance_id": 10, "instance_type": "device", ` + uploadMeasurements + `}`,
+			wantCode:     http.StatusOK,
+			wantInstance: instance{10, upload.Device},
+		},
+		{
+			name:         "device without instance uploads for itself",
+			auth:         &authorization.Authorization{Kind: authorization.DeviceToken, ID: 10},
+			body:         `{` + uploadMeasurements + `}`,
+			wantCode:     http.StatusOK,
+			wantInstance: instance{10, upload.Device},
+		},
+		{
+			name:     "device uploads for another device",
+			auth:     &authorization.Authorization{Kind: authorization.DeviceToken, ID: 10},
+			body:     `{"instance_id": 11, "instance_type": "device", ` + uploadMeasurements + `}`,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "device uploads for an energy query",
+			auth:     &authorization.Authorization{Kind: authorization.DeviceToken, ID: 20},
+			body:     `{"instance_id": 20, "instance_type": "energy_query", ` + uploadMeasurements + `}`,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:         "account uploads for its device",
+			auth:         &authorization.Authorization{Kind: authorization.AccountToken, ID: 1},
+			body:         `{"instance_id": 10, "instance_type": "device", ` + uploadMeasurements + `}`,
+			wantCode:     http.StatusOK,
+			wantInstance: instance{10, upload.Device},
+		},
+		{
+			name:     "account uploads for a device of another account",
+			auth:     &authorization.Authorization{Kind: authorization.AccountToken, ID: 1},
+			body:     `{"instance_id": 11, "instance_type": "device", ` + uploadMeasurements + `}`,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:         "account uploads for its energy query",
+			auth:         &authorization.Authorization{Kind: authorization.AccountToken, ID: 2},
+			body:         `{"instance_id": 21, "instance_type": "energy_query", ` + uploadMeasurements + `}`,
+			wantCode:     http.StatusOK,
+			wantInstance: instance{21, upload.EnergyQuery},
+		},
+		{
+			name:     "account uploads for an energy query of another account",
+			auth:     &authorization.Authorization{Kind: authorization.AccountToken, ID: 2},
+			body:     `{"instance_id": 20, "instance_type": "energy_query", ` + uploadMeasurements + `}`,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "account uploads for an energy query that does not exist",
+			auth:     &authorization.Authorization{Kind: authorization.AccountToken, ID: 1},
+			body:     `{"instance_id": 99, "instance_type": "energy_query", ` + uploadMeasurements + `}`,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "account uploads for an unknown instance type",
+			auth:     &authorization.Authorization{Kind: authorization.AccountToken, ID: 1},
+			body:     `{"instance_id": 10, "instance_type": "cloud_feed", ` + uploadMeasurements + `}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "admin uploads",
+			auth:     &authorization.Authorization{Kind: authorization.AdminToken, ID: 1},
+			body:     `{"instance_id": 10, "instance_type": "device", ` + uploadMeasurements + `}`,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			// The body is not read before the token kind is checked.
+			name:     "admin uploads an invalid body",
+			auth:     &authorization.Authorization{Kind: authorization.AdminToken, ID: 1},
+			body:     `{"instance_id": `,
+			wantCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, repository, _, _ := setupUploadHandler(t)
+
+			w := doUpload(handler, tt.auth, tt.body)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d; want %d (body: %s)", w.Code, tt.wantCode, w.Body)
+			}
+
+			if tt.wantCode != http.StatusOK {
+				if len(repository.created) != 0 {
+					t.Errorf("created %d uploads; want 0", len(repository.created))
+				}
+				return
+			}
+
+			if len(repository.created) != 1 {
+				t.Fatalf("created %d uploads; want 1", len(repository.created))
+			}
+
+			got := instance{repository.created[0].InstanceID, repository.created[0].InstanceType}
+			if got != tt.wantInstance {
+				t.Errorf("upload created for %v; want %v", got, tt.wantInstance)
+			}
+
+			var u upload.Upload
+			err := json.NewDecoder(w.Body).Decode(&u)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if u.InstanceID != tt.wantInstance.id {
+				t.Errorf("response instance_id = %d; want %d", u.InstanceID, tt.wantInstance.id)
+			}
+		})
+	}
+}
+
+func TestUploadHandlerCreate_clockSkew(t *testing.T) {
+	// The measurement was made 30 seconds before the device sent the upload.
+	body := func(skew time.Duration) string {
+		deviceTime := time.Now().Add(-skew)
+		return fmt.Sprintf(`{"device_time": %d, "measurements": [{"property": {"name": "temp_in__degC"}, "time": %d, "value": "21.5"}]}`,
+			deviceTime.Unix(), deviceTime.Add(-30*time.Second).Unix())
+	}
+
+	tests := []struct {
+		name     string
+		deviceID uint
+		skew     time.Duration
+
+		wantCode  int
+		wantShift bool
+	}{
+		{"default policy keeps times", 10, time.Hour, http.StatusOK, false},
+		{"shift below threshold", 12, 10 * time.Second, http.StatusOK, false},
+		{"shift above threshold", 12, time.Hour, http.StatusOK, true},
+		{"shift with device ahead", 12, -time.Hour, http.StatusOK, true},
+		{"reject below default threshold", 13, 10 * time.Second, http.StatusOK, false},
+		{"reject above default threshold", 13, time.Hour, http.StatusBadRequest, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, repository, _, _ := setupUploadHandler(t)
+
+			w := doUpload(handler, &authorization.Authorization{Kind: authorization.DeviceToken, ID: tt.deviceID}, body(tt.skew))
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d; want %d (body: %s)", w.Code, tt.wantCode, w.Body)
+			}
+
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			created := repository.created[0]
+			if created.ClockSkew == nil {
+				t.Fatal("clock_skew was not set")
+			}
+
+			// Allow a second of difference, because the server time is taken during the upload.
+			if diff := *created.ClockSkew - int64(tt.skew.Seconds()); diff < -1 || diff > 1 {
+				t.Errorf("clock_skew = %d; want %d", *created.ClockSkew, int64(tt.skew.Seconds()))
+			}
+
+			if shifted := created.TimeShift != 0; shifted != tt.wantShift {
+				t.Errorf("time_shift = %d; want shifted %v", created.TimeShift, tt.wantShift)
+			}
+
+			measured := time.Time(created.Measurements[0].Time)
+			want := time.Time(created.DeviceTime).Add(-30 * time.Second).Add(time.Duration(created.TimeShift) * time.Second)
+			if !measured.Equal(want) {
+				t.Errorf("measurement time = %v; want %v", measured, want)
+			}
+		})
+	}
+}
+
+func TestUploadHandlerCreate_latestValues(t *testing.T) {
+	handler, _, latestValues, _ := setupUploadHandler(t)
+	auth := &authorization.Authorization{Kind: authorization.DeviceToken, ID: 10}
+
+	uploads := []string{
+		`{"measurements": [
+			{"property": {"name": "temp_in__degC"}, "time": 1714229100, "value": "21.5"},
+			{"property": {"name": "temp_in__degC"}, "time": 1714229160, "value": "21.6"},
+			{"property": {"name": "presence__bool"}, "time": 1714229100, "value": "true"}
+		]}`,
+		// Measurements that arrive late do not replace more recent values.
+		`{"measurements": [
+			{"property": {"name": "temp_in__degC"}, "time": 1714229000, "value": "20.9"},
+			{"property": {"name": "presence__bool"}, "time": 1714229200, "value": "false"}
+		]}`,
+	}
+
+	for _, body := range uploads {
+		w := doUpload(handler, auth, body)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d; want %d (body: %s)", w.Code, http.StatusOK, w.Body)
+		}
+	}
+
+	want := map[string]string{
+		"temp_in__degC":  "21.6",
+		"presence__bool": "false",
+	}
+
+	values := latestValues.values[instance{10, upload.Device}]
+	if len(values) != len(want) {
+		t.Fatalf("got %d latest values; want %d", len(values), len(want))
+	}
+
+	for _, value := range values {
+		if value.Value != want[value.Property.Name] {
+			t.Errorf("latest value of %s = %s; want %s", value.Property.Name, value.Value, want[value.Property.Name])
+		}
+	}
+}
+
+func TestUploadHandlerCreate_valueTypes(t *testing.T) {
+	handler, repository, _, _ := setupUploadHandler(t)
+	auth := &authorization.Authorization{Kind: authorization.DeviceToken, ID: 10}
+
+	// Confirmed value types and value types derived from the name are both enforced.
+	// Only the invalid measurements are rejected. Properties without a unit are strings.
+	w := doUpload(handler, auth, `{"measurements": [
+		{"property": {"name": "co2__ppm"}, "time": 1714229100, "value": "420"},
+		{"property": {"name": "co2__ppm"}, "time": 1714229160, "value": "420.5"},
+		{"property": {"name": "temp_in__degC"}, "time": 1714229100, "value": "warm"},
+		{"property": {"name": "e_use_cum__kWh"}, "time": 1714229100, "value": "abc"},
+		{"property": {"name": "presence__bool"}, "time": 1714229100, "value": "yes"},
+		{"property": {"name": "presence__bool"}, "time": 1714229160, "value": "true"},
+		{"property": {"name": "firmware"}, "time": 1714229100, "value": "v1.2"}
+	]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d; want %d (body: %s)", w.Code, http.StatusOK, w.Body)
+	}
+
+	var created upload.Upload
+	err := json.NewDecoder(w.Body).Decode(&created)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if created.Rejected != 4 {
+		t.Errorf("rejected = %d; want 4", created.Rejected)
+	}
+
+	if size := len(repository.created[0].Measurements); size != 3 {
+		t.Errorf("stored %d measurements; want 3", size)
+	}
+
+	// An upload without valid measurements is rejected.
+	w = doUpload(handler, auth, `{"measurements": [{"property": {"name": "co2__ppm"}, "time": 1714229100, "value": "high"}]}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d; want %d (body: %s)", w.Code, http.StatusBadRequest, w.Body)
+	}
+
+	if len(repository.created) != 1 {
+		t.Errorf("created %d uploads; want 1", len(repository.created))
+	}
+}
+
+func TestUploadHandlerCreate_properties(t *testing.T) {
+	tests := []struct {
+		name     string
+		deviceID uint
+		property string
+
+		wantCode     int
+		wantProperty string
+		wantCreated  bool
+	}{
+		{"new property", 10, "presence__bool", http.StatusOK, "presence__bool", true},
+		{"alias", 10, "room_temp__degC", http.StatusOK, "temp_in__degC", false},
+		{"deprecated property", 10, "humidity__pct", http.StatusBadRequest, "", false},
+		{"strict device with allowed property", 14, "co2__ppm", http.StatusOK, "co2__ppm", false},
+		{"strict device with alias of allowed property", 14, "room_temp__degC", http.StatusOK, "temp_in__degC", false},
+		{"strict device with unknown property", 14, "roomTemp", http.StatusBadRequest, "", false},
+		{"strict device with property that is not allowed", 14, "humidity__pct", http.StatusBadRequest, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, repository, _, properties := setupUploadHandler(t)
+			before := len(properties.properties)
+
+			body := fmt.Sprintf(`{"measurements": [{"property": {"name": %q}, "time": 1714229100, "value": "1"}]}`, tt.property)
+			w := doUpload(handler, &authorization.Authorization{Kind: authorization.DeviceToken, ID: tt.deviceID}, body)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d; want %d (body: %s)", w.Code, tt.wantCode, w.Body)
+			}
+
+			if created := len(properties.properties) > before; created != tt.wantCreated {
+				t.Errorf("property created = %t; want %t", created, tt.wantCreated)
+			}
+
+			if tt.wantCode != http.StatusOK {
+				if len(repository.created) != 0 {
+					t.Errorf("created %d uploads; want 0", len(repository.created))
+				}
+				return
+			}
+
+			if name := repository.created[0].Measurements[0].Property.Name; name != tt.wantProperty {
+				t.Errorf("measurement stored for %s; want %s", name, tt.wantProperty)
+			}
+		})
+	}
+}
+
+func TestUploadHandlerCreateBatch(t *testing.T) {
+	body := `[
+		{"instance_id": 10, "instance_type": "device", "device_time": 1714229200,
+		 "properties": ["temp_in__degC", "presence__bool"],
+		 "columns": [
+			{"property": 0, "times": [1714229100, 1714229160], "values": [21.5, "21.6"]},
+			{"property": 1, "times": [1714229100], "values": [true]}
+		 ]},
+		{"instance_id": 11, "instance_type": "device", ` + uploadMeasurements + `},
+		{"instance_id": 10, "instance_type": "device", "properties": ["temp_in__degC"], "columns": [{"property": 1, "times": [1714229100], "values": [1]}]},
+		{"instance_id": 10, "instance_type": "device", "device_time": 1714229300, ` + uploadMeasurements + `}
+	]`
+
+	encoders := map[string]func(io.Writer) io.WriteCloser{
+		"": func(w io.Writer) io.WriteCloser {
+			return nopWriteCloser{w}
+		},
+		"gzip": func(w io.Writer) io.WriteCloser {
+			return gzip.NewWriter(w)
+		},
+		"zstd": func(w io.Writer) io.WriteCloser {
+			encoder, err := zstd.NewWriter(w)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return encoder
+		},
+	}
+
+	for encoding, newEncoder := range encoders {
+		t.Run("encoding "+encoding, func(t *testing.T) {
+			handler, repository, _, _ := setupUploadHandler(t)
+
+			var compressed bytes.Buffer
+			encoder := newEncoder(&compressed)
+			_, err := encoder.Write([]byte(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = encoder.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/upload/batch", &compressed)
+			r.Header.Set("Content-Encoding", encoding)
+			r = r.WithContext(context.WithValue(r.Context(), AuthorizationCtxKey, &authorization.Authorization{Kind: authorization.DeviceToken, ID: 10}))
+
+			w := httptest.NewRecorder()
+			Handler(handler.CreateBatch).ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d; want %d (body: %s)", w.Code, http.StatusOK, w.Body)
+			}
+
+			var results []batchResult
+			err = json.NewDecoder(w.Body).Decode(&results)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			wantStatuses := []int{http.StatusOK, http.StatusForbidden, http.StatusBadRequest, http.StatusOK}
+			if len(results) != len(wantStatuses) {
+				t.Fatalf("got %d results; want %d", len(results), len(wantStatuses))
+			}
+
+			for i, want := range wantStatuses {
+				if results[i].Index != i || results[i].Status != want {
+					t.Errorf("result %d = index %d, status %d (%s); want index %d, status %d", i, results[i].Index, results[i].Status, results[i].Error, i, want)
+				}
+			}
+
+			if len(repository.created) != 2 {
+				t.Fatalf("created %d uploads; want 2", len(repository.created))
+			}
+
+			if size := len(repository.created[0].Measurements); size != 3 {
+				t.Errorf("first upload has %d measurements; want 3", size)
+			}
+
+			if got, want := repository.created[1].DeviceTime, needforheat.Time(time.Unix(1714229300, 0)); !time.Time(got).Equal(time.Time(want)) {
+				t.Errorf("second upload device_time = %v; want %v", time.Time(got), time.Time(want))
+			}
+		})
+	}
+}
+
+func TestUploadHandlerCreateBatch_unsupportedEncoding(t *testing.T) {
+	handler, _, _, _ := setupUploadHandler(t)
+
+	r := httptest.NewRequest(http.MethodPost, "/upload/batch", strings.NewReader("[]"))
+	r.Header.Set("Content-Encoding", "br")
+	r = r.WithContext(context.WithValue(r.Context(), AuthorizationCtxKey, &authorization.Authorization{Kind: authorization.DeviceToken, ID: 10}))
+
+	w := httptest.NewRecorder()
+	Handler(handler.CreateBatch).ServeHTTP(w, r)
+
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("status = %d; want %d", w.Code, http.StatusUnsupportedMediaType)
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
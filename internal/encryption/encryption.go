@@ -5,29 +5,77 @@ package encryption
 import (
 	"database/sql/driver"
 	"errors"
+	"sync/atomic"
 )
 
 var (
 	ErrInvalidTypeInDB = errors.New("invalid type stored in database")
 )
 
+// Keyring used by [EncryptedString].
+var keyring atomic.Pointer[Keyring]
+
+// Set the keyring that is used to encrypt and decrypt values of [EncryptedString].
+func SetKeyring(k *Keyring) {
+	keyring.Store(k)
+}
+
 // EncryptedString will transparantly encrypt or decrypt data when
 // it is saved or loaded from the database.
 //
-// TODO: Implement actual encryption.
-// WARNING actual encryption is not yet implemented.
+// Values are encrypted with the keyring set by [SetKeyring].
+// Values that were stored before encryption was implemented are read as plaintext,
+// until they are encrypted by saving them again.
 type EncryptedString string
 
 func (e EncryptedString) Value() (driver.Value, error) {
-	return []byte(e), nil
+	// Empty values are not encrypted, so queries on empty columns keep working.
+	if e == "" {
+		return []byte{}, nil
+	}
+
+	k := keyring.Load()
+	if k == nil {
+		return nil, ErrNoKeyring
+	}
+
+	encrypted, err := k.Encrypt(string(e))
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(encrypted), nil
 }
 
 func (e *EncryptedString) Scan(src any) error {
-	source, ok := src.([]byte)
-	if !ok {
+	var source string
+	switch src := src.(type) {
+	case []byte:
+		source = string(src)
+	case string:
+		source = src
+	case nil:
+		*e = ""
+		return nil
+	default:
 		return ErrInvalidTypeInDB
 	}
 
-	*e = EncryptedString(source)
+	if !IsEncrypted(source) {
+		*e = EncryptedString(source)
+		return nil
+	}
+
+	k := keyring.Load()
+	if k == nil {
+		return ErrNoKeyring
+	}
+
+	decrypted, err := k.Decrypt(source)
+	if err != nil {
+		return err
+	}
+
+	*e = EncryptedString(decrypted)
 	return nil
 }
@@ -0,0 +1,409 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Type of the PEM blocks in a keyring file.
+	pemBlockType = "NEEDFORHEAT ENCRYPTION KEY"
+	// PEM header that contains the ID of a key.
+	pemHeaderKeyID = "Key-Id"
+
+	// Size of the keys in bytes. AES-256 is used.
+	keySize = 32
+
+	// Prefix of encrypted values, followed by the ID of the key encryption key,
+	// the encrypted data encryption key and the encrypted value, separated by colons.
+	encryptedPrefix = "enc:v1:"
+)
+
+var (
+	ErrNoKeyring    = errors.New("no encryption keyring was loaded")
+	ErrUnknownKey   = errors.New("unknown encryption key")
+	ErrInvalidKey   = errors.New("invalid encryption key")
+	ErrCiphertext   = errors.New("invalid ciphertext")
+	ErrEmptyKeyring = errors.New("keyring does not contain keys")
+)
+
+// A Keyring contains the keys used to encrypt and decrypt values.
+// The newest key is the primary key, which is used to encrypt new values.
+// Older keys are only used to decrypt values that were encrypted before a rotation.
+//
+// Values are encrypted using envelope encryption: every value is encrypted with a new
+// data encryption key, which is encrypted with a key from the keyring.
+//
+// The keyring file is read again when it changes, so a key that is added by a rotation
+// in another process, like the rotate command, becomes the primary key without a restart.
+type Keyring struct {
+	path string
+
+	mu   sync.RWMutex
+	ids  []string
+	keys map[string][]byte
+
+	// Modification time and size of the file when it was last read or written.
+	modTime time.Time
+	size    int64
+}
+
+// Load a keyring from a PEM file at path. If the file does not exist, it is created with a new key.
+func LoadKeyringFromFile(path string) (*Keyring, error) {
+	k := &Keyring{
+		path: path,
+		keys: make(map[string][]byte),
+	}
+
+	err := k.load()
+	if err == nil {
+		return k, nil
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	// File did not exist, so generate it.
+	_, err = k.Rotate()
+	if err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// Read the keys from the file of the keyring.
+func (k *Keyring) load() error {
+	info, err := os.Stat(k.path)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(k.path)
+	if err != nil {
+		return err
+	}
+
+	var ids []string
+	keys := make(map[string][]byte)
+
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		if block.Type != pemBlockType {
+			continue
+		}
+
+		id := block.Headers[pemHeaderKeyID]
+		if id == "" || strings.Contains(id, ":") || len(block.Bytes) != keySize {
+			return fmt.Errorf("%w in %s", ErrInvalidKey, k.path)
+		}
+
+		ids = append(ids, id)
+		keys[id] = block.Bytes
+	}
+
+	if len(ids) == 0 {
+		return fmt.Errorf("%w: %s", ErrEmptyKeyring, k.path)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.ids = ids
+	k.keys = keys
+	k.modTime = info.ModTime()
+	k.size = info.Size()
+
+	return nil
+}
+
+// Read the file of the keyring again if it changed since it was last read or written.
+func (k *Keyring) reloadIfChanged() error {
+	info, err := os.Stat(k.path)
+	if err != nil {
+		return err
+	}
+
+	k.mu.RLock()
+	changed := !info.ModTime().Equal(k.modTime) || info.Size() != k.size
+	k.mu.RUnlock()
+
+	if !changed {
+		return nil
+	}
+
+	return k.load()
+}
+
+// Rotate generates a new primary key and saves the keyring to its file.
+// Older keys are kept, so values encrypted with them can still be decrypted.
+// The ID of the new key is returned.
+func (k *Keyring) Rotate() (string, error) {
+	idBytes := make([]byte, 4)
+	_, err := rand.Read(idBytes)
+	if err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(idBytes)
+
+	key := make([]byte, keySize)
+	_, err = rand.Read(key)
+	if err != nil {
+		return "", err
+	}
+
+	// Keep the keys that were added by another process.
+	err = k.reloadIfChanged()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	var data []byte
+	for _, existingID := range k.ids {
+		data = append(data, encodeKey(existingID, k.keys[existingID])...)
+	}
+	data = append(data, encodeKey(id, key)...)
+
+	// Write to a temporary file first, so other processes never read a partially written keyring.
+	tmpPath := k.path + ".tmp"
+	err = os.WriteFile(tmpPath, data, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	err = os.Rename(tmpPath, k.path)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(k.path)
+	if err != nil {
+		return "", err
+	}
+
+	k.ids = append(k.ids, id)
+	k.keys[id] = key
+	k.modTime = info.ModTime()
+	k.size = info.Size()
+
+	return id, nil
+}
+
+// PrimaryKeyID returns the ID of the key that is used to encrypt new values.
+// If the keyring file changed, it is read again first. If that fails, the primary key
+// that was read before is returned.
+func (k *Keyring) PrimaryKeyID() string {
+	id, _ := k.primaryKeyID()
+	return id
+}
+
+// Return the ID of the primary key, after reading the keyring file again if it changed.
+// The returned ID is the primary key that was read before if an error is returned.
+func (k *Keyring) primaryKeyID() (string, error) {
+	err := k.reloadIfChanged()
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.ids[len(k.ids)-1], err
+}
+
+// Return the key with id. If it is unknown, the keyring file is read again,
+// because the key may have been added by a rotation in another process.
+func (k *Keyring) key(id string) ([]byte, error) {
+	k.mu.RLock()
+	key, ok := k.keys[id]
+	k.mu.RUnlock()
+
+	if ok {
+		return key, nil
+	}
+
+	err := k.load()
+	if err != nil {
+		return nil, err
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	key, ok = k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+
+	return key, nil
+}
+
+// Encrypt plaintext with a new data encryption key, which is encrypted with the primary key.
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	id, err := k.primaryKeyID()
+	if err != nil {
+		return "", err
+	}
+
+	key, err := k.key(id)
+	if err != nil {
+		return "", err
+	}
+
+	dataKey := make([]byte, keySize)
+	_, err = rand.Read(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	// The key ID is used as additional data, so the data key can not be moved to another key ID.
+	encryptedDataKey, err := seal(key, dataKey, []byte(id))
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, err := seal(dataKey, []byte(plaintext), nil)
+	if err != nil {
+		return "", err
+	}
+
+	return encryptedPrefix + id + ":" +
+		base64.RawStdEncoding.EncodeToString(encryptedDataKey) + ":" +
+		base64.RawStdEncoding.EncodeToString(ciphertext), nil
+}
+
+// Decrypt a value that was encrypted with [Keyring.Encrypt].
+func (k *Keyring) Decrypt(value string) (string, error) {
+	id, encryptedDataKey, ciphertext, err := parseEncrypted(value)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := k.key(id)
+	if err != nil {
+		return "", err
+	}
+
+	dataKey, err := open(key, encryptedDataKey, []byte(id))
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := open(dataKey, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// IsEncrypted returns true if value was encrypted with [Keyring.Encrypt].
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+// KeyID returns the ID of the key that was used to encrypt value.
+// An empty string is returned if value is not encrypted.
+func KeyID(value string) string {
+	id, _, _, err := parseEncrypted(value)
+	if err != nil {
+		return ""
+	}
+
+	return id
+}
+
+// Split an encrypted value into its parts.
+func parseEncrypted(value string) (string, []byte, []byte, error) {
+	if !IsEncrypted(value) {
+		return "", nil, nil, ErrCiphertext
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if len(parts) != 3 {
+		return "", nil, nil, ErrCiphertext
+	}
+
+	encryptedDataKey, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, nil, ErrCiphertext
+	}
+
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, nil, ErrCiphertext
+	}
+
+	return parts[0], encryptedDataKey, ciphertext, nil
+}
+
+// Encrypt plaintext with AES-GCM. The nonce is prepended to the ciphertext.
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// Decrypt ciphertext that was encrypted with seal.
+func open(key, ciphertext, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, ErrCiphertext
+	}
+
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCiphertext, err)
+	}
+
+	return plaintext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// Encode a key as a PEM block.
+func encodeKey(id string, key []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{
+		Type:    pemBlockType,
+		Headers: map[string]string{pemHeaderKeyID: id},
+		Bytes:   key,
+	})
+}
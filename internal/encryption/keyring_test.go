@@ -0,0 +1,220 @@
+package encryption
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestKeyring creates a keyring in a new file.
+func newTestKeyring(t *testing.T) (*Keyring, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "keyring.pem")
+
+	k, err := LoadKeyringFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return k, path
+}
+
+// encrypt encrypts plaintext with k.
+func encrypt(t *testing.T, k *Keyring, plaintext string) string {
+	t.Helper()
+
+	encrypted, err := k.Encrypt(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return encrypted
+}
+
+// checkDecrypt checks that k decrypts encrypted to want.
+func checkDecrypt(t *testing.T, k *Keyring, encrypted string, want string) {
+	t.Helper()
+
+	decrypted, err := k.Decrypt(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decrypted != want {
+		t.Errorf("decrypted %q, want %q", decrypted, want)
+	}
+}
+
+func TestKeyringEncryptDecrypt(t *testing.T) {
+	k, _ := newTestKeyring(t)
+
+	for _, plaintext := range []string{"secret", "", "with:colons", strings.Repeat("long ", 1000)} {
+		encrypted := encrypt(t, k, plaintext)
+
+		if !IsEncrypted(encrypted) {
+			t.Errorf("%q is not encrypted", encrypted)
+		}
+
+		if id := KeyID(encrypted); id != k.PrimaryKeyID() {
+			t.Errorf("encrypted with key %s, want primary key %s", id, k.PrimaryKeyID())
+		}
+
+		checkDecrypt(t, k, encrypted, plaintext)
+	}
+
+	// Every value gets its own data key and nonce.
+	if encrypt(t, k, "secret") == encrypt(t, k, "secret") {
+		t.Error("the same plaintext is encrypted to the same value twice")
+	}
+}
+
+func TestKeyringDecrypt_invalid(t *testing.T) {
+	k, _ := newTestKeyring(t)
+	other, _ := newTestKeyring(t)
+
+	encrypted := encrypt(t, k, "secret")
+
+	// Change a character in the ciphertext. The last character is not used, since it can contain unused bits.
+	i := len(encrypted) - 5
+	replacement := "A"
+	if encrypted[i] == 'A' {
+		replacement = "B"
+	}
+	tampered := encrypted[:i] + replacement + encrypted[i+1:]
+
+	tests := map[string]struct {
+		value string
+		want  error
+	}{
+		"plaintext":         {"secret", ErrCiphertext},
+		"missing parts":     {encryptedPrefix + k.PrimaryKeyID() + ":abc", ErrCiphertext},
+		"tampered":          {tampered, ErrCiphertext},
+		"key of other ring": {encrypt(t, other, "secret"), ErrUnknownKey},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := k.Decrypt(tt.value)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got error %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyringRotate(t *testing.T) {
+	k, path := newTestKeyring(t)
+
+	oldID := k.PrimaryKeyID()
+	encryptedOld := encrypt(t, k, "old")
+
+	newID, err := k.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if newID == oldID || k.PrimaryKeyID() != newID {
+		t.Fatalf("primary key is %s after rotating from %s to %s", k.PrimaryKeyID(), oldID, newID)
+	}
+
+	encryptedNew := encrypt(t, k, "new")
+	if id := KeyID(encryptedNew); id != newID {
+		t.Errorf("encrypted with key %s after rotation, want %s", id, newID)
+	}
+
+	// Values encrypted with the old key can still be decrypted, also after loading the file again.
+	loaded, err := LoadKeyringFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, keyring := range []*Keyring{k, loaded} {
+		checkDecrypt(t, keyring, encryptedOld, "old")
+		checkDecrypt(t, keyring, encryptedNew, "new")
+	}
+
+	if id := loaded.PrimaryKeyID(); id != newID {
+		t.Errorf("loaded primary key %s, want %s", id, newID)
+	}
+}
+
+func TestKeyringRotate_otherProcess(t *testing.T) {
+	server, path := newTestKeyring(t)
+
+	// The rotate command loads the keyring file itself.
+	command, err := LoadKeyringFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newID, err := command.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The server encrypts with the new primary key without a restart.
+	if id := server.PrimaryKeyID(); id != newID {
+		t.Errorf("server primary key is %s after rotation, want %s", id, newID)
+	}
+
+	encrypted := encrypt(t, server, "secret")
+	if id := KeyID(encrypted); id != newID {
+		t.Errorf("server encrypted with key %s after rotation, want %s", id, newID)
+	}
+
+	checkDecrypt(t, command, encrypted, "secret")
+
+	// A rotation by the server keeps the key that was added by the command.
+	serverID, err := server.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadKeyringFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkDecrypt(t, loaded, encrypted, "secret")
+
+	if id := loaded.PrimaryKeyID(); id != serverID {
+		t.Errorf("loaded primary key %s, want %s", id, serverID)
+	}
+}
+
+func TestEncryptedString(t *testing.T) {
+	k, _ := newTestKeyring(t)
+	SetKeyring(k)
+	t.Cleanup(func() { SetKeyring(nil) })
+
+	value, err := EncryptedString("secret").Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsEncrypted(string(value.([]byte))) {
+		t.Errorf("stored value %s is not encrypted", value)
+	}
+
+	var scanned EncryptedString
+	err = scanned.Scan(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if scanned != "secret" {
+		t.Errorf("scanned %q, want %q", scanned, "secret")
+	}
+
+	// Values that were stored before encryption are read as plaintext.
+	err = scanned.Scan("plaintext")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if scanned != "plaintext" {
+		t.Errorf("scanned %q, want %q", scanned, "plaintext")
+	}
+}
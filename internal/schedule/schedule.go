@@ -0,0 +1,244 @@
+// Package schedule implements cron-like schedules.
+//
+// A schedule consists of 5 fields separated by spaces:
+//
+//	minute (0-59) hour (0-23) day-of-month (1-31) month (1-12) day-of-week (0-6, 0 is sunday)
+//
+// Every field can be a '*', a value, a range of values (1-5), or a comma separated list of those.
+// A step can be added to a '*' or a range (*/15, 8-18/2).
+// The descriptors @hourly, @daily, @weekly and @monthly can be used as well.
+// All schedules are in UTC.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidSchedule = errors.New("invalid schedule")
+)
+
+// Limit of the search for the next time, to prevent looping forever on schedules like "0 0 31 2 *".
+const maxSearchYears = 5
+
+var descriptors = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+}
+
+// A field of a schedule, with its allowed values.
+type field struct {
+	name string
+	min  int
+	max  int
+}
+
+var (
+	fieldMinute     = field{"minute", 0, 59}
+	fieldHour       = field{"hour", 0, 23}
+	fieldDayOfMonth = field{"day-of-month", 1, 31}
+	fieldMonth      = field{"month", 1, 12}
+	fieldDayOfWeek  = field{"day-of-week", 0, 7}
+)
+
+// A Schedule is a parsed cron-like schedule.
+type Schedule struct {
+	expr string
+
+	// Bitsets of the allowed values per field.
+	minute     uint64
+	hour       uint64
+	dayOfMonth uint64
+	month      uint64
+	dayOfWeek  uint64
+
+	// True if the day-of-month or day-of-week field is a '*'.
+	anyDayOfMonth bool
+	anyDayOfWeek  bool
+}
+
+// Parse a schedule from expr.
+func Parse(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+
+	spec := expr
+	if descriptor, ok := descriptors[expr]; ok {
+		spec = descriptor
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return Schedule{}, fmt.Errorf("%w: %q should have 5 fields", ErrInvalidSchedule, expr)
+	}
+
+	s := Schedule{
+		expr:          expr,
+		anyDayOfMonth: fields[2] == "*",
+		anyDayOfWeek:  fields[4] == "*",
+	}
+
+	var err error
+	for i, f := range []struct {
+		field field
+		bits  *uint64
+	}{
+		{fieldMinute, &s.minute},
+		{fieldHour, &s.hour},
+		{fieldDayOfMonth, &s.dayOfMonth},
+		{fieldMonth, &s.month},
+		{fieldDayOfWeek, &s.dayOfWeek},
+	} {
+		*f.bits, err = parseField(fields[i], f.field)
+		if err != nil {
+			return Schedule{}, fmt.Errorf("%w: %q: %w", ErrInvalidSchedule, expr, err)
+		}
+	}
+
+	// Sunday can be 0 or 7.
+	if s.dayOfWeek&(1<<7) != 0 {
+		s.dayOfWeek |= 1
+	}
+
+	return s, nil
+}
+
+// Daily returns a schedule that runs every day at the time of day at.
+func Daily(at time.Duration) Schedule {
+	at = at % (time.Hour * 24)
+	hour := int(at / time.Hour)
+	minute := int(at % time.Hour / time.Minute)
+
+	s, _ := Parse(fmt.Sprintf("%d %d * * *", minute, hour))
+	return s
+}
+
+// String returns the expression the schedule was parsed from.
+func (s Schedule) String() string {
+	return s.expr
+}
+
+// IsZero returns true if s was not parsed from an expression.
+func (s Schedule) IsZero() bool {
+	return s.expr == ""
+}
+
+// Next returns the first time after t that matches the schedule.
+// The zero time is returned if there is no such time in the next years.
+func (s Schedule) Next(t time.Time) time.Time {
+	if s.IsZero() {
+		return time.Time{}
+	}
+
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(maxSearchYears, 0, 0)
+
+	for t.Before(limit) {
+		if !has(s.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+
+		if !has(s.hour, t.Hour()) {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+
+		if !has(s.minute, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// Check if the day of t matches the day-of-month and day-of-week fields.
+// Like cron, a day matches either field if both are restricted.
+func (s Schedule) matchesDay(t time.Time) bool {
+	dayOfMonth := has(s.dayOfMonth, t.Day())
+	dayOfWeek := has(s.dayOfWeek, int(t.Weekday()))
+
+	if s.anyDayOfMonth || s.anyDayOfWeek {
+		return dayOfMonth && dayOfWeek
+	}
+
+	return dayOfMonth || dayOfWeek
+}
+
+// Parse the value of a field into a bitset of allowed values.
+func parseField(value string, f field) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(value, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q in %s", stepPart, f.name)
+			}
+		}
+
+		start, end := f.min, f.max
+		if rangePart != "*" {
+			startPart, endPart, isRange := strings.Cut(rangePart, "-")
+
+			var err error
+			start, err = parseValue(startPart, f)
+			if err != nil {
+				return 0, err
+			}
+
+			end = start
+			if isRange {
+				end, err = parseValue(endPart, f)
+				if err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				end = f.max
+			}
+
+			if end < start {
+				return 0, fmt.Errorf("invalid range %q in %s", rangePart, f.name)
+			}
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << v
+		}
+	}
+
+	return bits, nil
+}
+
+// Parse a single value of a field.
+func parseValue(value string, f field) (int, error) {
+	v, err := strconv.Atoi(value)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid value %q in %s, should be between %d and %d", value, f.name, f.min, f.max)
+	}
+
+	return v, nil
+}
+
+// Check if v is set in bits.
+func has(bits uint64, v int) bool {
+	return bits&(1<<v) != 0
+}
@@ -0,0 +1,117 @@
+package schedule
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParse_invalid(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"@yearly",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"-1 * * * *",
+		"a * * * *",
+		"10-5 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"1-x * * * *",
+		"1,,2 * * * *",
+	}
+
+	for _, expr := range tests {
+		t.Run(expr, func(t *testing.T) {
+			_, err := Parse(expr)
+			if !errors.Is(err, ErrInvalidSchedule) {
+				t.Errorf("Parse(%q) error = %v; want %v", expr, err, ErrInvalidSchedule)
+			}
+		})
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	date := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	// 1 January 2024 is a monday.
+	tests := []struct {
+		name string
+		expr string
+		t    time.Time
+
+		want time.Time
+	}{
+		{"every minute", "* * * * *", date(2024, 1, 1, 10, 7), date(2024, 1, 1, 10, 8)},
+		{"seconds are ignored", "15 10 * * *", date(2024, 1, 1, 10, 14).Add(59 * time.Second), date(2024, 1, 1, 10, 15)},
+		{"step", "*/15 * * * *", date(2024, 1, 1, 10, 7), date(2024, 1, 1, 10, 15)},
+		{"only after t", "*/15 * * * *", date(2024, 1, 1, 10, 15), date(2024, 1, 1, 10, 30)},
+		{"step from a value", "5/20 * * * *", date(2024, 1, 1, 10, 30), date(2024, 1, 1, 10, 45)},
+		{"range with step", "30 8-18/2 * * *", date(2024, 1, 1, 9, 0), date(2024, 1, 1, 10, 30)},
+		{"range with step wraps to the next day", "30 8-18/2 * * *", date(2024, 1, 1, 18, 30), date(2024, 1, 2, 8, 30)},
+		{"list", "0 6,18 * * *", date(2024, 1, 1, 7, 0), date(2024, 1, 1, 18, 0)},
+		{"hourly", "@hourly", date(2024, 1, 1, 10, 30), date(2024, 1, 1, 11, 0)},
+		{"daily", "@daily", date(2024, 1, 1, 10, 30), date(2024, 1, 2, 0, 0)},
+		{"weekly", "@weekly", date(2024, 1, 1, 0, 0), date(2024, 1, 7, 0, 0)},
+		{"monthly", "@monthly", date(2024, 1, 15, 0, 0), date(2024, 2, 1, 0, 0)},
+		{"end of year", "0 0 1 * *", date(2024, 12, 31, 23, 59), date(2025, 1, 1, 0, 0)},
+		{"day-of-month skips short months", "0 0 31 * *", date(2024, 4, 15, 0, 0), date(2024, 5, 31, 0, 0)},
+		{"leap day", "0 0 29 2 *", date(2023, 3, 1, 0, 0), date(2024, 2, 29, 0, 0)},
+		{"day-of-week", "0 9 * * 1", date(2024, 1, 7, 12, 0), date(2024, 1, 8, 9, 0)},
+		{"weekdays", "0 9 * * 1-5", date(2024, 1, 5, 10, 0), date(2024, 1, 8, 9, 0)},
+		{"sunday as 7", "0 0 * * 7", date(2024, 1, 1, 0, 0), date(2024, 1, 7, 0, 0)},
+		{"day-of-week of the next month", "0 0 * 2 0", date(2024, 1, 1, 0, 0), date(2024, 2, 4, 0, 0)},
+		{"day-of-month or day-of-week, day-of-week first", "0 0 13 * 5", date(2024, 1, 1, 0, 0), date(2024, 1, 5, 0, 0)},
+		{"day-of-month or day-of-week, day-of-month first", "0 0 13 * 5", date(2024, 1, 12, 1, 0), date(2024, 1, 13, 0, 0)},
+		{"day-of-month and day-of-week with '*'", "0 0 13 * *", date(2024, 1, 1, 0, 0), date(2024, 1, 13, 0, 0)},
+		{"other time zone", "@daily", time.Date(2024, 1, 1, 1, 30, 0, 0, time.FixedZone("CEST", 2*60*60)), date(2024, 1, 1, 0, 0)},
+		{"never", "0 0 31 2 *", date(2024, 1, 1, 0, 0), time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := s.Next(tt.t)
+			if !got.Equal(tt.want) {
+				t.Errorf("Parse(%q).Next(%v) = %v; want %v", tt.expr, tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDaily(t *testing.T) {
+	s := Daily(6*time.Hour + 30*time.Minute)
+
+	if s.String() != "30 6 * * *" {
+		t.Errorf("Daily(6h30m) = %q; want %q", s, "30 6 * * *")
+	}
+
+	want := time.Date(2024, 1, 2, 6, 30, 0, 0, time.UTC)
+	if got := s.Next(time.Date(2024, 1, 1, 7, 0, 0, 0, time.UTC)); !got.Equal(want) {
+		t.Errorf("Next = %v; want %v", got, want)
+	}
+}
+
+func TestScheduleNext_zero(t *testing.T) {
+	var s Schedule
+
+	if !s.IsZero() {
+		t.Error("schedule that was not parsed is not zero")
+	}
+
+	if got := s.Next(time.Now()); !got.IsZero() {
+		t.Errorf("Next = %v; want the zero time", got)
+	}
+}
@@ -16,14 +16,18 @@ var (
 
 // An Account is registered to a research subject.
 type Account struct {
-	ID                 uint                  `json:"id"`
-	Campaign           campaign.Campaign     `json:"campaign"`
-	ActivatedAt        *needforheat.Time     `json:"activated_at"`
-	InvitationToken    string                `json:"invitation_token,omitempty"`
-	InvitationURL      string                `json:"invitation_url,omitempty"`
-	AuthorizationToken string                `json:"authorization_token,omitempty"`
-	Devices            []*device.Device      `json:"devices,omitempty"`
-	CloudFeeds         []cloudfeed.CloudFeed `json:"cloud_feeds,omitempty"`
+	ID                 uint              `json:"id"`
+	Campaign           campaign.Campaign `json:"campaign"`
+	ActivatedAt        *needforheat.Time `json:"activated_at"`
+	InvitationToken    string            `json:"invitation_token,omitempty"`
+	InvitationURL      string            `json:"invitation_url,omitempty"`
+	AuthorizationToken string            `json:"authorization_token,omitempty"`
+	// Expiry of the authorization token and the refresh token used to get the next one.
+	AuthorizationTokenExpiresAt *needforheat.Time     `json:"authorization_token_expires_at,omitempty"`
+	RefreshToken                string                `json:"refresh_token,omitempty"`
+	RefreshTokenExpiresAt       *needforheat.Time     `json:"refresh_token_expires_at,omitempty"`
+	Devices                     []*device.Device      `json:"devices,omitempty"`
+	CloudFeeds                  []cloudfeed.CloudFeed `json:"cloud_feeds,omitempty"`
 	// Maybe use separate pseudonym field,
 	// but right now we can derive a pseudonym
 	// using the ID or the campaign ID + account ID.
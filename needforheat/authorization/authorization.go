@@ -2,6 +2,8 @@ package authorization
 
 import (
 	"crypto"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"strconv"
 	"time"
@@ -22,7 +24,11 @@ const (
 	AccountToken           AuthKind = "accountToken"
 	DeviceToken            AuthKind = "deviceToken"
 	AccountActivationToken AuthKind = "accountActivationToken"
-	InvalidToken           AuthKind = "invalidToken"
+	// Used by a researcher to read the data of the campaigns they are bound to.
+	ResearcherToken AuthKind = "researcherToken"
+	// Used as the OAuth state parameter when an account authorizes a cloud feed.
+	CloudFeedStateToken AuthKind = "cloudFeedStateToken"
+	InvalidToken        AuthKind = "invalidToken"
 )
 
 // An Authorization is used to check for permissions.
@@ -30,12 +36,15 @@ type Authorization struct {
 	Kind   AuthKind
 	ID     uint
 	Claims *Claims
+	// Scopes of the token. Tokens that were issued before tokens had scopes have the scopes implied by their kind.
+	Scopes Scopes
 }
 
 // Claims contained in the JWT.
 type Claims struct {
 	jwt.RegisteredClaims
-	Kind AuthKind `json:"kind"`
+	Kind   AuthKind `json:"kind"`
+	Scopes Scopes   `json:"scope,omitempty"`
 }
 
 func ParseTokenToAuthorization(tokenString string, pubkey crypto.PublicKey) (*Authorization, error) {
@@ -44,11 +53,22 @@ func ParseTokenToAuthorization(tokenString string, pubkey crypto.PublicKey) (*Au
 		return nil, err
 	}
 
+	return makeAuthorization(kind, id, claims), nil
+}
+
+// Create an Authorization from the claims of a parsed token.
+func makeAuthorization(kind AuthKind, id uint, claims *Claims) *Authorization {
+	scopes := claims.Scopes
+	if len(scopes) == 0 {
+		scopes = ImpliedScopes(kind)
+	}
+
 	return &Authorization{
 		Kind:   kind,
 		ID:     id,
 		Claims: claims,
-	}, nil
+		Scopes: scopes,
+	}
 }
 
 // Returns if the Authorization is of the specified kind.
@@ -56,28 +76,66 @@ func (a *Authorization) IsKind(kind AuthKind) bool {
 	return a.Kind == kind
 }
 
+// Returns if the Authorization has the required scope, for a request on the campaign with campaignID.
+// CampaignID is 0 if the request is not for a campaign.
+func (a *Authorization) HasScope(required Scope, campaignID uint) bool {
+	return a.Scopes.Grants(required, campaignID)
+}
+
 // Create a new token of a specified kind, for specified ID.
 func NewToken(kind AuthKind, id uint, expiry time.Time, key crypto.PrivateKey) (string, error) {
+	claims, err := NewClaims(kind, id, expiry)
+	if err != nil {
+		return "", err
+	}
+
+	return SignClaims(claims, key)
+}
+
+// Create the claims of a new token of a specified kind, for specified ID.
+// Every token gets a unique random ID. Tokens without scopes get the scopes implied by their kind.
+func NewClaims(kind AuthKind, id uint, expiry time.Time, scopes ...Scope) (Claims, error) {
 	if expiry.IsZero() {
 		expiry = time.Now().UTC().Add(time.Hour * 24 * 365)
 	}
 
-	claims := Claims{
+	tokenID, err := newTokenID()
+	if err != nil {
+		return Claims{}, err
+	}
+
+	return Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    "NeedForHeatAPIv3",
 			Subject:   strconv.FormatUint(uint64(id), 10),
 			ExpiresAt: jwt.NewNumericDate(expiry),
 			NotBefore: jwt.NewNumericDate(time.Now().UTC()),
 			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ID:        tokenID,
 		},
-		Kind: kind,
-	}
+		Kind:   kind,
+		Scopes: scopes,
+	}, nil
+}
 
+// Sign claims to create a token.
+func SignClaims(claims Claims, key crypto.PrivateKey) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
 
 	return token.SignedString(key)
 }
 
+// Generate a random token ID.
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
 // Create a new token from an Authorization.
 func NewTokenFromAuthorization(auth Authorization, expiry time.Time, key crypto.PrivateKey) (string, error) {
 	return NewToken(auth.Kind, auth.ID, expiry, key)
@@ -85,11 +143,18 @@ func NewTokenFromAuthorization(auth Authorization, expiry time.Time, key crypto.
 
 // Parse a signed token. Check if it is valid and return the kind of token and the corresponding ID.
 func ParseToken(tokenString string, pubkey crypto.PublicKey) (AuthKind, uint, *Claims, error) {
+	return parseToken(tokenString, func(t *jwt.Token) (interface{}, error) {
+		return pubkey, nil
+	})
+}
+
+// Parse a signed token, using keyFunc to get the key to verify it with.
+func parseToken(tokenString string, keyFunc jwt.Keyfunc) (AuthKind, uint, *Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(t *jwt.Token) (interface{}, error) {
 		if _, ok := t.Method.(*jwt.SigningMethodECDSA); !ok {
 			return nil, ErrInvalidSigningMethod
 		}
-		return pubkey, nil
+		return keyFunc(t)
 	})
 	if err != nil {
 		return InvalidToken, 0, &Claims{}, err
@@ -0,0 +1,241 @@
+package authorization
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	ErrInvalidKey       = errors.New("signing key is not a P-256 ECDSA key")
+	ErrEmptyKeySet      = errors.New("key set does not contain keys")
+	ErrUnknownKey       = errors.New("unknown signing key")
+	ErrRemoveSigningKey = errors.New("the current signing key can not be removed")
+	errNoKeyID          = errors.New("token does not have a key ID")
+)
+
+// A SigningKey is a key used to sign and verify tokens.
+type SigningKey struct {
+	// ID of the key, used as the kid header of tokens.
+	// It is the JWK thumbprint (RFC 7638) of the public key.
+	ID        string
+	CreatedAt time.Time
+	Key       *ecdsa.PrivateKey
+}
+
+// Create a new SigningKey from a private key.
+func MakeSigningKey(key *ecdsa.PrivateKey, createdAt time.Time) (SigningKey, error) {
+	if key.Curve != elliptic.P256() {
+		return SigningKey{}, ErrInvalidKey
+	}
+
+	jwk, err := makeJWK("", &key.PublicKey)
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	// Members of the thumbprint are in lexicographic order.
+	thumbprint := sha256.Sum256([]byte(`{"crv":"` + jwk.Curve + `","kty":"` + jwk.KeyType + `","x":"` + jwk.X + `","y":"` + jwk.Y + `"}`))
+
+	return SigningKey{
+		ID:        base64.RawURLEncoding.EncodeToString(thumbprint[:]),
+		CreatedAt: createdAt,
+		Key:       key,
+	}, nil
+}
+
+// Generate a new SigningKey.
+func GenerateSigningKey() (SigningKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	return MakeSigningKey(key, time.Now().UTC())
+}
+
+// A KeySet contains the keys used to sign and verify tokens.
+// The newest key is the signing key. Older keys are retired: they are only used to verify tokens
+// that were signed before a rotation, so these tokens can still be used until the key is removed.
+type KeySet struct {
+	mu   sync.RWMutex
+	keys []SigningKey
+}
+
+// Create a new KeySet. The last key is the signing key.
+func NewKeySet(keys ...SigningKey) (*KeySet, error) {
+	if len(keys) == 0 {
+		return nil, ErrEmptyKeySet
+	}
+
+	return &KeySet{
+		keys: append([]SigningKey(nil), keys...),
+	}, nil
+}
+
+// Get all keys. The last key is the signing key.
+func (s *KeySet) Keys() []SigningKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]SigningKey(nil), s.keys...)
+}
+
+// Get the key that is used to sign new tokens.
+func (s *KeySet) SigningKey() SigningKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.keys[len(s.keys)-1]
+}
+
+// Add a key, which becomes the signing key. The previous signing key is retired.
+func (s *KeySet) Add(key SigningKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys = append(s.keys, key)
+}
+
+// Remove a retired key. Tokens that were signed with it can not be used anymore.
+func (s *KeySet) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, key := range s.keys {
+		if key.ID != id {
+			continue
+		}
+
+		if i == len(s.keys)-1 {
+			return ErrRemoveSigningKey
+		}
+
+		s.keys = append(s.keys[:i:i], s.keys[i+1:]...)
+		return nil
+	}
+
+	return ErrUnknownKey
+}
+
+// Sign claims with the signing key to create a token.
+// The ID of the key is added as the kid header.
+func (s *KeySet) Sign(claims Claims) (string, error) {
+	key := s.SigningKey()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(key.Key)
+}
+
+// Parse a signed token. Check if it is valid and return the kind of token and the corresponding ID.
+// The token is verified with the key in its kid header.
+// Tokens that were signed before keys had an ID are verified with every key.
+func (s *KeySet) ParseToken(tokenString string) (AuthKind, uint, *Claims, error) {
+	keys := s.Keys()
+
+	kind, id, claims, err := parseToken(tokenString, func(t *jwt.Token) (interface{}, error) {
+		kid, ok := t.Header["kid"].(string)
+		if !ok {
+			return nil, errNoKeyID
+		}
+
+		for _, key := range keys {
+			if key.ID == kid {
+				return key.Key.Public(), nil
+			}
+		}
+
+		return nil, ErrUnknownKey
+	})
+	if !errors.Is(err, errNoKeyID) {
+		return kind, id, claims, err
+	}
+
+	// Try the newest key first, since it is most likely to have signed the token.
+	for i := len(keys) - 1; i >= 0; i-- {
+		kind, id, claims, err = ParseToken(tokenString, keys[i].Key.Public())
+		if err == nil {
+			break
+		}
+	}
+
+	return kind, id, claims, err
+}
+
+// Parse a signed token to an Authorization.
+func (s *KeySet) ParseTokenToAuthorization(tokenString string) (*Authorization, error) {
+	kind, id, claims, err := s.ParseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	return makeAuthorization(kind, id, claims), nil
+}
+
+// A JWK is the public part of a signing key, as a JSON Web Key (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	Y         string `json:"y"`
+	KeyID     string `json:"kid,omitempty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+}
+
+// A JWKS is a JSON Web Key Set, which can be used by other services to verify tokens.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Get the public keys of all keys in the set, which can be used to verify tokens.
+func (s *KeySet) JWKS() (JWKS, error) {
+	keys := s.Keys()
+
+	jwks := JWKS{
+		Keys: make([]JWK, 0, len(keys)),
+	}
+
+	// List the signing key first.
+	for i := len(keys) - 1; i >= 0; i-- {
+		jwk, err := makeJWK(keys[i].ID, &keys[i].Key.PublicKey)
+		if err != nil {
+			return JWKS{}, err
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks, nil
+}
+
+// Create a JWK from a P-256 public key.
+func makeJWK(id string, key *ecdsa.PublicKey) (JWK, error) {
+	ecdhKey, err := key.ECDH()
+	if err != nil {
+		return JWK{}, err
+	}
+
+	// The uncompressed point is 0x04, followed by the X and Y coordinates.
+	point := ecdhKey.Bytes()
+	size := (len(point) - 1) / 2
+
+	return JWK{
+		KeyType:   "EC",
+		Curve:     "P-256",
+		X:         base64.RawURLEncoding.EncodeToString(point[1 : 1+size]),
+		Y:         base64.RawURLEncoding.EncodeToString(point[1+size:]),
+		KeyID:     id,
+		Use:       "sig",
+		Algorithm: "ES256",
+	}, nil
+}
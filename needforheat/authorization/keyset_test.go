@@ -0,0 +1,214 @@
+package authorization
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// generateKeys generates n signing keys.
+func generateKeys(t *testing.T, n int) []SigningKey {
+	t.Helper()
+
+	keys := make([]SigningKey, n)
+	for i := range keys {
+		key, err := GenerateSigningKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = key
+	}
+
+	return keys
+}
+
+// newTestClaims creates the claims of an account token that expires in an hour.
+func newTestClaims(t *testing.T) Claims {
+	t.Helper()
+
+	claims, err := NewClaims(AccountToken, 1, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return claims
+}
+
+func TestKeySetParseToken_retiredKey(t *testing.T) {
+	keys := generateKeys(t, 2)
+
+	set, err := NewKeySet(keys[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokenString, err := set.Sign(newTestClaims(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotating retires the key the token was signed with.
+	set.Add(keys[1])
+
+	if id := set.SigningKey().ID; id != keys[1].ID {
+		t.Fatalf("signing key is %s after rotation, want %s", id, keys[1].ID)
+	}
+
+	kind, id, _, err := set.ParseToken(tokenString)
+	if err != nil {
+		t.Fatalf("token signed with a retired key is rejected: %v", err)
+	}
+
+	if kind != AccountToken || id != 1 {
+		t.Errorf("got %s %d, want %s 1", kind, id, AccountToken)
+	}
+
+	// After the retired key is removed, the token can not be used anymore.
+	err = set.Remove(keys[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, _, err = set.ParseToken(tokenString)
+	if !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("got error %v after removing the key, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestKeySetParseToken_unknownKey(t *testing.T) {
+	keys := generateKeys(t, 2)
+
+	other, err := NewKeySet(keys[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokenString, err := other.Sign(newTestClaims(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := NewKeySet(keys[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, _, err = set.ParseToken(tokenString)
+	if !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("got error %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestKeySetParseToken_withoutKeyID(t *testing.T) {
+	keys := generateKeys(t, 3)
+
+	// A token that was signed before keys had an ID, with a key that is now retired.
+	tokenString, err := SignClaims(newTestClaims(t), keys[0].Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := NewKeySet(keys[0], keys[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, id, _, err := set.ParseToken(tokenString)
+	if err != nil {
+		t.Fatalf("token without key ID is rejected: %v", err)
+	}
+
+	if id != 1 {
+		t.Errorf("got ID %d, want 1", id)
+	}
+
+	// The token is rejected if no key in the set signed it.
+	set, err = NewKeySet(keys[1], keys[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, _, err = set.ParseToken(tokenString)
+	if err == nil {
+		t.Error("token without key ID is accepted by keys that did not sign it")
+	}
+}
+
+func TestKeySetRemove(t *testing.T) {
+	keys := generateKeys(t, 2)
+
+	set, err := NewKeySet(keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = set.Remove(keys[1].ID)
+	if !errors.Is(err, ErrRemoveSigningKey) {
+		t.Errorf("got error %v removing the signing key, want %v", err, ErrRemoveSigningKey)
+	}
+
+	err = set.Remove("unknown")
+	if !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("got error %v removing an unknown key, want %v", err, ErrUnknownKey)
+	}
+
+	err = set.Remove(keys[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := set.Keys(); len(got) != 1 || got[0].ID != keys[1].ID {
+		t.Errorf("got keys %v after removing the retired key, want only %s", got, keys[1].ID)
+	}
+}
+
+func TestMakeSigningKey_thumbprint(t *testing.T) {
+	// The P-256 key of RFC 7517, appendix A.1 and A.2.
+	// Its thumbprint is computed as described in RFC 7638, section 3.
+	decode := func(s string) *big.Int {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return new(big.Int).SetBytes(b)
+	}
+
+	key := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     decode("MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4"),
+			Y:     decode("4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyM"),
+		},
+		D: decode("870MB6gfuTJ4HtUnUvYMyJpr5eUZNP4Bk43bVdj3eAE"),
+	}
+
+	signingKey, err := MakeSigningKey(key, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "cn-I_WNMClehiVp51i_0VpOENW1upEerA8sEam5hn-s"
+	if signingKey.ID != want {
+		t.Errorf("got thumbprint %s, want %s", signingKey.ID, want)
+	}
+
+	// The key can sign and verify tokens.
+	set, err := NewKeySet(signingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokenString, err := set.Sign(newTestClaims(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, _, err = set.ParseToken(tokenString)
+	if err != nil {
+		t.Errorf("token signed with the key is rejected: %v", err)
+	}
+}
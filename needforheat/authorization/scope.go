@@ -0,0 +1,134 @@
+package authorization
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// A Scope is a permission of a token, like "measurements:read".
+//
+// A scope can be limited to one campaign by appending "@" and the ID of the campaign,
+// like "campaign:read@3". A limited scope only grants access to routes of that campaign.
+type Scope string
+
+const (
+	// Manage apps, campaigns, device types, properties and other resources shared by all campaigns.
+	ScopeAdmin Scope = "admin"
+	// Manage a campaign, like its retention policy. Implies [ScopeCampaignRead].
+	ScopeCampaignAdmin Scope = "campaign:admin"
+	// Read a campaign and export its measurements.
+	ScopeCampaignRead Scope = "campaign:read"
+	// Manage an account, its devices, energy queries and cloud feeds.
+	ScopeAccount Scope = "account"
+	// Activate an account.
+	ScopeAccountActivate Scope = "account:activate"
+	// Read the measurements of an account.
+	ScopeMeasurementsRead Scope = "measurements:read"
+	// Upload measurements.
+	ScopeUploadWrite Scope = "upload:write"
+)
+
+// Scopes that are granted by another scope.
+var impliedScopes = map[Scope]Scope{
+	ScopeAdmin:         ScopeCampaignAdmin,
+	ScopeCampaignAdmin: ScopeCampaignRead,
+}
+
+// Get the scopes of tokens that were issued before tokens had scopes.
+func ImpliedScopes(kind AuthKind) Scopes {
+	switch kind {
+	case AdminToken:
+		return Scopes{ScopeAdmin}
+	case AccountToken:
+		return Scopes{ScopeAccount, ScopeMeasurementsRead, ScopeUploadWrite}
+	case DeviceToken:
+		return Scopes{ScopeUploadWrite}
+	case AccountActivationToken:
+		return Scopes{ScopeAccountActivate}
+	default:
+		return nil
+	}
+}
+
+// Limit the scope to the campaign with campaignID.
+func (s Scope) ForCampaign(campaignID uint) Scope {
+	return Scope(string(s.base()) + "@" + strconv.FormatUint(uint64(campaignID), 10))
+}
+
+// Get the scope without its campaign.
+func (s Scope) base() Scope {
+	base, _, _ := strings.Cut(string(s), "@")
+	return Scope(base)
+}
+
+// Get the campaign the scope is limited to. Ok is false if the scope is limited to an invalid campaign.
+func (s Scope) campaign() (campaignID uint, ok bool) {
+	_, campaign, limited := strings.Cut(string(s), "@")
+	if !limited {
+		return 0, true
+	}
+
+	id, err := strconv.ParseUint(campaign, 10, 64)
+	if err != nil || id == 0 {
+		return 0, false
+	}
+
+	return uint(id), true
+}
+
+// Returns if the scope grants the required scope, for a request on the campaign with campaignID.
+// CampaignID is 0 if the request is not for a campaign.
+func (s Scope) Grants(required Scope, campaignID uint) bool {
+	limit, ok := s.campaign()
+	if !ok || (limit != 0 && limit != campaignID) {
+		return false
+	}
+
+	for scope := s.base(); scope != ""; scope = impliedScopes[scope] {
+		if scope == required {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Scopes is a list of scopes.
+// In a token, it is the "scope" claim: a string of scopes separated by spaces.
+type Scopes []Scope
+
+// Returns if any of the scopes grants the required scope, for a request on the campaign with campaignID.
+func (s Scopes) Grants(required Scope, campaignID uint) bool {
+	for _, scope := range s {
+		if scope.Grants(required, campaignID) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s Scopes) MarshalJSON() ([]byte, error) {
+	scopes := make([]string, len(s))
+	for i, scope := range s {
+		scopes[i] = string(scope)
+	}
+
+	return json.Marshal(strings.Join(scopes, " "))
+}
+
+func (s *Scopes) UnmarshalJSON(data []byte) error {
+	var scopes string
+	err := json.Unmarshal(data, &scopes)
+	if err != nil {
+		return err
+	}
+
+	*s = nil
+	for _, scope := range strings.Fields(scopes) {
+		*s = append(*s, Scope(scope))
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package authorization
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestScopeGrants(t *testing.T) {
+	tests := []struct {
+		scope      Scope
+		required   Scope
+		campaignID uint
+
+		want bool
+	}{
+		{ScopeAdmin, ScopeAdmin, 0, true},
+		{ScopeAdmin, ScopeCampaignAdmin, 1, true},
+		{ScopeAdmin, ScopeCampaignRead, 1, true},
+		{ScopeAdmin, ScopeAccount, 0, false},
+		{ScopeCampaignAdmin, ScopeCampaignAdmin, 1, true},
+		{ScopeCampaignAdmin, ScopeCampaignRead, 1, true},
+		{ScopeCampaignAdmin, ScopeAdmin, 0, false},
+		{ScopeCampaignRead, ScopeCampaignRead, 1, true},
+		{ScopeCampaignRead, ScopeCampaignAdmin, 1, false},
+		{ScopeCampaignRead.ForCampaign(1), ScopeCampaignRead, 1, true},
+		{ScopeCampaignRead.ForCampaign(1), ScopeCampaignRead, 2, false},
+		{ScopeCampaignRead.ForCampaign(1), ScopeCampaignAdmin, 1, false},
+		{ScopeCampaignRead.ForCampaign(1), ScopeCampaignRead, 0, false},
+		{ScopeCampaignAdmin.ForCampaign(1), ScopeCampaignAdmin, 1, true},
+		{ScopeCampaignAdmin.ForCampaign(1), ScopeCampaignRead, 1, true},
+		{ScopeCampaignAdmin.ForCampaign(1), ScopeCampaignAdmin, 2, false},
+		{ScopeCampaignAdmin.ForCampaign(1), ScopeAdmin, 1, false},
+		{ScopeAccount, ScopeAccount, 0, true},
+		{ScopeAccount, ScopeMeasurementsRead, 0, false},
+		{ScopeUploadWrite, ScopeUploadWrite, 0, true},
+		{"campaign:read@", ScopeCampaignRead, 0, false},
+		{"campaign:read@0", ScopeCampaignRead, 0, false},
+		{"campaign:read@x", ScopeCampaignRead, 1, false},
+		{"", ScopeCampaignRead, 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.scope)+" "+string(tt.required), func(t *testing.T) {
+			got := tt.scope.Grants(tt.required, tt.campaignID)
+			if got != tt.want {
+				t.Errorf("%q.Grants(%q, %d) = %t, want %t", tt.scope, tt.required, tt.campaignID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopesGrants(t *testing.T) {
+	scopes := Scopes{ScopeCampaignRead.ForCampaign(1), ScopeCampaignAdmin.ForCampaign(2)}
+
+	if !scopes.Grants(ScopeCampaignRead, 1) || !scopes.Grants(ScopeCampaignAdmin, 2) {
+		t.Error("scopes do not grant the scopes of their campaigns")
+	}
+
+	if scopes.Grants(ScopeCampaignAdmin, 1) || scopes.Grants(ScopeCampaignRead, 3) {
+		t.Error("scopes grant more than the scopes of their campaigns")
+	}
+
+	if (Scopes{}).Grants(ScopeCampaignRead, 1) {
+		t.Error("no scopes grant a scope")
+	}
+}
+
+func TestImpliedScopes(t *testing.T) {
+	tests := []struct {
+		kind AuthKind
+		want Scopes
+	}{
+		{AdminToken, Scopes{ScopeAdmin}},
+		{AccountToken, Scopes{ScopeAccount, ScopeMeasurementsRead, ScopeUploadWrite}},
+		{DeviceToken, Scopes{ScopeUploadWrite}},
+		{AccountActivationToken, Scopes{ScopeAccountActivate}},
+		{ResearcherToken, nil},
+		{CloudFeedStateToken, nil},
+		{InvalidToken, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.kind), func(t *testing.T) {
+			got := ImpliedScopes(tt.kind)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ImpliedScopes(%s) = %v, want %v", tt.kind, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopesJSON(t *testing.T) {
+	scopes := Scopes{ScopeAccount, ScopeCampaignRead.ForCampaign(3)}
+
+	b, err := json.Marshal(scopes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `"account campaign:read@3"`; string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+
+	var got Scopes
+	err = json.Unmarshal(b, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(got, scopes) {
+		t.Errorf("got %v, want %v", got, scopes)
+	}
+}
@@ -15,6 +15,12 @@ type CloudFeed struct {
 	Expiry          needforheat.Time  `json:"-"`
 	AuthGrantToken  string            `json:"auth_grant_token"`
 	ActivatedAt     *needforheat.Time `json:"activated_at"`
+	// Time at which refreshing the tokens started failing, if it is failing.
+	RefreshFailedAt *needforheat.Time `json:"-"`
+	// Error of the last failed refresh.
+	RefreshError string `json:"-"`
+	// Time at which the provider revoked the grant, e.g. when refreshing failed with "invalid_grant".
+	RevokedAt *needforheat.Time `json:"-"`
 }
 
 // Create a new CloudFeed.
@@ -31,3 +37,44 @@ func MakeCloudFeed(accountID, cloudFeedTypeID uint, accessToken string, refreshT
 		ActivatedAt:     &activatedAt,
 	}
 }
+
+// Record that refreshing the tokens failed with err.
+func (c *CloudFeed) RefreshFailed(err error) {
+	if c.RefreshFailedAt == nil {
+		now := needforheat.Time(time.Unix(time.Now().Unix(), 0))
+		c.RefreshFailedAt = &now
+	}
+	c.RefreshError = err.Error()
+}
+
+// Record that the tokens were refreshed successfully.
+func (c *CloudFeed) RefreshSucceeded() {
+	c.RefreshFailedAt = nil
+	c.RefreshError = ""
+}
+
+// Revoke the CloudFeed. The tokens are removed, since they can not be used anymore.
+func (c *CloudFeed) Revoke(err error) {
+	c.RefreshFailed(err)
+
+	now := needforheat.Time(time.Unix(time.Now().Unix(), 0))
+	c.RevokedAt = &now
+	c.AccessToken = ""
+	c.RefreshToken = ""
+}
+
+// Replace the tokens of the CloudFeed after it was authorized again.
+// A previous revocation or failed refresh is cleared.
+func (c *CloudFeed) ReplaceTokens(accessToken string, refreshToken string, expiry needforheat.Time, authGrantToken string) {
+	c.AccessToken = accessToken
+	c.RefreshToken = refreshToken
+	c.Expiry = expiry
+	c.AuthGrantToken = authGrantToken
+	c.RevokedAt = nil
+	c.RefreshSucceeded()
+}
+
+// Returns if the CloudFeed was revoked.
+func (c *CloudFeed) IsRevoked() bool {
+	return c.RevokedAt != nil
+}
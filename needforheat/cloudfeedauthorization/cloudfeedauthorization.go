@@ -0,0 +1,51 @@
+package cloudfeedauthorization
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat"
+)
+
+const (
+	// Time an account has to complete an authorization.
+	Lifetime = time.Minute * 15
+)
+
+// A CloudFeedAuthorization is an OAuth authorization of a cloud feed
+// that was started by an account, but was not completed yet.
+type CloudFeedAuthorization struct {
+	// Hash of the state parameter that was sent to the provider.
+	StateHash       string
+	AccountID       uint
+	CloudFeedTypeID uint
+	// PKCE code verifier, which is needed to exchange the authorization code.
+	Verifier string
+	// URL the participant is redirected to when the authorization is completed.
+	RedirectURL string
+	ExpiresAt   needforheat.Time
+}
+
+// Create a new CloudFeedAuthorization.
+func MakeCloudFeedAuthorization(state string, accountID, cloudFeedTypeID uint, verifier string, redirectURL string) CloudFeedAuthorization {
+	return CloudFeedAuthorization{
+		StateHash:       HashState(state),
+		AccountID:       accountID,
+		CloudFeedTypeID: cloudFeedTypeID,
+		Verifier:        verifier,
+		RedirectURL:     redirectURL,
+		ExpiresAt:       needforheat.Time(time.Now().Add(Lifetime).Truncate(time.Second)),
+	}
+}
+
+// HashState returns the hash of a state parameter, which is used to look up a CloudFeedAuthorization.
+func HashState(state string) string {
+	hash := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(hash[:])
+}
+
+// IsExpired returns true if the authorization can not be completed anymore.
+func (a CloudFeedAuthorization) IsExpired() bool {
+	return time.Now().After(time.Time(a.ExpiresAt))
+}
@@ -0,0 +1,9 @@
+package cloudfeedauthorization
+
+// A CloudFeedAuthorizationRepository can load, store and delete cloud feed authorizations.
+type CloudFeedAuthorizationRepository interface {
+	Create(CloudFeedAuthorization) (CloudFeedAuthorization, error)
+	// Find the authorization with stateHash and delete it, so it can only be used once.
+	Take(stateHash string) (CloudFeedAuthorization, error)
+	DeleteExpired() error
+}
@@ -0,0 +1,54 @@
+package cloudfeedrun
+
+import (
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat"
+)
+
+// A CloudFeedRun is a single attempt to download data from a CloudFeed.
+type CloudFeedRun struct {
+	ID              uint             `json:"id"`
+	AccountID       uint             `json:"account_id"`
+	CloudFeedTypeID uint             `json:"cloud_feed_id"`
+	StartPeriod     needforheat.Time `json:"start_period"`
+	EndPeriod       needforheat.Time `json:"end_period"`
+	StartedAt       needforheat.Time `json:"started_at"`
+	// Duration of the run in seconds.
+	Duration         float64 `json:"duration"`
+	MeasurementCount int     `json:"measurement_count"`
+	// ID of the upload that was created, if any.
+	UploadID *uint  `json:"upload_id"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Create a new CloudFeedRun that starts now.
+func MakeCloudFeedRun(accountID, cloudFeedTypeID uint, startPeriod, endPeriod needforheat.Time) CloudFeedRun {
+	return CloudFeedRun{
+		AccountID:       accountID,
+		CloudFeedTypeID: cloudFeedTypeID,
+		StartPeriod:     startPeriod,
+		EndPeriod:       endPeriod,
+		StartedAt:       needforheat.Time(time.Now().UTC()),
+	}
+}
+
+// Finish a run with its result.
+// UploadID should be 0 if no upload was created.
+func (r *CloudFeedRun) Finish(measurementCount int, uploadID uint, err error) {
+	r.Duration = time.Since(time.Time(r.StartedAt)).Seconds()
+	r.MeasurementCount = measurementCount
+
+	if uploadID != 0 {
+		r.UploadID = &uploadID
+	}
+
+	if err != nil {
+		r.Error = err.Error()
+	}
+}
+
+// Returns if the run finished without an error.
+func (r *CloudFeedRun) Succeeded() bool {
+	return r.Error == ""
+}
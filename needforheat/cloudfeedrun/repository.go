@@ -0,0 +1,12 @@
+package cloudfeedrun
+
+// A CloudFeedRunRepository can load and store CloudFeedRuns.
+type CloudFeedRunRepository interface {
+	// Get the latest runs of a CloudFeed. A limit of 0 returns all runs.
+	GetAllByCloudFeed(accountID uint, cloudFeedTypeID uint, limit int) ([]CloudFeedRun, error)
+	// Get the latest runs of all CloudFeeds. A limit of 0 returns all runs.
+	GetAll(failedOnly bool, limit int) ([]CloudFeedRun, error)
+	// Find the latest run of a CloudFeed, optionally only if it succeeded.
+	FindLatest(accountID uint, cloudFeedTypeID uint, succeededOnly bool) (CloudFeedRun, error)
+	Create(CloudFeedRun) (CloudFeedRun, error)
+}
@@ -1,25 +1,97 @@
 package cloudfeedstatus
 
 import (
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat"
 	"github.com/energietransitie/needforheat-server-api/needforheat/cloudfeed"
+	"github.com/energietransitie/needforheat-server-api/needforheat/cloudfeedrun"
 	"github.com/energietransitie/needforheat-server-api/needforheat/cloudfeedtype"
 )
 
-// A CloudFeedStatus contains all available cloud feeds for an account and if they are connected or not.
+// A token that expires within this duration is reported as expiring.
+// Tokens are normally refreshed well before this, so the refresh did not happen in time.
+const TokenExpiringDuration = 6 * time.Hour
+
+// State of the connection with a cloud feed.
+type State string
+
+const (
+	// The account did not authorize the cloud feed.
+	NotConnected State = "not_connected"
+	// The provider revoked the grant. The account has to authorize the cloud feed again.
+	Revoked State = "revoked"
+	// Refreshing the tokens is failing, but the grant was not revoked (yet).
+	RefreshFailing State = "refresh_failing"
+	// The access token expires soon or has expired.
+	TokenExpiring State = "token_expiring"
+	// The cloud feed is connected, but no data was downloaded successfully yet.
+	NeverDownloaded State = "never_downloaded"
+	// The cloud feed is connected and data was downloaded successfully.
+	Connected State = "connected"
+)
+
+// A CloudFeedStatus contains all available cloud feeds for an account and the state of their connection.
 type CloudFeedStatus struct {
 	CloudFeedType cloudfeedtype.CloudFeedType `json:"cloud_feed_type"`
-	Connected     bool                        `json:"connected"`
+	// Connected is true if the cloud feed is authorized and not revoked.
+	Connected              bool              `json:"connected"`
+	State                  State             `json:"state"`
+	TokenExpiry            *needforheat.Time `json:"token_expiry,omitempty"`
+	LastSuccessfulDownload *needforheat.Time `json:"last_successful_download"`
+	LastError              string            `json:"last_error,omitempty"`
 }
 
 // Create a new CloudFeedStatus.
-func MakeCloudFeedStatus(cloudFeedType cloudfeedtype.CloudFeedType, cloudFeed cloudfeed.CloudFeed) CloudFeedStatus {
-	// TODO: Change this to check if access and/or refresh token is valid.
-	// For now, we use the grant token, since we have not implemented functionality
-	// to exchange the  grant token for an access and refresh token.
-	connected := cloudFeed.AuthGrantToken != ""
-
-	return CloudFeedStatus{
+//
+// CloudFeed is the zero value if the account did not authorize the cloud feed.
+// LatestRun and latestSuccessfulRun are nil if there were no (successful) runs.
+func MakeCloudFeedStatus(
+	cloudFeedType cloudfeedtype.CloudFeedType,
+	cloudFeed cloudfeed.CloudFeed,
+	latestRun *cloudfeedrun.CloudFeedRun,
+	latestSuccessfulRun *cloudfeedrun.CloudFeedRun,
+) CloudFeedStatus {
+	status := CloudFeedStatus{
 		CloudFeedType: cloudFeedType,
-		Connected:     connected,
 	}
+
+	if cloudFeed.AccountID == 0 {
+		status.State = NotConnected
+		return status
+	}
+
+	if latestSuccessfulRun != nil {
+		status.LastSuccessfulDownload = &latestSuccessfulRun.StartedAt
+	}
+
+	if latestRun != nil && !latestRun.Succeeded() {
+		status.LastError = latestRun.Error
+	}
+
+	if cloudFeed.RefreshError != "" {
+		status.LastError = cloudFeed.RefreshError
+	}
+
+	expiry := time.Time(cloudFeed.Expiry)
+	if !expiry.IsZero() {
+		status.TokenExpiry = &cloudFeed.Expiry
+	}
+
+	switch {
+	case cloudFeed.IsRevoked():
+		status.State = Revoked
+	case cloudFeed.RefreshFailedAt != nil:
+		status.State = RefreshFailing
+	case !expiry.IsZero() && time.Until(expiry) < TokenExpiringDuration:
+		status.State = TokenExpiring
+	case latestSuccessfulRun == nil:
+		status.State = NeverDownloaded
+	default:
+		status.State = Connected
+	}
+
+	status.Connected = status.State != Revoked
+
+	return status
 }
@@ -1,22 +1,31 @@
 package cloudfeedtype
 
-import "github.com/energietransitie/needforheat-server-api/needforheat/cloudfeed"
+import (
+	"github.com/energietransitie/needforheat-server-api/needforheat"
+	"github.com/energietransitie/needforheat-server-api/needforheat/cloudfeed"
+)
 
 // A CloudFeedType is an external online data source.
 type CloudFeedType struct {
-	ID               uint                  `json:"id"`
-	Name             string                `json:"name"`
-	AuthorizationURL string                `json:"authorization_url"`
-	TokenURL         string                `json:"token_url"`
-	ClientID         string                `json:"client_id"`
-	ClientSecret     string                `json:"client_secret,omitempty"`
-	Scope            string                `json:"scope"`
-	RedirectURL      string                `json:"redirect_url"`
-	CloudFeeds       []cloudfeed.CloudFeed `json:"-"`
+	ID               uint   `json:"id"`
+	Name             string `json:"name"`
+	AuthorizationURL string `json:"authorization_url"`
+	TokenURL         string `json:"token_url"`
+	ClientID         string `json:"client_id"`
+	ClientSecret     string `json:"client_secret,omitempty"`
+	Scope            string `json:"scope"`
+	RedirectURL      string `json:"redirect_url"`
+	// OAuth token revocation endpoint (RFC 7009). Tokens are not revoked if it is empty.
+	RevocationURL string                `json:"revocation_url"`
+	CloudFeeds    []cloudfeed.CloudFeed `json:"-"`
+	// Cron-like schedule on which data is downloaded. The default schedule is used if it is empty.
+	DownloadSchedule string `json:"download_schedule"`
+	// Time at which data will be downloaded next.
+	NextDownloadAt *needforheat.Time `json:"next_download_at"`
 }
 
 // Create a new CloudFeedType.
-func MakeCloudFeedType(name, authorizationURL, tokenURL, clientID, clientSecret, scope, redirectURL string) CloudFeedType {
+func MakeCloudFeedType(name, authorizationURL, tokenURL, clientID, clientSecret, scope, redirectURL, revocationURL, downloadSchedule string) CloudFeedType {
 	return CloudFeedType{
 		Name:             name,
 		AuthorizationURL: authorizationURL,
@@ -25,5 +34,7 @@ func MakeCloudFeedType(name, authorizationURL, tokenURL, clientID, clientSecret,
 		ClientSecret:     clientSecret,
 		Scope:            scope,
 		RedirectURL:      redirectURL,
+		RevocationURL:    revocationURL,
+		DownloadSchedule: downloadSchedule,
 	}
 }
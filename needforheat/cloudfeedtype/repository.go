@@ -1,9 +1,13 @@
 package cloudfeedtype
 
+import "github.com/energietransitie/needforheat-server-api/needforheat"
+
 // A CloudFeedTypeRepository can load, store and delete cloud feeds.
 type CloudFeedTypeRepository interface {
 	Find(CloudFeedType) (CloudFeedType, error)
 	GetAll() ([]CloudFeedType, error)
 	Create(CloudFeedType) (CloudFeedType, error)
+	Update(CloudFeedType) (CloudFeedType, error)
+	ClaimDownload(cloudFeedType CloudFeedType, nextDownloadAt needforheat.Time) (bool, error)
 	Delete(CloudFeedType) error
 }
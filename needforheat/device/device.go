@@ -27,6 +27,8 @@ type Device struct {
 	AuthorizationToken   string                `json:"authorization_token,omitempty"`
 	Uploads              []upload.Upload       `json:"uploads,omitempty"`
 	LatestUpload         *needforheat.Time     `json:"latest_upload,omitempty"`
+	// Clock skew settings of the device. Fields that are not set use the defaults of the server.
+	Clock upload.ClockSettings `json:"clock"`
 }
 
 // Create a new Device.
@@ -1,17 +1,26 @@
 package device
 
 import (
+	"context"
+
 	"github.com/energietransitie/needforheat-server-api/needforheat"
 	"github.com/energietransitie/needforheat-server-api/needforheat/measurement"
 	"github.com/energietransitie/needforheat-server-api/needforheat/property"
+	"github.com/energietransitie/needforheat-server-api/needforheat/upload"
 )
 
 // A DeviceRepository can load, store and delete devices.
 type DeviceRepository interface {
 	Find(device Device) (Device, error)
 	FindCloudFeedAuthCreationTimeFromDeviceID(deviceID uint) (*needforheat.Time, error)
+	FindClockSettings(deviceID uint) (upload.ClockSettings, error)
+	UpdateClockSettings(deviceID uint, settings upload.ClockSettings) error
 	GetProperties(device Device) ([]property.Property, error)
 	GetMeasurements(device Device, filters map[string]string) ([]measurement.Measurement, error)
+	GetMeasurementsPage(device Device, filters map[string]string, page measurement.Page) ([]measurement.Measurement, *measurement.Cursor, error)
+	StreamMeasurements(ctx context.Context, device Device, filters map[string]string, fn func(measurement.Measurement) error) error
+	GetAggregatedMeasurements(device Device, filters map[string]string, aggregation measurement.Aggregation) ([]measurement.Series, error)
+	GetMeasurementCountsPerDay(device Device, start, end needforheat.Time) ([]measurement.DayCount, error)
 	GetAll() ([]Device, error)
 	Create(Device) (Device, error)
 	Update(Device) (Device, error)
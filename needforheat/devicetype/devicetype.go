@@ -1,9 +1,24 @@
 package devicetype
 
+import (
+	"errors"
+	"fmt"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat/property"
+)
+
+var (
+	ErrPropertyNotAllowed = errors.New("property is not allowed for the device type")
+)
+
 // A DeviceType contains information about a group of devices with the same functionality.
 type DeviceType struct {
 	ID   uint   `json:"id"`
 	Name string `json:"name"`
+	// Devices of a strict device type can only upload measurements of the allowed properties.
+	Strict bool `json:"strict"`
+	// Properties that are allowed in uploads of devices of a strict device type.
+	Properties []property.Property `json:"properties,omitempty"`
 }
 
 // Create a new DeviceType.
@@ -12,3 +27,18 @@ func MakeDeviceType(name string) DeviceType {
 		Name: name,
 	}
 }
+
+// Check if devices of the device type are allowed to upload measurements of p.
+func (d DeviceType) Allows(p property.Property) error {
+	if !d.Strict {
+		return nil
+	}
+
+	for _, allowed := range d.Properties {
+		if allowed.ID == p.ID {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s is not allowed for %s", ErrPropertyNotAllowed, p.Name, d.Name)
+}
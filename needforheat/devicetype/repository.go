@@ -5,5 +5,9 @@ type DeviceTypeRepository interface {
 	Find(deviceType DeviceType) (DeviceType, error)
 	GetAll() ([]DeviceType, error)
 	Create(DeviceType) (DeviceType, error)
+	// Update if the device type is strict and the properties it allows.
+	Update(DeviceType) (DeviceType, error)
+	// Find the device type of the device with deviceID, including the properties it allows.
+	FindByDeviceID(deviceID uint) (DeviceType, error)
 	Delete(DeviceType) error
 }
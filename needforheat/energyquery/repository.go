@@ -1,6 +1,8 @@
 package energyquery
 
 import (
+	"context"
+
 	"github.com/energietransitie/needforheat-server-api/needforheat/measurement"
 	"github.com/energietransitie/needforheat-server-api/needforheat/property"
 )
@@ -10,6 +12,8 @@ type EnergyQueryRepository interface {
 	Find(energyQuery EnergyQuery) (EnergyQuery, error)
 	GetProperties(energyQuery EnergyQuery) ([]property.Property, error)
 	GetMeasurements(EnergyQuery EnergyQuery, filters map[string]string) ([]measurement.Measurement, error)
+	GetMeasurementsPage(EnergyQuery EnergyQuery, filters map[string]string, page measurement.Page) ([]measurement.Measurement, *measurement.Cursor, error)
+	StreamMeasurements(ctx context.Context, EnergyQuery EnergyQuery, filters map[string]string, fn func(measurement.Measurement) error) error
 	GetAll() ([]EnergyQuery, error)
 	Create(EnergyQuery) (EnergyQuery, error)
 	Update(EnergyQuery) (EnergyQuery, error)
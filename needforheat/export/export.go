@@ -0,0 +1,147 @@
+// Package export writes the measurements of a campaign in formats used for research.
+package export
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown export format")
+	ErrInvalidTime   = errors.New("invalid time")
+)
+
+// A Filter selects the measurements of a campaign that are exported.
+type Filter struct {
+	CampaignID uint
+	// Only export measurements at or after Start, if it is set.
+	Start *time.Time
+	// Only export measurements at or before End, if it is set.
+	End *time.Time
+	// Only export measurements of these properties, if any are set.
+	Properties []string
+	// Only export raw measurements, without the aggregates of downsampled measurements.
+	Raw bool
+}
+
+// Create a Filter for the campaign with campaignID.
+// Start and end are parsed using [ParseTime] and are ignored if they are empty.
+// Properties can contain comma separated lists of property names.
+func MakeFilter(campaignID uint, start string, end string, properties []string) (Filter, error) {
+	filter := Filter{CampaignID: campaignID}
+
+	if start != "" {
+		t, err := ParseTime(start)
+		if err != nil {
+			return Filter{}, err
+		}
+		filter.Start = &t
+	}
+
+	if end != "" {
+		t, err := ParseTime(end)
+		if err != nil {
+			return Filter{}, err
+		}
+		filter.End = &t
+	}
+
+	for _, p := range properties {
+		for _, name := range strings.Split(p, ",") {
+			name = strings.TrimSpace(name)
+			if name != "" {
+				filter.Properties = append(filter.Properties, name)
+			}
+		}
+	}
+
+	return filter, nil
+}
+
+// A Record is a measurement of a campaign, as it is stored.
+type Record struct {
+	AccountID    uint
+	InstanceType string
+	// Name of the device, or ID of the energy query.
+	InstanceName string
+	// Name of the device type, or variety of the energy query type.
+	SourceType string
+	Property   string
+	Time       time.Time
+	Value      string
+}
+
+// A Row is an exported measurement in long format.
+// Account and instance are pseudonymised, so they can not be traced back to a participant.
+type Row struct {
+	Account      string    `parquet:"account,dict"`
+	InstanceType string    `parquet:"instance_type,dict"`
+	Instance     string    `parquet:"instance,dict"`
+	SourceType   string    `parquet:"source_type,dict"`
+	Property     string    `parquet:"property,dict"`
+	Time         time.Time `parquet:"time,timestamp(millisecond)"`
+	Value        string    `parquet:"value"`
+}
+
+// Create a Row from a Record, pseudonymising its account and instance using p.
+func MakeRow(record Record, p *Pseudonymizer) Row {
+	return Row{
+		Account:      p.Account(record.AccountID),
+		InstanceType: record.InstanceType,
+		Instance:     p.Instance(record.InstanceType, record.InstanceName),
+		SourceType:   record.SourceType,
+		Property:     record.Property,
+		Time:         record.Time.UTC(),
+		Value:        record.Value,
+	}
+}
+
+// A Format is a file format measurements can be exported in.
+type Format string
+
+const (
+	CSV     Format = "csv"
+	Parquet Format = "parquet"
+)
+
+// Parse a Format from s.
+func ParseFormat(s string) (Format, error) {
+	switch format := Format(s); format {
+	case CSV, Parquet:
+		return format, nil
+	}
+
+	return "", fmt.Errorf("%w: %q", ErrUnknownFormat, s)
+}
+
+// ContentType returns the media type of files in the format.
+func (f Format) ContentType() string {
+	switch f {
+	case Parquet:
+		return "application/vnd.apache.parquet"
+	default:
+		return "text/csv"
+	}
+}
+
+// Parse a time used in a [Filter]. It can be a unix timestamp in seconds,
+// a date (2006-01-02) or a time in RFC 3339 format. Dates are in UTC.
+func ParseTime(s string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %q should be a unix timestamp, a date or an RFC 3339 time", ErrInvalidTime, s)
+	}
+
+	return t, nil
+}
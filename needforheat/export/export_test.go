@@ -0,0 +1,119 @@
+package export
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		s    string
+		want time.Time
+	}{
+		{"1714229168", time.Date(2024, time.April, 27, 14, 46, 8, 0, time.UTC)},
+		{"0", time.Unix(0, 0).UTC()},
+		{"2024-04-27", time.Date(2024, time.April, 27, 0, 0, 0, 0, time.UTC)},
+		{"2024-04-27T14:46:08Z", time.Date(2024, time.April, 27, 14, 46, 8, 0, time.UTC)},
+		{"2024-04-27T16:46:08+02:00", time.Date(2024, time.April, 27, 14, 46, 8, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseTime(tt.s)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseTime(%q) = %v; want %v", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTime_invalid(t *testing.T) {
+	for _, s := range []string{"", "yesterday", "2024-13-01", "27-04-2024", "2024-04-27 14:46:08", "1714229168.5"} {
+		t.Run(s, func(t *testing.T) {
+			_, err := ParseTime(s)
+			if !errors.Is(err, ErrInvalidTime) {
+				t.Errorf("ParseTime(%q) error = %v; want %v", s, err, ErrInvalidTime)
+			}
+		})
+	}
+}
+
+func TestMakeFilter(t *testing.T) {
+	filter, err := MakeFilter(3, "2024-01-01", "1714229168", []string{"temp_in__degC, co2__ppm", "", "rel_humidity__0,,"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if filter.CampaignID != 3 {
+		t.Errorf("campaign = %d; want 3", filter.CampaignID)
+	}
+
+	if want := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC); filter.Start == nil || !filter.Start.Equal(want) {
+		t.Errorf("start = %v; want %v", filter.Start, want)
+	}
+
+	if want := time.Unix(1714229168, 0); filter.End == nil || !filter.End.Equal(want) {
+		t.Errorf("end = %v; want %v", filter.End, want)
+	}
+
+	if want := []string{"temp_in__degC", "co2__ppm", "rel_humidity__0"}; !slices.Equal(filter.Properties, want) {
+		t.Errorf("properties = %q; want %q", filter.Properties, want)
+	}
+
+	if filter.Raw {
+		t.Error("filter only selects raw measurements")
+	}
+}
+
+func TestMakeFilter_empty(t *testing.T) {
+	filter, err := MakeFilter(3, "", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if filter.Start != nil || filter.End != nil || filter.Properties != nil {
+		t.Errorf("got filter %+v; want only a campaign", filter)
+	}
+}
+
+func TestMakeFilter_invalidTime(t *testing.T) {
+	for _, times := range [][2]string{{"yesterday", ""}, {"", "tomorrow"}} {
+		_, err := MakeFilter(3, times[0], times[1], nil)
+		if !errors.Is(err, ErrInvalidTime) {
+			t.Errorf("MakeFilter(%q, %q) error = %v; want %v", times[0], times[1], err, ErrInvalidTime)
+		}
+	}
+}
+
+func TestMakeRow(t *testing.T) {
+	p := &Pseudonymizer{key: make([]byte, pseudonymKeySize)}
+	record := Record{
+		AccountID:    1,
+		InstanceType: "device",
+		InstanceName: "ABC123-dev",
+		SourceType:   "sensor",
+		Property:     "temp_in__degC",
+		Time:         time.Date(2024, time.April, 27, 16, 46, 8, 0, time.FixedZone("CEST", 2*60*60)),
+		Value:        "21.5",
+	}
+
+	row := MakeRow(record, p)
+
+	if row.Account != p.Account(1) || row.Instance != p.Instance("device", "ABC123-dev") {
+		t.Errorf("got account %s and instance %s; want pseudonyms", row.Account, row.Instance)
+	}
+
+	if row.Time.Location() != time.UTC || !row.Time.Equal(record.Time) {
+		t.Errorf("time = %v; want %v in UTC", row.Time, record.Time)
+	}
+
+	if row.InstanceType != "device" || row.SourceType != "sensor" || row.Property != "temp_in__degC" || row.Value != "21.5" {
+		t.Errorf("got row %+v; want the values of the record", row)
+	}
+}
@@ -0,0 +1,56 @@
+package export
+
+import "github.com/energietransitie/needforheat-server-api/needforheat"
+
+// An AccountRecord is an account of a campaign, as it is stored.
+type AccountRecord struct {
+	AccountID   uint
+	ActivatedAt *needforheat.Time
+}
+
+// An InstanceRecord is a device or energy query of a campaign, as it is stored.
+type InstanceRecord struct {
+	AccountID    uint
+	InstanceType string
+	// Name of the device, or ID of the energy query.
+	InstanceName string
+	// Name of the device type, or variety of the energy query type.
+	SourceType  string
+	ActivatedAt *needforheat.Time
+}
+
+// A Participant is an account of a campaign.
+// The account is pseudonymised like in exported rows, so it can not be traced back to a participant.
+type Participant struct {
+	Account     string            `json:"account"`
+	ActivatedAt *needforheat.Time `json:"activated_at"`
+}
+
+// Create a Participant from an AccountRecord, pseudonymising its account using p.
+func MakeParticipant(record AccountRecord, p *Pseudonymizer) Participant {
+	return Participant{
+		Account:     p.Account(record.AccountID),
+		ActivatedAt: record.ActivatedAt,
+	}
+}
+
+// An Instance is a device or energy query of a campaign.
+// Account and instance are pseudonymised like in exported rows.
+type Instance struct {
+	Account      string            `json:"account"`
+	InstanceType string            `json:"instance_type"`
+	Instance     string            `json:"instance"`
+	SourceType   string            `json:"source_type"`
+	ActivatedAt  *needforheat.Time `json:"activated_at"`
+}
+
+// Create an Instance from an InstanceRecord, pseudonymising its account and instance using p.
+func MakeInstance(record InstanceRecord, p *Pseudonymizer) Instance {
+	return Instance{
+		Account:      p.Account(record.AccountID),
+		InstanceType: record.InstanceType,
+		Instance:     p.Instance(record.InstanceType, record.InstanceName),
+		SourceType:   record.SourceType,
+		ActivatedAt:  record.ActivatedAt,
+	}
+}
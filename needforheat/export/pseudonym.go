@@ -0,0 +1,79 @@
+package export
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Size of the pseudonymisation key in bytes.
+	pseudonymKeySize = 32
+	// Number of hex characters in a pseudonym.
+	pseudonymLength = 16
+)
+
+var (
+	ErrInvalidPseudonymKey = errors.New("invalid pseudonymisation key")
+)
+
+// A Pseudonymizer replaces identifiers with pseudonyms.
+// The same identifier always gets the same pseudonym, so exports can be combined,
+// but pseudonyms can not be traced back to identifiers without the key.
+type Pseudonymizer struct {
+	key []byte
+}
+
+// Load the key of a Pseudonymizer from a hex encoded file at path.
+// If the file does not exist, it is created with a new key.
+func LoadPseudonymizerFromFile(path string) (*Pseudonymizer, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) != pseudonymKeySize {
+			return nil, fmt.Errorf("%w in %s", ErrInvalidPseudonymKey, path)
+		}
+
+		return &Pseudonymizer{key: key}, nil
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	// File did not exist, so generate it.
+	key := make([]byte, pseudonymKeySize)
+	_, err = rand.Read(key)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Pseudonymizer{key: key}, nil
+}
+
+// Account returns the pseudonym of the account with id.
+func (p *Pseudonymizer) Account(id uint) string {
+	return p.pseudonym("account:" + strconv.FormatUint(uint64(id), 10))
+}
+
+// Instance returns the pseudonym of the device or energy query with name.
+func (p *Pseudonymizer) Instance(instanceType string, name string) string {
+	return p.pseudonym(instanceType + ":" + name)
+}
+
+func (p *Pseudonymizer) pseudonym(identifier string) string {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(identifier))
+	return hex.EncodeToString(mac.Sum(nil))[:pseudonymLength]
+}
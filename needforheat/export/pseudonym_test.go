@@ -0,0 +1,82 @@
+package export
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// loadPseudonymizer loads the pseudonymizer with the key in path.
+func loadPseudonymizer(t *testing.T, path string) *Pseudonymizer {
+	t.Helper()
+
+	p, err := LoadPseudonymizerFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func TestPseudonymizer(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pseudonym.key")
+
+	p := loadPseudonymizer(t, path)
+	account := p.Account(1)
+
+	if len(account) != pseudonymLength {
+		t.Errorf("pseudonym %q has length %d; want %d", account, len(account), pseudonymLength)
+	}
+
+	// The key is stored, so the same key gives the same pseudonyms.
+	loaded := loadPseudonymizer(t, path)
+	if got := loaded.Account(1); got != account {
+		t.Errorf("account pseudonym with the same key = %s; want %s", got, account)
+	}
+
+	if got, want := loaded.Instance("device", "ABC123-dev"), p.Instance("device", "ABC123-dev"); got != want {
+		t.Errorf("instance pseudonym with the same key = %s; want %s", got, want)
+	}
+
+	// Different identifiers and different keys give different pseudonyms.
+	other := loadPseudonymizer(t, filepath.Join(dir, "other.key"))
+
+	pseudonyms := map[string]string{
+		"account 1":               account,
+		"account 2":               p.Account(2),
+		"device 1":                p.Instance("device", "1"),
+		"energy query 1":          p.Instance("energy_query", "1"),
+		"account 1 of other key":  other.Account(1),
+		"device 1 of another key": other.Instance("device", "1"),
+	}
+
+	seen := make(map[string]string)
+	for name, pseudonym := range pseudonyms {
+		if previous, ok := seen[pseudonym]; ok {
+			t.Errorf("%s and %s have the same pseudonym %s", name, previous, pseudonym)
+		}
+		seen[pseudonym] = name
+	}
+}
+
+func TestLoadPseudonymizerFromFile_invalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"not hex":   "not a key\n",
+		"too short": "abcdef\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "pseudonym.key")
+			err := os.WriteFile(path, []byte(content), 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = LoadPseudonymizerFromFile(path)
+			if !errors.Is(err, ErrInvalidPseudonymKey) {
+				t.Errorf("got error %v; want %v", err, ErrInvalidPseudonymKey)
+			}
+		})
+	}
+}
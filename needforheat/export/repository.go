@@ -0,0 +1,14 @@
+package export
+
+import "context"
+
+// An ExportRepository can read the measurements of a campaign.
+type ExportRepository interface {
+	// Call fn for every measurement selected by filter, while they are read from the database.
+	// Reading stops when ctx is done.
+	Stream(ctx context.Context, filter Filter, fn func(Record) error) error
+	// Get the accounts of a campaign.
+	GetAccounts(campaignID uint) ([]AccountRecord, error)
+	// Get the devices or energy queries of a campaign, depending on instanceType.
+	GetInstances(campaignID uint, instanceType string) ([]InstanceRecord, error)
+}
@@ -0,0 +1,106 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Number of rows that are buffered before they are written to a Parquet file.
+const parquetBatchSize = 1024
+
+// Maximum number of rows in a row group of a Parquet file.
+// Row groups are kept in memory until they are complete, so this limits the memory used by an export.
+const parquetRowGroupSize = 64 * 1024
+
+// A Writer writes rows to a file in a [Format].
+// Close must be called after the last row, to complete the file.
+type Writer interface {
+	Write(Row) error
+	Close() error
+}
+
+// Create a Writer that writes rows to w in format.
+func NewWriter(w io.Writer, format Format) (Writer, error) {
+	switch format {
+	case CSV:
+		return newCSVWriter(w)
+	case Parquet:
+		return newParquetWriter(w), nil
+	}
+
+	return nil, ErrUnknownFormat
+}
+
+// Writes rows as CSV with a header, and times in RFC 3339 format in UTC.
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	writer := &csvWriter{w: csv.NewWriter(w)}
+
+	err := writer.w.Write([]string{"account", "instance_type", "instance", "source_type", "property", "time", "value"})
+	if err != nil {
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+func (w *csvWriter) Write(row Row) error {
+	return w.w.Write([]string{
+		row.Account,
+		row.InstanceType,
+		row.Instance,
+		row.SourceType,
+		row.Property,
+		row.Time.Format(time.RFC3339),
+		row.Value,
+	})
+}
+
+func (w *csvWriter) Close() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// Writes rows as a Parquet file. Rows are written in batches,
+// and every [parquetRowGroupSize] rows a row group is written to the underlying writer.
+type parquetWriter struct {
+	w     *parquet.GenericWriter[Row]
+	batch []Row
+}
+
+func newParquetWriter(w io.Writer) *parquetWriter {
+	return &parquetWriter{
+		w:     parquet.NewGenericWriter[Row](w, parquet.Compression(&parquet.Zstd), parquet.MaxRowsPerRowGroup(parquetRowGroupSize)),
+		batch: make([]Row, 0, parquetBatchSize),
+	}
+}
+
+func (w *parquetWriter) Write(row Row) error {
+	w.batch = append(w.batch, row)
+	if len(w.batch) < parquetBatchSize {
+		return nil
+	}
+
+	return w.flush()
+}
+
+func (w *parquetWriter) flush() error {
+	_, err := w.w.Write(w.batch)
+	w.batch = w.batch[:0]
+	return err
+}
+
+func (w *parquetWriter) Close() error {
+	err := w.flush()
+	if err != nil {
+		return err
+	}
+
+	return w.w.Close()
+}
@@ -0,0 +1,80 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+
+	writer, err := NewWriter(&buf, CSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := []Row{
+		{Account: "a1", InstanceType: "device", Instance: "i1", SourceType: "sensor", Property: "temp_in__degC", Time: time.Date(2024, time.April, 27, 14, 46, 8, 0, time.UTC), Value: "21.5"},
+		{Account: "a1", InstanceType: "energy_query", Instance: "i2", SourceType: "weather", Property: "status", Time: time.Unix(0, 0).UTC(), Value: "on, \"heating\""},
+	}
+
+	for _, row := range rows {
+		err = writer.Write(row)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "account,instance_type,instance,source_type,property,time,value\n" +
+		"a1,device,i1,sensor,temp_in__degC,2024-04-27T14:46:08Z,21.5\n" +
+		"a1,energy_query,i2,weather,status,1970-01-01T00:00:00Z,\"on, \"\"heating\"\"\"\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got CSV\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestParquetWriter_rowGroups(t *testing.T) {
+	var buf bytes.Buffer
+
+	writer, err := NewWriter(&buf, Parquet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	row := Row{Account: "a", InstanceType: "device", Instance: "d", SourceType: "device_type", Property: "temp_in__degC", Time: time.Unix(1714229100, 0), Value: "21.5"}
+	rows := parquetRowGroupSize + parquetBatchSize
+
+	for i := 0; i < rows; i++ {
+		err = writer.Write(row)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Complete row groups are written while rows are written, so they are not kept in memory.
+	if n := len(file.RowGroups()); n != 2 {
+		t.Errorf("got %d row groups; want 2", n)
+	}
+
+	if n := file.NumRows(); n != int64(rows) {
+		t.Errorf("got %d rows; want %d", n, rows)
+	}
+}
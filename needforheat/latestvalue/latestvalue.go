@@ -0,0 +1,124 @@
+package latestvalue
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat"
+	"github.com/energietransitie/needforheat-server-api/needforheat/property"
+	"github.com/energietransitie/needforheat-server-api/needforheat/upload"
+)
+
+// A LatestValue is the most recent measurement of a property of an instance.
+type LatestValue struct {
+	InstanceID   uint                `json:"-"`
+	InstanceType upload.InstanceType `json:"-"`
+	Property     property.Property   `json:"property"`
+	Time         needforheat.Time    `json:"time"`
+	Value        string              `json:"value"`
+	// Upload the measurement was part of, and the server time of that upload.
+	UploadID   uint             `json:"upload_id"`
+	UploadTime needforheat.Time `json:"upload_time"`
+}
+
+// LatestValue without its JSON methods.
+type latestValueJSON LatestValue
+
+// MarshalJSON marshals the value as a number or boolean if the property has a numeric or boolean value type.
+func (v LatestValue) MarshalJSON() ([]byte, error) {
+	value, err := v.Property.ValueType.Parse(v.Value)
+	if err != nil {
+		// Values that were stored before the property had a value type may not match it.
+		value = v.Value
+	}
+
+	return json.Marshal(struct {
+		latestValueJSON
+		Value any `json:"value"`
+	}{latestValueJSON(v), value})
+}
+
+// Create the latest values of the measurements in an upload, with one value per property.
+// The upload should be stored, so its measurements have a stored property.
+func MakeLatestValues(u upload.Upload) []LatestValue {
+	var values []LatestValue
+	indexes := make(map[uint]int)
+
+	for _, m := range u.Measurements {
+		value := LatestValue{
+			InstanceID:   u.InstanceID,
+			InstanceType: u.InstanceType,
+			Property:     m.Property,
+			Time:         m.Time,
+			Value:        m.Value,
+			UploadID:     u.ID,
+			UploadTime:   u.ServerTime,
+		}
+
+		i, ok := indexes[m.Property.ID]
+		if !ok {
+			indexes[m.Property.ID] = len(values)
+			values = append(values, value)
+			continue
+		}
+
+		if !time.Time(m.Time).Before(time.Time(values[i].Time)) {
+			values[i] = value
+		}
+	}
+
+	return values
+}
+
+// A Snapshot is the state of an instance: the latest value of each of its properties.
+type Snapshot struct {
+	InstanceID   uint                `json:"instance_id"`
+	InstanceType upload.InstanceType `json:"instance_type"`
+	// Server time of the most recent upload that contains one of the values.
+	// It is not set if the instance has no values.
+	UploadTime *needforheat.Time `json:"upload_time"`
+	Values     []LatestValue     `json:"values"`
+}
+
+// Create a Snapshot of an instance from its latest values.
+func MakeSnapshot(instanceID uint, instanceType upload.InstanceType, values []LatestValue) Snapshot {
+	snapshot := Snapshot{
+		InstanceID:   instanceID,
+		InstanceType: instanceType,
+		Values:       make([]LatestValue, 0, len(values)),
+	}
+
+	for _, value := range values {
+		snapshot.add(value)
+	}
+
+	return snapshot
+}
+
+// Create a Snapshot per instance from the latest values of multiple instances.
+// The values of an instance should be next to each other.
+func MakeSnapshots(values []LatestValue) []Snapshot {
+	snapshots := make([]Snapshot, 0)
+
+	for _, value := range values {
+		last := len(snapshots) - 1
+		if last < 0 || snapshots[last].InstanceID != value.InstanceID || snapshots[last].InstanceType != value.InstanceType {
+			snapshots = append(snapshots, MakeSnapshot(value.InstanceID, value.InstanceType, nil))
+			last++
+		}
+
+		snapshots[last].add(value)
+	}
+
+	return snapshots
+}
+
+// Add a value to the snapshot.
+func (s *Snapshot) add(value LatestValue) {
+	s.Values = append(s.Values, value)
+
+	if s.UploadTime == nil || time.Time(value.UploadTime).After(time.Time(*s.UploadTime)) {
+		uploadTime := value.UploadTime
+		s.UploadTime = &uploadTime
+	}
+}
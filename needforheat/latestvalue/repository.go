@@ -0,0 +1,13 @@
+package latestvalue
+
+import "github.com/energietransitie/needforheat-server-api/needforheat/upload"
+
+// A LatestValueRepository can load and store the latest values of instances.
+type LatestValueRepository interface {
+	// Store the values, unless the stored value of the same instance and property is more recent.
+	Update(values []LatestValue) error
+	// Get the latest values of an instance, ordered by property name.
+	FindByInstance(instanceID uint, instanceType upload.InstanceType) ([]LatestValue, error)
+	// Get the latest values of the devices and energy queries of an account, ordered by instance and property name.
+	FindByAccount(accountID uint) ([]LatestValue, error)
+}
@@ -0,0 +1,96 @@
+package measurement
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat"
+	"github.com/energietransitie/needforheat-server-api/needforheat/property"
+)
+
+var (
+	ErrInvalidInterval          = errors.New("invalid aggregation interval")
+	ErrInvalidAggregateFunction = errors.New("invalid aggregate function")
+	ErrNotNumeric               = errors.New("only properties with a numeric value type can be aggregated")
+)
+
+// An AggregateFunction combines the measurements in an interval into a single value.
+type AggregateFunction string
+
+const (
+	Average AggregateFunction = "avg"
+	Minimum AggregateFunction = "min"
+	Maximum AggregateFunction = "max"
+	Sum     AggregateFunction = "sum"
+	// Value of the first measurement in an interval.
+	First AggregateFunction = "first"
+	// Value of the last measurement in an interval.
+	Last AggregateFunction = "last"
+	// Increase of a cumulative counter, like e_use_cum__kWh, since the previous interval.
+	// For the first interval, the increase since its first measurement is used.
+	Delta AggregateFunction = "delta"
+)
+
+// Intervals that can be used in an [Aggregation].
+var Intervals = map[string]time.Duration{
+	"5m": time.Minute * 5,
+	"1h": time.Hour,
+	"1d": time.Hour * 24,
+}
+
+// An Aggregation groups measurements in intervals and combines them using a function.
+// Intervals start at multiples of the interval since the unix epoch, in UTC.
+type Aggregation struct {
+	Interval string
+	Function AggregateFunction
+}
+
+// Parse an Aggregation from an interval and a function.
+func ParseAggregation(interval string, function string) (Aggregation, error) {
+	if _, ok := Intervals[interval]; !ok {
+		return Aggregation{}, fmt.Errorf("%w: %q, should be one of 5m, 1h or 1d", ErrInvalidInterval, interval)
+	}
+
+	switch AggregateFunction(function) {
+	case Average, Minimum, Maximum, Sum, First, Last, Delta:
+	default:
+		return Aggregation{}, fmt.Errorf("%w: %q", ErrInvalidAggregateFunction, function)
+	}
+
+	return Aggregation{
+		Interval: interval,
+		Function: AggregateFunction(function),
+	}, nil
+}
+
+// Duration returns the length of the intervals.
+func (a Aggregation) Duration() time.Duration {
+	return Intervals[a.Interval]
+}
+
+// Check that the measurements of p can be aggregated.
+// Only properties with a float or int value type can be aggregated.
+func (a Aggregation) Check(p property.Property) error {
+	if !p.ValueType.IsNumeric() {
+		return fmt.Errorf("%w: %s is a %s property", ErrNotNumeric, p.Name, p.ValueType)
+	}
+
+	return nil
+}
+
+// A Series contains the aggregated measurements of a single property.
+// Times and Values have the same length; Times contains the start of each interval.
+type Series struct {
+	Property property.Property  `json:"property"`
+	Interval string             `json:"interval"`
+	Function AggregateFunction  `json:"function"`
+	Times    []needforheat.Time `json:"times"`
+	Values   []float64          `json:"values"`
+}
+
+// Append the value of an interval starting at t.
+func (s *Series) Append(t time.Time, value float64) {
+	s.Times = append(s.Times, needforheat.Time(t))
+	s.Values = append(s.Values, value)
+}
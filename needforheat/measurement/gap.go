@@ -0,0 +1,150 @@
+package measurement
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// Maximum length of a single [Gap] returned by [FindGaps].
+	// Longer gaps are split, so they can be requested in chunks.
+	MaxGapDuration = time.Hour * 24 * 7
+)
+
+// A DayCount is the number of measurements of a property on a single day.
+type DayCount struct {
+	PropertyID uint
+	Day        time.Time
+	Count      int
+}
+
+// A Gap is a period in which measurements are missing.
+type Gap struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// FindGaps finds the days from start up to end on which measurements are missing.
+//
+// Gaps are found per day, because measurements are counted per day, whatever the interval
+// of the measurements is. Gaps always start and end at midnight, so missing measurements are
+// downloaded again for whole days. Start is rounded down to midnight, and only days that end
+// before or at end are checked, since the counts of a day that is not complete are too low.
+//
+// A day is missing for a property if it has no measurements, or less than half
+// of the median number of measurements per day of that property. Measurements that are
+// missing within a day are not found if the day still has at least half of the median.
+// A day is part of a gap if it is missing for any property.
+//
+// Properties that were added later, like a new meter, are only checked from their first day
+// with measurements. Properties that have measurements on the first day any property has
+// measurements are checked from start, so missing days at the start are found as well.
+// Consecutive missing days are merged into gaps of at most [MaxGapDuration].
+func FindGaps(counts []DayCount, start time.Time, end time.Time) []Gap {
+	start = truncateDay(start)
+
+	countsPerProperty := make(map[uint]map[time.Time]int)
+	for _, c := range counts {
+		if countsPerProperty[c.PropertyID] == nil {
+			countsPerProperty[c.PropertyID] = make(map[time.Time]int)
+		}
+		countsPerProperty[c.PropertyID][truncateDay(c.Day)] += c.Count
+	}
+
+	var days []time.Time
+	for day := start; !day.AddDate(0, 0, 1).After(end); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+
+	missing := make(map[time.Time]bool)
+	if len(countsPerProperty) == 0 {
+		// Without any measurements, every day is missing.
+		for _, day := range days {
+			missing[day] = true
+		}
+	}
+
+	firstDays := make(map[uint]time.Time)
+	var firstDay time.Time
+	for propertyID, dayCounts := range countsPerProperty {
+		day := first(dayCounts)
+		firstDays[propertyID] = day
+		if !day.IsZero() && (firstDay.IsZero() || day.Before(firstDay)) {
+			firstDay = day
+		}
+	}
+
+	for propertyID, dayCounts := range countsPerProperty {
+		threshold := median(dayCounts) / 2
+
+		// Properties that were added later are not missing before they were added.
+		from := start
+		if firstDays[propertyID].After(firstDay) {
+			from = firstDays[propertyID]
+		}
+
+		for _, day := range days {
+			if day.Before(from) {
+				continue
+			}
+
+			if dayCounts[day] == 0 || dayCounts[day] < threshold {
+				missing[day] = true
+			}
+		}
+	}
+
+	var gaps []Gap
+	for _, day := range days {
+		if !missing[day] {
+			continue
+		}
+
+		dayEnd := day.AddDate(0, 0, 1)
+
+		if len(gaps) > 0 {
+			last := &gaps[len(gaps)-1]
+			if last.End.Equal(day) && dayEnd.Sub(last.Start) <= MaxGapDuration {
+				last.End = dayEnd
+				continue
+			}
+		}
+
+		gaps = append(gaps, Gap{Start: day, End: dayEnd})
+	}
+
+	return gaps
+}
+
+// Return the median of the counts.
+func median(dayCounts map[time.Time]int) int {
+	counts := make([]int, 0, len(dayCounts))
+	for _, count := range dayCounts {
+		counts = append(counts, count)
+	}
+
+	if len(counts) == 0 {
+		return 0
+	}
+
+	sort.Ints(counts)
+	return counts[len(counts)/2]
+}
+
+// Return the first day with measurements.
+func first(dayCounts map[time.Time]int) time.Time {
+	var day time.Time
+	for d, count := range dayCounts {
+		if count > 0 && (day.IsZero() || d.Before(day)) {
+			day = d
+		}
+	}
+
+	return day
+}
+
+// Return t at midnight in its location.
+func truncateDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
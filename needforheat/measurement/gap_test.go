@@ -0,0 +1,114 @@
+package measurement
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// day returns midnight in UTC of the nth day of January 2024.
+func day(n int) time.Time {
+	return time.Date(2024, time.January, n, 0, 0, 0, 0, time.UTC)
+}
+
+// dayCounts returns count measurements of property 1 on every day from first up to and including last.
+func dayCounts(first int, last int, count int) []DayCount {
+	var counts []DayCount
+	for n := first; n <= last; n++ {
+		counts = append(counts, DayCount{PropertyID: 1, Day: day(n), Count: count})
+	}
+	return counts
+}
+
+func TestFindGaps(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts []DayCount
+		start  time.Time
+		end    time.Time
+
+		want []Gap
+	}{
+		{
+			name:   "complete",
+			counts: dayCounts(1, 5, 96),
+			start:  day(1),
+			end:    day(6),
+		},
+		{
+			name:   "missing day",
+			counts: append(dayCounts(1, 2, 96), dayCounts(4, 5, 96)...),
+			start:  day(1),
+			end:    day(6),
+			want:   []Gap{{day(3), day(4)}},
+		},
+		{
+			name:   "day below half of the median",
+			counts: append(dayCounts(1, 4, 96), DayCount{PropertyID: 1, Day: day(5), Count: 40}),
+			start:  day(1),
+			end:    day(6),
+			want:   []Gap{{day(5), day(6)}},
+		},
+		{
+			name:   "day with some measurements missing",
+			counts: append(dayCounts(1, 4, 96), DayCount{PropertyID: 1, Day: day(5), Count: 60}),
+			start:  day(1),
+			end:    day(6),
+		},
+		{
+			name:   "start within a day",
+			counts: dayCounts(2, 5, 96),
+			start:  day(1).Add(time.Hour * 12),
+			end:    day(6),
+			want:   []Gap{{day(1), day(2)}},
+		},
+		{
+			name:   "end within a day",
+			counts: dayCounts(1, 5, 96),
+			start:  day(1),
+			end:    day(6).Add(time.Hour * 12),
+		},
+		{
+			name:  "no measurements",
+			start: day(1),
+			end:   day(3),
+			want:  []Gap{{day(1), day(3)}},
+		},
+		{
+			name:   "property added later",
+			counts: append(dayCounts(1, 5, 96), DayCount{PropertyID: 2, Day: day(4), Count: 24}, DayCount{PropertyID: 2, Day: day(5), Count: 24}),
+			start:  day(1),
+			end:    day(6),
+		},
+		{
+			name:   "property added later with a missing day",
+			counts: append(dayCounts(1, 5, 96), DayCount{PropertyID: 2, Day: day(3), Count: 24}, DayCount{PropertyID: 2, Day: day(5), Count: 24}),
+			start:  day(1),
+			end:    day(6),
+			want:   []Gap{{day(4), day(5)}},
+		},
+		{
+			name:   "missing days at the start",
+			counts: append(dayCounts(3, 5, 96), DayCount{PropertyID: 2, Day: day(4), Count: 24}, DayCount{PropertyID: 2, Day: day(5), Count: 24}),
+			start:  day(1),
+			end:    day(6),
+			want:   []Gap{{day(1), day(3)}},
+		},
+		{
+			name:   "longer than the maximum",
+			counts: dayCounts(10, 10, 96),
+			start:  day(1),
+			end:    day(11),
+			want:   []Gap{{day(1), day(8)}, {day(8), day(10)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FindGaps(tt.counts, tt.start, tt.end)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got gaps %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
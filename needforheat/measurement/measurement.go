@@ -1,10 +1,18 @@
 package measurement
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
 	"github.com/energietransitie/needforheat-server-api/needforheat"
 	"github.com/energietransitie/needforheat-server-api/needforheat/property"
 )
 
+var (
+	ErrInvalidValue = errors.New("value should be a string, number or boolean")
+)
+
 // A Measurement is a measured value for a specific property.
 type Measurement struct {
 	ID         uint              `json:"id"`
@@ -14,3 +22,54 @@ type Measurement struct {
 	Time       needforheat.Time  `json:"time"`
 	Value      string            `json:"value"`
 }
+
+// Measurement without its JSON methods.
+type measurementJSON Measurement
+
+// MarshalJSON marshals the value as a number or boolean if the property has a numeric or boolean value type.
+func (m Measurement) MarshalJSON() ([]byte, error) {
+	value, err := m.Property.ValueType.Parse(m.Value)
+	if err != nil {
+		// Values that were stored before the property had a value type may not match it.
+		value = m.Value
+	}
+
+	return json.Marshal(struct {
+		measurementJSON
+		Value any `json:"value"`
+	}{measurementJSON(m), value})
+}
+
+// UnmarshalJSON unmarshals a measurement with a value that is a string, number or boolean.
+func (m *Measurement) UnmarshalJSON(b []byte) error {
+	var v struct {
+		measurementJSON
+		Value json.RawMessage `json:"value"`
+	}
+
+	err := json.Unmarshal(b, &v)
+	if err != nil {
+		return err
+	}
+
+	*m = Measurement(v.measurementJSON)
+	m.Value, err = ParseValue(v.Value)
+	return err
+}
+
+// Parse a value from JSON that is a string, number or boolean.
+// Numbers and booleans are returned as they were sent.
+func ParseValue(raw json.RawMessage) (string, error) {
+	switch {
+	case len(raw) == 0 || bytes.Equal(raw, []byte("null")):
+		return "", nil
+	case raw[0] == '"':
+		var value string
+		err := json.Unmarshal(raw, &value)
+		return value, err
+	case raw[0] == '{' || raw[0] == '[':
+		return "", ErrInvalidValue
+	}
+
+	return string(raw), nil
+}
@@ -0,0 +1,100 @@
+package measurement
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Number of measurements in a page if no limit is set.
+	DefaultPageLimit = 1000
+	// Maximum number of measurements in a page.
+	MaxPageLimit = 10000
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidLimit  = errors.New("invalid limit")
+)
+
+// A Cursor points to the last measurement of a page.
+// Measurements are ordered by time and ID, so the next page starts after the cursor.
+type Cursor struct {
+	Time time.Time
+	ID   uint
+}
+
+// Create a cursor pointing to m.
+func MakeCursor(m Measurement) Cursor {
+	return Cursor{
+		Time: time.Time(m.Time),
+		ID:   m.ID,
+	}
+}
+
+// String encodes the cursor as an opaque string, which can be parsed using [ParseCursor].
+func (c Cursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", c.Time.UnixNano(), c.ID)))
+}
+
+// Parse a cursor that was encoded using [Cursor.String].
+func ParseCursor(s string) (Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	timePart, idPart, ok := strings.Cut(string(data), ":")
+	if !ok {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	nanos, err := strconv.ParseInt(timePart, 10, 64)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	id, err := strconv.ParseUint(idPart, 10, 64)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	return Cursor{
+		Time: time.Unix(0, nanos).UTC(),
+		ID:   uint(id),
+	}, nil
+}
+
+// A Page selects a limited number of measurements, starting after a cursor.
+type Page struct {
+	Limit int
+	// The first page is selected if After is nil.
+	After *Cursor
+}
+
+// Parse a page from a limit and a cursor. Both may be empty.
+func ParsePage(limit string, cursor string) (Page, error) {
+	page := Page{Limit: DefaultPageLimit}
+
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil || l < 1 || l > MaxPageLimit {
+			return Page{}, fmt.Errorf("%w: should be between 1 and %d", ErrInvalidLimit, MaxPageLimit)
+		}
+		page.Limit = l
+	}
+
+	if cursor != "" {
+		c, err := ParseCursor(cursor)
+		if err != nil {
+			return Page{}, err
+		}
+		page.After = &c
+	}
+
+	return page, nil
+}
@@ -1,14 +1,192 @@
 package property
 
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat"
+)
+
+var (
+	ErrUnknownValueType = errors.New("unknown value type")
+	ErrInvalidValue     = errors.New("value does not match the value type of the property")
+	ErrDeprecated       = errors.New("property is deprecated")
+	ErrMergeIntoItself  = errors.New("property can not be merged into itself")
+	ErrNameInUse        = errors.New("name is already used by another property")
+	ErrEmptyName        = errors.New("name of a property can not be empty")
+	ErrUnknownName      = errors.New("no property has the name or alias")
+)
+
+// A ValueType is the type of the values of a property.
+type ValueType string
+
+const (
+	Float  ValueType = "float"
+	Int    ValueType = "int"
+	Bool   ValueType = "bool"
+	String ValueType = "string"
+)
+
+// Parse a ValueType from s.
+func ParseValueType(s string) (ValueType, error) {
+	switch valueType := ValueType(s); valueType {
+	case Float, Int, Bool, String:
+		return valueType, nil
+	}
+
+	return "", fmt.Errorf("%w: %q", ErrUnknownValueType, s)
+}
+
+// Value types of which the values are numbers.
+var NumericValueTypes = []ValueType{Float, Int}
+
+// IsNumeric returns true if the values of the value type are numbers.
+func (t ValueType) IsNumeric() bool {
+	return t == Float || t == Int
+}
+
+// Parse value using the value type. The returned value is a float64, int64, bool or string.
+func (t ValueType) Parse(value string) (any, error) {
+	switch t {
+	case Float:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, ErrInvalidValue
+		}
+		return f, nil
+	case Int:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidValue
+		}
+		return i, nil
+	case Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, ErrInvalidValue
+		}
+		return b, nil
+	}
+
+	return value, nil
+}
+
+// Separator between the name and the unit in property names, like e_use_cum__kWh.
+const unitSeparator = "__"
+
 // A Property is the type of a measurement.
 type Property struct {
-	ID   uint   `json:"id"`
-	Name string `json:"name"`
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	ValueType ValueType `json:"value_type"`
+	// The value type was set by an admin. Value types derived from the name are not confirmed,
+	// but they are enforced in uploads as well. An admin can correct a wrongly derived value type.
+	TypeConfirmed bool   `json:"type_confirmed"`
+	Unit          string `json:"unit"`
+	// Cumulative properties are counters that only increase, like meter readings.
+	Cumulative  bool   `json:"cumulative"`
+	Description string `json:"description,omitempty"`
+	// Measurements of deprecated properties are not accepted in uploads anymore.
+	DeprecatedAt *needforheat.Time `json:"deprecated_at,omitempty"`
 }
 
 // Create a new Property.
+// The value type, unit and if it is cumulative are derived from the name.
 func MakeProperty(name string) Property {
+	valueType, unit, cumulative := ParseName(name)
+
 	return Property{
-		Name: name,
+		Name:       name,
+		ValueType:  valueType,
+		Unit:       unit,
+		Cumulative: cumulative,
 	}
 }
+
+// Derive the value type, unit and if a property is cumulative from its name,
+// using the convention name__unit. Names ending in _cum are cumulative.
+//
+// Properties with unit bool are booleans, properties with unit p (persons) are integers,
+// and properties with unit 0 are dimensionless floats. Properties without a unit are strings.
+func ParseName(name string) (ValueType, string, bool) {
+	base, unit, found := strings.Cut(name, unitSeparator)
+	cumulative := strings.HasSuffix(base, "_cum")
+
+	if !found || unit == "" {
+		return String, "", cumulative
+	}
+
+	switch unit {
+	case "bool":
+		return Bool, "", cumulative
+	case "p":
+		return Int, unit, cumulative
+	case "0":
+		return Float, "", cumulative
+	}
+
+	return Float, unit, cumulative
+}
+
+// Validate that the property is not deprecated and that value matches its value type,
+// whether the value type is confirmed or derived from the name.
+func (p Property) Validate(value string) error {
+	if p.DeprecatedAt != nil {
+		return fmt.Errorf("%w: %s", ErrDeprecated, p.Name)
+	}
+
+	_, err := p.ValueType.Parse(value)
+	if err != nil {
+		return fmt.Errorf("%w: %q is not a valid %s value for %s", err, value, p.ValueType, p.Name)
+	}
+
+	return nil
+}
+
+// An Update changes the metadata of a property. Fields that are nil are not changed.
+type Update struct {
+	ValueType   *string `json:"value_type"`
+	Unit        *string `json:"unit"`
+	Cumulative  *bool   `json:"cumulative"`
+	Description *string `json:"description"`
+	Deprecated  *bool   `json:"deprecated"`
+}
+
+// Apply the update to p.
+func (u Update) Apply(p Property) (Property, error) {
+	if u.ValueType != nil {
+		valueType, err := ParseValueType(*u.ValueType)
+		if err != nil {
+			return Property{}, err
+		}
+		p.ValueType = valueType
+		p.TypeConfirmed = true
+	}
+
+	if u.Unit != nil {
+		p.Unit = *u.Unit
+	}
+
+	if u.Cumulative != nil {
+		p.Cumulative = *u.Cumulative
+	}
+
+	if u.Description != nil {
+		p.Description = *u.Description
+	}
+
+	if u.Deprecated != nil {
+		if !*u.Deprecated {
+			p.DeprecatedAt = nil
+		} else if p.DeprecatedAt == nil {
+			now := needforheat.Time(time.Now())
+			p.DeprecatedAt = &now
+		}
+	}
+
+	return p, nil
+}
@@ -5,5 +5,14 @@ type PropertyRepository interface {
 	Find(property Property) (Property, error)
 	GetAll() ([]Property, error)
 	Create(Property) (Property, error)
+	Update(Property) (Property, error)
+	// Find the property that has name as an alias.
+	FindByAlias(name string) (Property, error)
+	GetAliases(Property) ([]string, error)
+	// Rename a property. The old name is kept as an alias.
+	Rename(property Property, name string) (Property, error)
+	// Move all measurements and latest values of source to target and delete source.
+	// The name of source is kept as an alias of target.
+	Merge(source Property, target Property) error
 	Delete(Property) error
 }
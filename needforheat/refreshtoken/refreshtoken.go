@@ -0,0 +1,95 @@
+package refreshtoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat"
+)
+
+var (
+	ErrInvalid = errors.New("refresh token is invalid")
+	ErrExpired = errors.New("refresh token is expired")
+	ErrReused  = errors.New("refresh token was already used")
+)
+
+// A RefreshToken is an opaque token an account can use once to get a new access token and refresh token.
+//
+// Refresh tokens that were rotated from the same token are a family. A refresh token that is used twice
+// has leaked, so the whole family is revoked, including the access tokens that were issued with it.
+type RefreshToken struct {
+	ID        uint
+	FamilyID  string
+	AccountID uint
+	// Only the hash of the token is stored.
+	Hash      string
+	ExpiresAt needforheat.Time
+	UsedAt    *needforheat.Time
+	RevokedAt *needforheat.Time
+
+	// The token itself. It is only known when the token is created.
+	Token string
+}
+
+// Create a new RefreshToken for an account, valid for lifetime.
+// A new family is started if familyID is empty.
+func MakeRefreshToken(accountID uint, familyID string, lifetime time.Duration) (RefreshToken, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return RefreshToken{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	if familyID == "" {
+		f := make([]byte, 16)
+		_, err = rand.Read(f)
+		if err != nil {
+			return RefreshToken{}, err
+		}
+		familyID = hex.EncodeToString(f)
+	}
+
+	return RefreshToken{
+		FamilyID:  familyID,
+		AccountID: accountID,
+		Hash:      Hash(token),
+		ExpiresAt: needforheat.Time(time.Now().UTC().Add(lifetime)),
+		Token:     token,
+	}, nil
+}
+
+// Hash a refresh token, to store or find it.
+func Hash(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// Check if the refresh token can be used at a time.
+func (t *RefreshToken) Check(now time.Time) error {
+	if t.RevokedAt != nil {
+		return ErrInvalid
+	}
+
+	if t.UsedAt != nil {
+		return ErrReused
+	}
+
+	if !now.Before(time.Time(t.ExpiresAt)) {
+		return ErrExpired
+	}
+
+	return nil
+}
+
+// A Session is a short-lived access token and the refresh token used to get the next one.
+type Session struct {
+	AccessToken           string           `json:"access_token"`
+	AccessTokenExpiresAt  needforheat.Time `json:"access_token_expires_at"`
+	RefreshToken          string           `json:"refresh_token"`
+	RefreshTokenExpiresAt needforheat.Time `json:"refresh_token_expires_at"`
+}
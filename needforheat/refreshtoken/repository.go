@@ -0,0 +1,14 @@
+package refreshtoken
+
+import "time"
+
+// A RefreshTokenRepository can load, store and revoke refresh tokens.
+type RefreshTokenRepository interface {
+	Create(RefreshToken) (RefreshToken, error)
+	FindByHash(hash string) (RefreshToken, error)
+	// Mark a refresh token as used at a time.
+	// False is returned if it was already used, for example by a concurrent request.
+	MarkUsed(id uint, at time.Time) (bool, error)
+	// Revoke all refresh tokens of a family and the access tokens that were issued with them.
+	RevokeFamily(familyID string, at time.Time) error
+}
@@ -0,0 +1,10 @@
+package researcher
+
+// A ResearcherRepository can load, store and delete researchers.
+type ResearcherRepository interface {
+	Find(researcher Researcher) (Researcher, error)
+	GetAll() ([]Researcher, error)
+	Create(researcher Researcher) (Researcher, error)
+	Update(researcher Researcher) (Researcher, error)
+	Delete(researcher Researcher) error
+}
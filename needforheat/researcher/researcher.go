@@ -0,0 +1,65 @@
+package researcher
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+var (
+	ErrNoCampaigns = errors.New("researcher should be bound to at least one campaign")
+)
+
+// A Researcher has read-only access to the campaigns they are bound to, with a ResearcherToken.
+type Researcher struct {
+	// ID is a short unique identifier.
+	ID uint
+	// Easily recognisable name for a researcher.
+	Name string
+	// IDs of the campaigns the researcher can read.
+	CampaignIDs []uint
+	// Time the researcher was activated.
+	// Tokens that are generated before this time will be invalid.
+	// Researchers can be reactivated to invalidate old tokens.
+	ActivatedAt time.Time
+	// Time at which a researcher expires.
+	// This is by default the expiration date of any token.
+	Expiry time.Time
+	// Authorization token that is generated for the researcher.
+	// This will only be set upon creation and reactivation.
+	AuthorizationToken string
+}
+
+// Create a new researcher.
+func MakeResearcher(name string, campaignIDs []uint, expiry time.Time) (Researcher, error) {
+	if len(campaignIDs) == 0 {
+		return Researcher{}, ErrNoCampaigns
+	}
+
+	if expiry.IsZero() {
+		// Set to 1 year from now if empty.
+		expiry = time.Now().UTC().AddDate(1, 0, 0)
+	}
+
+	return Researcher{
+		Name:        name,
+		CampaignIDs: campaignIDs,
+		ActivatedAt: time.Now().UTC().Add(time.Second * -1),
+		Expiry:      expiry,
+	}, nil
+}
+
+// Reactivate researcher and invalidate all old tokens.
+func (r *Researcher) Reactivate() {
+	r.ActivatedAt = time.Now().UTC().Add(time.Second * -1)
+}
+
+// Change the expiry date.
+func (r *Researcher) SetExpiry(expiry time.Time) {
+	r.Expiry = expiry
+}
+
+// Returns if the researcher is bound to the campaign with campaignID.
+func (r *Researcher) HasCampaign(campaignID uint) bool {
+	return slices.Contains(r.CampaignIDs, campaignID)
+}
@@ -0,0 +1,97 @@
+package retention
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat"
+	"github.com/energietransitie/needforheat-server-api/needforheat/measurement"
+	"github.com/energietransitie/needforheat-server-api/needforheat/property"
+)
+
+// A Downsampler combines raw measurements into one measurement per property per interval.
+// Measurements should be added ordered by property and time.
+//
+// The value of an interval is the last value for cumulative properties and for properties
+// that are not numbers. For other properties it is the average, rounded for integers.
+// Intervals start at multiples of the interval since the unix epoch, like in an [measurement.Aggregation].
+type Downsampler struct {
+	interval time.Duration
+
+	// Interval that is being combined.
+	property property.Property
+	start    time.Time
+	count    int
+	sum      float64
+	last     string
+	// Number of values in the interval that are numbers.
+	numbers int
+
+	aggregates []measurement.Measurement
+}
+
+// Create a new Downsampler that combines measurements in intervals.
+func NewDownsampler(interval time.Duration) *Downsampler {
+	return &Downsampler{
+		interval: interval,
+	}
+}
+
+// Add a raw measurement.
+func (d *Downsampler) Add(m measurement.Measurement) {
+	start := time.Time(m.Time).UTC().Truncate(d.interval)
+
+	if d.count > 0 && (m.Property.ID != d.property.ID || !start.Equal(d.start)) {
+		d.flush()
+	}
+
+	if d.count == 0 {
+		d.property = m.Property
+		d.start = start
+	}
+
+	d.count++
+	d.last = m.Value
+
+	value, err := strconv.ParseFloat(m.Value, 64)
+	if err == nil && !math.IsNaN(value) && !math.IsInf(value, 0) {
+		d.sum += value
+		d.numbers++
+	}
+}
+
+// Get the measurements that combine the added measurements.
+func (d *Downsampler) Aggregates() []measurement.Measurement {
+	if d.count > 0 {
+		d.flush()
+	}
+
+	return d.aggregates
+}
+
+// Add the measurement that combines the current interval and start a new interval.
+func (d *Downsampler) flush() {
+	value := d.last
+
+	if !d.property.Cumulative && d.numbers > 0 {
+		mean := d.sum / float64(d.numbers)
+
+		switch d.property.ValueType {
+		case property.Float:
+			value = strconv.FormatFloat(mean, 'f', -1, 64)
+		case property.Int:
+			value = strconv.FormatInt(int64(math.Round(mean)), 10)
+		}
+	}
+
+	d.aggregates = append(d.aggregates, measurement.Measurement{
+		Property: d.property,
+		Time:     needforheat.Time(d.start),
+		Value:    value,
+	})
+
+	d.count = 0
+	d.sum = 0
+	d.numbers = 0
+}
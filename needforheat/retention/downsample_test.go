@@ -0,0 +1,161 @@
+package retention
+
+import (
+	"testing"
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat"
+	"github.com/energietransitie/needforheat-server-api/needforheat/measurement"
+	"github.com/energietransitie/needforheat-server-api/needforheat/property"
+)
+
+var (
+	temperature = property.Property{ID: 1, Name: "temp_in__degC", ValueType: property.Float}
+	occupancy   = property.Property{ID: 2, Name: "occupancy__p", ValueType: property.Int}
+	meter       = property.Property{ID: 3, Name: "e_use_cum__kWh", ValueType: property.Float, Cumulative: true}
+	status      = property.Property{ID: 4, Name: "status", ValueType: property.String}
+)
+
+// at returns 1 January 2024 at hour:minute in UTC.
+func at(hour, minute int) time.Time {
+	return time.Date(2024, time.January, 1, hour, minute, 0, 0, time.UTC)
+}
+
+// aggregate is the expected aggregate of a property in the interval starting at start.
+type aggregate struct {
+	property uint
+	start    time.Time
+	value    string
+}
+
+func TestDownsampler(t *testing.T) {
+	tests := []struct {
+		name         string
+		interval     time.Duration
+		measurements []measurement.Measurement
+
+		want []aggregate
+	}{
+		{
+			name:     "average of floats",
+			interval: time.Hour,
+			measurements: []measurement.Measurement{
+				{Property: temperature, Time: needforheat.Time(at(10, 0)), Value: "20"},
+				{Property: temperature, Time: needforheat.Time(at(10, 20)), Value: "21"},
+				{Property: temperature, Time: needforheat.Time(at(10, 40)), Value: "22.5"},
+			},
+			want: []aggregate{{1, at(10, 0), "21.166666666666668"}},
+		},
+		{
+			name:     "rounded average of integers",
+			interval: time.Hour,
+			measurements: []measurement.Measurement{
+				{Property: occupancy, Time: needforheat.Time(at(10, 0)), Value: "1"},
+				{Property: occupancy, Time: needforheat.Time(at(10, 30)), Value: "2"},
+			},
+			want: []aggregate{{2, at(10, 0), "2"}},
+		},
+		{
+			name:     "last value of cumulative properties",
+			interval: time.Hour,
+			measurements: []measurement.Measurement{
+				{Property: meter, Time: needforheat.Time(at(10, 0)), Value: "100.1"},
+				{Property: meter, Time: needforheat.Time(at(10, 59)), Value: "100.7"},
+			},
+			want: []aggregate{{3, at(10, 0), "100.7"}},
+		},
+		{
+			name:     "last value of properties that are not numbers",
+			interval: time.Hour,
+			measurements: []measurement.Measurement{
+				{Property: status, Time: needforheat.Time(at(10, 0)), Value: "heating"},
+				{Property: status, Time: needforheat.Time(at(10, 30)), Value: "idle"},
+			},
+			want: []aggregate{{4, at(10, 0), "idle"}},
+		},
+		{
+			name:     "values that are not numbers are left out of the average",
+			interval: time.Hour,
+			measurements: []measurement.Measurement{
+				{Property: temperature, Time: needforheat.Time(at(10, 0)), Value: "20"},
+				{Property: temperature, Time: needforheat.Time(at(10, 10)), Value: "NaN"},
+				{Property: temperature, Time: needforheat.Time(at(10, 20)), Value: "error"},
+				{Property: temperature, Time: needforheat.Time(at(10, 30)), Value: "22"},
+			},
+			want: []aggregate{{1, at(10, 0), "21"}},
+		},
+		{
+			name:     "interval boundaries",
+			interval: time.Hour,
+			measurements: []measurement.Measurement{
+				{Property: temperature, Time: needforheat.Time(at(9, 59).Add(59 * time.Second)), Value: "19"},
+				{Property: temperature, Time: needforheat.Time(at(10, 0)), Value: "20"},
+				{Property: temperature, Time: needforheat.Time(at(10, 59).Add(59 * time.Second)), Value: "22"},
+				{Property: temperature, Time: needforheat.Time(at(11, 0)), Value: "23"},
+			},
+			want: []aggregate{
+				{1, at(9, 0), "19"},
+				{1, at(10, 0), "21"},
+				{1, at(11, 0), "23"},
+			},
+		},
+		{
+			name:     "intervals start at multiples of the interval",
+			interval: time.Minute * 5,
+			measurements: []measurement.Measurement{
+				{Property: temperature, Time: needforheat.Time(at(10, 3)), Value: "20"},
+				{Property: temperature, Time: needforheat.Time(at(10, 7)), Value: "21"},
+			},
+			want: []aggregate{
+				{1, at(10, 0), "20"},
+				{1, at(10, 5), "21"},
+			},
+		},
+		{
+			name:     "days in UTC",
+			interval: time.Hour * 24,
+			measurements: []measurement.Measurement{
+				{Property: temperature, Time: needforheat.Time(time.Date(2024, time.January, 2, 0, 30, 0, 0, time.FixedZone("CET", 60*60))), Value: "20"},
+				{Property: temperature, Time: needforheat.Time(at(23, 0)), Value: "22"},
+			},
+			want: []aggregate{{1, at(0, 0), "21"}},
+		},
+		{
+			name:     "properties in the same interval",
+			interval: time.Hour,
+			measurements: []measurement.Measurement{
+				{Property: temperature, Time: needforheat.Time(at(10, 0)), Value: "20"},
+				{Property: occupancy, Time: needforheat.Time(at(10, 0)), Value: "3"},
+			},
+			want: []aggregate{
+				{1, at(10, 0), "20"},
+				{2, at(10, 0), "3"},
+			},
+		},
+		{
+			name:     "no measurements",
+			interval: time.Hour,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDownsampler(tt.interval)
+			for _, m := range tt.measurements {
+				d.Add(m)
+			}
+
+			got := d.Aggregates()
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d aggregates; want %d", len(got), len(tt.want))
+			}
+
+			for i, want := range tt.want {
+				if got[i].Property.ID != want.property || !time.Time(got[i].Time).Equal(want.start) || got[i].Value != want.value {
+					t.Errorf("aggregate %d = property %d at %v: %s; want property %d at %v: %s",
+						i, got[i].Property.ID, time.Time(got[i].Time), got[i].Value, want.property, want.start, want.value)
+				}
+			}
+		})
+	}
+}
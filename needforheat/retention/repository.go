@@ -0,0 +1,34 @@
+package retention
+
+import (
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat/measurement"
+)
+
+// A RetentionRepository can load, store and delete retention policies,
+// and remove the measurements of campaigns.
+type RetentionRepository interface {
+	Find(campaignID uint) (Policy, error)
+	GetAll() ([]Policy, error)
+	// Create or replace the policy of a campaign.
+	Save(Policy) (Policy, error)
+	Delete(campaignID uint) error
+
+	// Get the instances of a campaign with raw measurements before a time.
+	GetInstances(campaignID uint, before time.Time) ([]Instance, error)
+	// Count the raw measurements of a campaign before a time.
+	CountRaw(campaignID uint, before time.Time) (int64, error)
+	// Count the intervals with raw measurements of a campaign before a time, per instance and property.
+	CountIntervals(campaignID uint, before time.Time, interval time.Duration) (int64, error)
+	// Count the aggregates of a campaign before a time.
+	CountAggregates(campaignID uint, before time.Time) (int64, error)
+	// Call fn for every raw measurement of an instance before a time, ordered by property and time.
+	StreamRaw(instance Instance, before time.Time, fn func(measurement.Measurement) error) error
+	// Permanently delete the raw measurements and latest values of an instance before a time and store
+	// aggregates in their place, in a single transaction. The number of deleted measurements is returned.
+	ReplaceRaw(instance Instance, before time.Time, interval string, aggregates []measurement.Measurement) (int64, error)
+	// Permanently delete the aggregates of a campaign before a time.
+	// The number of deleted aggregates is returned.
+	DeleteAggregates(campaignID uint, before time.Time) (int64, error)
+}
@@ -0,0 +1,118 @@
+// Package retention limits how long the measurements of a campaign are stored.
+package retention
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat"
+	"github.com/energietransitie/needforheat-server-api/needforheat/measurement"
+	"github.com/energietransitie/needforheat-server-api/needforheat/upload"
+)
+
+var (
+	ErrInvalidPolicy = errors.New("invalid retention policy")
+)
+
+// A Policy limits how long the measurements of a campaign are stored, counted from the end of the campaign.
+//
+// Raw measurements are removed RawDays after the end of the campaign. If DownsampleInterval is set,
+// they are replaced with one aggregated measurement per property per interval, which are removed
+// AggregateDays after the end of the campaign.
+type Policy struct {
+	ID         uint `json:"id"`
+	CampaignID uint `json:"campaign_id"`
+	// Number of days raw measurements are kept after the end of the campaign.
+	RawDays int `json:"raw_days"`
+	// Interval of the aggregates raw measurements are replaced with, one of [measurement.Intervals].
+	// Raw measurements are removed without keeping aggregates if it is empty.
+	DownsampleInterval string `json:"downsample_interval,omitempty"`
+	// Number of days aggregates are kept after the end of the campaign.
+	// Aggregates are kept as long as the campaign exists if it is not set.
+	AggregateDays *int `json:"aggregate_days,omitempty"`
+	// Archive raw measurements in a pseudonymised Parquet file before they are removed.
+	Archive bool `json:"archive"`
+}
+
+// Create a new Policy.
+func MakePolicy(campaignID uint, rawDays int, downsampleInterval string, aggregateDays *int, archive bool) (Policy, error) {
+	policy := Policy{
+		CampaignID:         campaignID,
+		RawDays:            rawDays,
+		DownsampleInterval: downsampleInterval,
+		AggregateDays:      aggregateDays,
+		Archive:            archive,
+	}
+
+	return policy, policy.Validate()
+}
+
+// Validate that the days are not negative, aggregates are not removed before raw measurements
+// and that the downsample interval is known.
+func (p Policy) Validate() error {
+	if p.RawDays < 0 {
+		return fmt.Errorf("%w: raw_days should not be negative", ErrInvalidPolicy)
+	}
+
+	if p.DownsampleInterval != "" {
+		if _, ok := measurement.Intervals[p.DownsampleInterval]; !ok {
+			return fmt.Errorf("%w: downsample_interval %q should be one of 5m, 1h or 1d", ErrInvalidPolicy, p.DownsampleInterval)
+		}
+	}
+
+	if p.AggregateDays != nil && *p.AggregateDays < p.RawDays {
+		return fmt.Errorf("%w: aggregate_days should not be less than raw_days", ErrInvalidPolicy)
+	}
+
+	return nil
+}
+
+// Get the time before which raw measurements are removed, for a campaign that ended at end.
+func (p Policy) RawCutoff(end time.Time) time.Time {
+	return end.AddDate(0, 0, p.RawDays)
+}
+
+// Get the time before which aggregates are removed, for a campaign that ended at end.
+// Nil is returned if aggregates are not removed.
+func (p Policy) AggregateCutoff(end time.Time) *time.Time {
+	if p.AggregateDays == nil {
+		return nil
+	}
+
+	cutoff := end.AddDate(0, 0, *p.AggregateDays)
+	return &cutoff
+}
+
+// Get the length of the intervals raw measurements are downsampled to.
+// Zero is returned if raw measurements are not downsampled.
+func (p Policy) Interval() time.Duration {
+	return measurement.Intervals[p.DownsampleInterval]
+}
+
+// An Instance is a device or energy query that uploaded measurements.
+type Instance struct {
+	ID   uint
+	Type upload.InstanceType
+}
+
+// A Report describes what was, or in a dry run would be, removed when enforcing the policy of a campaign.
+type Report struct {
+	CampaignID   uint   `json:"campaign_id"`
+	CampaignName string `json:"campaign_name"`
+	DryRun       bool   `json:"dry_run"`
+	// Reason the policy was not enforced, if it was not.
+	Skipped string `json:"skipped,omitempty"`
+	// Raw measurements before this time are removed, if it has passed.
+	RawCutoff *needforheat.Time `json:"raw_cutoff,omitempty"`
+	// Number of raw measurements that are removed.
+	RawMeasurements int64 `json:"raw_measurements"`
+	// Number of aggregates that replace the raw measurements.
+	Aggregates int64 `json:"aggregates"`
+	// Aggregates before this time are removed, if it has passed.
+	AggregateCutoff *needforheat.Time `json:"aggregate_cutoff,omitempty"`
+	// Number of aggregates that are removed.
+	ExpiredAggregates int64 `json:"expired_aggregates"`
+	// Path of the file raw measurements were archived in.
+	ArchivePath string `json:"archive_path,omitempty"`
+}
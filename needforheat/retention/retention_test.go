@@ -0,0 +1,80 @@
+package retention
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMakePolicy(t *testing.T) {
+	days := func(n int) *int {
+		return &n
+	}
+
+	tests := []struct {
+		name               string
+		rawDays            int
+		downsampleInterval string
+		aggregateDays      *int
+
+		wantErr bool
+	}{
+		{"raw measurements only", 30, "", nil, false},
+		{"remove raw measurements at the end", 0, "", nil, false},
+		{"keep aggregates", 30, "1h", nil, false},
+		{"remove aggregates", 30, "1d", days(365), false},
+		{"remove aggregates with raw measurements", 30, "5m", days(30), false},
+		{"negative raw days", -1, "", nil, true},
+		{"unknown interval", 30, "2h", nil, true},
+		{"aggregates removed before raw measurements", 30, "1h", days(29), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := MakePolicy(1, tt.rawDays, tt.downsampleInterval, tt.aggregateDays, false)
+			if tt.wantErr != errors.Is(err, ErrInvalidPolicy) {
+				t.Errorf("got error %v; want invalid %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPolicyCutoffs(t *testing.T) {
+	end := time.Date(2024, time.January, 31, 12, 0, 0, 0, time.UTC)
+	aggregateDays := 365
+
+	policy, err := MakePolicy(1, 30, "1h", &aggregateDays, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := policy.RawCutoff(end), time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("RawCutoff = %v; want %v", got, want)
+	}
+
+	if got, want := policy.AggregateCutoff(end), time.Date(2025, time.January, 30, 12, 0, 0, 0, time.UTC); got == nil || !got.Equal(want) {
+		t.Errorf("AggregateCutoff = %v; want %v", got, want)
+	}
+
+	if got, want := policy.Interval(), time.Hour; got != want {
+		t.Errorf("Interval = %v; want %v", got, want)
+	}
+
+	// Aggregates are kept if aggregate days is not set.
+	policy.AggregateDays = nil
+	if got := policy.AggregateCutoff(end); got != nil {
+		t.Errorf("AggregateCutoff = %v; want nil", got)
+	}
+
+	// Raw measurements are removed without aggregates if there is no interval.
+	policy.DownsampleInterval = ""
+	if got := policy.Interval(); got != 0 {
+		t.Errorf("Interval = %v; want 0", got)
+	}
+
+	// Raw measurements can be removed when the campaign ends.
+	policy.RawDays = 0
+	if got := policy.RawCutoff(end); !got.Equal(end) {
+		t.Errorf("RawCutoff = %v; want %v", got, end)
+	}
+}
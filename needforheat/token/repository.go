@@ -0,0 +1,16 @@
+package token
+
+import "time"
+
+// A TokenRepository can load, store and revoke tokens.
+type TokenRepository interface {
+	Find(id string) (Token, error)
+	Create(Token) (Token, error)
+	// Create the token if no token with the same ID is stored, and return the stored token.
+	FindOrCreate(Token) (Token, error)
+	Update(Token) (Token, error)
+	// Get the tokens of an account and of its devices that are not revoked and do not expire before a time.
+	GetActiveByAccount(accountID uint, at time.Time) ([]Token, error)
+	// Find a token of an account or of one of its devices.
+	FindByAccount(accountID uint, id string) (Token, error)
+}
@@ -0,0 +1,86 @@
+package token
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat"
+	"github.com/energietransitie/needforheat-server-api/needforheat/authorization"
+)
+
+var (
+	ErrRevoked = errors.New("token is revoked")
+	ErrUnknown = errors.New("token was not issued by this server")
+)
+
+// Prefix of the IDs of tokens that were issued before tokens had an ID.
+const legacyPrefix = "legacy-"
+
+// A Token is an issued account or device token, which can be revoked before it expires.
+type Token struct {
+	ID        string                 `json:"id"`
+	Kind      authorization.AuthKind `json:"kind"`
+	SubjectID uint                   `json:"subject_id"`
+	// Name of the device, if it is a device token.
+	DeviceName string            `json:"device_name,omitempty"`
+	IssuedAt   needforheat.Time  `json:"issued_at"`
+	ExpiresAt  needforheat.Time  `json:"expires_at"`
+	RevokedAt  *needforheat.Time `json:"revoked_at,omitempty"`
+	// Family of the refresh token this token was issued with, if any.
+	FamilyID string `json:"-"`
+}
+
+// Returns if tokens of kind are stored, so they can be listed and revoked.
+func IsTracked(kind authorization.AuthKind) bool {
+	return kind == authorization.AccountToken || kind == authorization.DeviceToken
+}
+
+// Create a new Token from the claims of a token.
+func MakeToken(id uint, claims authorization.Claims) Token {
+	t := Token{
+		ID:        claims.ID,
+		Kind:      claims.Kind,
+		SubjectID: id,
+	}
+
+	if claims.IssuedAt != nil {
+		t.IssuedAt = needforheat.Time(claims.IssuedAt.Time)
+	}
+
+	if claims.ExpiresAt != nil {
+		t.ExpiresAt = needforheat.Time(claims.ExpiresAt.Time)
+	}
+
+	return t
+}
+
+// Create a new Token for a token that was issued before tokens had an ID.
+// The ID is derived from the signed token, so the token can be recognised when it is used again.
+func MakeLegacyToken(tokenString string, id uint, claims authorization.Claims) Token {
+	hash := sha256.Sum256([]byte(tokenString))
+
+	t := MakeToken(id, claims)
+	t.ID = legacyPrefix + hex.EncodeToString(hash[:])
+	return t
+}
+
+// Revoke the token, so it can not be used anymore.
+func (t *Token) Revoke() {
+	if t.RevokedAt != nil {
+		return
+	}
+
+	revokedAt := needforheat.Time(time.Now().UTC())
+	t.RevokedAt = &revokedAt
+}
+
+// Check if the token can still be used.
+func (t *Token) Check() error {
+	if t.RevokedAt != nil {
+		return ErrRevoked
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package upload
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat"
+	"github.com/energietransitie/needforheat-server-api/needforheat/measurement"
+	"github.com/energietransitie/needforheat-server-api/needforheat/property"
+)
+
+var (
+	ErrInvalidColumn = errors.New("invalid column")
+)
+
+// A BatchUpload is an upload in a batch of uploads.
+// Measurements can be sent as a list, or in columns that refer to a table of property names.
+type BatchUpload struct {
+	InstanceID     uint                      `json:"instance_id"`
+	InstanceType   InstanceType              `json:"instance_type"`
+	DeviceTime     needforheat.Time          `json:"device_time"`
+	IdempotencyKey string                    `json:"idempotency_key,omitempty"`
+	Measurements   []measurement.Measurement `json:"measurements,omitempty"`
+	// Names of the properties that are referred to by index in Columns.
+	Properties []string `json:"properties,omitempty"`
+	Columns    []Column `json:"columns,omitempty"`
+}
+
+// A Column contains the measurements of a single property.
+// Times and Values have the same length.
+type Column struct {
+	// Index of the property name in [BatchUpload.Properties].
+	Property int `json:"property"`
+	// Times in unix seconds.
+	Times  []int64           `json:"times"`
+	Values []json.RawMessage `json:"values"`
+}
+
+// Get the measurements of the upload, including the measurements in its columns.
+func (b BatchUpload) AllMeasurements() ([]measurement.Measurement, error) {
+	measurements := b.Measurements
+
+	for i, column := range b.Columns {
+		if column.Property < 0 || column.Property >= len(b.Properties) {
+			return nil, fmt.Errorf("%w: column %d refers to property %d, but there are %d properties", ErrInvalidColumn, i, column.Property, len(b.Properties))
+		}
+
+		if len(column.Times) != len(column.Values) {
+			return nil, fmt.Errorf("%w: column %d has %d times and %d values", ErrInvalidColumn, i, len(column.Times), len(column.Values))
+		}
+
+		name := b.Properties[column.Property]
+
+		for j, t := range column.Times {
+			value, err := measurement.ParseValue(column.Values[j])
+			if err != nil {
+				return nil, fmt.Errorf("%w: value %d of column %d: %w", ErrInvalidColumn, j, i, err)
+			}
+
+			measurements = append(measurements, measurement.Measurement{
+				Property: property.Property{Name: name},
+				Time:     needforheat.Time(time.Unix(t, 0)),
+				Value:    value,
+			})
+		}
+	}
+
+	return measurements, nil
+}
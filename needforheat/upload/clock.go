@@ -0,0 +1,218 @@
+package upload
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat"
+)
+
+var (
+	ErrUnknownClockPolicy = errors.New("unknown clock policy")
+	ErrInvalidThreshold   = errors.New("clock skew threshold should not be negative")
+	ErrClockSkew          = errors.New("clock skew of the upload is above the threshold")
+)
+
+// A ClockPolicy decides what happens with an upload when the clock of the device that created it
+// differs more than a threshold from the clock of the server.
+type ClockPolicy string
+
+const (
+	// Only store the clock skew of the upload.
+	ClockPolicyNone ClockPolicy = "none"
+	// Shift the times of the measurements in the upload by the clock skew.
+	ClockPolicyShift ClockPolicy = "shift"
+	// Reject the upload with [ErrClockSkew].
+	ClockPolicyReject ClockPolicy = "reject"
+)
+
+// Parse a ClockPolicy from s.
+func ParseClockPolicy(s string) (ClockPolicy, error) {
+	switch policy := ClockPolicy(s); policy {
+	case ClockPolicyNone, ClockPolicyShift, ClockPolicyReject:
+		return policy, nil
+	}
+
+	return "", fmt.Errorf("%w: %q", ErrUnknownClockPolicy, s)
+}
+
+// ClockSettings decide how the clock skew of uploads is handled.
+// Fields that are not set are taken from the defaults of the server.
+type ClockSettings struct {
+	Policy ClockPolicy `json:"policy,omitempty"`
+	// Clock skew in seconds above which the policy is applied.
+	Threshold *int64 `json:"threshold,omitempty"`
+}
+
+// Validate that the policy and threshold are valid, if they are set.
+func (c ClockSettings) Validate() error {
+	if c.Policy != "" {
+		_, err := ParseClockPolicy(string(c.Policy))
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.Threshold != nil && *c.Threshold < 0 {
+		return ErrInvalidThreshold
+	}
+
+	return nil
+}
+
+// Get the settings, with the fields that are not set taken from defaults.
+func (c ClockSettings) Or(defaults ClockSettings) ClockSettings {
+	if c.Policy == "" {
+		c.Policy = defaults.Policy
+	}
+
+	if c.Threshold == nil {
+		c.Threshold = defaults.Threshold
+	}
+
+	return c
+}
+
+// Apply the policy to u if its clock skew is above the threshold.
+func (c ClockSettings) Apply(u *Upload) error {
+	if u.ClockSkew == nil {
+		return nil
+	}
+
+	skew := *u.ClockSkew
+	if c.Threshold != nil && abs(skew) <= *c.Threshold {
+		return nil
+	}
+
+	switch c.Policy {
+	case ClockPolicyShift:
+		u.Shift(skew)
+	case ClockPolicyReject:
+		return fmt.Errorf("%w: the device clock differs %d seconds from the server clock", ErrClockSkew, skew)
+	}
+
+	return nil
+}
+
+// Shift the times of the measurements in u by seconds.
+func (u *Upload) Shift(seconds int64) {
+	for i := range u.Measurements {
+		u.Measurements[i].Time = needforheat.Time(time.Time(u.Measurements[i].Time).Add(time.Duration(seconds) * time.Second))
+	}
+
+	u.TimeShift += seconds
+}
+
+// Calculate the clock skew of a device in seconds, from the time on the device and on the server.
+// A positive skew means the clock of the device is behind.
+// No skew is returned if the device did not send its time.
+func clockSkew(deviceTime needforheat.Time, serverTime needforheat.Time) *int64 {
+	if time.Time(deviceTime).IsZero() || time.Time(deviceTime).Unix() == 0 {
+		return nil
+	}
+
+	skew := time.Time(serverTime).Unix() - time.Time(deviceTime).Unix()
+	return &skew
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// A ClockSample is the clock skew of an upload.
+type ClockSample struct {
+	ServerTime needforheat.Time
+	ClockSkew  int64
+	TimeShift  int64
+}
+
+// ClockHealth describes how well the clock of a device keeps time, based on the clock skew of its uploads.
+type ClockHealth struct {
+	// Settings that are applied to new uploads.
+	Settings ClockSettings `json:"settings"`
+	// Number of uploads with a known clock skew.
+	Uploads          int               `json:"uploads"`
+	LatestUploadTime *needforheat.Time `json:"latest_upload_time"`
+	LatestSkew       *int64            `json:"latest_skew"`
+	MinSkew          *int64            `json:"min_skew"`
+	MaxSkew          *int64            `json:"max_skew"`
+	MeanSkew         *float64          `json:"mean_skew"`
+	// Change of the clock skew in seconds per day.
+	Drift *float64 `json:"drift"`
+	// Number of uploads with a clock skew above the threshold.
+	AboveThreshold int `json:"above_threshold"`
+	// Number of uploads of which the measurement times were shifted.
+	Shifted int `json:"shifted"`
+}
+
+// Create a ClockHealth from samples ordered by server time.
+func MakeClockHealth(settings ClockSettings, samples []ClockSample) ClockHealth {
+	health := ClockHealth{
+		Settings: settings,
+		Uploads:  len(samples),
+	}
+
+	if len(samples) == 0 {
+		return health
+	}
+
+	latest := samples[len(samples)-1]
+	health.LatestUploadTime = &latest.ServerTime
+	health.LatestSkew = &latest.ClockSkew
+
+	minSkew, maxSkew := samples[0].ClockSkew, samples[0].ClockSkew
+	var sum float64
+
+	for _, sample := range samples {
+		minSkew = min(minSkew, sample.ClockSkew)
+		maxSkew = max(maxSkew, sample.ClockSkew)
+		sum += float64(sample.ClockSkew)
+
+		if settings.Threshold != nil && abs(sample.ClockSkew) > *settings.Threshold {
+			health.AboveThreshold++
+		}
+
+		if sample.TimeShift != 0 {
+			health.Shifted++
+		}
+	}
+
+	mean := sum / float64(len(samples))
+	health.MinSkew = &minSkew
+	health.MaxSkew = &maxSkew
+	health.MeanSkew = &mean
+	health.Drift = drift(samples)
+
+	return health
+}
+
+// Estimate the change of the clock skew in seconds per day, using linear regression.
+// No drift is returned if the samples do not span a period of time.
+func drift(samples []ClockSample) *float64 {
+	start := time.Time(samples[0].ServerTime)
+
+	var sumX, sumY, sumXY, sumXX float64
+	for _, sample := range samples {
+		x := time.Time(sample.ServerTime).Sub(start).Hours() / 24
+		y := float64(sample.ClockSkew)
+
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+
+	n := float64(len(samples))
+	denominator := n*sumXX - sumX*sumX
+	if len(samples) < 2 || math.Abs(denominator) < 1e-12 {
+		return nil
+	}
+
+	slope := (n*sumXY - sumX*sumY) / denominator
+	return &slope
+}
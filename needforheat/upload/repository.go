@@ -1,10 +1,19 @@
 package upload
 
+import "github.com/energietransitie/needforheat-server-api/needforheat"
+
 // An UploadRepository can load, store and delete uploads.
 type UploadRepository interface {
 	Find(Upload Upload) (Upload, error)
 	GetAll() ([]Upload, error)
-	Create(Upload) (Upload, error)
+	Create(upload Upload, policy ConflictPolicy) (Upload, error)
+	FindByIdempotencyKey(instanceID uint, instanceType InstanceType, key string) (Upload, error)
 	Delete(Upload) error
 	GetLatestUploadForDeviceWithID(id uint) (Upload, error)
+	// Find the ID of the account that owns the instance.
+	FindInstanceAccountID(instanceID uint, instanceType InstanceType) (uint, error)
+	// Get the clock skew of the uploads of the instance since a time, ordered by server time.
+	GetClockSamples(instanceID uint, instanceType InstanceType, since needforheat.Time) ([]ClockSample, error)
+	// Permanently delete all uploads, measurements and latest values of the device with id.
+	DeleteAllForDeviceWithID(id uint) (int64, error)
 }
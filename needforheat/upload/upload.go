@@ -1,12 +1,19 @@
 package upload
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/energietransitie/needforheat-server-api/needforheat"
 	"github.com/energietransitie/needforheat-server-api/needforheat/measurement"
 )
 
+var (
+	ErrMeasurementConflict   = errors.New("measurement conflicts with an existing measurement")
+	ErrUnknownConflictPolicy = errors.New("unknown measurement conflict policy")
+)
+
 // An Upload is a collection of measurements, with additional information.
 type Upload struct {
 	ID           uint                      `json:"id"`
@@ -16,6 +23,20 @@ type Upload struct {
 	DeviceTime   needforheat.Time          `json:"device_time"`
 	Size         int                       `json:"size"`
 	Measurements []measurement.Measurement `json:"measurements,omitempty"`
+	// Optional key chosen by the client. Creating an upload with a key that was
+	// already used by the same instance returns the existing upload.
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+	// Number of measurements that were not stored because they were already stored before.
+	Duplicates int `json:"duplicates"`
+	// Number of measurements that were not stored because their value does not match
+	// the value type of their property.
+	Rejected int `json:"rejected"`
+	// Difference in seconds between the server time and the device time, if the device sent its time.
+	ClockSkew *int64 `json:"clock_skew,omitempty"`
+	// Number of seconds the times of the measurements were shifted to correct the clock skew.
+	TimeShift int64 `json:"time_shift"`
+	// Interval of the aggregates in the upload, if it replaced raw measurements that were downsampled.
+	DownsampleInterval string `json:"downsample_interval,omitempty"`
 }
 
 type InstanceType string
@@ -25,14 +46,43 @@ const (
 	EnergyQuery InstanceType = "energy_query"
 )
 
+// A ConflictPolicy decides what happens when a measurement is uploaded for a property and time
+// that already has a measurement for the same instance.
+// A measurement is always stored only once per instance, property and time.
+type ConflictPolicy string
+
+const (
+	// Keep the measurement that was stored first and ignore the new one.
+	ConflictPolicyKeepFirst ConflictPolicy = "keep_first"
+	// Replace the value of the stored measurement with the new one.
+	ConflictPolicyKeepLast ConflictPolicy = "keep_last"
+	// Reject the whole upload with [ErrMeasurementConflict], even if the stored measurement has the same value.
+	// Measurements with the same value in one upload are still stored once.
+	ConflictPolicyReject ConflictPolicy = "reject"
+)
+
+// Parse a ConflictPolicy from s.
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch policy := ConflictPolicy(s); policy {
+	case ConflictPolicyKeepFirst, ConflictPolicyKeepLast, ConflictPolicyReject:
+		return policy, nil
+	}
+
+	return "", fmt.Errorf("%w: %q", ErrUnknownConflictPolicy, s)
+}
+
 // Create a new Upload.
-func MakeUpload(instanceID uint, instanceType InstanceType, deviceTime needforheat.Time, measurements []measurement.Measurement) Upload {
+func MakeUpload(instanceID uint, instanceType InstanceType, deviceTime needforheat.Time, measurements []measurement.Measurement, idempotencyKey string) Upload {
+	serverTime := needforheat.Time(time.Now().UTC())
+
 	return Upload{
-		InstanceID:   instanceID,
-		InstanceType: instanceType,
-		ServerTime:   needforheat.Time(time.Now().UTC()),
-		DeviceTime:   deviceTime,
-		Size:         len(measurements),
-		Measurements: measurements,
+		InstanceID:     instanceID,
+		InstanceType:   instanceType,
+		ServerTime:     serverTime,
+		DeviceTime:     deviceTime,
+		Size:           len(measurements),
+		Measurements:   measurements,
+		IdempotencyKey: idempotencyKey,
+		ClockSkew:      clockSkew(deviceTime, serverTime),
 	}
 }
@@ -26,12 +26,14 @@ type CloudFeedModel struct {
 	CreatedAt       needforheat.Time
 	UpdatedAt       needforheat.Time
 	DeletedAt       gorm.DeletedAt `gorm:"index"`
-	// TODO: WARNING encrypted string encryption not yet implemented.
-	AccessToken    encryption.EncryptedString
-	RefreshToken   encryption.EncryptedString
-	Expiry         needforheat.Time
-	AuthGrantToken encryption.EncryptedString
-	ActivatedAt    *needforheat.Time
+	AccessToken     encryption.EncryptedString
+	RefreshToken    encryption.EncryptedString
+	Expiry          needforheat.Time
+	AuthGrantToken  encryption.EncryptedString
+	ActivatedAt     *needforheat.Time
+	RefreshFailedAt *needforheat.Time
+	RefreshError    string `gorm:"type:text"`
+	RevokedAt       *needforheat.Time
 }
 
 // Set the name of the table in the database.
@@ -49,6 +51,9 @@ func MakeCloudFeedModel(cloudFeed cloudfeed.CloudFeed) CloudFeedModel {
 		Expiry:          cloudFeed.Expiry,
 		AuthGrantToken:  encryption.EncryptedString(cloudFeed.AuthGrantToken),
 		ActivatedAt:     cloudFeed.ActivatedAt,
+		RefreshFailedAt: cloudFeed.RefreshFailedAt,
+		RefreshError:    cloudFeed.RefreshError,
+		RevokedAt:       cloudFeed.RevokedAt,
 	}
 }
 
@@ -62,6 +67,9 @@ func (m *CloudFeedModel) fromModel() cloudfeed.CloudFeed {
 		Expiry:          m.Expiry,
 		AuthGrantToken:  string(m.AuthGrantToken),
 		ActivatedAt:     m.ActivatedAt,
+		RefreshFailedAt: m.RefreshFailedAt,
+		RefreshError:    m.RefreshError,
+		RevokedAt:       m.RevokedAt,
 	}
 }
 
@@ -74,17 +82,17 @@ func (r *CloudFeedRepository) Find(cloudFeed cloudfeed.CloudFeed) (cloudfeed.Clo
 func (r *CloudFeedRepository) FindOAuthInfo(accountID uint, cloudFeedID uint) (string, string, string, string, error) {
 	var result struct {
 		TokenURL     string
-		RefreshToken string
+		RefreshToken encryption.EncryptedString
 		ClientID     string
-		ClientSecret string
+		ClientSecret encryption.EncryptedString
 	}
 	err := r.db.Table("cloud_feed_type").Select("cloud_feed_type.token_url, cloud_feed.refresh_token AS refresh_token, cloud_feed_type.client_id, cloud_feed_type.client_secret").Joins("JOIN cloud_feed ON cloud_feed_type.id = cloud_feed.cloud_feed_type_id").Where("cloud_feed.account_id = ? AND cloud_feed.cloud_feed_type_id = ?", accountID, cloudFeedID).Scan(&result).Error
-	return result.TokenURL, result.RefreshToken, result.ClientID, result.ClientSecret, err
+	return result.TokenURL, string(result.RefreshToken), result.ClientID, string(result.ClientSecret), err
 }
 
 func (r *CloudFeedRepository) FindFirstTokenToExpire() (uint, uint, needforheat.Time, error) {
 	var cloudFeedModel CloudFeedModel
-	err := r.db.Order("expiry ASC").Where("expiry <> ''").First(&cloudFeedModel).Error
+	err := r.db.Order("expiry ASC").Where("expiry <> '' AND revoked_at IS NULL").First(&cloudFeedModel).Error
 	return cloudFeedModel.AccountID, cloudFeedModel.CloudFeedTypeID, cloudFeedModel.Expiry, err
 }
 
@@ -142,9 +150,9 @@ func (r *CloudFeedRepository) Create(cloudFeed cloudfeed.CloudFeed) (cloudfeed.C
 	// At this point we know that there are no errors,
 	// except maybe that the record was not found, so check.
 	if !helpers.IsMySQLRecordNotFoundError(err) {
-		// Record was found. Check if it was soft deleted.
-		if cfaCheck.DeletedAt.Valid {
-			// Record was soft deleted. Delete it so we can create a new one.
+		// Record was found. Check if it was soft deleted or revoked.
+		if cfaCheck.DeletedAt.Valid || cfaCheck.RevokedAt != nil {
+			// Record was soft deleted or revoked. Delete it so we can create a new one.
 			err := r.db.Unscoped().Delete(&cfaCheck).Error
 			if err != nil {
 				return cloudFeed, err
@@ -161,11 +169,16 @@ func (r *CloudFeedRepository) Create(cloudFeed cloudfeed.CloudFeed) (cloudfeed.C
 
 func (r *CloudFeedRepository) Update(cloudFeed cloudfeed.CloudFeed) (cloudfeed.CloudFeed, error) {
 	cloudFeedModel := MakeCloudFeedModel(cloudFeed)
-	err := r.db.Model(&cloudFeedModel).Updates(cloudFeedModel).Error
+	// Select all columns that can change, so they are also updated when set to their zero value.
+	err := r.db.Model(&cloudFeedModel).
+		Select("access_token", "refresh_token", "expiry", "auth_grant_token", "activated_at", "refresh_failed_at", "refresh_error", "revoked_at").
+		Updates(cloudFeedModel).
+		Error
 	return cloudFeedModel.fromModel(), err
 }
 
+// Delete the CloudFeed permanently, so its tokens are not kept in the database.
 func (r *CloudFeedRepository) Delete(cloudFeed cloudfeed.CloudFeed) error {
 	CloudFeedAuthModel := MakeCloudFeedModel(cloudFeed)
-	return r.db.Delete(&CloudFeedAuthModel).Error
+	return r.db.Unscoped().Delete(&CloudFeedAuthModel).Error
 }
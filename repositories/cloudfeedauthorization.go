@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/internal/encryption"
+	"github.com/energietransitie/needforheat-server-api/needforheat"
+	"github.com/energietransitie/needforheat-server-api/needforheat/cloudfeedauthorization"
+	"gorm.io/gorm"
+)
+
+type CloudFeedAuthorizationRepository struct {
+	db *gorm.DB
+}
+
+// Create a new CloudFeedAuthorizationRepository.
+func NewCloudFeedAuthorizationRepository(db *gorm.DB) *CloudFeedAuthorizationRepository {
+	return &CloudFeedAuthorizationRepository{
+		db: db,
+	}
+}
+
+// Database representation of a [cloudfeedauthorization.CloudFeedAuthorization].
+type CloudFeedAuthorizationModel struct {
+	StateHash       string `gorm:"primaryKey;size:64"`
+	CreatedAt       needforheat.Time
+	AccountID       uint
+	CloudFeedTypeID uint
+	Verifier        encryption.EncryptedString
+	RedirectURL     string
+	ExpiresAt       needforheat.Time `gorm:"index"`
+}
+
+// Set the name of the table in the database.
+func (CloudFeedAuthorizationModel) TableName() string {
+	return "cloud_feed_authorization"
+}
+
+// Create a CloudFeedAuthorizationModel from a [cloudfeedauthorization.CloudFeedAuthorization].
+func MakeCloudFeedAuthorizationModel(authorization cloudfeedauthorization.CloudFeedAuthorization) CloudFeedAuthorizationModel {
+	return CloudFeedAuthorizationModel{
+		StateHash:       authorization.StateHash,
+		AccountID:       authorization.AccountID,
+		CloudFeedTypeID: authorization.CloudFeedTypeID,
+		Verifier:        encryption.EncryptedString(authorization.Verifier),
+		RedirectURL:     authorization.RedirectURL,
+		ExpiresAt:       authorization.ExpiresAt,
+	}
+}
+
+// Create a [cloudfeedauthorization.CloudFeedAuthorization] from a CloudFeedAuthorizationModel.
+func (m *CloudFeedAuthorizationModel) fromModel() cloudfeedauthorization.CloudFeedAuthorization {
+	return cloudfeedauthorization.CloudFeedAuthorization{
+		StateHash:       m.StateHash,
+		AccountID:       m.AccountID,
+		CloudFeedTypeID: m.CloudFeedTypeID,
+		Verifier:        string(m.Verifier),
+		RedirectURL:     m.RedirectURL,
+		ExpiresAt:       m.ExpiresAt,
+	}
+}
+
+func (r *CloudFeedAuthorizationRepository) Create(authorization cloudfeedauthorization.CloudFeedAuthorization) (cloudfeedauthorization.CloudFeedAuthorization, error) {
+	authorizationModel := MakeCloudFeedAuthorizationModel(authorization)
+	err := r.db.Create(&authorizationModel).Error
+	return authorizationModel.fromModel(), err
+}
+
+func (r *CloudFeedAuthorizationRepository) Take(stateHash string) (cloudfeedauthorization.CloudFeedAuthorization, error) {
+	var authorizationModel CloudFeedAuthorizationModel
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("state_hash = ?", stateHash).First(&authorizationModel).Error
+		if err != nil {
+			return err
+		}
+
+		result := tx.Where("state_hash = ?", stateHash).Delete(&CloudFeedAuthorizationModel{})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		// The authorization was taken by a concurrent request.
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return nil
+	})
+
+	return authorizationModel.fromModel(), err
+}
+
+func (r *CloudFeedAuthorizationRepository) DeleteExpired() error {
+	return r.db.Where("expires_at < ?", time.Now()).Delete(&CloudFeedAuthorizationModel{}).Error
+}
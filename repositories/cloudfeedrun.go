@@ -0,0 +1,122 @@
+package repositories
+
+import (
+	"github.com/energietransitie/needforheat-server-api/needforheat"
+	"github.com/energietransitie/needforheat-server-api/needforheat/cloudfeedrun"
+	"gorm.io/gorm"
+)
+
+type CloudFeedRunRepository struct {
+	db *gorm.DB
+}
+
+// Create a new CloudFeedRunRepository.
+func NewCloudFeedRunRepository(db *gorm.DB) *CloudFeedRunRepository {
+	return &CloudFeedRunRepository{
+		db: db,
+	}
+}
+
+// Database representation of a [cloudfeedrun.CloudFeedRun].
+type CloudFeedRunModel struct {
+	gorm.Model
+	AccountID        uint `gorm:"index:idx_cloud_feed_run_cloud_feed"`
+	CloudFeedTypeID  uint `gorm:"index:idx_cloud_feed_run_cloud_feed"`
+	StartPeriod      needforheat.Time
+	EndPeriod        needforheat.Time
+	StartedAt        needforheat.Time `gorm:"index"`
+	Duration         float64
+	MeasurementCount int
+	UploadID         *uint
+	Error            string `gorm:"type:text"`
+}
+
+// Set the name of the table in the database.
+func (CloudFeedRunModel) TableName() string {
+	return "cloud_feed_run"
+}
+
+// Create a CloudFeedRunModel from a [cloudfeedrun.CloudFeedRun].
+func MakeCloudFeedRunModel(run cloudfeedrun.CloudFeedRun) CloudFeedRunModel {
+	return CloudFeedRunModel{
+		Model:            gorm.Model{ID: run.ID},
+		AccountID:        run.AccountID,
+		CloudFeedTypeID:  run.CloudFeedTypeID,
+		StartPeriod:      run.StartPeriod,
+		EndPeriod:        run.EndPeriod,
+		StartedAt:        run.StartedAt,
+		Duration:         run.Duration,
+		MeasurementCount: run.MeasurementCount,
+		UploadID:         run.UploadID,
+		Error:            run.Error,
+	}
+}
+
+// Create a [cloudfeedrun.CloudFeedRun] from a CloudFeedRunModel.
+func (m *CloudFeedRunModel) fromModel() cloudfeedrun.CloudFeedRun {
+	return cloudfeedrun.CloudFeedRun{
+		ID:               m.Model.ID,
+		AccountID:        m.AccountID,
+		CloudFeedTypeID:  m.CloudFeedTypeID,
+		StartPeriod:      m.StartPeriod,
+		EndPeriod:        m.EndPeriod,
+		StartedAt:        m.StartedAt,
+		Duration:         m.Duration,
+		MeasurementCount: m.MeasurementCount,
+		UploadID:         m.UploadID,
+		Error:            m.Error,
+	}
+}
+
+func (r *CloudFeedRunRepository) GetAllByCloudFeed(accountID uint, cloudFeedTypeID uint, limit int) ([]cloudfeedrun.CloudFeedRun, error) {
+	query := r.db.Where("account_id = ? AND cloud_feed_type_id = ?", accountID, cloudFeedTypeID)
+	return r.find(query, limit)
+}
+
+func (r *CloudFeedRunRepository) GetAll(failedOnly bool, limit int) ([]cloudfeedrun.CloudFeedRun, error) {
+	query := r.db
+	if failedOnly {
+		query = query.Where("error <> ''")
+	}
+	return r.find(query, limit)
+}
+
+func (r *CloudFeedRunRepository) FindLatest(accountID uint, cloudFeedTypeID uint, succeededOnly bool) (cloudfeedrun.CloudFeedRun, error) {
+	var runModel CloudFeedRunModel
+
+	query := r.db.Where("account_id = ? AND cloud_feed_type_id = ?", accountID, cloudFeedTypeID)
+	if succeededOnly {
+		query = query.Where("error = ''")
+	}
+
+	err := query.Order("started_at DESC").First(&runModel).Error
+	return runModel.fromModel(), err
+}
+
+func (r *CloudFeedRunRepository) Create(run cloudfeedrun.CloudFeedRun) (cloudfeedrun.CloudFeedRun, error) {
+	runModel := MakeCloudFeedRunModel(run)
+	err := r.db.Create(&runModel).Error
+	return runModel.fromModel(), err
+}
+
+// Find the latest runs matching query.
+func (r *CloudFeedRunRepository) find(query *gorm.DB, limit int) ([]cloudfeedrun.CloudFeedRun, error) {
+	runs := make([]cloudfeedrun.CloudFeedRun, 0)
+
+	query = query.Order("started_at DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var runModels []CloudFeedRunModel
+	err := query.Find(&runModels).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, runModel := range runModels {
+		runs = append(runs, runModel.fromModel())
+	}
+
+	return runs, nil
+}
@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"github.com/energietransitie/needforheat-server-api/internal/encryption"
+	"github.com/energietransitie/needforheat-server-api/needforheat"
 	"github.com/energietransitie/needforheat-server-api/needforheat/cloudfeedtype"
 	"gorm.io/gorm"
 )
@@ -24,12 +25,14 @@ type CloudFeedTypeModel struct {
 	AuthorizationURL string
 	TokenURL         string
 	ClientID         string
-	// TODO: WARNING EncryptedString still has to implement the encryption.
-	ClientSecret    encryption.EncryptedString
-	Scope           string
-	RedirectURL     string
-	CloudFeeds      []CloudFeedModel      `gorm:"foreignKey:CloudFeedTypeID"`
-	DataSourceTypes []DataSourceTypeModel `gorm:"polymorphic:TypeInstance;"`
+	ClientSecret     encryption.EncryptedString
+	Scope            string
+	RedirectURL      string
+	RevocationURL    string
+	CloudFeeds       []CloudFeedModel      `gorm:"foreignKey:CloudFeedTypeID"`
+	DataSourceTypes  []DataSourceTypeModel `gorm:"polymorphic:TypeInstance;"`
+	DownloadSchedule string
+	NextDownloadAt   *needforheat.Time
 }
 
 // Set the name of the table in the database.
@@ -48,6 +51,9 @@ func MakeCloudFeedTypeModel(cloudFeedType cloudfeedtype.CloudFeedType) CloudFeed
 		ClientSecret:     encryption.EncryptedString(cloudFeedType.ClientSecret),
 		Scope:            cloudFeedType.Scope,
 		RedirectURL:      cloudFeedType.RedirectURL,
+		RevocationURL:    cloudFeedType.RevocationURL,
+		DownloadSchedule: cloudFeedType.DownloadSchedule,
+		NextDownloadAt:   cloudFeedType.NextDownloadAt,
 	}
 }
 
@@ -62,6 +68,9 @@ func (m *CloudFeedTypeModel) fromModel() cloudfeedtype.CloudFeedType {
 		ClientSecret:     string(m.ClientSecret),
 		Scope:            m.Scope,
 		RedirectURL:      m.RedirectURL,
+		RevocationURL:    m.RevocationURL,
+		DownloadSchedule: m.DownloadSchedule,
+		NextDownloadAt:   m.NextDownloadAt,
 	}
 }
 
@@ -95,10 +104,35 @@ func (r *CloudFeedTypeRepository) Create(cloudFeedType cloudfeedtype.CloudFeedTy
 
 func (r *CloudFeedTypeRepository) Update(cloudFeedType cloudfeedtype.CloudFeedType) (cloudfeedtype.CloudFeedType, error) {
 	cloudFeedTypeModel := MakeCloudFeedTypeModel(cloudFeedType)
-	err := r.db.Model(&cloudFeedTypeModel).Updates(cloudFeedTypeModel).Error
+	err := r.db.
+		Model(&cloudFeedTypeModel).
+		// Select the columns explicitly, so NextDownloadAt can be cleared.
+		Select("name", "authorization_url", "token_url", "client_id", "client_secret", "scope", "redirect_url", "revocation_url", "download_schedule", "next_download_at").
+		Updates(cloudFeedTypeModel).
+		Error
 	return cloudFeedTypeModel.fromModel(), err
 }
 
+// Claim the next download of a cloud feed type by moving its NextDownloadAt to nextDownloadAt.
+// The claim only succeeds if NextDownloadAt was not changed since cloudFeedType was loaded,
+// so a download is never claimed twice, even by multiple instances of the server.
+func (r *CloudFeedTypeRepository) ClaimDownload(cloudFeedType cloudfeedtype.CloudFeedType, nextDownloadAt needforheat.Time) (bool, error) {
+	query := r.db.Model(&CloudFeedTypeModel{}).Where("id = ?", cloudFeedType.ID)
+
+	if cloudFeedType.NextDownloadAt == nil {
+		query = query.Where("next_download_at IS NULL")
+	} else {
+		query = query.Where("next_download_at = ?", cloudFeedType.NextDownloadAt)
+	}
+
+	result := query.Update("next_download_at", nextDownloadAt)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
 func (r *CloudFeedTypeRepository) Delete(cloudFeedType cloudfeedtype.CloudFeedType) error {
 	cloudFeedTypeModel := MakeCloudFeedTypeModel(cloudFeedType)
 	return r.db.Delete(&cloudFeedTypeModel).Error
@@ -1,6 +1,11 @@
 package repositories
 
 import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/energietransitie/needforheat-server-api/needforheat"
 	"github.com/energietransitie/needforheat-server-api/needforheat/device"
 	"github.com/energietransitie/needforheat-server-api/needforheat/measurement"
@@ -29,7 +34,9 @@ type DeviceModel struct {
 	AccountModelID       uint `gorm:"column:account_id"`
 	ActivationSecretHash string
 	ActivatedAt          *needforheat.Time
-	Uploads              []UploadModel `gorm:"polymorphic:Instance;"`
+	Uploads              []UploadModel      `gorm:"polymorphic:Instance;"`
+	ClockPolicy          upload.ClockPolicy `gorm:"size:16"`
+	ClockSkewThreshold   *int64
 }
 
 // Set the name of the table in the database.
@@ -54,6 +61,8 @@ func MakeDeviceModel(device device.Device) DeviceModel {
 		ActivationSecretHash: device.ActivationSecretHash,
 		ActivatedAt:          device.ActivatedAt,
 		Uploads:              uploadModels,
+		ClockPolicy:          device.Clock.Policy,
+		ClockSkewThreshold:   device.Clock.Threshold,
 	}
 }
 
@@ -73,6 +82,10 @@ func (m *DeviceModel) fromModel() device.Device {
 		ActivationSecretHash: m.ActivationSecretHash,
 		ActivatedAt:          m.ActivatedAt,
 		Uploads:              uploads,
+		Clock: upload.ClockSettings{
+			Policy:    m.ClockPolicy,
+			Threshold: m.ClockSkewThreshold,
+		},
 	}
 }
 
@@ -105,38 +118,180 @@ func (r *DeviceRepository) FindCloudFeedAuthCreationTimeFromDeviceID(deviceID ui
 	return &result.CreatedAt, nil
 }
 
-func (r *DeviceRepository) GetMeasurements(device device.Device, filters map[string]string) ([]measurement.Measurement, error) {
-	// empty array of measurements
-	var measurements []measurement.Measurement = make([]measurement.Measurement, 0)
+// Get the clock skew settings of the device with deviceID. Fields that are not set use the defaults of the server.
+func (r *DeviceRepository) FindClockSettings(deviceID uint) (upload.ClockSettings, error) {
+	var deviceModel DeviceModel
+
+	err := r.db.
+		Select("clock_policy", "clock_skew_threshold").
+		First(&deviceModel, deviceID).
+		Error
 
+	return upload.ClockSettings{
+		Policy:    deviceModel.ClockPolicy,
+		Threshold: deviceModel.ClockSkewThreshold,
+	}, err
+}
+
+func (r *DeviceRepository) UpdateClockSettings(deviceID uint, settings upload.ClockSettings) error {
+	return r.db.
+		Model(&DeviceModel{Model: gorm.Model{ID: deviceID}}).
+		Select("clock_policy", "clock_skew_threshold").
+		Updates(DeviceModel{
+			ClockPolicy:        settings.Policy,
+			ClockSkewThreshold: settings.Threshold,
+		}).
+		Error
+}
+
+// Query the measurements of device, filtered by filters.
+func (r *DeviceRepository) measurements(device device.Device, filters map[string]string) *gorm.DB {
 	query := r.db.
 		Model(&measurement.Measurement{}).
-		Preload("Property").
 		Joins("JOIN upload ON measurement.upload_id = upload.id AND upload.instance_type = 'device'").
 		Joins("JOIN device ON upload.instance_id = device.id").
 		Where("device.id = ?", device.ID)
 
-	// apply filters
-	for name, value := range filters {
-		switch name {
-		case "property":
-			name = "property_id"
-		case "start":
-			name = "measurement.time >= ?"
-		case "end":
-			name = "measurement.time <= ?"
+	return filterMeasurements(query, filters)
+}
+
+func (r *DeviceRepository) GetMeasurements(device device.Device, filters map[string]string) ([]measurement.Measurement, error) {
+	// empty array of measurements
+	var measurements []measurement.Measurement = make([]measurement.Measurement, 0)
+
+	err := r.measurements(device, filters).Preload("Property").Find(&measurements).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return measurements, nil
+}
+
+func (r *DeviceRepository) GetMeasurementsPage(device device.Device, filters map[string]string, page measurement.Page) ([]measurement.Measurement, *measurement.Cursor, error) {
+	return pageMeasurements(r.measurements(device, filters), page)
+}
+
+func (r *DeviceRepository) StreamMeasurements(ctx context.Context, device device.Device, filters map[string]string, fn func(measurement.Measurement) error) error {
+	return streamMeasurements(ctx, r.measurements(device, filters), fn)
+}
+
+// Get the measurements of the device aggregated per property in intervals.
+// The aggregation is done in the database, so only one row per interval is returned.
+func (r *DeviceRepository) GetAggregatedMeasurements(device device.Device, filters map[string]string, aggregation measurement.Aggregation) ([]measurement.Series, error) {
+	seconds := int64(aggregation.Duration() / time.Second)
+
+	// Start of the interval of a measurement, in seconds since the unix epoch.
+	// TIMESTAMPDIFF is used instead of UNIX_TIMESTAMP, because it does not depend on the time zone of the session.
+	// Only properties with a numeric value type are aggregated.
+	measurements := r.db.
+		Table("measurement").
+		Select("measurement.property_id, property.name AS property_name, FLOOR(TIMESTAMPDIFF(SECOND, '1970-01-01 00:00:00', measurement.time) / ?) * ? AS bucket, measurement.time, CAST(measurement.value AS DOUBLE) AS value", seconds, seconds).
+		Joins("JOIN upload ON measurement.upload_id = upload.id AND upload.instance_type = 'device'").
+		Joins("JOIN property ON property.id = measurement.property_id").
+		Where("upload.instance_id = ? AND property.value_type IN ?", device.ID, property.NumericValueTypes)
+	measurements = filterMeasurements(measurements, filters)
+
+	var query *gorm.DB
+
+	switch aggregation.Function {
+	case measurement.Average, measurement.Minimum, measurement.Maximum, measurement.Sum:
+		// The function names are the same in SQL.
+		query = r.db.
+			Table("(?) AS m", measurements).
+			Select(fmt.Sprintf("property_id, property_name, bucket, %s(value) AS value", strings.ToUpper(string(aggregation.Function)))).
+			Group("property_id, property_name, bucket")
+	default:
+		// First and last value of every interval.
+		windowed := r.db.
+			Table("(?) AS m", measurements).
+			Select("property_id, property_name, bucket, " +
+				"FIRST_VALUE(value) OVER (PARTITION BY property_id, bucket ORDER BY time ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) AS first_value, " +
+				"LAST_VALUE(value) OVER (PARTITION BY property_id, bucket ORDER BY time ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) AS last_value")
+
+		buckets := r.db.
+			Table("(?) AS w", windowed).
+			Select("property_id, property_name, bucket, MIN(first_value) AS first_value, MIN(last_value) AS last_value").
+			Group("property_id, property_name, bucket")
+
+		value := map[measurement.AggregateFunction]string{
+			measurement.First: "first_value",
+			measurement.Last:  "last_value",
+			measurement.Delta: "last_value - COALESCE(LAG(last_value) OVER (PARTITION BY property_id ORDER BY bucket), first_value)",
+		}[aggregation.Function]
+
+		query = r.db.
+			Table("(?) AS b", buckets).
+			Select("property_id, property_name, bucket, " + value + " AS value")
+	}
+
+	var rows []struct {
+		PropertyID   uint
+		PropertyName string
+		Bucket       int64
+		Value        float64
+	}
+
+	err := query.Order("property_id, bucket").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	series := make([]measurement.Series, 0)
+	for _, row := range rows {
+		if len(series) == 0 || series[len(series)-1].Property.ID != row.PropertyID {
+			series = append(series, measurement.Series{
+				Property: property.Property{ID: row.PropertyID, Name: row.PropertyName},
+				Interval: aggregation.Interval,
+				Function: aggregation.Function,
+				Times:    make([]needforheat.Time, 0),
+				Values:   make([]float64, 0),
+			})
 		}
 
-		query = query.Where(name, value)
+		series[len(series)-1].Append(time.Unix(row.Bucket, 0).UTC(), row.Value)
 	}
 
-	err := query.Find(&measurements).Error
+	return series, nil
+}
+
+// Get the number of measurements per property per day, for measurements from start up to end.
+func (r *DeviceRepository) GetMeasurementCountsPerDay(device device.Device, start, end needforheat.Time) ([]measurement.DayCount, error) {
+	var results []struct {
+		PropertyID uint
+		Day        string
+		Count      int
+	}
+
+	err := r.db.
+		Table("measurement").
+		Select("measurement.property_id, DATE_FORMAT(measurement.time, '%Y-%m-%d') AS day, COUNT(*) AS count").
+		Joins("JOIN upload ON measurement.upload_id = upload.id AND upload.instance_type = 'device'").
+		Where("upload.instance_id = ?", device.ID).
+		Where("measurement.time >= ? AND measurement.time < ?", start, end).
+		Group("measurement.property_id, day").
+		Scan(&results).
+		Error
 
 	if err != nil {
 		return nil, err
 	}
 
-	return measurements, nil
+	counts := make([]measurement.DayCount, 0, len(results))
+	for _, result := range results {
+		day, err := time.Parse(time.DateOnly, result.Day)
+		if err != nil {
+			return nil, err
+		}
+
+		counts = append(counts, measurement.DayCount{
+			PropertyID: result.PropertyID,
+			Day:        day,
+			Count:      result.Count,
+		})
+	}
+
+	return counts, nil
 }
 
 func (r *DeviceRepository) GetProperties(device device.Device) ([]property.Property, error) {
@@ -144,7 +299,7 @@ func (r *DeviceRepository) GetProperties(device device.Device) ([]property.Prope
 
 	err := r.db.
 		Table("device").
-		Select("DISTINCT property.id, property.name").
+		Select("DISTINCT property.id, property.name, property.value_type, property.type_confirmed, property.unit, property.cumulative, property.description, property.deprecated_at").
 		Joins("JOIN upload ON device.id = upload.instance_id AND upload.instance_type = 'device'").
 		Joins("JOIN measurement ON upload.id = measurement.upload_id").
 		Joins("JOIN property ON property.id = measurement.property_id").
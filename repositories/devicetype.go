@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"github.com/energietransitie/needforheat-server-api/needforheat/devicetype"
+	"github.com/energietransitie/needforheat-server-api/needforheat/property"
 	"gorm.io/gorm"
 )
 
@@ -21,6 +22,8 @@ type DeviceTypeModel struct {
 	gorm.Model
 	Name            string                `gorm:"unique;non null"`
 	DataSourceTypes []DataSourceTypeModel `gorm:"polymorphic:TypeInstance;"`
+	Strict          bool
+	Properties      []PropertyModel `gorm:"many2many:device_type_property;joinForeignKey:DeviceTypeID;joinReferences:PropertyID"`
 }
 
 // Set the name of the table in the database.
@@ -30,23 +33,39 @@ func (DeviceTypeModel) TableName() string {
 
 // Create a DeviceTypeModel from a [devicetype.DeviceType].
 func MakeDeviceTypeModel(deviceType devicetype.DeviceType) DeviceTypeModel {
+	var propertyModels []PropertyModel
+
+	for _, property := range deviceType.Properties {
+		propertyModels = append(propertyModels, MakePropertyModel(property))
+	}
+
 	return DeviceTypeModel{
-		Model: gorm.Model{ID: deviceType.ID},
-		Name:  deviceType.Name,
+		Model:      gorm.Model{ID: deviceType.ID},
+		Name:       deviceType.Name,
+		Strict:     deviceType.Strict,
+		Properties: propertyModels,
 	}
 }
 
 // Create a [devicetype.DeviceType] from a DeviceTypeModel.
 func (m *DeviceTypeModel) fromModel() devicetype.DeviceType {
+	var properties []property.Property
+
+	for _, propertyModel := range m.Properties {
+		properties = append(properties, propertyModel.fromModel())
+	}
+
 	return devicetype.DeviceType{
-		ID:   m.Model.ID,
-		Name: m.Name,
+		ID:         m.Model.ID,
+		Name:       m.Name,
+		Strict:     m.Strict,
+		Properties: properties,
 	}
 }
 
 func (r *DeviceTypeRepository) Find(deviceType devicetype.DeviceType) (devicetype.DeviceType, error) {
 	deviceTypeModel := MakeDeviceTypeModel(deviceType)
-	err := r.db.Where(&deviceTypeModel).First(&deviceTypeModel).Error
+	err := r.db.Preload("Properties").Where(&deviceTypeModel).First(&deviceTypeModel).Error
 	return deviceTypeModel.fromModel(), err
 }
 
@@ -72,6 +91,37 @@ func (r *DeviceTypeRepository) Create(deviceType devicetype.DeviceType) (devicet
 	return deviceTypeModel.fromModel(), err
 }
 
+func (r *DeviceTypeRepository) Update(deviceType devicetype.DeviceType) (devicetype.DeviceType, error) {
+	deviceTypeModel := MakeDeviceTypeModel(deviceType)
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// Select the column explicitly, so strict can be disabled.
+		err := tx.Model(&deviceTypeModel).Select("strict").Updates(deviceTypeModel).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&deviceTypeModel).Association("Properties").Replace(deviceTypeModel.Properties)
+	})
+	if err != nil {
+		return deviceType, err
+	}
+
+	err = r.db.Preload("Properties").First(&deviceTypeModel, deviceTypeModel.ID).Error
+	return deviceTypeModel.fromModel(), err
+}
+
+func (r *DeviceTypeRepository) FindByDeviceID(deviceID uint) (devicetype.DeviceType, error) {
+	var deviceTypeModel DeviceTypeModel
+	err := r.db.
+		Preload("Properties").
+		Joins("JOIN device ON device.device_type_id = device_type.id").
+		Where("device.id = ?", deviceID).
+		First(&deviceTypeModel).
+		Error
+	return deviceTypeModel.fromModel(), err
+}
+
 func (r *DeviceTypeRepository) Delete(deviceType devicetype.DeviceType) error {
 	deviceTypeModel := MakeDeviceTypeModel(deviceType)
 	return r.db.Delete(&deviceTypeModel).Error
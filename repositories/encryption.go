@@ -0,0 +1,112 @@
+package repositories
+
+import (
+	"fmt"
+
+	"github.com/energietransitie/needforheat-server-api/internal/encryption"
+	"gorm.io/gorm"
+)
+
+// A table with columns that contain values of [encryption.EncryptedString].
+type encryptedTable struct {
+	name        string
+	primaryKeys []string
+	columns     []string
+}
+
+// All tables with columns that contain values of [encryption.EncryptedString].
+var encryptedTables = []encryptedTable{
+	{
+		name:        "cloud_feed",
+		primaryKeys: []string{"account_id", "cloud_feed_type_id"},
+		columns:     []string{"access_token", "refresh_token", "auth_grant_token"},
+	},
+	{
+		name:        "cloud_feed_type",
+		primaryKeys: []string{"id"},
+		columns:     []string{"client_secret"},
+	},
+}
+
+// ReEncryptSecrets encrypts all secrets in the database with the primary key of keyring.
+// Secrets that are stored as plaintext, or that are encrypted with an older key, are encrypted again.
+// Soft deleted rows are included. The number of values that were encrypted again is returned.
+func ReEncryptSecrets(db *gorm.DB, keyring *encryption.Keyring) (int, error) {
+	count := 0
+
+	for _, table := range encryptedTables {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var rows []map[string]any
+			err := tx.Table(table.name).Select(append(table.primaryKeys, table.columns...)).Find(&rows).Error
+			if err != nil {
+				return err
+			}
+
+			for _, row := range rows {
+				for _, column := range table.columns {
+					value, err := reEncryptValue(keyring, row[column])
+					if err != nil {
+						return fmt.Errorf("error encrypting %s.%s: %w", table.name, column, err)
+					}
+
+					if value == nil {
+						continue
+					}
+
+					query := tx.Table(table.name)
+					for _, key := range table.primaryKeys {
+						query = query.Where(key+" = ?", row[key])
+					}
+
+					err = query.Update(column, *value).Error
+					if err != nil {
+						return err
+					}
+
+					count++
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return count, err
+		}
+	}
+
+	return count, nil
+}
+
+// Return the stored value encrypted with the primary key of keyring.
+// Nil is returned if the value does not have to be encrypted again.
+func reEncryptValue(keyring *encryption.Keyring, stored any) (*string, error) {
+	var value string
+	switch stored := stored.(type) {
+	case []byte:
+		value = string(stored)
+	case string:
+		value = stored
+	default:
+		return nil, nil
+	}
+
+	if value == "" || encryption.KeyID(value) == keyring.PrimaryKeyID() {
+		return nil, nil
+	}
+
+	plaintext := value
+	if encryption.IsEncrypted(value) {
+		var err error
+		plaintext, err = keyring.Decrypt(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	encrypted, err := keyring.Encrypt(plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encrypted, nil
+}
@@ -1,6 +1,8 @@
 package repositories
 
 import (
+	"context"
+
 	"github.com/energietransitie/needforheat-server-api/needforheat"
 	"github.com/energietransitie/needforheat-server-api/needforheat/energyquery"
 	"github.com/energietransitie/needforheat-server-api/needforheat/measurement"
@@ -76,32 +78,22 @@ func (r *EnergyQueryRepository) Find(energyQuery energyquery.EnergyQuery) (energ
 	return EnergyQueryModel.fromModel(), err
 }
 
-func (r *EnergyQueryRepository) GetMeasurements(energyQuery energyquery.EnergyQuery, filters map[string]string) ([]measurement.Measurement, error) {
-	// empty array of measurements
-	var measurements []measurement.Measurement = make([]measurement.Measurement, 0)
-
+// Query the measurements of energyQuery, filtered by filters.
+func (r *EnergyQueryRepository) measurements(energyQuery energyquery.EnergyQuery, filters map[string]string) *gorm.DB {
 	query := r.db.
 		Model(&measurement.Measurement{}).
-		Preload("Property").
 		Joins("JOIN upload ON measurement.upload_id = upload.id").
 		Joins("JOIN energy_query ON upload.instance_id = energy_query.id AND upload.instance_type = 'energy_query'").
 		Where("energy_query.id = ?", energyQuery.ID)
 
-	// apply filters
-	for name, value := range filters {
-		switch name {
-		case "property":
-			name = "property_id"
-		case "start":
-			name = "measurement.time >= ?"
-		case "end":
-			name = "measurement.time <= ?"
-		}
-
-		query = query.Where(name, value)
-	}
+	return filterMeasurements(query, filters)
+}
 
-	err := query.Find(&measurements).Error
+func (r *EnergyQueryRepository) GetMeasurements(energyQuery energyquery.EnergyQuery, filters map[string]string) ([]measurement.Measurement, error) {
+	// empty array of measurements
+	var measurements []measurement.Measurement = make([]measurement.Measurement, 0)
+
+	err := r.measurements(energyQuery, filters).Preload("Property").Find(&measurements).Error
 
 	if err != nil {
 		return nil, err
@@ -110,12 +102,20 @@ func (r *EnergyQueryRepository) GetMeasurements(energyQuery energyquery.EnergyQu
 	return measurements, nil
 }
 
+func (r *EnergyQueryRepository) GetMeasurementsPage(energyQuery energyquery.EnergyQuery, filters map[string]string, page measurement.Page) ([]measurement.Measurement, *measurement.Cursor, error) {
+	return pageMeasurements(r.measurements(energyQuery, filters), page)
+}
+
+func (r *EnergyQueryRepository) StreamMeasurements(ctx context.Context, energyQuery energyquery.EnergyQuery, filters map[string]string, fn func(measurement.Measurement) error) error {
+	return streamMeasurements(ctx, r.measurements(energyQuery, filters), fn)
+}
+
 func (r *EnergyQueryRepository) GetProperties(energyQuery energyquery.EnergyQuery) ([]property.Property, error) {
 	var properties []property.Property = make([]property.Property, 0)
 
 	err := r.db.
 		Table("energy_query").
-		Select("DISTINCT property.id, property.name").
+		Select("DISTINCT property.id, property.name, property.value_type, property.type_confirmed, property.unit, property.cumulative, property.description, property.deprecated_at").
 		Joins("JOIN upload ON energy_query.id = upload.instance_id AND upload.instance_type = 'energy_query'").
 		Joins("JOIN measurement ON upload.id = measurement.upload_id").
 		Joins("JOIN property ON property.id = measurement.property_id").
@@ -0,0 +1,134 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat/export"
+	"gorm.io/gorm"
+)
+
+type ExportRepository struct {
+	db *gorm.DB
+}
+
+// Create a new ExportRepository.
+func NewExportRepository(db *gorm.DB) *ExportRepository {
+	return &ExportRepository{
+		db: db,
+	}
+}
+
+func (r *ExportRepository) Stream(ctx context.Context, filter export.Filter, fn func(export.Record) error) error {
+	deviceMeasurements := r.filter(r.db.
+		Table("measurement").
+		Select("account.id AS account_id, 'device' AS instance_type, device.name AS instance_name, device_type.name AS source_type, property.name AS property, measurement.time, measurement.value").
+		Joins("JOIN upload ON measurement.upload_id = upload.id AND upload.instance_type = 'device'").
+		Joins("JOIN device ON upload.instance_id = device.id").
+		Joins("JOIN device_type ON device.device_type_id = device_type.id").
+		Joins("JOIN account ON device.account_id = account.id").
+		Joins("JOIN property ON measurement.property_id = property.id"), filter)
+
+	energyQueryMeasurements := r.filter(r.db.
+		Table("measurement").
+		Select("account.id AS account_id, 'energy_query' AS instance_type, CAST(energy_query.id AS CHAR) AS instance_name, energy_query_type.energy_query_variety AS source_type, property.name AS property, measurement.time, measurement.value").
+		Joins("JOIN upload ON measurement.upload_id = upload.id AND upload.instance_type = 'energy_query'").
+		Joins("JOIN energy_query ON upload.instance_id = energy_query.id").
+		Joins("JOIN energy_query_type ON energy_query.energy_query_type_id = energy_query_type.id").
+		Joins("JOIN account ON energy_query.account_id = account.id").
+		Joins("JOIN property ON measurement.property_id = property.id"), filter)
+
+	// Cloud feeds upload their measurements to a device, so they are part of the device measurements.
+	rows, err := r.db.WithContext(ctx).Raw("(?) UNION ALL (?)", deviceMeasurements, energyQueryMeasurements).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var record export.Record
+
+		err = rows.Scan(&record.AccountID, &record.InstanceType, &record.InstanceName, &record.SourceType, &record.Property, &record.Time, &record.Value)
+		if err != nil {
+			return err
+		}
+
+		err = fn(record)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (r *ExportRepository) GetAccounts(campaignID uint) ([]export.AccountRecord, error) {
+	var records []export.AccountRecord
+	err := r.db.
+		Table("account").
+		Select("account.id AS account_id, account.activated_at").
+		Where("account.campaign_id = ? AND account.deleted_at IS NULL", campaignID).
+		Order("account.id").
+		Scan(&records).
+		Error
+
+	return records, err
+}
+
+func (r *ExportRepository) GetInstances(campaignID uint, instanceType string) ([]export.InstanceRecord, error) {
+	var query *gorm.DB
+
+	switch instanceType {
+	case "device":
+		query = r.db.
+			Table("device").
+			Select("account.id AS account_id, 'device' AS instance_type, device.name AS instance_name, device_type.name AS source_type, device.activated_at").
+			Joins("JOIN device_type ON device.device_type_id = device_type.id").
+			Joins("JOIN account ON device.account_id = account.id").
+			Where("device.deleted_at IS NULL").
+			Order("account.id, device.id")
+	case "energy_query":
+		query = r.db.
+			Table("energy_query").
+			Select("account.id AS account_id, 'energy_query' AS instance_type, CAST(energy_query.id AS CHAR) AS instance_name, energy_query_type.energy_query_variety AS source_type, energy_query.activated_at").
+			Joins("JOIN energy_query_type ON energy_query.energy_query_type_id = energy_query_type.id").
+			Joins("JOIN account ON energy_query.account_id = account.id").
+			Where("energy_query.deleted_at IS NULL").
+			Order("account.id, energy_query.id")
+	default:
+		return nil, fmt.Errorf("unknown instance type %q", instanceType)
+	}
+
+	var records []export.InstanceRecord
+	err := query.
+		Where("account.campaign_id = ? AND account.deleted_at IS NULL", campaignID).
+		Scan(&records).
+		Error
+
+	return records, err
+}
+
+// Apply filter to a query on the measurements of a campaign.
+func (r *ExportRepository) filter(query *gorm.DB, filter export.Filter) *gorm.DB {
+	query = query.
+		Where("account.campaign_id = ?", filter.CampaignID).
+		Where("measurement.deleted_at IS NULL AND upload.deleted_at IS NULL")
+
+	if filter.Start != nil {
+		query = query.Where("measurement.time >= ?", *filter.Start)
+	}
+
+	if filter.End != nil {
+		query = query.Where("measurement.time <= ?", *filter.End)
+	}
+
+	if len(filter.Properties) > 0 {
+		query = query.Where("property.name IN ?", filter.Properties)
+	}
+
+	if filter.Raw {
+		query = query.Where("upload.downsample_interval = ''")
+	}
+
+	return query
+}
@@ -0,0 +1,166 @@
+package repositories
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat"
+	"github.com/energietransitie/needforheat-server-api/needforheat/latestvalue"
+	"github.com/energietransitie/needforheat-server-api/needforheat/upload"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LatestValueRepository struct {
+	db *gorm.DB
+}
+
+// Create a new LatestValueRepository.
+func NewLatestValueRepository(db *gorm.DB) *LatestValueRepository {
+	return &LatestValueRepository{
+		db: db,
+	}
+}
+
+// Database representation of a [latestvalue.LatestValue].
+type LatestValueModel struct {
+	InstanceType    upload.InstanceType `gorm:"primaryKey;size:32"`
+	InstanceID      uint                `gorm:"primaryKey;autoIncrement:false"`
+	PropertyModelID uint                `gorm:"column:property_id;primaryKey;autoIncrement:false"`
+	Property        PropertyModel
+	CreatedAt       needforheat.Time
+	UpdatedAt       needforheat.Time
+	UploadModelID   uint `gorm:"column:upload_id"`
+	UploadTime      needforheat.Time
+	Time            time.Time
+	Value           string
+}
+
+// Set the name of the table in the database.
+func (LatestValueModel) TableName() string {
+	return "latest_value"
+}
+
+// Create a LatestValueModel from a [latestvalue.LatestValue].
+func MakeLatestValueModel(value latestvalue.LatestValue) LatestValueModel {
+	return LatestValueModel{
+		InstanceType:    value.InstanceType,
+		InstanceID:      value.InstanceID,
+		PropertyModelID: value.Property.ID,
+		UploadModelID:   value.UploadID,
+		UploadTime:      value.UploadTime,
+		Time:            time.Time(value.Time),
+		Value:           value.Value,
+	}
+}
+
+// Create a [latestvalue.LatestValue] from a LatestValueModel.
+func (m *LatestValueModel) fromModel() latestvalue.LatestValue {
+	return latestvalue.LatestValue{
+		InstanceID:   m.InstanceID,
+		InstanceType: StringToType(string(m.InstanceType)),
+		Property:     m.Property.fromModel(),
+		Time:         needforheat.Time(m.Time),
+		Value:        m.Value,
+		UploadID:     m.UploadModelID,
+		UploadTime:   m.UploadTime,
+	}
+}
+
+func (r *LatestValueRepository) Update(values []latestvalue.LatestValue) error {
+	valueModels := make([]LatestValueModel, 0, len(values))
+	for _, value := range values {
+		valueModels = append(valueModels, MakeLatestValueModel(value))
+	}
+
+	return upsertLatestValues(r.db, valueModels)
+}
+
+// Insert latest values, or update the stored values of the same instance and property
+// with the values that are at least as recent.
+func upsertLatestValues(tx *gorm.DB, valueModels []LatestValueModel) error {
+	if len(valueModels) == 0 {
+		return nil
+	}
+
+	// MySQL assigns the columns in order, so the time is assigned after the columns that compare it.
+	return tx.
+		Clauses(clause.OnConflict{
+			DoUpdates: clause.Set{
+				newerLatestValue("value"),
+				newerLatestValue("upload_id"),
+				newerLatestValue("upload_time"),
+				newerLatestValue("updated_at"),
+				newerLatestValue("time"),
+			},
+		}).
+		Omit("Property").
+		Create(&valueModels).
+		Error
+}
+
+// Assign the inserted column of a latest value if it is at least as recent as the stored value.
+func newerLatestValue(column string) clause.Assignment {
+	return clause.Assignment{
+		Column: clause.Column{Name: column},
+		Value:  gorm.Expr(fmt.Sprintf("IF(VALUES(`time`) >= latest_value.`time`, VALUES(`%s`), latest_value.`%s`)", column, column)),
+	}
+}
+
+func (r *LatestValueRepository) FindByInstance(instanceID uint, instanceType upload.InstanceType) ([]latestvalue.LatestValue, error) {
+	query := r.db.
+		Joins("Property").
+		Where("latest_value.instance_id = ? AND latest_value.instance_type = ?", instanceID, instanceType)
+
+	return findLatestValues(query)
+}
+
+func (r *LatestValueRepository) FindByAccount(accountID uint) ([]latestvalue.LatestValue, error) {
+	query := r.db.
+		Joins("Property").
+		Joins("LEFT JOIN device ON latest_value.instance_type = 'device' AND latest_value.instance_id = device.id AND device.deleted_at IS NULL").
+		Joins("LEFT JOIN energy_query ON latest_value.instance_type = 'energy_query' AND latest_value.instance_id = energy_query.id AND energy_query.deleted_at IS NULL").
+		Where("COALESCE(device.account_id, energy_query.account_id) = ?", accountID).
+		Order("latest_value.instance_type, latest_value.instance_id")
+
+	return findLatestValues(query)
+}
+
+// Find the latest values selected by query, ordered by property name after the order of query.
+func findLatestValues(query *gorm.DB) ([]latestvalue.LatestValue, error) {
+	var valueModels []LatestValueModel
+	err := query.Order("`Property`.`name`").Find(&valueModels).Error
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]latestvalue.LatestValue, 0, len(valueModels))
+	for _, valueModel := range valueModels {
+		values = append(values, valueModel.fromModel())
+	}
+
+	return values, nil
+}
+
+// Fill the latest values from the stored measurements, if there are no latest values yet.
+// This is only needed once, for measurements that were stored before latest values were maintained.
+func migrateLatestValues(db *gorm.DB) error {
+	var count int64
+	err := db.Model(&LatestValueModel{}).Limit(1).Count(&count).Error
+	if err != nil || count > 0 {
+		return err
+	}
+
+	return db.Exec(`
+		INSERT INTO latest_value (instance_type, instance_id, property_id, created_at, updated_at, upload_id, upload_time, time, value)
+		SELECT instance_type, instance_id, property_id, NOW(3), NOW(3), upload_id, server_time, time, value
+		FROM (
+			SELECT upload.instance_type, upload.instance_id, measurement.property_id, measurement.upload_id, upload.server_time, measurement.time, measurement.value,
+				ROW_NUMBER() OVER (PARTITION BY upload.instance_type, upload.instance_id, measurement.property_id ORDER BY measurement.time DESC, measurement.id DESC) AS n
+			FROM measurement
+			JOIN upload ON measurement.upload_id = upload.id
+			WHERE measurement.deleted_at IS NULL AND upload.deleted_at IS NULL
+		) AS latest
+		WHERE n = 1`).
+		Error
+}
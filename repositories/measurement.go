@@ -1,20 +1,29 @@
 package repositories
 
 import (
+	"context"
 	"time"
 
 	"github.com/energietransitie/needforheat-server-api/needforheat"
 	"github.com/energietransitie/needforheat-server-api/needforheat/measurement"
+	"github.com/energietransitie/needforheat-server-api/needforheat/upload"
+	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
+// Name of the unique index that stores a measurement only once per instance, property and time.
+const measurementUniqueIndex = "idx_measurement_instance_property_time"
+
 // Database representation of a [measurement.Measurement]
 type MeasurementModel struct {
 	gorm.Model
-	PropertyModelID uint `gorm:"column:property_id"`
+	// The instance is also stored in the upload. It is stored here for the unique index.
+	InstanceID      uint                `gorm:"column:instance_id;not null;default:0;uniqueIndex:idx_measurement_instance_property_time,priority:1"`
+	InstanceType    upload.InstanceType `gorm:"size:32;not null;default:'';uniqueIndex:idx_measurement_instance_property_time,priority:2"`
+	PropertyModelID uint                `gorm:"column:property_id;index:idx_measurement_property_time,priority:1;uniqueIndex:idx_measurement_instance_property_time,priority:3"`
 	Property        PropertyModel
-	UploadModelID   uint `gorm:"column:upload_id"`
-	Time            time.Time
+	UploadModelID   uint      `gorm:"column:upload_id"`
+	Time            time.Time `gorm:"index:idx_measurement_property_time,priority:2;uniqueIndex:idx_measurement_instance_property_time,priority:4"`
 	Value           string
 }
 
@@ -45,3 +54,130 @@ func (m *MeasurementModel) fromModel() measurement.Measurement {
 		Value:    m.Value,
 	}
 }
+
+// Apply the property, start and end filters to a query on measurements.
+func filterMeasurements(query *gorm.DB, filters map[string]string) *gorm.DB {
+	for name, value := range filters {
+		switch name {
+		case "property":
+			query = query.Where("measurement.property_id = ?", value)
+		case "start":
+			query = query.Where("measurement.time >= ?", value)
+		case "end":
+			query = query.Where("measurement.time <= ?", value)
+		}
+	}
+
+	return query
+}
+
+// Get a page of the measurements selected by query, ordered by time and ID.
+// A cursor pointing to the last measurement is returned if there are more measurements.
+func pageMeasurements(query *gorm.DB, page measurement.Page) ([]measurement.Measurement, *measurement.Cursor, error) {
+	measurements := make([]measurement.Measurement, 0, page.Limit)
+
+	if page.After != nil {
+		query = query.Where("measurement.time > ? OR (measurement.time = ? AND measurement.id > ?)", page.After.Time, page.After.Time, page.After.ID)
+	}
+
+	// Get one extra measurement to know if there is a next page.
+	err := query.
+		Preload("Property").
+		Order("measurement.time, measurement.id").
+		Limit(page.Limit + 1).
+		Find(&measurements).
+		Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(measurements) <= page.Limit {
+		return measurements, nil, nil
+	}
+
+	measurements = measurements[:page.Limit]
+	cursor := measurement.MakeCursor(measurements[len(measurements)-1])
+	return measurements, &cursor, nil
+}
+
+// Call fn for every measurement selected by query, ordered by time and ID.
+// Measurements are read from the database while they are passed to fn,
+// so they are never all loaded in memory. Reading stops when fn returns an error or when ctx is done.
+func streamMeasurements(ctx context.Context, query *gorm.DB, fn func(measurement.Measurement) error) error {
+	rows, err := query.
+		WithContext(ctx).
+		Select("measurement.id, measurement.upload_id, measurement.property_id, property.name, COALESCE(property.value_type, ''), COALESCE(property.type_confirmed, FALSE), COALESCE(property.unit, ''), COALESCE(property.cumulative, FALSE), COALESCE(property.description, ''), property.deprecated_at, measurement.time, measurement.value").
+		Joins("JOIN property ON property.id = measurement.property_id").
+		Order("measurement.time, measurement.id").
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m measurement.Measurement
+		var t time.Time
+
+		err = rows.Scan(&m.ID, &m.UploadID, &m.Property.ID, &m.Property.Name, &m.Property.ValueType, &m.Property.TypeConfirmed, &m.Property.Unit, &m.Property.Cumulative, &m.Property.Description, &m.Property.DeprecatedAt, &t, &m.Value)
+		if err != nil {
+			return err
+		}
+		m.Time = needforheat.Time(t)
+
+		err = fn(m)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// Store the instance of existing measurements in the measurement table and remove measurements
+// that were stored multiple times for the same instance, property and time, keeping the first one.
+// This has to be done before the unique index on measurements can be created.
+func migrateMeasurementInstances(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&MeasurementModel{}) || migrator.HasIndex(&MeasurementModel{}, measurementUniqueIndex) {
+		return nil
+	}
+
+	for _, column := range []string{"InstanceID", "InstanceType"} {
+		if migrator.HasColumn(&MeasurementModel{}, column) {
+			continue
+		}
+
+		err := migrator.AddColumn(&MeasurementModel{}, column)
+		if err != nil {
+			return err
+		}
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec("UPDATE measurement JOIN upload ON measurement.upload_id = upload.id SET measurement.instance_id = upload.instance_id, measurement.instance_type = upload.instance_type").Error
+		if err != nil {
+			return err
+		}
+
+		duplicates := "FROM measurement JOIN measurement AS kept ON kept.instance_id = measurement.instance_id AND kept.instance_type = measurement.instance_type " +
+			"AND kept.property_id = measurement.property_id AND kept.time = measurement.time AND kept.id < measurement.id"
+
+		err = tx.Exec("UPDATE upload JOIN (SELECT measurement.upload_id, COUNT(DISTINCT measurement.id) AS count " + duplicates + " GROUP BY measurement.upload_id) AS duplicate " +
+			"ON duplicate.upload_id = upload.id SET upload.size = upload.size - duplicate.count").Error
+		if err != nil {
+			return err
+		}
+
+		result := tx.Exec("DELETE measurement " + duplicates)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected > 0 {
+			logrus.Infoln("removed", result.RowsAffected, "measurements that were stored multiple times")
+		}
+
+		return nil
+	})
+}
@@ -45,7 +45,12 @@ func NewDatabaseConnectionAndMigrate(ctx context.Context, dsn string) (db *gorm.
 	for {
 		db, err = NewDatabaseConnection(dsn)
 		if err == nil {
-			return db, db.AutoMigrate(
+			err = migrateMeasurementInstances(db)
+			if err != nil {
+				return db, err
+			}
+
+			err = db.AutoMigrate(
 				&AppModel{},
 				&CloudFeedTypeModel{},
 				&DataSourceListModel{},
@@ -53,7 +58,10 @@ func NewDatabaseConnectionAndMigrate(ctx context.Context, dsn string) (db *gorm.
 				&DataSourceTypeModel{},
 				&AccountModel{},
 				&CloudFeedModel{},
+				&CloudFeedRunModel{},
+				&CloudFeedAuthorizationModel{},
 				&PropertyModel{},
+				&PropertyAliasModel{},
 				&UploadModel{},
 				&DeviceTypeModel{},
 				&DeviceModel{},
@@ -62,7 +70,21 @@ func NewDatabaseConnectionAndMigrate(ctx context.Context, dsn string) (db *gorm.
 				&EnergyQueryTypeModel{},
 				&EnergyQueryModel{},
 				&APIKeyModel{},
+				&RetentionPolicyModel{},
+				&LatestValueModel{},
+				&TokenModel{},
+				&RefreshTokenModel{},
 			)
+			if err != nil {
+				return db, err
+			}
+
+			err = migratePropertyMetadata(db)
+			if err != nil {
+				return db, err
+			}
+
+			return db, migrateLatestValues(db)
 		}
 
 		select {
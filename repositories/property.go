@@ -1,6 +1,9 @@
 package repositories
 
 import (
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat"
 	"github.com/energietransitie/needforheat-server-api/needforheat/property"
 	"gorm.io/gorm"
 )
@@ -19,7 +22,14 @@ func NewPropertyRepository(db *gorm.DB) *PropertyRepository {
 // Database representation of a [property.Property]
 type PropertyModel struct {
 	gorm.Model
-	Name string `gorm:"unique;non null"`
+	Name          string             `gorm:"unique;non null"`
+	ValueType     property.ValueType `gorm:"size:16"`
+	TypeConfirmed bool               `gorm:"not null;default:false"`
+	Unit          string             `gorm:"size:32"`
+	Cumulative    bool
+	Description   string
+	DeprecatedAt  *time.Time
+	Aliases       []PropertyAliasModel
 }
 
 // Set the name of the table in the database.
@@ -30,19 +40,44 @@ func (PropertyModel) TableName() string {
 // Create a PropertyModel from a [property.Property].
 func MakePropertyModel(property property.Property) PropertyModel {
 	return PropertyModel{
-		Model: gorm.Model{ID: property.ID},
-		Name:  property.Name,
+		Model:         gorm.Model{ID: property.ID},
+		Name:          property.Name,
+		ValueType:     property.ValueType,
+		TypeConfirmed: property.TypeConfirmed,
+		Unit:          property.Unit,
+		Cumulative:    property.Cumulative,
+		Description:   property.Description,
+		DeprecatedAt:  (*time.Time)(property.DeprecatedAt),
 	}
 }
 
 // Create a [property.Property] from a PropertyModel.
 func (m *PropertyModel) fromModel() property.Property {
 	return property.Property{
-		ID:   m.Model.ID,
-		Name: m.Name,
+		ID:            m.Model.ID,
+		Name:          m.Name,
+		ValueType:     m.ValueType,
+		TypeConfirmed: m.TypeConfirmed,
+		Unit:          m.Unit,
+		Cumulative:    m.Cumulative,
+		Description:   m.Description,
+		DeprecatedAt:  (*needforheat.Time)(m.DeprecatedAt),
 	}
 }
 
+// Database representation of an alternative name of a property.
+// Measurements uploaded with the alias are stored for the property.
+type PropertyAliasModel struct {
+	gorm.Model
+	Name            string `gorm:"unique;non null"`
+	PropertyModelID uint   `gorm:"column:property_id"`
+}
+
+// Set the name of the table in the database.
+func (PropertyAliasModel) TableName() string {
+	return "property_alias"
+}
+
 func (r *PropertyRepository) Find(property property.Property) (property.Property, error) {
 	propertyModel := MakePropertyModel(property)
 	err := r.db.Where(&propertyModel).First(&propertyModel).Error
@@ -71,7 +106,186 @@ func (r *PropertyRepository) Create(property property.Property) (property.Proper
 	return propertyModel.fromModel(), err
 }
 
+func (r *PropertyRepository) Update(property property.Property) (property.Property, error) {
+	propertyModel := MakePropertyModel(property)
+	err := r.db.
+		Model(&propertyModel).
+		// Select the columns explicitly, so fields can be cleared.
+		Select("value_type", "type_confirmed", "unit", "cumulative", "description", "deprecated_at").
+		Updates(propertyModel).
+		Error
+	if err != nil {
+		return property, err
+	}
+
+	err = r.db.First(&propertyModel, propertyModel.ID).Error
+	return propertyModel.fromModel(), err
+}
+
+func (r *PropertyRepository) FindByAlias(name string) (property.Property, error) {
+	var propertyModel PropertyModel
+	err := r.db.
+		Joins("JOIN property_alias ON property_alias.property_id = property.id AND property_alias.deleted_at IS NULL").
+		Where("property_alias.name = ?", name).
+		First(&propertyModel).
+		Error
+	return propertyModel.fromModel(), err
+}
+
+func (r *PropertyRepository) GetAliases(property property.Property) ([]string, error) {
+	aliases := make([]string, 0)
+	err := r.db.
+		Model(&PropertyAliasModel{}).
+		Where("property_id = ?", property.ID).
+		Order("name").
+		Pluck("name", &aliases).
+		Error
+	return aliases, err
+}
+
+func (r *PropertyRepository) Rename(property property.Property, name string) (property.Property, error) {
+	propertyModel := MakePropertyModel(property)
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.First(&propertyModel, propertyModel.ID).Error
+		if err != nil {
+			return err
+		}
+
+		// The new name can be an alias of the property itself.
+		err = tx.Unscoped().Where("name = ? AND property_id = ?", name, propertyModel.ID).Delete(&PropertyAliasModel{}).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Create(&PropertyAliasModel{Name: propertyModel.Name, PropertyModelID: propertyModel.ID}).Error
+		if err != nil {
+			return err
+		}
+
+		propertyModel.Name = name
+		return tx.Model(&propertyModel).Update("name", name).Error
+	})
+
+	return propertyModel.fromModel(), err
+}
+
+func (r *PropertyRepository) Merge(source property.Property, target property.Property) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var sourceModel PropertyModel
+		err := tx.First(&sourceModel, source.ID).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.First(&PropertyModel{}, target.ID).Error
+		if err != nil {
+			return err
+		}
+
+		// Measurements of source that have the same instance and time as a measurement of target
+		// can not be moved, because a measurement is stored only once. These are removed.
+		err = tx.Exec("UPDATE IGNORE measurement SET property_id = ? WHERE property_id = ?", target.ID, source.ID).Error
+		if err != nil {
+			return err
+		}
+
+		var uploadIDs []uint
+		err = tx.Model(&MeasurementModel{}).Unscoped().Where("property_id = ?", source.ID).Distinct().Pluck("upload_id", &uploadIDs).Error
+		if err != nil {
+			return err
+		}
+
+		if len(uploadIDs) > 0 {
+			err = tx.Unscoped().Where("property_id = ?", source.ID).Delete(&MeasurementModel{}).Error
+			if err != nil {
+				return err
+			}
+
+			err = tx.
+				Table("upload").
+				Where("id IN ?", uploadIDs).
+				Update("size", gorm.Expr("(SELECT COUNT(*) FROM measurement WHERE measurement.upload_id = upload.id)")).
+				Error
+			if err != nil {
+				return err
+			}
+		}
+
+		err = tx.Model(&PropertyAliasModel{}).Unscoped().Where("property_id = ?", source.ID).Update("property_id", target.ID).Error
+		if err != nil {
+			return err
+		}
+
+		// Device types that allowed source now allow target.
+		err = tx.Exec("INSERT IGNORE INTO device_type_property (device_type_id, property_id) SELECT device_type_id, ? FROM device_type_property WHERE property_id = ?", target.ID, source.ID).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Exec("DELETE FROM device_type_property WHERE property_id = ?", source.ID).Error
+		if err != nil {
+			return err
+		}
+
+		// Latest values of source become latest values of target, unless target has a more recent value.
+		var valueModels []LatestValueModel
+		err = tx.Where("property_id = ?", source.ID).Find(&valueModels).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Where("property_id = ?", source.ID).Delete(&LatestValueModel{}).Error
+		if err != nil {
+			return err
+		}
+
+		for i := range valueModels {
+			valueModels[i].PropertyModelID = target.ID
+		}
+
+		err = upsertLatestValues(tx, valueModels)
+		if err != nil {
+			return err
+		}
+
+		// The name of source must be free before it can be used as an alias.
+		err = tx.Unscoped().Delete(&sourceModel).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(&PropertyAliasModel{Name: sourceModel.Name, PropertyModelID: target.ID}).Error
+	})
+}
+
 func (r *PropertyRepository) Delete(property property.Property) error {
 	propertyModel := MakePropertyModel(property)
 	return r.db.Delete(&propertyModel).Error
 }
+
+// Derive the value type, unit and if a property is cumulative from the name of properties
+// that were created before properties had a value type. The derived value types are not confirmed.
+func migratePropertyMetadata(db *gorm.DB) error {
+	var propertyModels []PropertyModel
+	err := db.Where("value_type IS NULL OR value_type = ''").Find(&propertyModels).Error
+	if err != nil {
+		return err
+	}
+
+	for _, propertyModel := range propertyModels {
+		p := property.MakeProperty(propertyModel.Name)
+		p.ID = propertyModel.ID
+
+		err = db.
+			Model(&PropertyModel{Model: gorm.Model{ID: p.ID}}).
+			Select("value_type", "unit", "cumulative").
+			Updates(MakePropertyModel(p)).
+			Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat"
+	"github.com/energietransitie/needforheat-server-api/needforheat/refreshtoken"
+	"gorm.io/gorm"
+)
+
+type RefreshTokenRepository struct {
+	db *gorm.DB
+}
+
+// Create a new RefreshTokenRepository.
+func NewRefreshTokenRepository(db *gorm.DB) *RefreshTokenRepository {
+	return &RefreshTokenRepository{
+		db: db,
+	}
+}
+
+// Database representation of a [refreshtoken.RefreshToken].
+type RefreshTokenModel struct {
+	gorm.Model
+	FamilyID  string `gorm:"size:32;index"`
+	AccountID uint   `gorm:"index"`
+	Hash      string `gorm:"size:64;unique"`
+	ExpiresAt needforheat.Time
+	UsedAt    *needforheat.Time
+	RevokedAt *needforheat.Time
+}
+
+// Set the name of the table in the database.
+func (RefreshTokenModel) TableName() string {
+	return "refresh_token"
+}
+
+// Create a RefreshTokenModel from a [refreshtoken.RefreshToken].
+func MakeRefreshTokenModel(t refreshtoken.RefreshToken) RefreshTokenModel {
+	return RefreshTokenModel{
+		Model:     gorm.Model{ID: t.ID},
+		FamilyID:  t.FamilyID,
+		AccountID: t.AccountID,
+		Hash:      t.Hash,
+		ExpiresAt: t.ExpiresAt,
+		UsedAt:    t.UsedAt,
+		RevokedAt: t.RevokedAt,
+	}
+}
+
+// Create a [refreshtoken.RefreshToken] from a RefreshTokenModel.
+func (m *RefreshTokenModel) fromModel() refreshtoken.RefreshToken {
+	return refreshtoken.RefreshToken{
+		ID:        m.Model.ID,
+		FamilyID:  m.FamilyID,
+		AccountID: m.AccountID,
+		Hash:      m.Hash,
+		ExpiresAt: m.ExpiresAt,
+		UsedAt:    m.UsedAt,
+		RevokedAt: m.RevokedAt,
+	}
+}
+
+func (r *RefreshTokenRepository) Create(t refreshtoken.RefreshToken) (refreshtoken.RefreshToken, error) {
+	refreshTokenModel := MakeRefreshTokenModel(t)
+	err := r.db.Create(&refreshTokenModel).Error
+	if err != nil {
+		return refreshtoken.RefreshToken{}, err
+	}
+
+	created := refreshTokenModel.fromModel()
+	created.Token = t.Token
+	return created, nil
+}
+
+func (r *RefreshTokenRepository) FindByHash(hash string) (refreshtoken.RefreshToken, error) {
+	var refreshTokenModel RefreshTokenModel
+	err := r.db.Where("hash = ?", hash).First(&refreshTokenModel).Error
+	return refreshTokenModel.fromModel(), err
+}
+
+func (r *RefreshTokenRepository) MarkUsed(id uint, at time.Time) (bool, error) {
+	result := r.db.
+		Model(&RefreshTokenModel{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", needforheat.Time(at))
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+func (r *RefreshTokenRepository) RevokeFamily(familyID string, at time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.
+			Model(&RefreshTokenModel{}).
+			Where("family_id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", needforheat.Time(at)).
+			Error
+		if err != nil {
+			return err
+		}
+
+		return tx.
+			Model(&TokenModel{}).
+			Where("family_id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", needforheat.Time(at)).
+			Error
+	})
+}
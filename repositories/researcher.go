@@ -0,0 +1,133 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat/researcher"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+type ResearcherRepository struct {
+	db *gorm.DB
+}
+
+// Create a new ResearcherRepository from an SQLite DB at fileName.
+func NewResearcherRepository(fileName string) (*ResearcherRepository, error) {
+	db, err := gorm.Open(sqlite.Open(fileName), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return &ResearcherRepository{}, err
+	}
+
+	err = db.AutoMigrate(&ResearcherModel{}, &ResearcherCampaignModel{})
+	if err != nil {
+		return &ResearcherRepository{}, err
+	}
+
+	return &ResearcherRepository{
+		db: db,
+	}, nil
+}
+
+// Database representation of a [researcher.Researcher].
+type ResearcherModel struct {
+	gorm.Model
+	Name        string                    `gorm:"unique;not null"`
+	Campaigns   []ResearcherCampaignModel `gorm:"foreignKey:ResearcherID"`
+	ActivatedAt time.Time
+	Expiry      time.Time
+}
+
+// Set the name of the table in the database.
+func (ResearcherModel) TableName() string {
+	return "researcher"
+}
+
+// Database representation of a campaign a researcher is bound to.
+// Campaigns are stored in another database, so CampaignID is not a foreign key.
+type ResearcherCampaignModel struct {
+	ResearcherID uint `gorm:"primaryKey;autoIncrement:false"`
+	CampaignID   uint `gorm:"primaryKey;autoIncrement:false"`
+}
+
+// Set the name of the table in the database.
+func (ResearcherCampaignModel) TableName() string {
+	return "researcher_campaign"
+}
+
+// Create a new ResearcherModel from a [researcher.Researcher].
+func MakeResearcherModel(researcher researcher.Researcher) ResearcherModel {
+	var campaigns []ResearcherCampaignModel
+	for _, campaignID := range researcher.CampaignIDs {
+		campaigns = append(campaigns, ResearcherCampaignModel{ResearcherID: researcher.ID, CampaignID: campaignID})
+	}
+
+	return ResearcherModel{
+		Model:       gorm.Model{ID: researcher.ID},
+		Name:        researcher.Name,
+		Campaigns:   campaigns,
+		ActivatedAt: researcher.ActivatedAt,
+		Expiry:      researcher.Expiry,
+	}
+}
+
+// Create a [researcher.Researcher] from a ResearcherModel.
+func (m *ResearcherModel) fromModel() researcher.Researcher {
+	var campaignIDs []uint
+	for _, campaign := range m.Campaigns {
+		campaignIDs = append(campaignIDs, campaign.CampaignID)
+	}
+
+	return researcher.Researcher{
+		ID:          m.Model.ID,
+		Name:        m.Name,
+		CampaignIDs: campaignIDs,
+		ActivatedAt: m.ActivatedAt,
+		Expiry:      m.Expiry,
+	}
+}
+
+func (r *ResearcherRepository) Find(researcher researcher.Researcher) (researcher.Researcher, error) {
+	researcherModel := MakeResearcherModel(researcher)
+	researcherModel.Campaigns = nil
+	err := r.db.Preload("Campaigns").Where(&researcherModel).First(&researcherModel).Error
+	return researcherModel.fromModel(), err
+}
+
+func (r *ResearcherRepository) GetAll() ([]researcher.Researcher, error) {
+	researchers := make([]researcher.Researcher, 0)
+
+	var researcherModels []ResearcherModel
+	err := r.db.Preload("Campaigns").Find(&researcherModels).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, researcherModel := range researcherModels {
+		researchers = append(researchers, researcherModel.fromModel())
+	}
+
+	return researchers, nil
+}
+
+func (r *ResearcherRepository) Create(researcher researcher.Researcher) (researcher.Researcher, error) {
+	researcherModel := MakeResearcherModel(researcher)
+	err := r.db.Create(&researcherModel).Error
+	return researcherModel.fromModel(), err
+}
+
+func (r *ResearcherRepository) Update(researcher researcher.Researcher) (researcher.Researcher, error) {
+	researcherModel := MakeResearcherModel(researcher)
+	err := r.db.Model(&researcherModel).Omit(clause.Associations).Updates(researcherModel).Error
+	return researcherModel.fromModel(), err
+}
+
+func (r *ResearcherRepository) Delete(researcher researcher.Researcher) error {
+	researcherModel := MakeResearcherModel(researcher)
+	researcherModel.Campaigns = nil
+	return r.db.Where(&researcherModel).Delete(&researcherModel).Error
+}
@@ -0,0 +1,307 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat"
+	"github.com/energietransitie/needforheat-server-api/needforheat/measurement"
+	"github.com/energietransitie/needforheat-server-api/needforheat/retention"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Number of aggregates that are inserted in a single statement.
+const aggregateBatchSize = 1000
+
+type RetentionRepository struct {
+	db *gorm.DB
+}
+
+// Create a new RetentionRepository.
+func NewRetentionRepository(db *gorm.DB) *RetentionRepository {
+	return &RetentionRepository{
+		db: db,
+	}
+}
+
+// Database representation of a [retention.Policy].
+type RetentionPolicyModel struct {
+	gorm.Model
+	CampaignModelID    uint `gorm:"column:campaign_id;uniqueIndex"`
+	Campaign           CampaignModel
+	RawDays            int
+	DownsampleInterval string `gorm:"size:8"`
+	AggregateDays      *int
+	Archive            bool
+}
+
+// Set the name of the table in the database.
+func (RetentionPolicyModel) TableName() string {
+	return "retention_policy"
+}
+
+// Create a RetentionPolicyModel from a [retention.Policy].
+func MakeRetentionPolicyModel(policy retention.Policy) RetentionPolicyModel {
+	return RetentionPolicyModel{
+		Model:              gorm.Model{ID: policy.ID},
+		CampaignModelID:    policy.CampaignID,
+		RawDays:            policy.RawDays,
+		DownsampleInterval: policy.DownsampleInterval,
+		AggregateDays:      policy.AggregateDays,
+		Archive:            policy.Archive,
+	}
+}
+
+// Create a [retention.Policy] from a RetentionPolicyModel.
+func (m *RetentionPolicyModel) fromModel() retention.Policy {
+	return retention.Policy{
+		ID:                 m.Model.ID,
+		CampaignID:         m.CampaignModelID,
+		RawDays:            m.RawDays,
+		DownsampleInterval: m.DownsampleInterval,
+		AggregateDays:      m.AggregateDays,
+		Archive:            m.Archive,
+	}
+}
+
+func (r *RetentionRepository) Find(campaignID uint) (retention.Policy, error) {
+	var policyModel RetentionPolicyModel
+	err := r.db.Where("campaign_id = ?", campaignID).First(&policyModel).Error
+	return policyModel.fromModel(), err
+}
+
+func (r *RetentionRepository) GetAll() ([]retention.Policy, error) {
+	var policies []retention.Policy
+
+	var policyModels []RetentionPolicyModel
+	err := r.db.Order("campaign_id").Find(&policyModels).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, policyModel := range policyModels {
+		policies = append(policies, policyModel.fromModel())
+	}
+
+	return policies, nil
+}
+
+func (r *RetentionRepository) Save(policy retention.Policy) (retention.Policy, error) {
+	policyModel := MakeRetentionPolicyModel(policy)
+
+	// Policies are deleted permanently, so a campaign has at most one policy in the table.
+	err := r.db.
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "campaign_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"updated_at", "raw_days", "downsample_interval", "aggregate_days", "archive"}),
+		}).
+		Omit("Campaign").
+		Create(&policyModel).
+		Error
+	if err != nil {
+		return retention.Policy{}, err
+	}
+
+	return r.Find(policy.CampaignID)
+}
+
+func (r *RetentionRepository) Delete(campaignID uint) error {
+	return r.db.Unscoped().Where("campaign_id = ?", campaignID).Delete(&RetentionPolicyModel{}).Error
+}
+
+// Get a query on the uploads of the devices and energy queries of the accounts in a campaign.
+func (r *RetentionRepository) campaignUploads(campaignID uint) *gorm.DB {
+	return r.db.
+		Table("upload").
+		Joins("LEFT JOIN device ON upload.instance_type = 'device' AND upload.instance_id = device.id").
+		Joins("LEFT JOIN energy_query ON upload.instance_type = 'energy_query' AND upload.instance_id = energy_query.id").
+		Joins("JOIN account ON account.id = COALESCE(device.account_id, energy_query.account_id)").
+		Where("account.campaign_id = ?", campaignID)
+}
+
+// Get a query on the measurements of a campaign before a time, in raw uploads or in downsampled uploads.
+// Measurements that were soft deleted are included, because they are removed as well.
+func (r *RetentionRepository) campaignMeasurements(campaignID uint, before time.Time, downsampled bool) *gorm.DB {
+	uploads := r.campaignUploads(campaignID).Select("upload.id")
+	if downsampled {
+		uploads = uploads.Where("upload.downsample_interval <> ''")
+	} else {
+		uploads = uploads.Where("upload.downsample_interval = ''")
+	}
+
+	return r.db.
+		Table("measurement").
+		Where("measurement.upload_id IN (?)", uploads).
+		Where("measurement.time < ?", before)
+}
+
+func (r *RetentionRepository) GetInstances(campaignID uint, before time.Time) ([]retention.Instance, error) {
+	var instances []retention.Instance
+
+	err := r.campaignUploads(campaignID).
+		Select("DISTINCT upload.instance_id AS id, upload.instance_type AS type").
+		Where("upload.downsample_interval = ''").
+		Where("EXISTS (SELECT 1 FROM measurement WHERE measurement.upload_id = upload.id AND measurement.time < ?)", before).
+		Order("upload.instance_type, upload.instance_id").
+		Scan(&instances).
+		Error
+
+	return instances, err
+}
+
+func (r *RetentionRepository) CountRaw(campaignID uint, before time.Time) (int64, error) {
+	var count int64
+	err := r.campaignMeasurements(campaignID, before, false).Count(&count).Error
+	return count, err
+}
+
+func (r *RetentionRepository) CountIntervals(campaignID uint, before time.Time, interval time.Duration) (int64, error) {
+	seconds := int64(interval / time.Second)
+
+	// TIMESTAMPDIFF is used instead of UNIX_TIMESTAMP, because it does not depend on the time zone of the session.
+	intervals := r.campaignMeasurements(campaignID, before, false).
+		Select("DISTINCT upload.instance_id, upload.instance_type, measurement.property_id, FLOOR(TIMESTAMPDIFF(SECOND, '1970-01-01 00:00:00', measurement.time) / ?) AS bucket", seconds).
+		Joins("JOIN upload ON measurement.upload_id = upload.id")
+
+	var count int64
+	err := r.db.Table("(?) AS intervals", intervals).Count(&count).Error
+	return count, err
+}
+
+func (r *RetentionRepository) CountAggregates(campaignID uint, before time.Time) (int64, error) {
+	var count int64
+	err := r.campaignMeasurements(campaignID, before, true).Count(&count).Error
+	return count, err
+}
+
+// Get a query on the raw uploads of an instance.
+func (r *RetentionRepository) rawUploads(tx *gorm.DB, instance retention.Instance) *gorm.DB {
+	return tx.
+		Table("upload").
+		Where("instance_id = ? AND instance_type = ? AND downsample_interval = ''", instance.ID, instance.Type)
+}
+
+func (r *RetentionRepository) StreamRaw(instance retention.Instance, before time.Time, fn func(measurement.Measurement) error) error {
+	// Measurements that were soft deleted are removed, but not used for the aggregates.
+	uploads := r.rawUploads(r.db, instance).Select("id").Where("deleted_at IS NULL")
+
+	query := r.db.
+		Table("measurement").
+		Where("measurement.upload_id IN (?)", uploads).
+		Where("measurement.time < ? AND measurement.deleted_at IS NULL", before).
+		Order("measurement.property_id")
+
+	return streamMeasurements(context.Background(), query, fn)
+}
+
+func (r *RetentionRepository) ReplaceRaw(instance retention.Instance, before time.Time, interval string, aggregates []measurement.Measurement) (int64, error) {
+	var count int64
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// Raw measurements are removed first, because aggregates are stored at the start of their interval,
+		// which can be the time of a raw measurement.
+		result := tx.
+			Unscoped().
+			Where("upload_id IN (?)", r.rawUploads(tx, instance).Select("id")).
+			Where("time < ?", before).
+			Delete(&MeasurementModel{})
+		if result.Error != nil {
+			return result.Error
+		}
+		count = result.RowsAffected
+
+		if len(aggregates) > 0 {
+			now := needforheat.Time(time.Now().UTC())
+
+			uploadModel := UploadModel{
+				InstanceID:         instance.ID,
+				InstanceType:       instance.Type,
+				ServerTime:         now,
+				DeviceTime:         now,
+				Size:               len(aggregates),
+				DownsampleInterval: interval,
+			}
+
+			err := tx.Create(&uploadModel).Error
+			if err != nil {
+				return err
+			}
+
+			measurementModels := make([]MeasurementModel, 0, len(aggregates))
+			for _, aggregate := range aggregates {
+				measurementModel := MakeMeasurementModel(aggregate)
+				measurementModel.InstanceID = instance.ID
+				measurementModel.InstanceType = instance.Type
+				measurementModel.UploadModelID = uploadModel.ID
+				measurementModels = append(measurementModels, measurementModel)
+			}
+
+			// An interval that was downsampled in an earlier run keeps its aggregate.
+			err = tx.Omit("Property").Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&measurementModels, aggregateBatchSize).Error
+			if err != nil {
+				return err
+			}
+
+			err = tx.
+				Model(&uploadModel).
+				Update("size", gorm.Expr("(SELECT COUNT(*) FROM measurement WHERE measurement.upload_id = upload.id)")).
+				Error
+			if err != nil {
+				return err
+			}
+		}
+
+		// Latest values are raw measurements as well.
+		err := tx.
+			Where("instance_id = ? AND instance_type = ? AND time < ?", instance.ID, instance.Type, before).
+			Delete(&LatestValueModel{}).
+			Error
+		if err != nil {
+			return err
+		}
+
+		// Uploads that have no measurements left are kept, so the times of the uploads are still known.
+		return r.rawUploads(tx, instance).
+			Update("size", gorm.Expr("(SELECT COUNT(*) FROM measurement WHERE measurement.upload_id = upload.id)")).
+			Error
+	})
+
+	return count, err
+}
+
+func (r *RetentionRepository) DeleteAggregates(campaignID uint, before time.Time) (int64, error) {
+	var count int64
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var uploadIDs []uint
+		err := r.campaignUploads(campaignID).
+			Where("upload.downsample_interval <> ''").
+			Pluck("upload.id", &uploadIDs).
+			Error
+		if err != nil {
+			return err
+		}
+
+		if len(uploadIDs) == 0 {
+			return nil
+		}
+
+		result := tx.
+			Unscoped().
+			Where("upload_id IN ? AND time < ?", uploadIDs, before).
+			Delete(&MeasurementModel{})
+		if result.Error != nil {
+			return result.Error
+		}
+		count = result.RowsAffected
+
+		return tx.
+			Table("upload").
+			Where("id IN ?", uploadIDs).
+			Update("size", gorm.Expr("(SELECT COUNT(*) FROM measurement WHERE measurement.upload_id = upload.id)")).
+			Error
+	})
+
+	return count, err
+}
@@ -0,0 +1,152 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat"
+	"github.com/energietransitie/needforheat-server-api/needforheat/authorization"
+	"github.com/energietransitie/needforheat-server-api/needforheat/token"
+	"gorm.io/gorm"
+)
+
+type TokenRepository struct {
+	db *gorm.DB
+}
+
+// Create a new TokenRepository.
+func NewTokenRepository(db *gorm.DB) *TokenRepository {
+	return &TokenRepository{
+		db: db,
+	}
+}
+
+// Database representation of a [token.Token].
+type TokenModel struct {
+	ID        string `gorm:"primaryKey;size:71"`
+	CreatedAt needforheat.Time
+	UpdatedAt needforheat.Time
+	Kind      authorization.AuthKind `gorm:"size:32;index:idx_token_subject,priority:1"`
+	SubjectID uint                   `gorm:"index:idx_token_subject,priority:2"`
+	IssuedAt  needforheat.Time
+	ExpiresAt needforheat.Time
+	RevokedAt *needforheat.Time
+	FamilyID  *string `gorm:"size:32;index"`
+}
+
+// Set the name of the table in the database.
+func (TokenModel) TableName() string {
+	return "token"
+}
+
+// Create a TokenModel from a [token.Token].
+func MakeTokenModel(t token.Token) TokenModel {
+	m := TokenModel{
+		ID:        t.ID,
+		Kind:      t.Kind,
+		SubjectID: t.SubjectID,
+		IssuedAt:  t.IssuedAt,
+		ExpiresAt: t.ExpiresAt,
+		RevokedAt: t.RevokedAt,
+	}
+	if t.FamilyID != "" {
+		m.FamilyID = &t.FamilyID
+	}
+	return m
+}
+
+// Create a [token.Token] from a TokenModel.
+func (m *TokenModel) fromModel() token.Token {
+	t := token.Token{
+		ID:        m.ID,
+		Kind:      m.Kind,
+		SubjectID: m.SubjectID,
+		IssuedAt:  m.IssuedAt,
+		ExpiresAt: m.ExpiresAt,
+		RevokedAt: m.RevokedAt,
+	}
+	if m.FamilyID != nil {
+		t.FamilyID = *m.FamilyID
+	}
+	return t
+}
+
+// A token with the name of its device.
+type tokenRow struct {
+	TokenModel
+	DeviceName *string
+}
+
+func (r *TokenRepository) Find(id string) (token.Token, error) {
+	var tokenModel TokenModel
+	err := r.db.Where("id = ?", id).First(&tokenModel).Error
+	return tokenModel.fromModel(), err
+}
+
+func (r *TokenRepository) Create(t token.Token) (token.Token, error) {
+	tokenModel := MakeTokenModel(t)
+	err := r.db.Create(&tokenModel).Error
+	return tokenModel.fromModel(), err
+}
+
+func (r *TokenRepository) FindOrCreate(t token.Token) (token.Token, error) {
+	tokenModel := MakeTokenModel(t)
+	err := r.db.FirstOrCreate(&tokenModel).Error
+	return tokenModel.fromModel(), err
+}
+
+func (r *TokenRepository) Update(t token.Token) (token.Token, error) {
+	tokenModel := MakeTokenModel(t)
+	err := r.db.Model(&tokenModel).Select("revoked_at").Updates(&tokenModel).Error
+	if err != nil {
+		return token.Token{}, err
+	}
+
+	return t, nil
+}
+
+// Get a query on the tokens of an account and of its devices.
+func (r *TokenRepository) accountTokens(accountID uint) *gorm.DB {
+	return r.db.
+		Table("token").
+		Select("token.*, device.name AS device_name").
+		Joins("LEFT JOIN device ON token.kind = ? AND token.subject_id = device.id AND device.deleted_at IS NULL", authorization.DeviceToken).
+		Where("((token.kind = ? AND token.subject_id = ?) OR device.account_id = ?)", authorization.AccountToken, accountID, accountID)
+}
+
+func (r *TokenRepository) GetActiveByAccount(accountID uint, at time.Time) ([]token.Token, error) {
+	var rows []tokenRow
+	err := r.accountTokens(accountID).
+		Where("token.revoked_at IS NULL AND token.expires_at > ?", at).
+		Order("token.issued_at DESC").
+		Find(&rows).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := make([]token.Token, 0, len(rows))
+	for _, row := range rows {
+		tokens = append(tokens, row.fromModel())
+	}
+
+	return tokens, nil
+}
+
+func (r *TokenRepository) FindByAccount(accountID uint, id string) (token.Token, error) {
+	var row tokenRow
+	err := r.accountTokens(accountID).
+		Where("token.id = ?", id).
+		Take(&row).
+		Error
+
+	return row.fromModel(), err
+}
+
+// Create a [token.Token] from a tokenRow.
+func (row *tokenRow) fromModel() token.Token {
+	t := row.TokenModel.fromModel()
+	if row.DeviceName != nil {
+		t.DeviceName = *row.DeviceName
+	}
+	return t
+}
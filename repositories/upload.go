@@ -1,13 +1,20 @@
 package repositories
 
 import (
+	"fmt"
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/internal/helpers"
 	"github.com/energietransitie/needforheat-server-api/needforheat"
 	"github.com/energietransitie/needforheat-server-api/needforheat/measurement"
 	"github.com/energietransitie/needforheat-server-api/needforheat/upload"
-	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// Number of measurements that are inserted in one statement.
+const measurementBatchSize = 1000
+
 type UploadRepository struct {
 	db *gorm.DB
 }
@@ -22,12 +29,17 @@ func NewUploadRepository(db *gorm.DB) *UploadRepository {
 // Database representation of a [upload.Upload]
 type UploadModel struct {
 	gorm.Model
-	InstanceID   uint                `gorm:"column:instance_id"`
-	InstanceType upload.InstanceType `gorm:"default:device"`
-	ServerTime   needforheat.Time
-	DeviceTime   needforheat.Time
-	Size         int
-	Measurements []MeasurementModel
+	InstanceID     uint                `gorm:"column:instance_id;uniqueIndex:idx_upload_idempotency_key,priority:2"`
+	InstanceType   upload.InstanceType `gorm:"size:32;default:device;uniqueIndex:idx_upload_idempotency_key,priority:1"`
+	ServerTime     needforheat.Time
+	DeviceTime     needforheat.Time
+	Size           int
+	Measurements   []MeasurementModel
+	IdempotencyKey *string `gorm:"size:191;uniqueIndex:idx_upload_idempotency_key,priority:3"`
+	ClockSkew      *int64
+	TimeShift      int64
+	// Uploads with raw measurements have an empty interval.
+	DownsampleInterval string `gorm:"size:8;not null;default:''"`
 }
 
 // Set the name of the table in the database.
@@ -43,14 +55,23 @@ func MakeUploadModel(upload upload.Upload) UploadModel {
 		measurementModels = append(measurementModels, MakeMeasurementModel(measurement))
 	}
 
+	var idempotencyKey *string
+	if upload.IdempotencyKey != "" {
+		idempotencyKey = &upload.IdempotencyKey
+	}
+
 	return UploadModel{
-		Model:        gorm.Model{ID: upload.ID},
-		InstanceID:   upload.InstanceID,
-		InstanceType: upload.InstanceType,
-		ServerTime:   upload.ServerTime,
-		DeviceTime:   upload.DeviceTime,
-		Size:         upload.Size,
-		Measurements: measurementModels,
+		Model:              gorm.Model{ID: upload.ID},
+		InstanceID:         upload.InstanceID,
+		InstanceType:       upload.InstanceType,
+		ServerTime:         upload.ServerTime,
+		DeviceTime:         upload.DeviceTime,
+		Size:               upload.Size,
+		Measurements:       measurementModels,
+		IdempotencyKey:     idempotencyKey,
+		ClockSkew:          upload.ClockSkew,
+		TimeShift:          upload.TimeShift,
+		DownsampleInterval: upload.DownsampleInterval,
 	}
 }
 
@@ -62,14 +83,23 @@ func (m *UploadModel) fromModel() upload.Upload {
 		measurements = append(measurements, measurementModel.fromModel())
 	}
 
+	var idempotencyKey string
+	if m.IdempotencyKey != nil {
+		idempotencyKey = *m.IdempotencyKey
+	}
+
 	return upload.Upload{
-		ID:           m.Model.ID,
-		InstanceID:   m.InstanceID,
-		InstanceType: StringToType(string(m.InstanceType)),
-		ServerTime:   needforheat.Time(m.ServerTime),
-		DeviceTime:   needforheat.Time(m.DeviceTime),
-		Size:         m.Size,
-		Measurements: measurements,
+		ID:                 m.Model.ID,
+		InstanceID:         m.InstanceID,
+		InstanceType:       StringToType(string(m.InstanceType)),
+		ServerTime:         needforheat.Time(m.ServerTime),
+		DeviceTime:         needforheat.Time(m.DeviceTime),
+		Size:               m.Size,
+		Measurements:       measurements,
+		IdempotencyKey:     idempotencyKey,
+		ClockSkew:          m.ClockSkew,
+		TimeShift:          m.TimeShift,
+		DownsampleInterval: m.DownsampleInterval,
 	}
 }
 
@@ -95,14 +125,176 @@ func (r *UploadRepository) GetAll() ([]upload.Upload, error) {
 	return uploads, nil
 }
 
-func (r *UploadRepository) Create(upload upload.Upload) (upload.Upload, error) {
-	uploadModel := MakeUploadModel(upload)
-	logrus.Info(uploadModel)
-	err := r.db.Create(&uploadModel).Error
-	logrus.Info(err)
+// Create an upload. Measurements that were already stored for the same instance, property and time
+// are resolved using policy, when they are inserted. The number of measurements that were not stored
+// as new measurements is set in Duplicates. With [upload.ConflictPolicyKeepLast], these measurements
+// replaced the value of the stored measurement, which stays part of the upload it was stored with.
+func (r *UploadRepository) Create(u upload.Upload, policy upload.ConflictPolicy) (upload.Upload, error) {
+	measurements, err := deduplicateMeasurements(u.Measurements, policy)
+	if err != nil {
+		return upload.Upload{}, err
+	}
+
+	var created upload.Upload
+
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		uploadModel := MakeUploadModel(u)
+		uploadModel.Measurements = nil
+
+		err := tx.Create(&uploadModel).Error
+		if err != nil {
+			return err
+		}
+
+		measurementModels := make([]MeasurementModel, 0, len(measurements))
+		for _, m := range measurements {
+			measurementModel := MakeMeasurementModel(m)
+			measurementModel.InstanceID = u.InstanceID
+			measurementModel.InstanceType = u.InstanceType
+			measurementModel.UploadModelID = uploadModel.ID
+			measurementModels = append(measurementModels, measurementModel)
+		}
+
+		err = insertMeasurements(tx, measurementModels, policy)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Preload("Property").Where("upload_id = ?", uploadModel.ID).Order("id").Find(&uploadModel.Measurements).Error
+		if err != nil {
+			return err
+		}
+
+		uploadModel.Size = len(uploadModel.Measurements)
+		err = tx.Model(&uploadModel).Update("size", uploadModel.Size).Error
+		if err != nil {
+			return err
+		}
+
+		created = uploadModel.fromModel()
+		created.Duplicates = len(u.Measurements) - created.Size
+		return nil
+	})
+
+	return created, err
+}
+
+// Find the upload of an instance that was created with the idempotency key.
+func (r *UploadRepository) FindByIdempotencyKey(instanceID uint, instanceType upload.InstanceType, key string) (upload.Upload, error) {
+	var uploadModel UploadModel
+
+	err := r.db.
+		Where("instance_id = ? AND instance_type = ? AND idempotency_key = ?", instanceID, instanceType, key).
+		First(&uploadModel).
+		Error
+
 	return uploadModel.fromModel(), err
 }
 
+func (r *UploadRepository) FindInstanceAccountID(instanceID uint, instanceType upload.InstanceType) (uint, error) {
+	var accountIDs []uint
+
+	err := r.db.
+		Table(string(instanceType)).
+		Where("id = ? AND deleted_at IS NULL", instanceID).
+		Limit(1).
+		Pluck("account_id", &accountIDs).
+		Error
+	if err != nil {
+		return 0, err
+	}
+
+	if len(accountIDs) == 0 {
+		return 0, gorm.ErrRecordNotFound
+	}
+
+	return accountIDs[0], nil
+}
+
+func (r *UploadRepository) GetClockSamples(instanceID uint, instanceType upload.InstanceType, since needforheat.Time) ([]upload.ClockSample, error) {
+	var samples []upload.ClockSample
+
+	err := r.db.
+		Model(&UploadModel{}).
+		Select("server_time, clock_skew, time_shift").
+		Where("instance_id = ? AND instance_type = ?", instanceID, instanceType).
+		Where("server_time >= ? AND clock_skew IS NOT NULL", since).
+		Order("server_time").
+		Scan(&samples).
+		Error
+
+	return samples, err
+}
+
+// A measurementKey identifies the measurements of a property at a specific time.
+type measurementKey struct {
+	propertyName string
+	time         int64
+}
+
+func makeMeasurementKey(propertyName string, t time.Time) measurementKey {
+	// Times are stored in the database with millisecond precision.
+	return measurementKey{propertyName, t.UnixMilli()}
+}
+
+// Remove the measurements that occur multiple times in measurements.
+// Conflicting measurements with a different value are resolved using policy.
+func deduplicateMeasurements(measurements []measurement.Measurement, policy upload.ConflictPolicy) ([]measurement.Measurement, error) {
+	var deduplicated []measurement.Measurement
+	indexes := make(map[measurementKey]int)
+
+	for _, m := range measurements {
+		key := makeMeasurementKey(m.Property.Name, time.Time(m.Time))
+
+		i, ok := indexes[key]
+		if !ok {
+			indexes[key] = len(deduplicated)
+			deduplicated = append(deduplicated, m)
+			continue
+		}
+
+		if deduplicated[i].Value == m.Value {
+			continue
+		}
+
+		switch policy {
+		case upload.ConflictPolicyKeepFirst:
+			// The first measurement is already kept.
+		case upload.ConflictPolicyKeepLast:
+			deduplicated[i] = m
+		default:
+			return nil, fmt.Errorf("%w: %s at %s occurs multiple times", upload.ErrMeasurementConflict, m.Property.Name, time.Time(m.Time))
+		}
+	}
+
+	return deduplicated, nil
+}
+
+// Insert measurements. Measurements that were already stored for the same instance, property and time
+// are ignored, replace the value of the stored measurement, or make the insert fail, depending on policy.
+func insertMeasurements(tx *gorm.DB, measurementModels []MeasurementModel, policy upload.ConflictPolicy) error {
+	if len(measurementModels) == 0 {
+		return nil
+	}
+
+	query := tx.Omit("Property")
+
+	switch policy {
+	case upload.ConflictPolicyKeepFirst:
+		// Only conflicts on the unique index are ignored. INSERT IGNORE would also drop rows with other errors.
+		query = query.Clauses(clause.OnConflict{DoNothing: true})
+	case upload.ConflictPolicyKeepLast:
+		query = query.Clauses(clause.OnConflict{DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"})})
+	}
+
+	err := query.CreateInBatches(&measurementModels, measurementBatchSize).Error
+	if helpers.IsMySQLDuplicateError(err) {
+		return fmt.Errorf("%w: a measurement was already stored for the same property and time", upload.ErrMeasurementConflict)
+	}
+
+	return err
+}
+
 func (r *UploadRepository) Delete(upload upload.Upload) error {
 	uploadModel := MakeUploadModel(upload)
 	return r.db.Delete(&uploadModel).Error
@@ -130,6 +322,38 @@ func (r *UploadRepository) GetLatestUploadForDeviceWithID(id uint) (upload.Uploa
 	return uploadModel.fromModel(), nil
 }
 
+func (r *UploadRepository) DeleteAllForDeviceWithID(id uint) (int64, error) {
+	var count int64
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		uploadIDs := tx.Model(&UploadModel{}).
+			Unscoped().
+			Select("id").
+			Where("instance_id = ? AND instance_type = ?", id, upload.Device)
+
+		result := tx.Unscoped().Where("upload_id IN (?)", uploadIDs).Delete(&MeasurementModel{})
+		if result.Error != nil {
+			return result.Error
+		}
+		count = result.RowsAffected
+
+		err := tx.
+			Where("instance_id = ? AND instance_type = ?", id, upload.Device).
+			Delete(&LatestValueModel{}).
+			Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Unscoped().
+			Where("instance_id = ? AND instance_type = ?", id, upload.Device).
+			Delete(&UploadModel{}).
+			Error
+	})
+
+	return count, err
+}
+
 func StringToType(category string) upload.InstanceType {
 	switch category {
 	case "device":
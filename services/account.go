@@ -8,13 +8,12 @@ import (
 	"regexp"
 	"time"
 
-	"github.com/energietransitie/needforheat-server-api/internal/helpers"
 	"github.com/energietransitie/needforheat-server-api/needforheat/account"
 	"github.com/energietransitie/needforheat-server-api/needforheat/authorization"
 	"github.com/energietransitie/needforheat-server-api/needforheat/campaign"
-	"github.com/energietransitie/needforheat-server-api/needforheat/cloudfeed"
 	"github.com/energietransitie/needforheat-server-api/needforheat/cloudfeedstatus"
 	"github.com/energietransitie/needforheat-server-api/needforheat/cloudfeedtype"
+	"github.com/energietransitie/needforheat-server-api/needforheat/latestvalue"
 	"github.com/sirupsen/logrus"
 )
 
@@ -35,6 +34,9 @@ type AccountService struct {
 	dataSourceTypeService *DataSourceTypeService
 	cloudFeedService      *CloudFeedService
 
+	// Service used for getting the latest values of the devices and energy queries of an account.
+	uploadService *UploadService
+
 	// Regular expression used for pattern matching in a provisioning_url_template.
 	activationTokenRegex *regexp.Regexp
 }
@@ -47,6 +49,7 @@ func NewAccountService(
 	campaignService *CampaignService,
 	cloudFeedService *CloudFeedService,
 	dataSourceTypeService *DataSourceTypeService,
+	uploadService *UploadService,
 ) *AccountService {
 	activationTokenRegex, err := regexp.Compile(`<account_activation_token>`)
 	if err != nil {
@@ -60,6 +63,7 @@ func NewAccountService(
 		campaignService:       campaignService,
 		cloudFeedService:      cloudFeedService,
 		dataSourceTypeService: dataSourceTypeService,
+		uploadService:         uploadService,
 		activationTokenRegex:  activationTokenRegex,
 	}
 }
@@ -105,11 +109,16 @@ func (s *AccountService) Activate(id uint) (account.Account, error) {
 		return account.Account{}, err
 	}
 
-	a.AuthorizationToken, err = s.authService.CreateToken(authorization.AccountToken, a.ID, time.Time{})
+	session, err := s.authService.CreateSession(a.ID)
 	if err != nil {
 		return account.Account{}, err
 	}
 
+	a.AuthorizationToken = session.AccessToken
+	a.AuthorizationTokenExpiresAt = &session.AccessTokenExpiresAt
+	a.RefreshToken = session.RefreshToken
+	a.RefreshTokenExpiresAt = &session.RefreshTokenExpiresAt
+
 	return a, nil
 }
 
@@ -118,6 +127,11 @@ func (s *AccountService) GetByID(id uint) (account.Account, error) {
 	return s.repository.Find(account.Account{ID: id})
 }
 
+// Get the latest value of each property of the devices and energy queries of the account with id.
+func (s *AccountService) GetSnapshots(id uint) ([]latestvalue.Snapshot, error) {
+	return s.uploadService.GetSnapshotsForAccount(id)
+}
+
 // Get cloud feed auth statuses.
 func (s *AccountService) GetCloudFeedAuthStatuses(id uint) ([]cloudfeedstatus.CloudFeedStatus, error) {
 	var cloudFeedAuthStatuses []cloudfeedstatus.CloudFeedStatus
@@ -145,14 +159,26 @@ func (s *AccountService) GetCloudFeedAuthStatuses(id uint) ([]cloudfeedstatus.Cl
 		cloudFeedTypes = append(cloudFeedTypes, cloudFeedType)
 	}
 
-	for _, cloudFeed := range cloudFeedTypes {
-		cloudFeedAuth, err := s.cloudFeedService.Find(cloudfeed.CloudFeed{AccountID: id, CloudFeedTypeID: cloudFeed.ID})
-		if err != nil && !helpers.IsMySQLRecordNotFoundError(err) {
+	for _, cloudFeedType := range cloudFeedTypes {
+		cloudFeedAuthStatus, err := s.cloudFeedService.GetStatus(id, cloudFeedType)
+		if err != nil {
 			return cloudFeedAuthStatuses, err
 		}
 
-		cloudFeedAuthStatuses = append(cloudFeedAuthStatuses, cloudfeedstatus.MakeCloudFeedStatus(cloudFeed, cloudFeedAuth))
+		cloudFeedAuthStatuses = append(cloudFeedAuthStatuses, cloudFeedAuthStatus)
 	}
 
 	return cloudFeedAuthStatuses, nil
 }
+
+// Start the authorization of a cloud feed for the account with id.
+// The participant is redirected to the OAuth redirect URL of the app of the account's campaign
+// when the authorization is completed.
+func (s *AccountService) AuthorizeCloudFeed(id uint, cloudFeedTypeID uint) (string, error) {
+	a, err := s.GetByID(id)
+	if err != nil {
+		return "", err
+	}
+
+	return s.cloudFeedService.Authorize(id, cloudFeedTypeID, a.Campaign.App.OauthRedirectURL)
+}
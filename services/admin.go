@@ -1,12 +1,18 @@
 package services
 
 import (
+	"errors"
 	"time"
 
 	"github.com/energietransitie/needforheat-server-api/needforheat/admin"
 	"github.com/energietransitie/needforheat-server-api/needforheat/authorization"
 )
 
+var (
+	ErrInvalidCampaignScope = errors.New("campaign tokens can only have the campaign:admin or campaign:read scope")
+	ErrNoTokenCampaigns     = errors.New("campaign tokens should be limited to at least one campaign")
+)
+
 type AdminService struct {
 	repository admin.AdminRepository
 
@@ -79,3 +85,28 @@ func (s *AdminService) SetExpiry(a admin.Admin, expiry time.Time) (admin.Admin,
 	a.SetExpiry(expiry)
 	return s.repository.Update(a)
 }
+
+// Create a token for an admin with scope, limited to the campaigns with campaignIDs.
+// The token can be used for the routes of these campaigns, but not for other admin routes.
+// Like other tokens of the admin, it is invalidated when the admin is reactivated.
+func (s *AdminService) CreateCampaignToken(a admin.Admin, scope authorization.Scope, campaignIDs []uint) (string, error) {
+	if scope != authorization.ScopeCampaignAdmin && scope != authorization.ScopeCampaignRead {
+		return "", ErrInvalidCampaignScope
+	}
+
+	if len(campaignIDs) == 0 {
+		return "", ErrNoTokenCampaigns
+	}
+
+	a, err := s.repository.Find(a)
+	if err != nil {
+		return "", err
+	}
+
+	scopes := make([]authorization.Scope, 0, len(campaignIDs))
+	for _, campaignID := range campaignIDs {
+		scopes = append(scopes, scope.ForCampaign(campaignID))
+	}
+
+	return s.authService.CreateToken(authorization.AdminToken, a.ID, a.Expiry, scopes...)
+}
@@ -1,124 +1,406 @@
 package services
 
 import (
-	"crypto"
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
-	"io"
+	"fmt"
 	"os"
+	"sync"
 	"time"
 
+	"github.com/energietransitie/needforheat-server-api/internal/helpers"
+	"github.com/energietransitie/needforheat-server-api/needforheat"
 	"github.com/energietransitie/needforheat-server-api/needforheat/authorization"
+	"github.com/energietransitie/needforheat-server-api/needforheat/refreshtoken"
+	"github.com/energietransitie/needforheat-server-api/needforheat/token"
 	"github.com/sirupsen/logrus"
 )
 
-var (
-	ErrInvalidKeyAlgorithm = errors.New("invalid key algorithm")
+const (
+	// Type of the PEM blocks in a signing key file.
+	pemBlockTypeSigningKey = "EC PRIVATE KEY"
+	// PEM header that contains the time a signing key was created.
+	pemHeaderCreatedAt = "Created-At"
 )
 
 type AuthorizationService struct {
-	key *ecdsa.PrivateKey
+	keys *authorization.KeySet
+
+	// File the keys are saved to when they are rotated. Keys are only kept in memory if it is empty.
+	keyPath string
+	// Used to rotate and remove keys one at a time.
+	keyMu sync.Mutex
+
+	// Repository used to store account and device tokens, so they can be revoked.
+	tokenRepo token.TokenRepository
+
+	// Repository used to store the refresh tokens of accounts.
+	refreshTokenRepo refreshtoken.RefreshTokenRepository
+
+	lifetimes TokenLifetimes
 }
 
-// Create a new AuthorizationService.
-func NewAuthorizationService(key crypto.PrivateKey) (*AuthorizationService, error) {
-	ecdsaKey, ok := key.(*ecdsa.PrivateKey)
-	if !ok {
-		return nil, ErrInvalidKeyAlgorithm
-	}
+// Lifetimes of the tokens of an account session.
+type TokenLifetimes struct {
+	Access  time.Duration
+	Refresh time.Duration
+}
 
+// Create a new AuthorizationService.
+func NewAuthorizationService(keys *authorization.KeySet, tokenRepo token.TokenRepository, refreshTokenRepo refreshtoken.RefreshTokenRepository, lifetimes TokenLifetimes) *AuthorizationService {
 	return &AuthorizationService{
-		key: ecdsaKey,
-	}, nil
+		keys:             keys,
+		tokenRepo:        tokenRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		lifetimes:        lifetimes,
+	}
 }
 
-// Create a new AuthorizationService with the key from a file.
-func NewAuthorizationServiceFromFile(path string) (*AuthorizationService, error) {
-	key, err := keyFromFile(path)
+// Create a new AuthorizationService with the keys from a file.
+// The last key in the file is used to sign tokens. Rotated keys are saved to the file.
+func NewAuthorizationServiceFromFile(path string, tokenRepo token.TokenRepository, refreshTokenRepo refreshtoken.RefreshTokenRepository, lifetimes TokenLifetimes) (*AuthorizationService, error) {
+	keys, err := keysFromFile(path)
 	if err != nil {
 		if !os.IsNotExist(err) {
 			return nil, err
 		}
 
 		// File did not exist, so generate it.
-		err = generateKeyFile(path)
+		logrus.Info("generating key file")
+
+		key, err := authorization.GenerateSigningKey()
 		if err != nil {
 			return nil, err
 		}
 
-		// Load the key from newly generated file.
-		key, err = keyFromFile(path)
+		keys = []authorization.SigningKey{key}
+
+		err = saveKeysToFile(path, keys)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	return NewAuthorizationService(key)
-}
-
-// Open a file and attempt to read the private key from it.
-func keyFromFile(path string) (crypto.PrivateKey, error) {
-	file, err := os.Open(path)
+	keySet, err := authorization.NewKeySet(keys...)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
 
-	pemString, err := io.ReadAll(file)
+	s := NewAuthorizationService(keySet, tokenRepo, refreshTokenRepo, lifetimes)
+	s.keyPath = path
+
+	return s, nil
+}
+
+// Open a file and attempt to read the private keys from it.
+func keysFromFile(path string) ([]authorization.SigningKey, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
-	defer logrus.Info("key was successfully loaded from file")
+	var keys []authorization.SigningKey
+
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		if block.Type != pemBlockTypeSigningKey {
+			continue
+		}
+
+		privateKey, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		// Keys that were generated before keys could be rotated do not have a creation time.
+		var createdAt time.Time
+		if value, ok := block.Headers[pemHeaderCreatedAt]; ok {
+			createdAt, err = time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		key, err := authorization.MakeSigningKey(privateKey, createdAt)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: %s", authorization.ErrEmptyKeySet, path)
+	}
 
-	block, _ := pem.Decode(pemString)
-	return x509.ParseECPrivateKey(block.Bytes)
+	logrus.Infoln(len(keys), "keys were successfully loaded from file")
+
+	return keys, nil
 }
 
-// Generate a new private key and save it to a file.
-func generateKeyFile(path string) error {
-	logrus.Info("generating key file")
+// Save private keys to a file, replacing the keys in it.
+func saveKeysToFile(path string, keys []authorization.SigningKey) error {
+	var data []byte
 
-	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-	if err != nil {
-		return err
+	for _, key := range keys {
+		ecder, err := x509.MarshalECPrivateKey(key.Key)
+		if err != nil {
+			return err
+		}
+
+		block := &pem.Block{
+			Type:  pemBlockTypeSigningKey,
+			Bytes: ecder,
+		}
+
+		if !key.CreatedAt.IsZero() {
+			block.Headers = map[string]string{pemHeaderCreatedAt: key.CreatedAt.Format(time.RFC3339)}
+		}
+
+		data = append(data, pem.EncodeToMemory(block)...)
 	}
 
-	ecder, err := x509.MarshalECPrivateKey(key)
+	return os.WriteFile(path, data, 0600)
+}
+
+// Get the keys used to sign and verify tokens. The last key is the signing key.
+func (s *AuthorizationService) GetSigningKeys() []authorization.SigningKey {
+	return s.keys.Keys()
+}
+
+// Get the public keys that can be used to verify tokens.
+func (s *AuthorizationService) GetJWKS() (authorization.JWKS, error) {
+	return s.keys.JWKS()
+}
+
+// Generate a new signing key. The previous signing key is retired:
+// it is kept to verify the tokens it signed, until it is removed.
+func (s *AuthorizationService) RotateSigningKey() (authorization.SigningKey, error) {
+	s.keyMu.Lock()
+	defer s.keyMu.Unlock()
+
+	key, err := authorization.GenerateSigningKey()
 	if err != nil {
-		return err
+		return authorization.SigningKey{}, err
+	}
+
+	// Save the key before it is used, so tokens signed with it can be verified after a restart.
+	if s.keyPath != "" {
+		err = saveKeysToFile(s.keyPath, append(s.keys.Keys(), key))
+		if err != nil {
+			return authorization.SigningKey{}, err
+		}
 	}
 
-	file, err := os.Create(path)
+	s.keys.Add(key)
+
+	return key, nil
+}
+
+// Remove a retired signing key. Tokens that were signed with it can not be used anymore.
+func (s *AuthorizationService) RemoveSigningKey(id string) error {
+	s.keyMu.Lock()
+	defer s.keyMu.Unlock()
+
+	// Remove the key from a copy first, so it is only removed if the file was saved.
+	keys, err := authorization.NewKeySet(s.keys.Keys()...)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	err = pem.Encode(file, &pem.Block{Type: "EC PRIVATE KEY", Bytes: ecder})
+	err = keys.Remove(id)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	if s.keyPath != "" {
+		err = saveKeysToFile(s.keyPath, keys.Keys())
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.keys.Remove(id)
 }
 
-func (s *AuthorizationService) CreateToken(kind authorization.AuthKind, id uint, expiry time.Time) (string, error) {
-	return authorization.NewToken(kind, id, expiry, s.key)
+// Create a new token. Account and device tokens are stored, so they can be revoked.
+// Tokens without scopes get the scopes implied by their kind.
+func (s *AuthorizationService) CreateToken(kind authorization.AuthKind, id uint, expiry time.Time, scopes ...authorization.Scope) (string, error) {
+	return s.createToken(kind, id, expiry, "", scopes...)
+}
+
+// Create a new token, which is revoked together with the refresh token family familyID.
+func (s *AuthorizationService) createToken(kind authorization.AuthKind, id uint, expiry time.Time, familyID string, scopes ...authorization.Scope) (string, error) {
+	claims, err := authorization.NewClaims(kind, id, expiry, scopes...)
+	if err != nil {
+		return "", err
+	}
+
+	if token.IsTracked(kind) {
+		t := token.MakeToken(id, claims)
+		t.FamilyID = familyID
+
+		_, err = s.tokenRepo.Create(t)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return s.keys.Sign(claims)
 }
 
 func (s *AuthorizationService) CreateTokenFromAuthorization(auth authorization.Authorization, expiry time.Time) (string, error) {
-	return authorization.NewTokenFromAuthorization(auth, expiry, s.key)
+	return s.CreateToken(auth.Kind, auth.ID, expiry)
 }
 
 func (s *AuthorizationService) ParseToken(tokenString string) (authorization.AuthKind, uint, *authorization.Claims, error) {
-	return authorization.ParseToken(tokenString, s.key.Public())
+	return s.keys.ParseToken(tokenString)
 }
 
 func (s *AuthorizationService) ParseTokenToAuthorization(tokenString string) (*authorization.Authorization, error) {
-	return authorization.ParseTokenToAuthorization(tokenString, s.key.Public())
+	return s.keys.ParseTokenToAuthorization(tokenString)
+}
+
+// Check that an account or device token was issued by this server and was not revoked.
+// Tokens that were issued before tokens had an ID are stored the first time they are used,
+// so they can be revoked as well.
+func (s *AuthorizationService) CheckRevocation(tokenString string, auth *authorization.Authorization) error {
+	if !token.IsTracked(auth.Kind) {
+		return nil
+	}
+
+	var t token.Token
+	var err error
+
+	if auth.Claims.ID == "" {
+		legacy := token.MakeLegacyToken(tokenString, auth.ID, *auth.Claims)
+		t, err = s.tokenRepo.FindOrCreate(legacy)
+		if helpers.IsMySQLDuplicateError(err) {
+			// The token was stored by a concurrent request.
+			t, err = s.tokenRepo.Find(legacy.ID)
+		}
+	} else {
+		t, err = s.tokenRepo.Find(auth.Claims.ID)
+		if helpers.IsMySQLRecordNotFoundError(err) {
+			return token.ErrUnknown
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return t.Check()
+}
+
+// Get the tokens of the account with accountID and of its devices that can still be used.
+func (s *AuthorizationService) GetActiveTokens(accountID uint) ([]token.Token, error) {
+	return s.tokenRepo.GetActiveByAccount(accountID, time.Now())
+}
+
+// Revoke a token of the account with accountID or of one of its devices.
+func (s *AuthorizationService) RevokeToken(accountID uint, id string) (token.Token, error) {
+	t, err := s.tokenRepo.FindByAccount(accountID, id)
+	if err != nil {
+		return token.Token{}, err
+	}
+
+	t.Revoke()
+
+	t, err = s.tokenRepo.Update(t)
+	if err != nil {
+		return token.Token{}, err
+	}
+
+	// A session can not be continued after its access token was revoked.
+	if t.FamilyID != "" {
+		err = s.refreshTokenRepo.RevokeFamily(t.FamilyID, time.Time(*t.RevokedAt))
+		if err != nil {
+			return token.Token{}, err
+		}
+	}
+
+	return t, nil
+}
+
+// Create a new session for the account with accountID.
+// The session has a short-lived access token and a refresh token to get the next one.
+func (s *AuthorizationService) CreateSession(accountID uint) (refreshtoken.Session, error) {
+	return s.createSession(accountID, "")
+}
+
+// Use a refresh token to continue a session. A refresh token can only be used once.
+// If it is used again, the refresh token has leaked and all tokens of its family are revoked.
+func (s *AuthorizationService) RefreshSession(refreshToken string) (refreshtoken.Session, error) {
+	t, err := s.refreshTokenRepo.FindByHash(refreshtoken.Hash(refreshToken))
+	if err != nil {
+		if helpers.IsMySQLRecordNotFoundError(err) {
+			return refreshtoken.Session{}, refreshtoken.ErrInvalid
+		}
+		return refreshtoken.Session{}, err
+	}
+
+	now := time.Now().UTC()
+
+	err = t.Check(now)
+	if errors.Is(err, refreshtoken.ErrReused) {
+		return refreshtoken.Session{}, s.revokeFamily(t.FamilyID, now)
+	}
+	if err != nil {
+		return refreshtoken.Session{}, err
+	}
+
+	used, err := s.refreshTokenRepo.MarkUsed(t.ID, now)
+	if err != nil {
+		return refreshtoken.Session{}, err
+	}
+	if !used {
+		// The token was used by a concurrent request.
+		return refreshtoken.Session{}, s.revokeFamily(t.FamilyID, now)
+	}
+
+	return s.createSession(t.AccountID, t.FamilyID)
+}
+
+// Revoke all tokens of a refresh token family that was reused.
+func (s *AuthorizationService) revokeFamily(familyID string, at time.Time) error {
+	err := s.refreshTokenRepo.RevokeFamily(familyID, at)
+	if err != nil {
+		return err
+	}
+
+	logrus.WithField("family", familyID).Warn("refresh token was reused, revoked its family")
+	return refreshtoken.ErrReused
+}
+
+// Create a session in the refresh token family familyID, or in a new family if it is empty.
+func (s *AuthorizationService) createSession(accountID uint, familyID string) (refreshtoken.Session, error) {
+	refreshToken, err := refreshtoken.MakeRefreshToken(accountID, familyID, s.lifetimes.Refresh)
+	if err != nil {
+		return refreshtoken.Session{}, err
+	}
+
+	refreshToken, err = s.refreshTokenRepo.Create(refreshToken)
+	if err != nil {
+		return refreshtoken.Session{}, err
+	}
+
+	expiry := time.Now().UTC().Add(s.lifetimes.Access)
+
+	accessToken, err := s.createToken(authorization.AccountToken, accountID, expiry, refreshToken.FamilyID)
+	if err != nil {
+		return refreshtoken.Session{}, err
+	}
+
+	return refreshtoken.Session{
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  needforheat.Time(expiry),
+		RefreshToken:          refreshToken.Token,
+		RefreshTokenExpiresAt: refreshToken.ExpiresAt,
+	}, nil
 }
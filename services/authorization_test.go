@@ -0,0 +1,412 @@
+package services
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat/authorization"
+	"github.com/energietransitie/needforheat-server-api/needforheat/refreshtoken"
+	"github.com/energietransitie/needforheat-server-api/needforheat/token"
+	"github.com/energietransitie/needforheat-server-api/repositories"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupAuthorizationService creates an AuthorizationService that stores its tokens in a new SQLite database.
+// The database has device 1 of account 1 and device 2 of account 2.
+func setupAuthorizationService(t *testing.T) *AuthorizationService {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.AutoMigrate(&repositories.TokenModel{}, &repositories.RefreshTokenModel{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the columns of devices that are used to list tokens.
+	err = db.Exec("CREATE TABLE device (id INTEGER PRIMARY KEY, name TEXT, account_id INTEGER, deleted_at DATETIME)").Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Exec("INSERT INTO device (id, name, account_id) VALUES (1, 'device-1', 1), (2, 'device-2', 2)").Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := authorization.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := authorization.NewKeySet(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewAuthorizationService(
+		keys,
+		repositories.NewTokenRepository(db),
+		repositories.NewRefreshTokenRepository(db),
+		TokenLifetimes{Access: time.Hour, Refresh: time.Hour * 24},
+	)
+}
+
+// checkToken parses tokenString and checks if it is revoked.
+func checkToken(t *testing.T, s *AuthorizationService, tokenString string) error {
+	t.Helper()
+
+	auth, err := s.ParseTokenToAuthorization(tokenString)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s.CheckRevocation(tokenString, auth)
+}
+
+// signLegacyToken signs an account token without an ID, like tokens that were issued before tokens had an ID.
+func signLegacyToken(t *testing.T, s *AuthorizationService, accountID uint) string {
+	t.Helper()
+
+	claims, err := authorization.NewClaims(authorization.AccountToken, accountID, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims.ID = ""
+
+	tokenString, err := s.keys.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tokenString
+}
+
+func TestCheckRevocation_revoked(t *testing.T) {
+	s := setupAuthorizationService(t)
+
+	tokenString, err := s.CreateToken(authorization.AccountToken, 1, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = checkToken(t, s, tokenString)
+	if err != nil {
+		t.Fatalf("token is rejected before it is revoked: %v", err)
+	}
+
+	_, _, claims, err := s.ParseToken(tokenString)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another account can not revoke the token.
+	_, err = s.RevokeToken(2, claims.ID)
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("got error %v revoking the token of another account, want %v", err, gorm.ErrRecordNotFound)
+	}
+
+	revoked, err := s.RevokeToken(1, claims.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if revoked.RevokedAt == nil {
+		t.Error("revoked token has no revocation time")
+	}
+
+	err = checkToken(t, s, tokenString)
+	if !errors.Is(err, token.ErrRevoked) {
+		t.Fatalf("got error %v after revoking, want %v", err, token.ErrRevoked)
+	}
+}
+
+func TestCheckRevocation_unknown(t *testing.T) {
+	s := setupAuthorizationService(t)
+
+	// A token with an ID that was signed, but never stored.
+	claims, err := authorization.NewClaims(authorization.AccountToken, 1, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokenString, err := s.keys.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = checkToken(t, s, tokenString)
+	if !errors.Is(err, token.ErrUnknown) {
+		t.Fatalf("got error %v, want %v", err, token.ErrUnknown)
+	}
+}
+
+func TestCheckRevocation_legacy(t *testing.T) {
+	s := setupAuthorizationService(t)
+
+	tokenString := signLegacyToken(t, s, 1)
+
+	// The token is accepted and stored the first time it is used, and recognised when it is used again.
+	for i := 0; i < 2; i++ {
+		err := checkToken(t, s, tokenString)
+		if err != nil {
+			t.Fatalf("legacy token is rejected on use %d: %v", i+1, err)
+		}
+	}
+
+	auth, err := s.ParseTokenToAuthorization(tokenString)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	legacy := token.MakeLegacyToken(tokenString, 1, *auth.Claims)
+
+	tokens, err := s.GetActiveTokens(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tokens) != 1 || tokens[0].ID != legacy.ID {
+		t.Fatalf("got active tokens %+v, want only %s", tokens, legacy.ID)
+	}
+
+	_, err = s.RevokeToken(1, legacy.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = checkToken(t, s, tokenString)
+	if !errors.Is(err, token.ErrRevoked) {
+		t.Fatalf("got error %v after revoking, want %v", err, token.ErrRevoked)
+	}
+}
+
+func TestMakeLegacyToken(t *testing.T) {
+	s := setupAuthorizationService(t)
+
+	tokenString := signLegacyToken(t, s, 1)
+	otherTokenString := signLegacyToken(t, s, 1)
+
+	_, _, claims, err := s.ParseToken(tokenString)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	legacy := token.MakeLegacyToken(tokenString, 1, *claims)
+
+	if again := token.MakeLegacyToken(tokenString, 1, *claims); again.ID != legacy.ID {
+		t.Errorf("ID of the same token changed from %s to %s", legacy.ID, again.ID)
+	}
+
+	if other := token.MakeLegacyToken(otherTokenString, 1, *claims); other.ID == legacy.ID {
+		t.Errorf("different tokens have the same ID %s", legacy.ID)
+	}
+
+	// The ID should fit in the ID column of the token table.
+	if len(legacy.ID) != 71 {
+		t.Errorf("ID %s has length %d, want 71", legacy.ID, len(legacy.ID))
+	}
+
+	if legacy.Kind != authorization.AccountToken || legacy.SubjectID != 1 {
+		t.Errorf("got kind %s and subject %d, want %s and 1", legacy.Kind, legacy.SubjectID, authorization.AccountToken)
+	}
+}
+
+func TestGetActiveTokens(t *testing.T) {
+	s := setupAuthorizationService(t)
+
+	tokens := map[string]struct {
+		kind   authorization.AuthKind
+		id     uint
+		expiry time.Time
+	}{
+		"account":        {authorization.AccountToken, 1, time.Now().Add(time.Hour)},
+		"device":         {authorization.DeviceToken, 1, time.Now().Add(time.Hour)},
+		"expired":        {authorization.AccountToken, 1, time.Now().Add(-time.Hour)},
+		"revoked":        {authorization.AccountToken, 1, time.Now().Add(time.Hour)},
+		"other account":  {authorization.AccountToken, 2, time.Now().Add(time.Hour)},
+		"other's device": {authorization.DeviceToken, 2, time.Now().Add(time.Hour)},
+	}
+
+	ids := make(map[string]string)
+	for name, tt := range tokens {
+		tokenString, err := s.CreateToken(tt.kind, tt.id, tt.expiry)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Expired tokens can not be parsed, but their ID is not needed.
+		if name == "expired" {
+			continue
+		}
+
+		_, _, claims, err := s.ParseToken(tokenString)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = claims.ID
+	}
+
+	_, err := s.RevokeToken(1, ids["revoked"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	active, err := s.GetActiveTokens(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]token.Token)
+	for _, tok := range active {
+		got[tok.ID] = tok
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("got %d active tokens, want 2: %+v", len(got), active)
+	}
+
+	if _, ok := got[ids["account"]]; !ok {
+		t.Error("account token is not active")
+	}
+
+	deviceToken, ok := got[ids["device"]]
+	if !ok {
+		t.Fatal("device token is not active")
+	}
+
+	if deviceToken.DeviceName != "device-1" {
+		t.Errorf("device token has device name %q, want %q", deviceToken.DeviceName, "device-1")
+	}
+}
+
+func TestRefreshSession(t *testing.T) {
+	s := setupAuthorizationService(t)
+
+	session, err := s.CreateSession(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := s.RefreshSession(session.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rotated.RefreshToken == session.RefreshToken {
+		t.Error("refresh token was not rotated")
+	}
+
+	for _, accessToken := range []string{session.AccessToken, rotated.AccessToken} {
+		err = checkToken(t, s, accessToken)
+		if err != nil {
+			t.Fatalf("access token is rejected before the refresh token was reused: %v", err)
+		}
+	}
+
+	// The rotated refresh token can be used once.
+	next, err := s.RefreshSession(rotated.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.RefreshSession("unknown")
+	if !errors.Is(err, refreshtoken.ErrInvalid) {
+		t.Errorf("got error %v for an unknown refresh token, want %v", err, refreshtoken.ErrInvalid)
+	}
+
+	// Reusing a refresh token revokes its whole family.
+	_, err = s.RefreshSession(session.RefreshToken)
+	if !errors.Is(err, refreshtoken.ErrReused) {
+		t.Fatalf("got error %v reusing a refresh token, want %v", err, refreshtoken.ErrReused)
+	}
+
+	_, err = s.RefreshSession(next.RefreshToken)
+	if !errors.Is(err, refreshtoken.ErrInvalid) {
+		t.Errorf("got error %v for the latest refresh token of a revoked family, want %v", err, refreshtoken.ErrInvalid)
+	}
+
+	for _, accessToken := range []string{session.AccessToken, rotated.AccessToken, next.AccessToken} {
+		err = checkToken(t, s, accessToken)
+		if !errors.Is(err, token.ErrRevoked) {
+			t.Errorf("got error %v for an access token of a revoked family, want %v", err, token.ErrRevoked)
+		}
+	}
+}
+
+func TestRefreshSession_otherFamily(t *testing.T) {
+	s := setupAuthorizationService(t)
+
+	session, err := s.CreateSession(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := s.CreateSession(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.RefreshSession(session.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.RefreshSession(session.RefreshToken)
+	if !errors.Is(err, refreshtoken.ErrReused) {
+		t.Fatalf("got error %v reusing a refresh token, want %v", err, refreshtoken.ErrReused)
+	}
+
+	// Other sessions of the account are not revoked.
+	err = checkToken(t, s, other.AccessToken)
+	if err != nil {
+		t.Errorf("access token of another session is rejected: %v", err)
+	}
+
+	_, err = s.RefreshSession(other.RefreshToken)
+	if err != nil {
+		t.Errorf("refresh token of another session is rejected: %v", err)
+	}
+}
+
+func TestMarkUsed(t *testing.T) {
+	s := setupAuthorizationService(t)
+
+	session, err := s.CreateSession(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := s.refreshTokenRepo.FindByHash(refreshtoken.Hash(session.RefreshToken))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the first of concurrent uses marks the token as used.
+	used, err := s.refreshTokenRepo.MarkUsed(stored.ID, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !used {
+		t.Error("refresh token was not marked as used")
+	}
+
+	used, err = s.refreshTokenRepo.MarkUsed(stored.ID, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if used {
+		t.Error("refresh token was marked as used twice")
+	}
+}
@@ -10,42 +10,80 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/energietransitie/needforheat-server-api/internal/helpers"
+	"github.com/energietransitie/needforheat-server-api/internal/schedule"
 	"github.com/energietransitie/needforheat-server-api/needforheat"
+	"github.com/energietransitie/needforheat-server-api/needforheat/authorization"
 	"github.com/energietransitie/needforheat-server-api/needforheat/cloudfeed"
+	"github.com/energietransitie/needforheat-server-api/needforheat/cloudfeedauthorization"
+	"github.com/energietransitie/needforheat-server-api/needforheat/cloudfeedrun"
+	"github.com/energietransitie/needforheat-server-api/needforheat/cloudfeedstatus"
 	"github.com/energietransitie/needforheat-server-api/needforheat/cloudfeedtype"
+	"github.com/energietransitie/needforheat-server-api/needforheat/measurement"
 	"github.com/energietransitie/needforheat-server-api/needforheat/upload"
-	"github.com/energietransitie/needforheat-server-api/services/cloudfeeds/enelogic"
+	"github.com/energietransitie/needforheat-server-api/services/cloudfeeds"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/oauth2"
 )
 
 const (
-	CloudFeedTypeDownloadInterval = time.Hour * 24
+	// Maximum time between checks of the download schedules,
+	// so changed schedules are picked up without a restart.
+	DownloadSchedulerInterval = time.Minute
 )
 
 var (
-	ErrDuplicateCloudFeed = errors.New("duplicate cloud feed auth")
+	ErrDuplicateCloudFeed   = errors.New("duplicate cloud feed auth")
+	ErrInvalidGrant         = errors.New("cloud feed grant is invalid")
+	ErrInvalidState         = errors.New("cloud feed authorization state is invalid")
+	ErrAuthorizationExpired = errors.New("cloud feed authorization expired")
 
 	NoLatestUploadTime = needforheat.Time{}
 )
 
 type CloudFeedService struct {
-	cloudFeedRepo     cloudfeed.CloudFeedRepository
-	cloudFeedTypeRepo cloudfeedtype.CloudFeedTypeRepository
-	uploadService     *UploadService
-	updateChan        chan struct{}
+	cloudFeedRepo              cloudfeed.CloudFeedRepository
+	cloudFeedTypeRepo          cloudfeedtype.CloudFeedTypeRepository
+	cloudFeedRunRepo           cloudfeedrun.CloudFeedRunRepository
+	cloudFeedAuthorizationRepo cloudfeedauthorization.CloudFeedAuthorizationRepository
+	uploadService              *UploadService
+	authService                *AuthorizationService
+	updateChan                 chan struct{}
+
+	// URL the provider redirects to after an account authorized a cloud feed.
+	callbackURL string
+
+	// IDs of the CloudFeedTypes that are being downloaded.
+	downloading sync.Map
+
+	// Providers used to download data, keyed by CloudFeedType name.
+	providers *cloudfeeds.Registry
 }
 
 // Create a new CloudFeedService.
-func NewCloudFeedService(cloudFeedRepo cloudfeed.CloudFeedRepository, cloudFeedTypeRepo cloudfeedtype.CloudFeedTypeRepository, uploadService *UploadService) *CloudFeedService {
+func NewCloudFeedService(
+	cloudFeedRepo cloudfeed.CloudFeedRepository,
+	cloudFeedTypeRepo cloudfeedtype.CloudFeedTypeRepository,
+	cloudFeedRunRepo cloudfeedrun.CloudFeedRunRepository,
+	cloudFeedAuthorizationRepo cloudfeedauthorization.CloudFeedAuthorizationRepository,
+	uploadService *UploadService,
+	authService *AuthorizationService,
+	providers *cloudfeeds.Registry,
+	callbackURL string,
+) *CloudFeedService {
 	return &CloudFeedService{
-		cloudFeedRepo:     cloudFeedRepo,
-		cloudFeedTypeRepo: cloudFeedTypeRepo,
-		uploadService:     uploadService,
-		updateChan:        make(chan struct{}, 1),
+		cloudFeedRepo:              cloudFeedRepo,
+		cloudFeedTypeRepo:          cloudFeedTypeRepo,
+		cloudFeedRunRepo:           cloudFeedRunRepo,
+		cloudFeedAuthorizationRepo: cloudFeedAuthorizationRepo,
+		uploadService:              uploadService,
+		authService:                authService,
+		updateChan:                 make(chan struct{}, 1),
+		callbackURL:                callbackURL,
+		providers:                  providers,
 	}
 }
 
@@ -57,43 +95,286 @@ func (s *CloudFeedService) Create(ctx context.Context, accountID, cloudFeedTypeI
 		return cloudfeed.CloudFeed{}, err
 	}
 
-	scopes := strings.Split(cloudFeedType.Scope, " ")
+	conf := oauthConfig(cloudFeedType, cloudFeedType.RedirectURL)
 
-	conf := &oauth2.Config{
-		ClientID:     cloudFeedType.ClientID,
-		ClientSecret: cloudFeedType.ClientSecret,
-		Scopes:       scopes,
-		Endpoint: oauth2.Endpoint{
-			AuthURL:  cloudFeedType.AuthorizationURL,
-			TokenURL: cloudFeedType.TokenURL,
-		},
-		RedirectURL: cloudFeedType.RedirectURL,
+	accessToken, refreshToken, expiry, err := exchangeAuthCode(ctx, conf, authGrantToken)
+	if err != nil {
+		return cloudfeed.CloudFeed{}, err
+	}
+
+	return s.create(accountID, cloudFeedTypeID, accessToken, refreshToken, expiry, authGrantToken)
+}
+
+// Store a new cloudFeed with the tokens that were received from the provider.
+func (s *CloudFeedService) create(accountID, cloudFeedTypeID uint, accessToken, refreshToken string, expiry time.Time, authGrantToken string) (cloudfeed.CloudFeed, error) {
+	cloudFeed := cloudfeed.MakeCloudFeed(accountID, cloudFeedTypeID, accessToken, refreshToken, needforheat.Time(expiry), authGrantToken)
+
+	cloudFeed, err := s.cloudFeedRepo.Create(cloudFeed)
+	if err != nil {
+		return cloudFeed, err
+	}
+
+	// Signal an update. An update that is already pending is enough, so don't block.
+	select {
+	case s.updateChan <- struct{}{}:
+	default:
+	}
+
+	return cloudFeed, nil
+}
+
+// Start the authorization of a cloud feed by an account.
+// The returned URL should be opened by the participant to grant access at the provider.
+// When access is granted, the provider redirects to the callback of the server,
+// which completes the authorization using [CloudFeedService.CompleteAuthorization]
+// and then redirects the participant to appRedirectURL.
+//
+// The state parameter is a signed token, and a PKCE verifier is used,
+// so the authorization code can only be exchanged by this server, once.
+func (s *CloudFeedService) Authorize(accountID, cloudFeedTypeID uint, appRedirectURL string) (string, error) {
+	cloudFeedType, err := s.cloudFeedTypeRepo.Find(cloudfeedtype.CloudFeedType{ID: cloudFeedTypeID})
+	if err != nil {
+		return "", err
 	}
 
+	state, err := s.authService.CreateToken(authorization.CloudFeedStateToken, accountID, time.Now().Add(cloudfeedauthorization.Lifetime))
+	if err != nil {
+		return "", err
+	}
+
+	verifier := oauth2.GenerateVerifier()
+
+	err = s.cloudFeedAuthorizationRepo.DeleteExpired()
+	if err != nil {
+		logrus.Warnln("error deleting expired cloud feed authorizations:", err)
+	}
+
+	_, err = s.cloudFeedAuthorizationRepo.Create(cloudfeedauthorization.MakeCloudFeedAuthorization(state, accountID, cloudFeedTypeID, verifier, appRedirectURL))
+	if err != nil {
+		return "", err
+	}
+
+	conf := oauthConfig(cloudFeedType, s.callbackURL)
+
+	return conf.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// Complete the authorization that was started with [CloudFeedService.Authorize].
+// The code is exchanged for tokens, which are stored in a new cloud feed.
+//
+// The pending authorization is returned, even if the exchange failed,
+// so the participant can be redirected to the app.
+func (s *CloudFeedService) CompleteAuthorization(ctx context.Context, state string, code string) (cloudfeedauthorization.CloudFeedAuthorization, error) {
+	kind, accountID, _, err := s.authService.ParseToken(state)
+	if err != nil || kind != authorization.CloudFeedStateToken {
+		return cloudfeedauthorization.CloudFeedAuthorization{}, ErrInvalidState
+	}
+
+	pending, err := s.cloudFeedAuthorizationRepo.Take(cloudfeedauthorization.HashState(state))
+	if err != nil {
+		if helpers.IsMySQLRecordNotFoundError(err) {
+			return cloudfeedauthorization.CloudFeedAuthorization{}, ErrInvalidState
+		}
+		return cloudfeedauthorization.CloudFeedAuthorization{}, err
+	}
+
+	if pending.AccountID != accountID {
+		return cloudfeedauthorization.CloudFeedAuthorization{}, ErrInvalidState
+	}
+
+	if pending.IsExpired() {
+		return pending, ErrAuthorizationExpired
+	}
+
+	// An error returned by the provider is passed as an empty code.
+	if code == "" {
+		return pending, nil
+	}
+
+	cloudFeedType, err := s.cloudFeedTypeRepo.Find(cloudfeedtype.CloudFeedType{ID: pending.CloudFeedTypeID})
+	if err != nil {
+		return pending, err
+	}
+
+	conf := oauthConfig(cloudFeedType, s.callbackURL)
+
+	token, err := conf.Exchange(ctx, code, oauth2.AccessTypeOffline, oauth2.VerifierOption(pending.Verifier))
+	if err != nil {
+		return pending, err
+	}
+
+	// Replace the tokens if the cloud feed was authorized before, so it keeps its device and data.
+	_, err = s.replaceTokens(pending.AccountID, pending.CloudFeedTypeID, token.AccessToken, token.RefreshToken, token.Expiry, code)
+	if helpers.IsMySQLRecordNotFoundError(err) {
+		_, err = s.create(pending.AccountID, pending.CloudFeedTypeID, token.AccessToken, token.RefreshToken, token.Expiry, code)
+	}
+
+	return pending, err
+}
+
+// Authorize an existing cloud feed again, e.g. after it was revoked by the provider.
+// The AuthGrantToken (Code) is exchanged for new tokens, which replace the tokens of the cloud feed.
+// The cloud feed keeps its device and measurements.
+func (s *CloudFeedService) Reauthorize(ctx context.Context, accountID, cloudFeedTypeID uint, authGrantToken string) (cloudfeed.CloudFeed, error) {
+	_, err := s.cloudFeedRepo.Find(cloudfeed.CloudFeed{AccountID: accountID, CloudFeedTypeID: cloudFeedTypeID})
+	if err != nil {
+		return cloudfeed.CloudFeed{}, err
+	}
+
+	cloudFeedType, err := s.cloudFeedTypeRepo.Find(cloudfeedtype.CloudFeedType{ID: cloudFeedTypeID})
+	if err != nil {
+		return cloudfeed.CloudFeed{}, err
+	}
+
+	conf := oauthConfig(cloudFeedType, cloudFeedType.RedirectURL)
+
 	accessToken, refreshToken, expiry, err := exchangeAuthCode(ctx, conf, authGrantToken)
 	if err != nil {
 		return cloudfeed.CloudFeed{}, err
 	}
 
-	cloudFeed := cloudfeed.MakeCloudFeed(accountID, cloudFeedTypeID, accessToken, refreshToken, needforheat.Time(expiry), authGrantToken)
+	return s.replaceTokens(accountID, cloudFeedTypeID, accessToken, refreshToken, expiry, authGrantToken)
+}
 
-	cloudFeed, err = s.cloudFeedRepo.Create(cloudFeed)
+// Replace the tokens of an existing cloudFeed with tokens that were received from the provider.
+func (s *CloudFeedService) replaceTokens(accountID, cloudFeedTypeID uint, accessToken, refreshToken string, expiry time.Time, authGrantToken string) (cloudfeed.CloudFeed, error) {
+	cloudFeed, err := s.cloudFeedRepo.Find(cloudfeed.CloudFeed{AccountID: accountID, CloudFeedTypeID: cloudFeedTypeID})
+	if err != nil {
+		return cloudfeed.CloudFeed{}, err
+	}
+
+	cloudFeed.ReplaceTokens(accessToken, refreshToken, needforheat.Time(expiry), authGrantToken)
+
+	cloudFeed, err = s.cloudFeedRepo.Update(cloudFeed)
 	if err != nil {
 		return cloudFeed, err
 	}
 
-	// Signal an update
-	s.updateChan <- struct{}{}
+	// Signal an update. An update that is already pending is enough, so don't block.
+	select {
+	case s.updateChan <- struct{}{}:
+	default:
+	}
 
 	return cloudFeed, nil
 }
 
+// Disconnect the cloud feed of an account.
+// The tokens are revoked at the provider if its CloudFeedType has a RevocationURL, and the cloud feed is deleted.
+// If purge is true, all measurements of the device of the cloud feed are deleted as well.
+//
+// A failed revocation does not stop the disconnect, since the tokens are deleted anyway.
+func (s *CloudFeedService) Disconnect(ctx context.Context, accountID, cloudFeedTypeID uint, purge bool) error {
+	cloudFeed, err := s.cloudFeedRepo.Find(cloudfeed.CloudFeed{AccountID: accountID, CloudFeedTypeID: cloudFeedTypeID})
+	if err != nil {
+		return err
+	}
+
+	cloudFeedType, err := s.cloudFeedTypeRepo.Find(cloudfeedtype.CloudFeedType{ID: cloudFeedTypeID})
+	if err != nil {
+		return err
+	}
+
+	err = revokeTokens(ctx, s.providers.Client(cloudFeedType.Name), cloudFeedType, cloudFeed)
+	if err != nil {
+		logrus.Warnln("error revoking tokens for accountID", accountID, "cloudFeedTypeID", cloudFeedTypeID, ":", err)
+	}
+
+	if purge {
+		// The device is found through the cloud feed, so purge before deleting it.
+		device, err := s.cloudFeedRepo.FindDevice(cloudFeed)
+		if err != nil && !helpers.IsMySQLRecordNotFoundError(err) {
+			return err
+		}
+
+		if device != nil {
+			count, err := s.uploadService.DeleteAllForDeviceWithID(device.ID)
+			if err != nil {
+				return err
+			}
+
+			logrus.Infoln("purged", count, "measurements of device", device.Name, "for accountID", accountID)
+		}
+	}
+
+	err = s.cloudFeedRepo.Delete(cloudFeed)
+	if err != nil {
+		return err
+	}
+
+	// Signal an update. An update that is already pending is enough, so don't block.
+	select {
+	case s.updateChan <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Revoke the tokens of cloudFeed at the revocation endpoint of cloudFeedType (RFC 7009), using client.
+// Revoking the refresh token also invalidates the access token at most providers,
+// so the access token is only revoked if there is no refresh token.
+func revokeTokens(ctx context.Context, client *http.Client, cloudFeedType cloudfeedtype.CloudFeedType, cloudFeed cloudfeed.CloudFeed) error {
+	if cloudFeedType.RevocationURL == "" || cloudFeed.IsRevoked() {
+		return nil
+	}
+
+	token, tokenTypeHint := cloudFeed.RefreshToken, "refresh_token"
+	if token == "" {
+		token, tokenTypeHint = cloudFeed.AccessToken, "access_token"
+	}
+	if token == "" {
+		return nil
+	}
+
+	form := url.Values{}
+	form.Add("token", token)
+	form.Add("token_type_hint", tokenTypeHint)
+	form.Add("client_id", cloudFeedType.ClientID)
+	form.Add("client_secret", cloudFeedType.ClientSecret)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cloudFeedType.RevocationURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unsuccessful revocation request. status: %d, response: %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil
+}
+
+// Create the OAuth configuration of cloudFeedType, which redirects to redirectURL.
+func oauthConfig(cloudFeedType cloudfeedtype.CloudFeedType, redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     cloudFeedType.ClientID,
+		ClientSecret: cloudFeedType.ClientSecret,
+		Scopes:       strings.Split(cloudFeedType.Scope, " "),
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  cloudFeedType.AuthorizationURL,
+			TokenURL: cloudFeedType.TokenURL,
+		},
+		RedirectURL: redirectURL,
+	}
+}
+
 // Find a cloudFeed using any field set in the cloudFeed struct.
 func (s *CloudFeedService) Find(cloudFeed cloudfeed.CloudFeed) (cloudfeed.CloudFeed, error) {
 	return s.cloudFeedRepo.Find(cloudFeed)
 }
 
 // Refresh the tokens for the CloudFeed corresponding to accountID and cloudFeedTypeID.
+// A failed refresh is recorded in the CloudFeed. If the provider does not accept the
+// refresh token anymore, the CloudFeed is revoked.
 func (s *CloudFeedService) RefreshTokens(ctx context.Context, accountID uint, cloudFeedTypeID uint) (cloudfeed.CloudFeed, error) {
 	logrus.Infoln("refreshing token for accountID", accountID, "cloudFeedTypeID", cloudFeedTypeID)
 
@@ -102,18 +383,46 @@ func (s *CloudFeedService) RefreshTokens(ctx context.Context, accountID uint, cl
 		return cloudfeed.CloudFeed{}, err
 	}
 
-	tokenURL, refreshToken, clientID, clientSecret, err := s.cloudFeedRepo.FindOAuthInfo(accountID, cloudFeedTypeID)
+	accessToken, refreshToken, expiry, err := s.requestTokenRefresh(ctx, accountID, cloudFeedTypeID)
 	if err != nil {
+		if errors.Is(err, ErrInvalidGrant) {
+			// Revoke auth since we can not recover from "invalid_grant" error.
+			logrus.Warnln("revoking invalid cloud feed auth for accountID", accountID, "cloudFeedTypeID", cloudFeedTypeID)
+			cloudFeed.Revoke(err)
+		} else {
+			cloudFeed.RefreshFailed(err)
+		}
+
+		_, updateErr := s.cloudFeedRepo.Update(cloudFeed)
+		if updateErr != nil {
+			return cloudfeed.CloudFeed{}, fmt.Errorf("error saving failed refresh: %w", updateErr)
+		}
+
 		return cloudfeed.CloudFeed{}, err
 	}
 
+	cloudFeed.AccessToken = accessToken
+	cloudFeed.RefreshToken = refreshToken
+	cloudFeed.Expiry = expiry
+	cloudFeed.RefreshSucceeded()
+
+	return s.cloudFeedRepo.Update(cloudFeed)
+}
+
+// Request new tokens at the token endpoint of the cloud feed type, using the stored refresh token.
+func (s *CloudFeedService) requestTokenRefresh(ctx context.Context, accountID uint, cloudFeedTypeID uint) (string, string, needforheat.Time, error) {
+	tokenURL, refreshToken, clientID, clientSecret, err := s.cloudFeedRepo.FindOAuthInfo(accountID, cloudFeedTypeID)
+	if err != nil {
+		return "", "", needforheat.Time{}, err
+	}
+
 	if refreshToken == "" {
-		return cloudfeed.CloudFeed{}, errors.New("refresh token empty")
+		return "", "", needforheat.Time{}, errors.New("refresh token empty")
 	}
 
 	u, err := url.Parse(tokenURL)
 	if err != nil {
-		return cloudfeed.CloudFeed{}, err
+		return "", "", needforheat.Time{}, err
 	}
 
 	form := url.Values{}
@@ -124,19 +433,19 @@ func (s *CloudFeedService) RefreshTokens(ctx context.Context, accountID uint, cl
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), strings.NewReader(form.Encode()))
 	if err != nil {
-		return cloudfeed.CloudFeed{}, err
+		return "", "", needforheat.Time{}, err
 	}
 
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return cloudfeed.CloudFeed{}, err
+		return "", "", needforheat.Time{}, err
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return cloudfeed.CloudFeed{}, errors.New("error reading response from token endpoint")
+		return "", "", needforheat.Time{}, errors.New("error reading response from token endpoint")
 	}
 
 	response := struct {
@@ -149,29 +458,20 @@ func (s *CloudFeedService) RefreshTokens(ctx context.Context, accountID uint, cl
 	respBodyReader := bytes.NewReader(respBody)
 	err = json.NewDecoder(respBodyReader).Decode(&response)
 	if err != nil {
-		return cloudfeed.CloudFeed{}, err
+		return "", "", needforheat.Time{}, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		// Delete auth since we can not recover from "invalid_grant" error.
 		if response.Error == "invalid_grant" {
-			logrus.Warnln("deleting invalid cloud feed auth for accountID", accountID, "cloudFeedTypeID", cloudFeedTypeID)
-			err := s.cloudFeedRepo.Delete(cloudfeed.CloudFeed{AccountID: accountID, CloudFeedTypeID: cloudFeedTypeID})
-			if err != nil {
-				return cloudfeed.CloudFeed{}, fmt.Errorf("error deleting invalid auth: %w", err)
-			}
+			return "", "", needforheat.Time{}, fmt.Errorf("%w. request: %s", ErrInvalidGrant, string(respBody))
 		}
 
-		return cloudfeed.CloudFeed{}, fmt.Errorf("unsuccessful refresh request. request: %s", string(respBody))
+		return "", "", needforheat.Time{}, fmt.Errorf("unsuccessful refresh request. request: %s", string(respBody))
 	}
 
-	cloudFeed.AccessToken = response.AccessToken
-	cloudFeed.RefreshToken = response.RefreshToken
-
 	expiryUnix := time.Now().Add(time.Second * time.Duration(response.ExpiresIn)).Unix()
-	cloudFeed.Expiry = needforheat.Time(time.Unix(expiryUnix, 0))
 
-	return s.cloudFeedRepo.Update(cloudFeed)
+	return response.AccessToken, response.RefreshToken, needforheat.Time(time.Unix(expiryUnix, 0)), nil
 }
 
 // Run this function in a goroutine to keep tokens refreshed before they expire.
@@ -231,109 +531,332 @@ func exchangeAuthCode(ctx context.Context, conf *oauth2.Config, code string) (st
 	return token.AccessToken, token.RefreshToken, token.Expiry, nil
 }
 
-// Run this function in a goroutine to periodically download data from the cloud feed.
-// downloadStartTime is the time at which the data should be downloaded and repeated each day.
-func (s *CloudFeedService) DownloadInBackground(ctx context.Context, downloadStartTime time.Time) {
-	waitTime := time.Until(downloadStartTime)
-	startTimer := time.NewTimer(waitTime)
+// Run this function in a goroutine to download data from the cloud feeds on the schedule of their CloudFeedType.
+// CloudFeedTypes without a schedule are downloaded on defaultSchedule.
+//
+// The time of the next download is stored with the CloudFeedType, so the schedule survives restarts.
+// Downloads that were missed while the server was down are started when it is running again.
+func (s *CloudFeedService) DownloadInBackground(ctx context.Context, defaultSchedule schedule.Schedule) {
+	for {
+		waitTime := s.scheduleDownloads(ctx, defaultSchedule)
 
-	logrus.Infoln("waiting", waitTime.String(), "to start downloading data from cloud feeds")
+		timer := time.NewTimer(waitTime)
 
-	select {
-	case <-startTimer.C:
-		err := s.download(ctx)
-		if err != nil {
-			logrus.Errorln(err)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
 		}
-	case <-ctx.Done():
-		return
 	}
+}
 
-	ticker := time.NewTicker(CloudFeedTypeDownloadInterval)
+// Start the downloads of the CloudFeedTypes that are due and return the time until the next download.
+func (s *CloudFeedService) scheduleDownloads(ctx context.Context, defaultSchedule schedule.Schedule) time.Duration {
+	waitTime := DownloadSchedulerInterval
 
-	for {
-		waitTime = time.Until(time.Now().Add(CloudFeedTypeDownloadInterval))
-		logrus.Infoln("waiting", waitTime.String(), "to download data from cloud feeds")
+	cloudFeedTypes, err := s.cloudFeedTypeRepo.GetAll()
+	if err != nil {
+		logrus.Errorln("error getting cloud feed types:", err)
+		return waitTime
+	}
 
-		select {
-		case <-ticker.C:
-			err := s.download(ctx)
+	now := time.Now()
+
+	for _, cloudFeedType := range cloudFeedTypes {
+		if cloudFeedType.NextDownloadAt != nil && time.Time(*cloudFeedType.NextDownloadAt).After(now) {
+			waitTime = min(waitTime, time.Time(*cloudFeedType.NextDownloadAt).Sub(now))
+			continue
+		}
+
+		downloadSchedule := defaultSchedule
+		if cloudFeedType.DownloadSchedule != "" {
+			downloadSchedule, err = schedule.Parse(cloudFeedType.DownloadSchedule)
 			if err != nil {
-				logrus.Errorln(err)
+				logrus.Warningln("using default download schedule for cloud feed type", cloudFeedType.Name, ":", err)
+				downloadSchedule = defaultSchedule
 			}
-		case <-ctx.Done():
-			return
 		}
+
+		next := downloadSchedule.Next(now)
+		if next.IsZero() {
+			logrus.Warningln("download schedule of cloud feed type", cloudFeedType.Name, "never runs")
+			continue
+		}
+
+		// Claiming the download makes sure it is not started twice, e.g. by another instance.
+		claimed, err := s.cloudFeedTypeRepo.ClaimDownload(cloudFeedType, needforheat.Time(next))
+		if err != nil {
+			logrus.Errorln("error claiming download of cloud feed type", cloudFeedType.Name, ":", err)
+			continue
+		}
+
+		if !claimed {
+			continue
+		}
+
+		waitTime = min(waitTime, next.Sub(now))
+		logrus.Infoln("next download of cloud feed type", cloudFeedType.Name, "at", next)
+
+		// A CloudFeedType without a next download was not scheduled before, so it waits for the first scheduled time.
+		if cloudFeedType.NextDownloadAt == nil {
+			continue
+		}
+
+		if _, running := s.downloading.LoadOrStore(cloudFeedType.ID, struct{}{}); running {
+			logrus.Warningln("skipping download of cloud feed type", cloudFeedType.Name, "because the previous download is still running")
+			continue
+		}
+
+		go func(cloudFeedType cloudfeedtype.CloudFeedType) {
+			defer s.downloading.Delete(cloudFeedType.ID)
+
+			err := s.download(ctx, cloudFeedType)
+			if err != nil {
+				logrus.Errorln(err)
+			}
+		}(cloudFeedType)
 	}
+
+	return waitTime
 }
 
-func (s *CloudFeedService) download(ctx context.Context) error {
+// Download data from all cloud feeds of a CloudFeedType. Cloud feeds are downloaded concurrently,
+// using a pool of workers to limit the load on the provider.
+func (s *CloudFeedService) download(ctx context.Context, cloudFeedType cloudfeedtype.CloudFeedType) error {
 	cloudFeeds, err := s.cloudFeedRepo.GetAll()
 	if err != nil {
 		return err
 	}
 
-	logrus.Infoln("starting download of data from cloud feeds")
+	logrus.Infoln("starting download of data from cloud feeds of type", cloudFeedType.Name)
 
+	queue := make(chan cloudfeed.CloudFeed)
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.providers.Workers(cloudFeedType.Name); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for cfa := range queue {
+				s.downloadCloudFeed(ctx, cfa)
+			}
+		}()
+	}
+
+queueLoop:
 	for _, cfa := range cloudFeeds {
-		device, err := s.cloudFeedRepo.FindDevice(cfa)
-		if err != nil {
-			logrus.Warningln("error finding device for cloud feed auth:", err)
+		if cfa.CloudFeedTypeID != cloudFeedType.ID || cfa.IsRevoked() {
 			continue
 		}
 
-		latestUpload, isUpload, err := s.uploadService.GetLatestUploadTimeForDeviceWithID(device.ID)
-		if err != nil && !helpers.IsMySQLRecordNotFoundError(err) {
-			logrus.Warningln("error getting latest upload time for device:", err)
-			continue
+		select {
+		case queue <- cfa:
+		case <-ctx.Done():
+			break queueLoop
 		}
+	}
 
-		if latestUpload == nil || !isUpload {
-			*latestUpload = NoLatestUploadTime
-		}
+	close(queue)
+	wg.Wait()
 
-		now := time.Now().Unix()
-		unixNow := needforheat.Time(time.Unix(now, 0))
+	logrus.Infoln("finished download of data from cloud feeds of type", cloudFeedType.Name)
 
-		err = s.Download(ctx, cfa, *latestUpload, unixNow)
-		if err != nil {
-			logrus.Warningln(err)
-		}
+	return ctx.Err()
+}
+
+// Download the data of a cloud feed since its latest upload.
+func (s *CloudFeedService) downloadCloudFeed(ctx context.Context, cfa cloudfeed.CloudFeed) {
+	device, err := s.cloudFeedRepo.FindDevice(cfa)
+	if err != nil {
+		logrus.Warningln("error finding device for cloud feed auth:", err)
+		return
 	}
 
-	return nil
+	latestUpload, isUpload, err := s.uploadService.GetLatestUploadTimeForDeviceWithID(device.ID)
+	if err != nil && !helpers.IsMySQLRecordNotFoundError(err) {
+		logrus.Warningln("error getting latest upload time for device:", err)
+		return
+	}
+
+	startPeriod := NoLatestUploadTime
+	if latestUpload != nil && isUpload {
+		startPeriod = *latestUpload
+	}
+
+	now := time.Now().Unix()
+	unixNow := needforheat.Time(time.Unix(now, 0))
+
+	err = s.Download(ctx, cfa, startPeriod, unixNow)
+	if err != nil {
+		logrus.Warningln(err)
+	}
 }
 
 // Download data from a cloud feed using the cloud feed auth and store it in the database.
 // startPeriod and endPeriod are the time periods for which data should be downloaded.
+// Every call is recorded as a [cloudfeedrun.CloudFeedRun].
 func (s *CloudFeedService) Download(ctx context.Context, cfa cloudfeed.CloudFeed, startPeriod needforheat.Time, endPeriod needforheat.Time) error {
+	return s.runDownload(ctx, cfa, startPeriod, endPeriod, cloudfeeds.Download)
+}
+
+// Backfill detects the gaps in the stored measurements of a cloud feed from startPeriod
+// up to endPeriod, and downloads the data for exactly those gaps.
+// Gaps are whole days in UTC, so startPeriod and endPeriod are rounded down to midnight.
+// Every gap is downloaded and recorded as a separate [cloudfeedrun.CloudFeedRun].
+// The gaps that were found are returned.
+func (s *CloudFeedService) Backfill(ctx context.Context, cfa cloudfeed.CloudFeed, startPeriod needforheat.Time, endPeriod needforheat.Time) ([]measurement.Gap, error) {
+	device, err := s.cloudFeedRepo.FindDevice(cfa)
+	if err != nil {
+		return nil, fmt.Errorf("error finding device for cloud feed auth: %w", err)
+	}
+
+	// Measurements are counted per day, so only whole days are checked.
+	start := time.Time(startPeriod).UTC().Truncate(time.Hour * 24)
+	end := time.Time(endPeriod).UTC().Truncate(time.Hour * 24)
+
+	// Today is not complete yet, so it would always be detected as a gap.
+	today := time.Now().UTC().Truncate(time.Hour * 24)
+	if end.After(today) {
+		end = today
+	}
+
+	counts, err := s.uploadService.GetMeasurementCountsPerDayForDeviceWithID(device.ID, needforheat.Time(start), needforheat.Time(end))
+	if err != nil {
+		return nil, fmt.Errorf("error counting measurements: %w", err)
+	}
+
+	gaps := measurement.FindGaps(counts, start, end)
+
+	logrus.Infoln("found", len(gaps), "gaps for cloud feed auth with accountID", cfa.AccountID, "cloudFeedTypeID", cfa.CloudFeedTypeID)
+
+	for _, gap := range gaps {
+		err = s.runDownload(ctx, cfa, needforheat.Time(gap.Start), needforheat.Time(gap.End), cloudfeeds.Backfill)
+		if err != nil {
+			if errors.Is(err, cloudfeeds.ErrBackfillNotSupported) || errors.Is(err, context.Canceled) {
+				return gaps, err
+			}
+
+			// The provider may not have data for every gap, so continue with the next one.
+			logrus.Warningln("error backfilling gap from", gap.Start, "to", gap.End, ":", err)
+		}
+	}
+
+	return gaps, nil
+}
+
+// A downloadFunc downloads measurements from a provider, like [cloudfeeds.Download].
+type downloadFunc func(ctx context.Context, provider cloudfeeds.Provider, token string, startPeriod time.Time, endPeriod time.Time) ([]measurement.Measurement, error)
+
+// Download data from a cloud feed using download, store it in the database
+// and record it as a [cloudfeedrun.CloudFeedRun].
+func (s *CloudFeedService) runDownload(ctx context.Context, cfa cloudfeed.CloudFeed, startPeriod needforheat.Time, endPeriod needforheat.Time, download downloadFunc) error {
+	run := cloudfeedrun.MakeCloudFeedRun(cfa.AccountID, cfa.CloudFeedTypeID, startPeriod, endPeriod)
+
+	upload, err := s.downloadAndUpload(ctx, cfa, startPeriod, endPeriod, download)
+	run.Finish(upload.Size, upload.ID, err)
+
+	_, runErr := s.cloudFeedRunRepo.Create(run)
+	if runErr != nil {
+		logrus.Warningln("error saving cloud feed run:", runErr)
+	}
+
+	return err
+}
+
+// Get the status of the connection with a cloud feed type for an account.
+func (s *CloudFeedService) GetStatus(accountID uint, cloudFeedType cloudfeedtype.CloudFeedType) (cloudfeedstatus.CloudFeedStatus, error) {
+	cloudFeed, err := s.cloudFeedRepo.Find(cloudfeed.CloudFeed{AccountID: accountID, CloudFeedTypeID: cloudFeedType.ID})
+	if err != nil {
+		if helpers.IsMySQLRecordNotFoundError(err) {
+			return cloudfeedstatus.MakeCloudFeedStatus(cloudFeedType, cloudfeed.CloudFeed{}, nil, nil), nil
+		}
+		return cloudfeedstatus.CloudFeedStatus{}, err
+	}
+
+	latestRun, err := s.findLatestRun(accountID, cloudFeedType.ID, false)
+	if err != nil {
+		return cloudfeedstatus.CloudFeedStatus{}, err
+	}
+
+	latestSuccessfulRun, err := s.findLatestRun(accountID, cloudFeedType.ID, true)
+	if err != nil {
+		return cloudfeedstatus.CloudFeedStatus{}, err
+	}
+
+	return cloudfeedstatus.MakeCloudFeedStatus(cloudFeedType, cloudFeed, latestRun, latestSuccessfulRun), nil
+}
+
+// Find the latest run of a CloudFeed. Nil is returned if there is no such run.
+func (s *CloudFeedService) findLatestRun(accountID uint, cloudFeedTypeID uint, succeededOnly bool) (*cloudfeedrun.CloudFeedRun, error) {
+	run, err := s.cloudFeedRunRepo.FindLatest(accountID, cloudFeedTypeID, succeededOnly)
+	if err != nil {
+		if helpers.IsMySQLRecordNotFoundError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &run, nil
+}
+
+// Get the latest runs of the CloudFeed corresponding to accountID and cloudFeedTypeID.
+func (s *CloudFeedService) GetRuns(accountID uint, cloudFeedTypeID uint, limit int) ([]cloudfeedrun.CloudFeedRun, error) {
+	return s.cloudFeedRunRepo.GetAllByCloudFeed(accountID, cloudFeedTypeID, limit)
+}
+
+// Get the latest runs of all CloudFeeds.
+func (s *CloudFeedService) GetAllRuns(failedOnly bool, limit int) ([]cloudfeedrun.CloudFeedRun, error) {
+	return s.cloudFeedRunRepo.GetAll(failedOnly, limit)
+}
+
+func (s *CloudFeedService) downloadAndUpload(ctx context.Context, cfa cloudfeed.CloudFeed, startPeriod needforheat.Time, endPeriod needforheat.Time, download downloadFunc) (upload.Upload, error) {
 	logrus.Infoln("downloading data from cloud feed auth with accountID", cfa.AccountID, "cloudFeedTypeID", cfa.CloudFeedTypeID)
 
 	device, err := s.cloudFeedRepo.FindDevice(cfa)
 	if err != nil {
-		return fmt.Errorf("error finding device for cloud feed auth: %w", err)
+		return upload.Upload{}, fmt.Errorf("error finding device for cloud feed auth: %w", err)
+	}
+
+	cloudFeedType, err := s.cloudFeedTypeRepo.Find(cloudfeedtype.CloudFeedType{ID: cfa.CloudFeedTypeID})
+	if err != nil {
+		return upload.Upload{}, fmt.Errorf("error finding cloud feed type: %w", err)
 	}
 
-	measurements, err := enelogic.Download(ctx, cfa.AccessToken, time.Time(startPeriod), time.Time(endPeriod))
+	provider, err := s.providers.Get(cloudFeedType.Name)
 	if err != nil {
-		if err == enelogic.ErrNoData {
+		return upload.Upload{}, err
+	}
+
+	measurements, err := download(ctx, provider, cfa.AccessToken, time.Time(startPeriod), time.Time(endPeriod))
+	if err != nil {
+		if errors.Is(err, cloudfeeds.ErrNoData) {
 			logrus.Infoln("no (new) data found for cloud feed auth with accountID", cfa.AccountID, "cloudFeedTypeID", cfa.CloudFeedTypeID)
 		}
-		return err
+		return upload.Upload{}, err
 	}
 
 	if len(measurements) == 0 {
-		return errors.New(fmt.Sprint("no (new) data found for cloud feed auth with accountID", cfa.AccountID, "cloudFeedTypeID", cfa.CloudFeedTypeID))
+		return upload.Upload{}, errors.New(fmt.Sprint("no (new) data found for cloud feed auth with accountID", cfa.AccountID, "cloudFeedTypeID", cfa.CloudFeedTypeID))
 	}
 
-	upload, err := s.uploadService.Create(device.ID, upload.Device, needforheat.Time(time.Now()), measurements)
+	u, err := s.uploadService.Create(device.ID, upload.Device, needforheat.Time(time.Now()), measurements, "")
 	if err != nil {
-		return errors.New(fmt.Sprint("error creating upload:", err))
+		return upload.Upload{}, errors.New(fmt.Sprint("error creating upload:", err))
 	}
 
-	if upload.Size != len(measurements) {
-		return errors.New(fmt.Sprint("upload size", upload.Size, "does not match number of measurements", len(measurements)))
+	// Overlapping periods return measurements that were already stored, which are not stored again.
+	if u.Size+u.Duplicates+u.Rejected != len(measurements) {
+		return u, errors.New(fmt.Sprint("upload size", u.Size, "duplicates", u.Duplicates, "and rejected", u.Rejected, "do not match number of measurements", len(measurements)))
 	}
 
-	return nil
+	if u.Duplicates > 0 {
+		logrus.Infoln("skipped", u.Duplicates, "measurements that were already stored for cloud feed auth with accountID", cfa.AccountID, "cloudFeedTypeID", cfa.CloudFeedTypeID)
+	}
+
+	if u.Rejected > 0 {
+		logrus.Warnln("rejected", u.Rejected, "measurements with invalid values for cloud feed auth with accountID", cfa.AccountID, "cloudFeedTypeID", cfa.CloudFeedTypeID)
+	}
+
+	return u, nil
 }
@@ -0,0 +1,195 @@
+// Package cloudfeeds defines how data is downloaded from external cloud feeds.
+// Every cloud feed (like Enelogic) implements a [Provider],
+// which is registered in a [Registry] under the name of its CloudFeedType.
+package cloudfeeds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat/measurement"
+)
+
+var (
+	ErrNoData          = errors.New("no data from cloud feed")
+	ErrInvalidPeriod   = errors.New("invalid period")
+	ErrUnknownProvider = errors.New("no provider registered for cloud feed type")
+
+	ErrBackfillNotSupported = errors.New("cloud feed provider does not support backfilling")
+)
+
+// A MeasuringPoint is a source of measurements at a provider, like a smart meter.
+type MeasuringPoint struct {
+	// ID of the measuring point, as used by the provider.
+	ID string
+	// Kind of measuring point, like electricity or gas.
+	Kind string
+}
+
+// A Provider can download measurements from an external cloud feed.
+type Provider interface {
+	// MeasuringPoints returns all measuring points that can be accessed with token.
+	MeasuringPoints(ctx context.Context, token string) ([]MeasuringPoint, error)
+
+	// Download downloads the data of a measuring point and maps it to measurements.
+	//
+	// StartPeriod is the start of the period from which data should be downloaded.
+	// If StartPeriod is the zero value, this is the first download and the provider
+	// can decide how much history it downloads.
+	// EndPeriod is the end of the period from which data should be downloaded.
+	Download(ctx context.Context, token string, measuringPoint MeasuringPoint, startPeriod time.Time, endPeriod time.Time) ([]measurement.Measurement, error)
+}
+
+// A Backfiller is a [Provider] that can download an arbitrary period of history,
+// which is used to fill gaps in the stored measurements.
+type Backfiller interface {
+	Provider
+
+	// DownloadWindow downloads the data of a measuring point for exactly the period
+	// from startPeriod to endPeriod, in the highest resolution that is available.
+	DownloadWindow(ctx context.Context, token string, measuringPoint MeasuringPoint, startPeriod time.Time, endPeriod time.Time) ([]measurement.Measurement, error)
+}
+
+// A ClientProvider is a [Provider] that sends its requests using an http client.
+// The client is used for other requests to the provider as well, like revoking tokens,
+// so they are rate limited and retried like downloads.
+type ClientProvider interface {
+	Provider
+
+	// Client returns the http client the provider sends its requests with.
+	Client() *http.Client
+}
+
+// Client used for requests to providers that do not implement [ClientProvider].
+var defaultClient = &http.Client{
+	Timeout: 10 * time.Second,
+}
+
+// A Registry contains the providers that are available, keyed by CloudFeedType name.
+type Registry struct {
+	providers map[string]Provider
+	workers   map[string]int
+}
+
+// Create a new Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		providers: make(map[string]Provider),
+		workers:   make(map[string]int),
+	}
+}
+
+// Register a provider for the CloudFeedType with name.
+// Workers is the maximum number of cloud feeds that are downloaded from the provider at the same time.
+// A provider that was registered earlier with the same name is replaced.
+func (r *Registry) Register(name string, provider Provider, workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	r.providers[name] = provider
+	r.workers[name] = workers
+}
+
+// Get the provider for the CloudFeedType with name.
+func (r *Registry) Get(name string) (Provider, error) {
+	provider, ok := r.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, name)
+	}
+
+	return provider, nil
+}
+
+// Get the http client for requests to the provider for the CloudFeedType with name.
+// If the provider does not implement [ClientProvider], a client with a timeout is returned.
+func (r *Registry) Client(name string) *http.Client {
+	provider, ok := r.providers[name].(ClientProvider)
+	if !ok {
+		return defaultClient
+	}
+
+	return provider.Client()
+}
+
+// Get the maximum number of cloud feeds that are downloaded at the same time
+// from the provider for the CloudFeedType with name.
+func (r *Registry) Workers(name string) int {
+	workers, ok := r.workers[name]
+	if !ok {
+		return 1
+	}
+
+	return workers
+}
+
+// Download downloads the data for all measuring points available to token.
+// A slice of measurements is returned, which can be saved to the database.
+//
+// StartPeriod is the start of the period from which data should be downloaded.
+// EndPeriod is the end of the period from which data should be downloaded.
+func Download(ctx context.Context, provider Provider, token string, startPeriod time.Time, endPeriod time.Time) ([]measurement.Measurement, error) {
+	if endPeriod.Before(startPeriod) {
+		return nil, fmt.Errorf("%w: end period is before start period", ErrInvalidPeriod)
+	}
+
+	if DateEqual(startPeriod, endPeriod) {
+		return nil, ErrNoData
+	}
+
+	return downloadMeasuringPoints(ctx, provider, token, func(measuringPoint MeasuringPoint) ([]measurement.Measurement, error) {
+		return provider.Download(ctx, token, measuringPoint, startPeriod, endPeriod)
+	})
+}
+
+// Backfill downloads the data for all measuring points available to token,
+// for exactly the period from startPeriod to endPeriod.
+// ErrBackfillNotSupported is returned if provider is not a [Backfiller].
+func Backfill(ctx context.Context, provider Provider, token string, startPeriod time.Time, endPeriod time.Time) ([]measurement.Measurement, error) {
+	backfiller, ok := provider.(Backfiller)
+	if !ok {
+		return nil, ErrBackfillNotSupported
+	}
+
+	if !endPeriod.After(startPeriod) {
+		return nil, fmt.Errorf("%w: end period is not after start period", ErrInvalidPeriod)
+	}
+
+	return downloadMeasuringPoints(ctx, provider, token, func(measuringPoint MeasuringPoint) ([]measurement.Measurement, error) {
+		return backfiller.DownloadWindow(ctx, token, measuringPoint, startPeriod, endPeriod)
+	})
+}
+
+// Call download for every measuring point available to token and combine the results.
+func downloadMeasuringPoints(ctx context.Context, provider Provider, token string, download func(MeasuringPoint) ([]measurement.Measurement, error)) ([]measurement.Measurement, error) {
+	measuringPoints, err := provider.MeasuringPoints(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("error getting measuring points: %w", err)
+	}
+
+	if len(measuringPoints) == 0 {
+		return nil, ErrNoData
+	}
+
+	var measurements []measurement.Measurement
+
+	for _, measuringPoint := range measuringPoints {
+		m, err := download(measuringPoint)
+		if err != nil {
+			return nil, err
+		}
+		measurements = append(measurements, m...)
+	}
+
+	return measurements, nil
+}
+
+// DateEqual returns true if a and b are on the same date.
+func DateEqual(a, b time.Time) bool {
+	y1, m1, d1 := a.Date()
+	y2, m2, d2 := b.Date()
+	return y1 == y2 && m1 == m2 && d1 == d2
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
@@ -14,22 +15,14 @@ import (
 	"github.com/energietransitie/needforheat-server-api/needforheat"
 	"github.com/energietransitie/needforheat-server-api/needforheat/measurement"
 	"github.com/energietransitie/needforheat-server-api/needforheat/property"
+	"github.com/energietransitie/needforheat-server-api/services/cloudfeeds"
 	"github.com/sirupsen/logrus"
 )
 
-// Client is the http client used to make requests to enelogic.
-var client = &http.Client{
-	Timeout: 10 * time.Second,
-	Transport: &http.Transport{
-
-		MaxIdleConns:        10,
-		MaxIdleConnsPerHost: 10,
-		MaxConnsPerHost:     10,
-		IdleConnTimeout:     time.Second * 30,
-	},
-}
-
 const (
+	// Name of the CloudFeedType this provider is registered for.
+	Name = "enelogic"
+
 	endpointMeasuringPoints    = "/measuringpoints"
 	endpointDatapointsMonths   = "/measuringpoints/{{.MeasuringPointID}}/datapoint/months/{{.From}}/{{.To}}"
 	endpointDatapointsDays     = "/measuringpoints/{{.MeasuringPointID}}/datapoint/days/{{.From}}/{{.To}}"
@@ -39,13 +32,17 @@ const (
 	enelogicDefaultTimeLocation = "Europe/Amsterdam"
 
 	Day = time.Hour * 24
+
+	// BaseURL of the Enelogic API.
+	BaseURL = "https://enelogic.com/api"
+
+	// Number of cloud feeds that are downloaded from enelogic at the same time.
+	Workers = 4
 )
 
 var (
-	baseURL = "https://enelogic.com/api"
-
-	ErrNoData        = errors.New("no data from enelogic")
-	ErrInvalidPeriod = errors.New("invalid period")
+	ErrNoData        = cloudfeeds.ErrNoData
+	ErrInvalidPeriod = cloudfeeds.ErrInvalidPeriod
 )
 
 // EnelogicTime is a custom time type for enelogic.
@@ -105,6 +102,18 @@ func (u UnitType) String() string {
 	return "unknown"
 }
 
+// Parse a UnitType from the string returned by [UnitType.String].
+func parseUnitType(s string) (UnitType, error) {
+	switch s {
+	case UnitTypeElectricity.String():
+		return UnitTypeElectricity, nil
+	case UnitTypeGas.String():
+		return UnitTypeGas, nil
+	}
+
+	return 0, fmt.Errorf("unknown unit type %q", s)
+}
+
 // Rate is the type of the Rate, as defined by enelogic.
 type Rate int
 
@@ -218,13 +227,72 @@ func newRequestArgs(measuringPointID int, from, to time.Time) RequestArgs {
 	}
 }
 
-// Download downloads the data from enelogic.
+// Create a new http client for requests to enelogic.
+// Requests are rate limited and retried when enelogic is busy.
+func NewClient() *http.Client {
+	transport := &http.Transport{
+		MaxIdleConns:        10,
+		MaxIdleConnsPerHost: 10,
+		MaxConnsPerHost:     10,
+		IdleConnTimeout:     time.Second * 30,
+	}
+
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: cloudfeeds.NewTransport(transport, cloudfeeds.RateLimit{
+			RequestsPerSecond: 5,
+			MaxRetries:        5,
+			MinBackoff:        time.Second,
+			MaxBackoff:        time.Minute,
+		}),
+	}
+}
+
+// Provider downloads data from the Enelogic API.
+// It implements [cloudfeeds.Provider], [cloudfeeds.Backfiller] and [cloudfeeds.ClientProvider].
+type Provider struct {
+	client  *http.Client
+	baseURL string
+}
+
+// Create a new Provider that sends requests to the Enelogic API at baseURL using client.
+func NewProvider(client *http.Client, baseURL string) *Provider {
+	return &Provider{
+		client:  client,
+		baseURL: baseURL,
+	}
+}
+
+// Client returns the http client the provider sends its requests with.
+func (p *Provider) Client() *http.Client {
+	return p.client
+}
+
+// MeasuringPoints returns the measuring points for the account with the given token.
+func (p *Provider) MeasuringPoints(ctx context.Context, token string) ([]cloudfeeds.MeasuringPoint, error) {
+	response, err := p.getMeasuringPoints(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	measuringPoints := make([]cloudfeeds.MeasuringPoint, 0, len(response))
+	for _, measuringPoint := range response {
+		measuringPoints = append(measuringPoints, cloudfeeds.MeasuringPoint{
+			ID:   strconv.Itoa(measuringPoint.ID),
+			Kind: measuringPoint.UnitType.String(),
+		})
+	}
+
+	return measuringPoints, nil
+}
+
+// Download downloads the data of a measuring point from enelogic.
 // A slice of measurements is returned, which can be saved to the database.
 //
 // StartPeriod is the start of the period from which data should be downloaded.
+// If StartPeriod is the zero value, data from the last 14 months is downloaded.
 // EndPeriod is the end of the period from which data should be downloaded.
-// If EndPeriod is the zero value, the current time is used.
-func Download(ctx context.Context, token string, startPeriod time.Time, endPeriod time.Time) ([]measurement.Measurement, error) {
+func (p *Provider) Download(ctx context.Context, token string, mp cloudfeeds.MeasuringPoint, startPeriod time.Time, endPeriod time.Time) ([]measurement.Measurement, error) {
 	var measurements []measurement.Measurement
 	isFirstDownload := startPeriod.IsZero()
 
@@ -232,70 +300,112 @@ func Download(ctx context.Context, token string, startPeriod time.Time, endPerio
 		startPeriod = endPeriod.AddDate(-1, -2, 0)
 	}
 
-	if endPeriod.Before(startPeriod) {
-		return nil, fmt.Errorf("%w: end period is before start period", ErrInvalidPeriod)
+	measuringPointID, err := strconv.Atoi(mp.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid measuring point ID: %w", err)
 	}
 
-	if dateEqual(startPeriod, endPeriod) {
-		return nil, ErrNoData
+	unitType, err := parseUnitType(mp.Kind)
+	if err != nil {
+		return nil, err
 	}
 
-	measuringPoints, err := getMeasuringPoints(ctx, token)
+	// Get month datapoints.
+	// Only get month datapoints on the first download or the first day of the month.
+	if endPeriod.Day() == 1 || isFirstDownload {
+		logrus.Infoln("downloading", unitType.String(), "month datapoints from", RequestTime{startPeriod}, "to", RequestTime{endPeriod})
+
+		args := newRequestArgs(measuringPointID, startPeriod, endPeriod)
+		datapoints, err := p.getDatapoints(ctx, token, endpointDatapointsMonths, args)
+		if err != nil {
+			return nil, fmt.Errorf("error getting month datapoints: %w", err)
+		}
+		measurements = append(measurements, parseDatapoints(datapoints, unitType)...)
+	}
+
+	// Get day datapoints.
+	dayStartPeriod := startPeriod
+	if time.Since(dayStartPeriod) > Day*40 {
+		// Set dayStartPeriod to 40 days ago, if the real dayStartPeriod is more than 40 days ago.
+		dayStartPeriod = endPeriod.Add(-Day * 40)
+	}
+
+	dayMeasurements, err := p.downloadDays(ctx, token, measuringPointID, unitType, dayStartPeriod, endPeriod)
 	if err != nil {
-		return nil, fmt.Errorf("error getting measuring points: %w", err)
+		return nil, err
 	}
+	measurements = append(measurements, dayMeasurements...)
 
-	if len(measuringPoints) == 0 {
-		return nil, ErrNoData
+	// Get interval datapoints.
+	intervalStartPeriod := startPeriod
+	if time.Since(intervalStartPeriod) > Day*10 {
+		// Set intervalStartPeriod to 10 days ago, if the real intervalStartPeriod is more than 10 days ago.
+		intervalStartPeriod = endPeriod.Add(-Day * 10)
 	}
 
-	for _, measuringPoint := range measuringPoints {
-		// Get month datapoints.
-		// Only get month datapoints on the first download or the first day of the month.
-		if endPeriod.Day() == 1 || isFirstDownload {
-			logrus.Infoln("downloading", measuringPoint.UnitType.String(), "month datapoints from", RequestTime{startPeriod}, "to", RequestTime{endPeriod})
+	intervalMeasurements, err := p.downloadIntervals(ctx, token, measuringPointID, unitType, intervalStartPeriod, endPeriod)
+	if err != nil {
+		return nil, err
+	}
+	measurements = append(measurements, intervalMeasurements...)
 
-			args := newRequestArgs(measuringPoint.ID, startPeriod, endPeriod)
-			datapoints, err := getDatapoints(ctx, token, endpointDatapointsMonths, args)
-			if err != nil {
-				return nil, fmt.Errorf("error getting month datapoints: %w", err)
-			}
-			measurements = append(measurements, parseDatapoints(datapoints, measuringPoint.UnitType)...)
-		}
+	return measurements, nil
+}
 
-		// Get day datapoints.
-		dayStartPeriod := startPeriod
-		if time.Since(dayStartPeriod) > Day*40 {
-			// Set dayStartPeriod to 40 days ago, if the real dayStartPeriod is more than 40 days ago.
-			dayStartPeriod = endPeriod.Add(-Day * 40)
-		}
+// DownloadWindow downloads the day and interval data of a measuring point from enelogic,
+// for exactly the period from startPeriod to endPeriod.
+// Unlike [Provider.Download], the period is not limited to the last days.
+func (p *Provider) DownloadWindow(ctx context.Context, token string, mp cloudfeeds.MeasuringPoint, startPeriod time.Time, endPeriod time.Time) ([]measurement.Measurement, error) {
+	measuringPointID, err := strconv.Atoi(mp.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid measuring point ID: %w", err)
+	}
 
-		logrus.Infoln("downloading", measuringPoint.UnitType.String(), "day datapoints from", RequestTime{dayStartPeriod}, "to", RequestTime{endPeriod})
+	unitType, err := parseUnitType(mp.Kind)
+	if err != nil {
+		return nil, err
+	}
 
-		args := newRequestArgs(measuringPoint.ID, dayStartPeriod, endPeriod)
-		datapoints, err := getDatapoints(ctx, token, endpointDatapointsDays, args)
-		if err != nil {
-			return nil, fmt.Errorf("error getting day datapoints: %w", err)
-		}
-		measurements = append(measurements, parseDatapoints(datapoints, measuringPoint.UnitType)...)
+	measurements, err := p.downloadDays(ctx, token, measuringPointID, unitType, startPeriod, endPeriod)
+	if err != nil {
+		return nil, err
+	}
 
-		// Get interval datapoints.
-		intervalStartPeriod := startPeriod
-		if time.Since(intervalStartPeriod) > Day*10 {
-			// Set intervalStartPeriod to 10 days ago, if the real intervalStartPeriod is more than 10 days ago.
-			intervalStartPeriod = endPeriod.Add(-Day * 10)
-		}
+	intervalMeasurements, err := p.downloadIntervals(ctx, token, measuringPointID, unitType, startPeriod, endPeriod)
+	if err != nil {
+		return nil, err
+	}
 
-		logrus.Infoln("downloading", measuringPoint.UnitType.String(), "interval datapoints from", RequestTime{intervalStartPeriod}, "to", RequestTime{endPeriod})
+	return append(measurements, intervalMeasurements...), nil
+}
 
-		for _, day := range splitDays(intervalStartPeriod, endPeriod) {
-			args := newRequestArgs(measuringPoint.ID, day.Start, day.End)
-			datapoints, err := getDatapoints(ctx, token, endpointDatapointsInterval, args)
-			if err != nil {
-				return nil, fmt.Errorf("error getting interval datapoints: %w", err)
-			}
-			measurements = append(measurements, parseDatapoints(datapoints, measuringPoint.UnitType)...)
+// Download the day datapoints of a measuring point from startPeriod to endPeriod.
+func (p *Provider) downloadDays(ctx context.Context, token string, measuringPointID int, unitType UnitType, startPeriod time.Time, endPeriod time.Time) ([]measurement.Measurement, error) {
+	logrus.Infoln("downloading", unitType.String(), "day datapoints from", RequestTime{startPeriod}, "to", RequestTime{endPeriod})
+
+	args := newRequestArgs(measuringPointID, startPeriod, endPeriod)
+	datapoints, err := p.getDatapoints(ctx, token, endpointDatapointsDays, args)
+	if err != nil {
+		return nil, fmt.Errorf("error getting day datapoints: %w", err)
+	}
+
+	return parseDatapoints(datapoints, unitType), nil
+}
+
+// Download the interval datapoints of a measuring point from startPeriod to endPeriod.
+// Enelogic only returns interval datapoints per day, so a request is made for every day.
+func (p *Provider) downloadIntervals(ctx context.Context, token string, measuringPointID int, unitType UnitType, startPeriod time.Time, endPeriod time.Time) ([]measurement.Measurement, error) {
+	var measurements []measurement.Measurement
+
+	logrus.Infoln("downloading", unitType.String(), "interval datapoints from", RequestTime{startPeriod}, "to", RequestTime{endPeriod})
+
+	for _, day := range splitDays(startPeriod, endPeriod) {
+		args := newRequestArgs(measuringPointID, day.Start, day.End)
+		datapoints, err := p.getDatapoints(ctx, token, endpointDatapointsInterval, args)
+		if err != nil {
+			return nil, fmt.Errorf("error getting interval datapoints: %w", err)
 		}
+		measurements = append(measurements, parseDatapoints(datapoints, unitType)...)
 	}
 
 	return measurements, nil
@@ -312,8 +422,8 @@ func parseDatapoints(datapoints DatapointsResponse, unit UnitType) []measurement
 }
 
 // GetMeasuringPoints returns the measuring points for the account with the given token.
-func getMeasuringPoints(ctx context.Context, token string) (MeasuringsPointsResponse, error) {
-	requestURL := baseURL + endpointMeasuringPoints
+func (p *Provider) getMeasuringPoints(ctx context.Context, token string) (MeasuringsPointsResponse, error) {
+	requestURL := p.baseURL + endpointMeasuringPoints
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
 	if err != nil {
@@ -322,24 +432,28 @@ func getMeasuringPoints(ctx context.Context, token string) (MeasuringsPointsResp
 
 	req.Header.Add("Authorization", "Bearer "+token)
 
-	resp, err := client.Do(req)
+	resp, err := p.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("error executing request to Enelogic: %w", err)
 	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status from Enelogic: %s", resp.Status)
+	}
 
 	var response MeasuringsPointsResponse
 	err = json.NewDecoder(resp.Body).Decode(&response)
 	if err != nil {
 		return nil, fmt.Errorf("error decoding response to json: %w", err)
 	}
-	defer resp.Body.Close()
 
 	return response, nil
 }
 
 // GetDatapoints returns the data points for the account with the given token.
-func getDatapoints(ctx context.Context, token string, endpoint string, args RequestArgs) (DatapointsResponse, error) {
-	requestUrl, err := getRequestURL(endpoint, args)
+func (p *Provider) getDatapoints(ctx context.Context, token string, endpoint string, args RequestArgs) (DatapointsResponse, error) {
+	requestUrl, err := p.getRequestURL(endpoint, args)
 	if err != nil {
 		return nil, fmt.Errorf("error getting request url: %w", err)
 	}
@@ -351,24 +465,29 @@ func getDatapoints(ctx context.Context, token string, endpoint string, args Requ
 
 	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
 
-	resp, err := client.Do(req)
+	resp, err := p.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("error executing request to Enelogic: %w", err)
 	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status from Enelogic: %s", resp.Status)
+	}
 
 	var response DatapointsResponse
-	json.NewDecoder(resp.Body).Decode(&response)
-	if err != nil {
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil && !errors.Is(err, io.EOF) {
 		return nil, fmt.Errorf("error decoding response to json: %w", err)
 	}
 
 	return response, nil
 }
 
-func getRequestURL(endpoint string, args RequestArgs) (string, error) {
+func (p *Provider) getRequestURL(endpoint string, args RequestArgs) (string, error) {
 	requestURL := strings.Builder{}
 
-	t, err := template.New("url").Parse(baseURL + endpoint)
+	t, err := template.New("url").Parse(p.baseURL + endpoint)
 	if err != nil {
 		return "", fmt.Errorf("error parsing template: %w", err)
 	}
@@ -381,12 +500,6 @@ func getRequestURL(endpoint string, args RequestArgs) (string, error) {
 	return requestURL.String(), nil
 }
 
-func dateEqual(a, b time.Time) bool {
-	y1, m1, d1 := a.Date()
-	y2, m2, d2 := b.Date()
-	return y1 == y2 && m1 == m2 && d1 == d2
-}
-
 type day struct {
 	Start time.Time
 	End   time.Time
@@ -395,7 +508,7 @@ type day struct {
 func splitDays(from, to time.Time) []day {
 	var days []day
 
-	for from.Before(to) && !dateEqual(from, to) {
+	for from.Before(to) && !cloudfeeds.DateEqual(from, to) {
 		to := from.AddDate(0, 0, 1)
 		days = append(days, day{
 			Start: from,
@@ -5,17 +5,19 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/energietransitie/needforheat-server-api/services/cloudfeeds"
 	"github.com/go-chi/chi/v5"
 )
 
-// setupTestServer starts a mocked Enelogic API server and changes the baseURL to make
-// all requests go to the mocked API.
+// setupTestServer starts a mocked Enelogic API server and returns a Provider
+// that makes all requests go to the mocked API.
 //
 // Call the teardown function that is returned when you are done.
-func setupTestServer(t *testing.T, defineRoutesFn func(r *chi.Mux)) func() {
+func setupTestServer(t *testing.T, defineRoutesFn func(r *chi.Mux)) (*Provider, func()) {
 	t.Helper()
 
 	r := chi.NewMux()
@@ -23,13 +25,13 @@ func setupTestServer(t *testing.T, defineRoutesFn func(r *chi.Mux)) func() {
 	defineRoutesFn(r)
 
 	server := httptest.NewServer(r)
-	originalURL := baseURL
-	baseURL = server.URL
-
-	return func() {
-		baseURL = originalURL
-		server.Close()
+	client := &http.Client{
+		Transport: cloudfeeds.NewTransport(server.Client().Transport, cloudfeeds.RateLimit{
+			MaxRetries: 2,
+		}),
 	}
+
+	return NewProvider(client, server.URL), server.Close
 }
 
 func TestSplitDays_multipleDays(t *testing.T) {
@@ -140,7 +142,7 @@ func TestSplitDays_singeDay(t *testing.T) {
 }
 
 func TestGetMeasuringPoints(t *testing.T) {
-	teardown := setupTestServer(t, func(r *chi.Mux) {
+	provider, teardown := setupTestServer(t, func(r *chi.Mux) {
 		// Emulate /measuringpoints API endpoint
 		r.Get(endpointMeasuringPoints, func(w http.ResponseWriter, r *http.Request) {
 			data := `[{
@@ -158,8 +160,80 @@ func TestGetMeasuringPoints(t *testing.T) {
 	})
 	defer teardown()
 
-	_, err := getMeasuringPoints(context.Background(), "token")
+	_, err := provider.getMeasuringPoints(context.Background(), "token")
 	if err != nil {
 		t.Fatalf("getMeasuringPoints() err = %v", err)
 	}
 }
+
+func TestDownloadWindow_retriesTooManyRequests(t *testing.T) {
+	var requests atomic.Int32
+
+	provider, teardown := setupTestServer(t, func(r *chi.Mux) {
+		// Emulate /measuringpoints/{id}/datapoint/days/{from}/{to} API endpoint
+		r.Get("/measuringpoints/1/datapoint/days/2023-12-15/2023-12-16", func(w http.ResponseWriter, r *http.Request) {
+			// Fail the first request, like enelogic does when it is busy.
+			if requests.Add(1) == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+
+			w.Write([]byte(`[{"quantity": "1.5", "rate": 180, "date": "2023-12-15 00:00:00"}]`))
+		})
+
+		// Emulate /measuringpoints/{id}/datapoints/{from}/{to} API endpoint
+		r.Get("/measuringpoints/1/datapoints/2023-12-15/2023-12-16", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`[{"quantity": 2, "rate": 180, "datetime": "2023-12-15 00:15:00"}]`))
+		})
+	})
+	defer teardown()
+
+	from := time.Date(2023, 12, 15, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+	mp := cloudfeeds.MeasuringPoint{ID: "1", Kind: UnitTypeElectricity.String()}
+
+	measurements, err := provider.DownloadWindow(context.Background(), "token", mp, from, to)
+	if err != nil {
+		t.Fatalf("DownloadWindow() err = %v", err)
+	}
+
+	if len(measurements) != 2 {
+		t.Fatalf("DownloadWindow() len = %d; want 2", len(measurements))
+	}
+
+	if got := requests.Load(); got != 2 {
+		t.Errorf("day datapoints requests = %d; want 2", got)
+	}
+
+	if got, want := measurements[0].Value, "1.500"; got != want {
+		t.Errorf("DownloadWindow() [0].Value = %s; want %s", got, want)
+	}
+}
+
+func TestDownloadWindow_serverError(t *testing.T) {
+	var requests atomic.Int32
+
+	provider, teardown := setupTestServer(t, func(r *chi.Mux) {
+		r.Get("/measuringpoints/1/datapoint/days/2023-12-15/2023-12-16", func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		})
+	})
+	defer teardown()
+
+	from := time.Date(2023, 12, 15, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+	mp := cloudfeeds.MeasuringPoint{ID: "1", Kind: UnitTypeElectricity.String()}
+
+	_, err := provider.DownloadWindow(context.Background(), "token", mp, from, to)
+	if err == nil {
+		t.Fatal("DownloadWindow() err = nil; want error")
+	}
+
+	// The first request and 2 retries.
+	if got := requests.Load(); got != 3 {
+		t.Errorf("day datapoints requests = %d; want 3", got)
+	}
+}
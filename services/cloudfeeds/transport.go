@@ -0,0 +1,169 @@
+package cloudfeeds
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RateLimit configures a [Transport].
+type RateLimit struct {
+	// Maximum number of requests per second. Zero means no limit.
+	RequestsPerSecond float64
+	// Maximum number of times a request is retried after a 429 or 5xx response.
+	MaxRetries int
+	// Time to wait before the first retry. It is doubled on every next retry.
+	MinBackoff time.Duration
+	// Maximum time to wait before a retry.
+	MaxBackoff time.Duration
+}
+
+// A Transport is an [http.RoundTripper] that limits the rate of requests
+// and retries requests that failed with 429 Too Many Requests or a 5xx status code.
+// It is safe to share a Transport between goroutines.
+type Transport struct {
+	base      http.RoundTripper
+	rateLimit RateLimit
+
+	mu          sync.Mutex
+	nextRequest time.Time
+}
+
+// Create a new Transport that sends requests using base.
+// If base is nil, [http.DefaultTransport] is used.
+func NewTransport(base http.RoundTripper, rateLimit RateLimit) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &Transport{
+		base:      base,
+		rateLimit: rateLimit,
+	}
+}
+
+// RoundTrip implements [http.RoundTripper].
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	for attempt := 0; ; attempt++ {
+		err := t.wait(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if err != nil || !shouldRetry(resp.StatusCode) || attempt >= t.rateLimit.MaxRetries {
+			return resp, err
+		}
+
+		retryReq, ok := rewind(req)
+		if !ok {
+			return resp, nil
+		}
+
+		backoff := t.backoff(attempt, resp)
+		resp.Body.Close()
+
+		logrus.Infoln("request to", req.URL.Host, "failed with status", resp.StatusCode, "retrying in", backoff.String())
+
+		err = sleep(ctx, backoff)
+		if err != nil {
+			return nil, err
+		}
+
+		req = retryReq
+	}
+}
+
+// Wait until the next request is allowed by the rate limit.
+func (t *Transport) wait(ctx context.Context) error {
+	if t.rateLimit.RequestsPerSecond <= 0 {
+		return nil
+	}
+
+	interval := time.Duration(float64(time.Second) / t.rateLimit.RequestsPerSecond)
+
+	t.mu.Lock()
+	now := time.Now()
+	if t.nextRequest.Before(now) {
+		t.nextRequest = now
+	}
+	wait := t.nextRequest.Sub(now)
+	t.nextRequest = t.nextRequest.Add(interval)
+	t.mu.Unlock()
+
+	return sleep(ctx, wait)
+}
+
+// Return the time to wait before retrying a request for the attempt'th time.
+// The Retry-After header of resp is used if it is set.
+func (t *Transport) backoff(attempt int, resp *http.Response) time.Duration {
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+		backoff := time.Duration(seconds) * time.Second
+		if t.rateLimit.MaxBackoff > 0 && backoff > t.rateLimit.MaxBackoff {
+			return t.rateLimit.MaxBackoff
+		}
+		return backoff
+	}
+
+	backoff := t.rateLimit.MinBackoff << attempt
+	if t.rateLimit.MaxBackoff > 0 && (backoff > t.rateLimit.MaxBackoff || backoff <= 0) {
+		backoff = t.rateLimit.MaxBackoff
+	}
+
+	// Add jitter so concurrent requests do not retry at the same time.
+	if backoff > 0 {
+		backoff = backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+	}
+
+	return backoff
+}
+
+// Check if a request that failed with statusCode should be retried.
+func shouldRetry(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+}
+
+// Return a copy of req that can be sent again.
+// False is returned if the body of req can not be read again.
+func rewind(req *http.Request) (*http.Request, bool) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, true
+	}
+
+	if req.GetBody == nil {
+		return nil, false
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, false
+	}
+
+	retryReq := req.Clone(req.Context())
+	retryReq.Body = body
+	return retryReq, true
+}
+
+// Sleep for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
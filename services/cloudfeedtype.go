@@ -1,6 +1,7 @@
 package services
 
 import (
+	"github.com/energietransitie/needforheat-server-api/internal/schedule"
 	"github.com/energietransitie/needforheat-server-api/needforheat/cloudfeedtype"
 )
 
@@ -15,11 +16,40 @@ func NewCloudFeedTypeService(repository cloudfeedtype.CloudFeedTypeRepository) *
 	}
 }
 
-func (s *CloudFeedTypeService) Create(name string, authorizationURL string, tokenURL string, clientID string, clientSecret string, scope string, redirectURL string) (cloudfeedtype.CloudFeedType, error) {
-	cloudFeed := cloudfeedtype.MakeCloudFeedType(name, authorizationURL, tokenURL, clientID, clientSecret, scope, redirectURL)
+func (s *CloudFeedTypeService) Create(name string, authorizationURL string, tokenURL string, clientID string, clientSecret string, scope string, redirectURL string, revocationURL string, downloadSchedule string) (cloudfeedtype.CloudFeedType, error) {
+	if downloadSchedule != "" {
+		_, err := schedule.Parse(downloadSchedule)
+		if err != nil {
+			return cloudfeedtype.CloudFeedType{}, err
+		}
+	}
+
+	cloudFeed := cloudfeedtype.MakeCloudFeedType(name, authorizationURL, tokenURL, clientID, clientSecret, scope, redirectURL, revocationURL, downloadSchedule)
 	return s.repository.Create(cloudFeed)
 }
 
+// Set the schedule on which data is downloaded for the CloudFeedType with id.
+// An empty downloadSchedule resets it to the default schedule.
+func (s *CloudFeedTypeService) SetDownloadSchedule(id uint, downloadSchedule string) (cloudfeedtype.CloudFeedType, error) {
+	if downloadSchedule != "" {
+		_, err := schedule.Parse(downloadSchedule)
+		if err != nil {
+			return cloudfeedtype.CloudFeedType{}, err
+		}
+	}
+
+	cloudFeedType, err := s.repository.Find(cloudfeedtype.CloudFeedType{ID: id})
+	if err != nil {
+		return cloudfeedtype.CloudFeedType{}, err
+	}
+
+	cloudFeedType.DownloadSchedule = downloadSchedule
+	// Let the scheduler calculate the next download using the new schedule.
+	cloudFeedType.NextDownloadAt = nil
+
+	return s.repository.Update(cloudFeedType)
+}
+
 func (s *CloudFeedTypeService) Find(cloudFeed cloudfeedtype.CloudFeedType) (cloudfeedtype.CloudFeedType, error) {
 	return s.repository.Find(cloudFeed)
 }
@@ -1,12 +1,17 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/energietransitie/needforheat-server-api/internal/helpers"
+	"github.com/energietransitie/needforheat-server-api/needforheat"
 	"github.com/energietransitie/needforheat-server-api/needforheat/authorization"
 	"github.com/energietransitie/needforheat-server-api/needforheat/device"
+	"github.com/energietransitie/needforheat-server-api/needforheat/latestvalue"
 	"github.com/energietransitie/needforheat-server-api/needforheat/measurement"
 	"github.com/energietransitie/needforheat-server-api/needforheat/property"
 	"github.com/energietransitie/needforheat-server-api/needforheat/upload"
@@ -29,16 +34,20 @@ type DeviceService struct {
 
 	// Services used when getting device info.
 	uploadService *UploadService
+
+	// Service used when aggregating measurements.
+	propertyService *PropertyService
 }
 
 // Create a new DeviceService.
-func NewDeviceService(repository device.DeviceRepository, authService *AuthorizationService, deviceTypeService *DeviceTypeService, AccountService *AccountService, uploadService *UploadService) *DeviceService {
+func NewDeviceService(repository device.DeviceRepository, authService *AuthorizationService, deviceTypeService *DeviceTypeService, AccountService *AccountService, uploadService *UploadService, propertyService *PropertyService) *DeviceService {
 	return &DeviceService{
 		repository:        repository,
 		authService:       authService,
 		deviceTypeService: deviceTypeService,
 		accountService:    AccountService,
 		uploadService:     uploadService,
+		propertyService:   propertyService,
 	}
 }
 
@@ -112,6 +121,26 @@ func (s *DeviceService) AddUpload(id uint, upload upload.Upload) (device.Device,
 	return s.repository.Update(d)
 }
 
+// Set the clock skew settings of the device with id.
+func (s *DeviceService) SetClockSettings(id uint, settings upload.ClockSettings) (upload.ClockSettings, error) {
+	err := settings.Validate()
+	if err != nil {
+		return upload.ClockSettings{}, err
+	}
+
+	err = s.repository.UpdateClockSettings(id, settings)
+	if err != nil {
+		return upload.ClockSettings{}, err
+	}
+
+	return settings, nil
+}
+
+// Get a report of the clock skew of the uploads of the device with id since a time.
+func (s *DeviceService) GetClockHealthByDeviceID(id uint, since needforheat.Time) (upload.ClockHealth, error) {
+	return s.uploadService.GetClockHealthForDeviceWithID(id, since)
+}
+
 func (s *DeviceService) GetAccountByDeviceID(id uint) (uint, error) {
 	device, err := s.repository.Find(device.Device{ID: id})
 	if err != nil {
@@ -130,6 +159,46 @@ func (s *DeviceService) GetMeasurementsByDeviceID(id uint, filters map[string]st
 	return measurements, nil
 }
 
+// Get a page of the measurements of the device with id.
+func (s *DeviceService) GetMeasurementsPageByDeviceID(id uint, filters map[string]string, page measurement.Page) ([]measurement.Measurement, *measurement.Cursor, error) {
+	return s.repository.GetMeasurementsPage(device.Device{ID: id}, filters, page)
+}
+
+// Call fn for every measurement of the device with id, while they are read from the database.
+func (s *DeviceService) StreamMeasurementsByDeviceID(ctx context.Context, id uint, filters map[string]string, fn func(measurement.Measurement) error) error {
+	return s.repository.StreamMeasurements(ctx, device.Device{ID: id}, filters, fn)
+}
+
+// Get the measurements of the device with id, aggregated per property in intervals.
+// Only properties with a numeric value type are aggregated. If the property that is filtered on
+// does not have a numeric value type, [measurement.ErrNotNumeric] is returned.
+func (s *DeviceService) GetAggregatedMeasurementsByDeviceID(id uint, filters map[string]string, aggregation measurement.Aggregation) ([]measurement.Series, error) {
+	if value, ok := filters["property"]; ok {
+		propertyID, err := strconv.ParseUint(value, 10, 64)
+		if err == nil {
+			p, err := s.propertyService.GetByID(uint(propertyID))
+			if err != nil && !helpers.IsMySQLRecordNotFoundError(err) {
+				return nil, err
+			}
+
+			// A property that does not exist has no measurements, so it is not checked.
+			if err == nil {
+				err = aggregation.Check(p)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return s.repository.GetAggregatedMeasurements(device.Device{ID: id}, filters, aggregation)
+}
+
+// Get the latest value of each property of the device with id.
+func (s *DeviceService) GetSnapshotByDeviceID(id uint) (latestvalue.Snapshot, error) {
+	return s.uploadService.GetSnapshot(id, upload.Device)
+}
+
 func (s *DeviceService) GetPropertiesByDeviceID(id uint) ([]property.Property, error) {
 	properties, err := s.repository.GetProperties(device.Device{ID: id})
 	if err != nil {
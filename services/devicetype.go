@@ -4,7 +4,9 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/energietransitie/needforheat-server-api/internal/helpers"
 	"github.com/energietransitie/needforheat-server-api/needforheat/devicetype"
+	"github.com/energietransitie/needforheat-server-api/needforheat/property"
 	"github.com/sigurn/crc16"
 	"github.com/sirupsen/logrus"
 )
@@ -48,6 +50,42 @@ func (s *DeviceTypeService) Create(name string) (devicetype.DeviceType, error) {
 	return deviceType, nil
 }
 
+// Update if the device type with id is strict and the names of the properties it allows.
+// Properties is not changed if it is nil. Names can be aliases of properties.
+// Properties that do not exist are not created, so a mistyped name is not allowed by accident.
+func (s *DeviceTypeService) Update(id uint, strict *bool, properties []string) (devicetype.DeviceType, error) {
+	deviceType, err := s.repository.Find(devicetype.DeviceType{ID: id})
+	if err != nil {
+		return devicetype.DeviceType{}, err
+	}
+
+	if strict != nil {
+		deviceType.Strict = *strict
+	}
+
+	if properties != nil {
+		deviceType.Properties = make([]property.Property, 0, len(properties))
+
+		for _, name := range properties {
+			p, err := s.propertyService.FindByNameOrAlias(name)
+			if helpers.IsMySQLRecordNotFoundError(err) {
+				return devicetype.DeviceType{}, fmt.Errorf("%w: %s", property.ErrUnknownName, name)
+			}
+			if err != nil {
+				return devicetype.DeviceType{}, err
+			}
+			deviceType.Properties = append(deviceType.Properties, p)
+		}
+	}
+
+	return s.repository.Update(deviceType)
+}
+
+// Get the device type of the device with deviceID, including the properties it allows.
+func (s *DeviceTypeService) GetByDeviceID(deviceID uint) (devicetype.DeviceType, error) {
+	return s.repository.FindByDeviceID(deviceID)
+}
+
 func (s *DeviceTypeService) Find(deviceType devicetype.DeviceType) (devicetype.DeviceType, error) {
 	return s.repository.Find(deviceType)
 }
@@ -1,10 +1,12 @@
 package services
 
 import (
+	"context"
 	"errors"
 
 	"github.com/energietransitie/needforheat-server-api/needforheat/energyquery"
 	"github.com/energietransitie/needforheat-server-api/needforheat/energyquerytype"
+	"github.com/energietransitie/needforheat-server-api/needforheat/latestvalue"
 	"github.com/energietransitie/needforheat-server-api/needforheat/measurement"
 	"github.com/energietransitie/needforheat-server-api/needforheat/property"
 	"github.com/energietransitie/needforheat-server-api/needforheat/upload"
@@ -76,6 +78,21 @@ func (s *EnergyQueryService) GetMeasurementsByEnergyQueryID(id uint, filters map
 	return measurements, nil
 }
 
+// Get a page of the measurements of the energy query with id.
+func (s *EnergyQueryService) GetMeasurementsPageByEnergyQueryID(id uint, filters map[string]string, page measurement.Page) ([]measurement.Measurement, *measurement.Cursor, error) {
+	return s.repository.GetMeasurementsPage(energyquery.EnergyQuery{ID: id}, filters, page)
+}
+
+// Call fn for every measurement of the energy query with id, while they are read from the database.
+func (s *EnergyQueryService) StreamMeasurementsByEnergyQueryID(ctx context.Context, id uint, filters map[string]string, fn func(measurement.Measurement) error) error {
+	return s.repository.StreamMeasurements(ctx, energyquery.EnergyQuery{ID: id}, filters, fn)
+}
+
+// Get the latest value of each property of the energy query with id.
+func (s *EnergyQueryService) GetSnapshotByEnergyQueryID(id uint) (latestvalue.Snapshot, error) {
+	return s.uploadService.GetSnapshot(id, upload.EnergyQuery)
+}
+
 func (s *EnergyQueryService) GetPropertiesByEnergyQueryID(id uint) ([]property.Property, error) {
 	properties, err := s.repository.GetProperties(energyquery.EnergyQuery{ID: id})
 	if err != nil {
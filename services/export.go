@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"io"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat/campaign"
+	"github.com/energietransitie/needforheat-server-api/needforheat/export"
+)
+
+type ExportService struct {
+	repository    export.ExportRepository
+	campaignRepo  campaign.CampaignRepository
+	pseudonymizer *export.Pseudonymizer
+}
+
+// Create a new ExportService.
+func NewExportService(repository export.ExportRepository, campaignRepo campaign.CampaignRepository, pseudonymizer *export.Pseudonymizer) *ExportService {
+	return &ExportService{
+		repository:    repository,
+		campaignRepo:  campaignRepo,
+		pseudonymizer: pseudonymizer,
+	}
+}
+
+// Export the measurements of a campaign selected by filter to w in format.
+// Account IDs and instances are pseudonymised. The number of exported measurements is returned.
+// The export stops when ctx is done.
+func (s *ExportService) Export(ctx context.Context, filter export.Filter, format export.Format, w io.Writer) (int, error) {
+	_, err := s.campaignRepo.Find(campaign.Campaign{ID: filter.CampaignID})
+	if err != nil {
+		return 0, err
+	}
+
+	writer, err := export.NewWriter(w, format)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = s.repository.Stream(ctx, filter, func(record export.Record) error {
+		count++
+		return writer.Write(export.MakeRow(record, s.pseudonymizer))
+	})
+	if err != nil {
+		return count, err
+	}
+
+	return count, writer.Close()
+}
+
+// Get the accounts of a campaign. Accounts are pseudonymised like in exports.
+func (s *ExportService) GetParticipants(campaignID uint) ([]export.Participant, error) {
+	_, err := s.campaignRepo.Find(campaign.Campaign{ID: campaignID})
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := s.repository.GetAccounts(campaignID)
+	if err != nil {
+		return nil, err
+	}
+
+	participants := make([]export.Participant, 0, len(records))
+	for _, record := range records {
+		participants = append(participants, export.MakeParticipant(record, s.pseudonymizer))
+	}
+
+	return participants, nil
+}
+
+// Get the devices or energy queries of a campaign, depending on instanceType.
+// Accounts and instances are pseudonymised like in exports.
+func (s *ExportService) GetInstances(campaignID uint, instanceType string) ([]export.Instance, error) {
+	_, err := s.campaignRepo.Find(campaign.Campaign{ID: campaignID})
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := s.repository.GetInstances(campaignID, instanceType)
+	if err != nil {
+		return nil, err
+	}
+
+	instances := make([]export.Instance, 0, len(records))
+	for _, record := range records {
+		instances = append(instances, export.MakeInstance(record, s.pseudonymizer))
+	}
+
+	return instances, nil
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"github.com/energietransitie/needforheat-server-api/internal/helpers"
 	"github.com/energietransitie/needforheat-server-api/needforheat/property"
 )
 
@@ -31,3 +32,108 @@ func (s *PropertyService) GetByID(id uint) (property.Property, error) {
 func (s *PropertyService) GetByName(name string) (property.Property, error) {
 	return s.repository.Find(property.Property{Name: name})
 }
+
+func (s *PropertyService) GetAll() ([]property.Property, error) {
+	return s.repository.GetAll()
+}
+
+// Get the aliases of the property with id.
+func (s *PropertyService) GetAliases(id uint) ([]string, error) {
+	return s.repository.GetAliases(property.Property{ID: id})
+}
+
+// Find the property with name or with name as an alias.
+func (s *PropertyService) FindByNameOrAlias(name string) (property.Property, error) {
+	p, err := s.GetByName(name)
+	if err == nil || !helpers.IsMySQLRecordNotFoundError(err) {
+		return p, err
+	}
+
+	return s.repository.FindByAlias(name)
+}
+
+// Find the property with name or with name as an alias, or create it if it does not exist yet.
+func (s *PropertyService) FindOrCreate(name string) (property.Property, error) {
+	p, err := s.FindByNameOrAlias(name)
+	if err == nil || !helpers.IsMySQLRecordNotFoundError(err) {
+		return p, err
+	}
+
+	p, err = s.Create(name)
+	if err != nil && helpers.IsMySQLDuplicateError(err) {
+		// The property was created concurrently.
+		return s.GetByName(name)
+	}
+
+	return p, err
+}
+
+// Update the metadata of the property with id.
+func (s *PropertyService) Update(id uint, update property.Update) (property.Property, error) {
+	p, err := s.GetByID(id)
+	if err != nil {
+		return property.Property{}, err
+	}
+
+	p, err = update.Apply(p)
+	if err != nil {
+		return property.Property{}, err
+	}
+
+	return s.repository.Update(p)
+}
+
+// Rename the property with id. The old name is kept as an alias,
+// so measurements that are uploaded with the old name are stored for the property.
+func (s *PropertyService) Rename(id uint, name string) (property.Property, error) {
+	if name == "" {
+		return property.Property{}, property.ErrEmptyName
+	}
+
+	p, err := s.GetByID(id)
+	if err != nil {
+		return property.Property{}, err
+	}
+
+	if p.Name == name {
+		return p, nil
+	}
+
+	err = s.checkNameNotInUse(id, name)
+	if err != nil {
+		return property.Property{}, err
+	}
+
+	return s.repository.Rename(p, name)
+}
+
+// Merge the property with sourceID into the property with targetID.
+// All measurements of the source are moved to the target and the source is deleted.
+// The name of the source is kept as an alias of the target.
+func (s *PropertyService) Merge(sourceID uint, targetID uint) (property.Property, error) {
+	if sourceID == targetID {
+		return property.Property{}, property.ErrMergeIntoItself
+	}
+
+	err := s.repository.Merge(property.Property{ID: sourceID}, property.Property{ID: targetID})
+	if err != nil {
+		return property.Property{}, err
+	}
+
+	return s.GetByID(targetID)
+}
+
+// Check that name is not the name or an alias of another property than the property with id.
+func (s *PropertyService) checkNameNotInUse(id uint, name string) error {
+	for _, find := range []func(string) (property.Property, error){s.GetByName, s.repository.FindByAlias} {
+		p, err := find(name)
+		if err == nil && p.ID != id {
+			return property.ErrNameInUse
+		}
+		if err != nil && !helpers.IsMySQLRecordNotFoundError(err) {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/needforheat/authorization"
+	"github.com/energietransitie/needforheat-server-api/needforheat/researcher"
+)
+
+type ResearcherService struct {
+	repository researcher.ResearcherRepository
+
+	// Services used when creating a researcher.
+	authService     *AuthorizationService
+	campaignService *CampaignService
+}
+
+// Create a new ResearcherService.
+func NewResearcherService(repository researcher.ResearcherRepository, authService *AuthorizationService, campaignService *CampaignService) *ResearcherService {
+	return &ResearcherService{
+		repository:      repository,
+		authService:     authService,
+		campaignService: campaignService,
+	}
+}
+
+func (s *ResearcherService) Create(name string, campaignIDs []uint, expiry time.Time) (researcher.Researcher, error) {
+	r, err := researcher.MakeResearcher(name, campaignIDs, expiry)
+	if err != nil {
+		return researcher.Researcher{}, err
+	}
+
+	for _, campaignID := range r.CampaignIDs {
+		_, err = s.campaignService.GetByID(campaignID)
+		if err != nil {
+			return researcher.Researcher{}, err
+		}
+	}
+
+	r, err = s.repository.Create(r)
+	if err != nil {
+		return researcher.Researcher{}, err
+	}
+
+	r.AuthorizationToken, err = s.createToken(r)
+	if err != nil {
+		return researcher.Researcher{}, err
+	}
+
+	return r, nil
+}
+
+// Create a token with read access to the campaigns of a researcher.
+func (s *ResearcherService) createToken(r researcher.Researcher) (string, error) {
+	scopes := make([]authorization.Scope, 0, len(r.CampaignIDs))
+	for _, campaignID := range r.CampaignIDs {
+		scopes = append(scopes, authorization.ScopeCampaignRead.ForCampaign(campaignID))
+	}
+
+	return s.authService.CreateToken(authorization.ResearcherToken, r.ID, r.Expiry, scopes...)
+}
+
+func (s *ResearcherService) Find(researcher researcher.Researcher) (researcher.Researcher, error) {
+	return s.repository.Find(researcher)
+}
+
+func (s *ResearcherService) GetAll() ([]researcher.Researcher, error) {
+	return s.repository.GetAll()
+}
+
+func (s *ResearcherService) Delete(researcher researcher.Researcher) error {
+	return s.repository.Delete(researcher)
+}
+
+func (s *ResearcherService) Reactivate(r researcher.Researcher) (researcher.Researcher, error) {
+	r, err := s.repository.Find(r)
+	if err != nil {
+		return researcher.Researcher{}, err
+	}
+
+	r.Reactivate()
+
+	_, err = s.repository.Update(r)
+	if err != nil {
+		return researcher.Researcher{}, err
+	}
+
+	r.AuthorizationToken, err = s.createToken(r)
+	if err != nil {
+		return researcher.Researcher{}, err
+	}
+
+	return r, nil
+}
+
+func (s *ResearcherService) SetExpiry(r researcher.Researcher, expiry time.Time) (researcher.Researcher, error) {
+	r, err := s.repository.Find(r)
+	if err != nil {
+		return researcher.Researcher{}, err
+	}
+
+	r.SetExpiry(expiry)
+
+	_, err = s.repository.Update(r)
+	if err != nil {
+		return researcher.Researcher{}, err
+	}
+
+	return r, nil
+}
@@ -0,0 +1,299 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/energietransitie/needforheat-server-api/internal/schedule"
+	"github.com/energietransitie/needforheat-server-api/needforheat"
+	"github.com/energietransitie/needforheat-server-api/needforheat/campaign"
+	"github.com/energietransitie/needforheat-server-api/needforheat/export"
+	"github.com/energietransitie/needforheat-server-api/needforheat/measurement"
+	"github.com/energietransitie/needforheat-server-api/needforheat/retention"
+	"github.com/sirupsen/logrus"
+)
+
+type RetentionService struct {
+	repository   retention.RetentionRepository
+	campaignRepo campaign.CampaignRepository
+
+	// Service used to archive raw measurements before they are removed.
+	exportService *ExportService
+	// Directory the archives are stored in.
+	archiveDir string
+}
+
+// Create a new RetentionService.
+func NewRetentionService(
+	repository retention.RetentionRepository,
+	campaignRepo campaign.CampaignRepository,
+	exportService *ExportService,
+	archiveDir string,
+) *RetentionService {
+	return &RetentionService{
+		repository:    repository,
+		campaignRepo:  campaignRepo,
+		exportService: exportService,
+		archiveDir:    archiveDir,
+	}
+}
+
+// Get the retention policy of the campaign with campaignID.
+func (s *RetentionService) GetPolicy(campaignID uint) (retention.Policy, error) {
+	return s.repository.Find(campaignID)
+}
+
+// Set the retention policy of the campaign with campaignID, replacing its current policy.
+func (s *RetentionService) SetPolicy(campaignID uint, rawDays int, downsampleInterval string, aggregateDays *int, archive bool) (retention.Policy, error) {
+	_, err := s.campaignRepo.Find(campaign.Campaign{ID: campaignID})
+	if err != nil {
+		return retention.Policy{}, err
+	}
+
+	policy, err := retention.MakePolicy(campaignID, rawDays, downsampleInterval, aggregateDays, archive)
+	if err != nil {
+		return retention.Policy{}, err
+	}
+
+	return s.repository.Save(policy)
+}
+
+// Delete the retention policy of the campaign with campaignID.
+// Measurements of the campaign are stored as long as the campaign exists after that.
+func (s *RetentionService) DeletePolicy(campaignID uint) error {
+	return s.repository.Delete(campaignID)
+}
+
+// Run this function in a goroutine to enforce the retention policies of all campaigns on a schedule.
+func (s *RetentionService) EnforceInBackground(ctx context.Context, enforceSchedule schedule.Schedule) {
+	for {
+		next := enforceSchedule.Next(time.Now())
+		if next.IsZero() {
+			logrus.Warningln("retention schedule", enforceSchedule, "never runs")
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+
+		reports, err := s.Enforce(false, 0)
+		if err != nil {
+			logrus.Errorln("error enforcing retention policies:", err)
+		}
+
+		for _, report := range reports {
+			if report.Skipped != "" {
+				logrus.Infoln("skipped retention policy of campaign", report.CampaignName, ":", report.Skipped)
+				continue
+			}
+
+			logrus.Infof("enforced retention policy of campaign %s: removed %d raw measurements, created %d aggregates and removed %d aggregates",
+				report.CampaignName, report.RawMeasurements, report.Aggregates, report.ExpiredAggregates)
+		}
+	}
+}
+
+// Enforce the retention policy of the campaign with campaignID, or of all campaigns if campaignID is 0.
+// If dryRun is true, nothing is removed, but the reports describe what would be removed.
+//
+// Policies of other campaigns are still enforced when enforcing the policy of a campaign fails.
+func (s *RetentionService) Enforce(dryRun bool, campaignID uint) ([]retention.Report, error) {
+	var policies []retention.Policy
+
+	if campaignID != 0 {
+		policy, err := s.repository.Find(campaignID)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	} else {
+		var err error
+		policies, err = s.repository.GetAll()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var reports []retention.Report
+	var errs []error
+
+	for _, policy := range policies {
+		report, err := s.enforce(policy, dryRun)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("campaign %d: %w", policy.CampaignID, err))
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, errors.Join(errs...)
+}
+
+// Enforce a retention policy.
+func (s *RetentionService) enforce(policy retention.Policy, dryRun bool) (retention.Report, error) {
+	report := retention.Report{
+		CampaignID: policy.CampaignID,
+		DryRun:     dryRun,
+	}
+
+	c, err := s.campaignRepo.Find(campaign.Campaign{ID: policy.CampaignID})
+	if err != nil {
+		return report, err
+	}
+	report.CampaignName = c.Name
+
+	if c.EndTime == nil {
+		report.Skipped = "campaign has no end time"
+		return report, nil
+	}
+
+	now := time.Now()
+	end := time.Time(*c.EndTime)
+
+	aggregateCutoff := policy.AggregateCutoff(end)
+	aggregatesExpired := aggregateCutoff != nil && aggregateCutoff.Before(now)
+
+	if rawCutoff := policy.RawCutoff(end); rawCutoff.Before(now) {
+		cutoff := needforheat.Time(rawCutoff)
+		report.RawCutoff = &cutoff
+
+		// Aggregates are not created if they would be removed right away.
+		downsample := policy.DownsampleInterval != "" && !aggregatesExpired
+
+		err = s.removeRaw(policy, rawCutoff, downsample, dryRun, &report)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	if aggregatesExpired {
+		cutoff := needforheat.Time(*aggregateCutoff)
+		report.AggregateCutoff = &cutoff
+
+		if dryRun {
+			report.ExpiredAggregates, err = s.repository.CountAggregates(policy.CampaignID, *aggregateCutoff)
+		} else {
+			report.ExpiredAggregates, err = s.repository.DeleteAggregates(policy.CampaignID, *aggregateCutoff)
+		}
+		if err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// Remove the raw measurements of a campaign before cutoff, replacing them with aggregates if downsample is true.
+func (s *RetentionService) removeRaw(policy retention.Policy, cutoff time.Time, downsample bool, dryRun bool, report *retention.Report) error {
+	var err error
+
+	if dryRun {
+		report.RawMeasurements, err = s.repository.CountRaw(policy.CampaignID, cutoff)
+		if err != nil {
+			return err
+		}
+
+		if downsample {
+			report.Aggregates, err = s.repository.CountIntervals(policy.CampaignID, cutoff, policy.Interval())
+		}
+
+		return err
+	}
+
+	if policy.Archive {
+		report.ArchivePath, err = s.archive(policy.CampaignID, cutoff)
+		if err != nil {
+			return err
+		}
+	}
+
+	instances, err := s.repository.GetInstances(policy.CampaignID, cutoff)
+	if err != nil {
+		return err
+	}
+
+	interval := ""
+	if downsample {
+		interval = policy.DownsampleInterval
+	}
+
+	// Every instance is done in its own transaction, so a failure does not undo the instances that were done.
+	for _, instance := range instances {
+		var aggregates []measurement.Measurement
+
+		if downsample {
+			downsampler := retention.NewDownsampler(policy.Interval())
+
+			err = s.repository.StreamRaw(instance, cutoff, func(m measurement.Measurement) error {
+				downsampler.Add(m)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			aggregates = downsampler.Aggregates()
+		}
+
+		count, err := s.repository.ReplaceRaw(instance, cutoff, interval, aggregates)
+		if err != nil {
+			return err
+		}
+
+		report.RawMeasurements += count
+		report.Aggregates += int64(len(aggregates))
+	}
+
+	return nil
+}
+
+// Archive the raw measurements of a campaign before cutoff in a pseudonymised Parquet file.
+// The path of the file is returned, or an empty path if there were no measurements.
+func (s *RetentionService) archive(campaignID uint, cutoff time.Time) (string, error) {
+	err := os.MkdirAll(s.archiveDir, 0o700)
+	if err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("campaign-%d-%s.parquet", campaignID, time.Now().UTC().Format("20060102T150405Z"))
+	path := filepath.Join(s.archiveDir, name)
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", err
+	}
+
+	// Times are stored with millisecond precision, so this exports the measurements before the cutoff.
+	end := cutoff.Add(-time.Millisecond)
+	filter := export.Filter{
+		CampaignID: campaignID,
+		End:        &end,
+		Raw:        true,
+	}
+
+	count, err := s.exportService.Export(context.Background(), filter, export.Parquet, file)
+	closeErr := file.Close()
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	if closeErr != nil {
+		os.Remove(path)
+		return "", closeErr
+	}
+
+	if count == 0 {
+		return "", os.Remove(path)
+	}
+
+	return path, nil
+}
@@ -2,40 +2,69 @@ package services
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/energietransitie/needforheat-server-api/internal/helpers"
 	"github.com/energietransitie/needforheat-server-api/needforheat"
+	"github.com/energietransitie/needforheat-server-api/needforheat/authorization"
 	"github.com/energietransitie/needforheat-server-api/needforheat/device"
+	"github.com/energietransitie/needforheat-server-api/needforheat/devicetype"
+	"github.com/energietransitie/needforheat-server-api/needforheat/latestvalue"
 	"github.com/energietransitie/needforheat-server-api/needforheat/measurement"
+	"github.com/energietransitie/needforheat-server-api/needforheat/property"
 	"github.com/energietransitie/needforheat-server-api/needforheat/upload"
+	"github.com/sirupsen/logrus"
 )
 
 var (
-	ErrEmptyUpload = errors.New("no measurements in upload")
+	ErrEmptyUpload         = errors.New("no measurements in upload")
+	ErrUnknownInstanceType = errors.New("unknown instance type")
+	ErrInstanceNotOwned    = errors.New("instance does not belong to the authenticated device or account")
 )
 
 type UploadService struct {
-	repository upload.UploadRepository
-	deviceRepo device.DeviceRepository
+	repository      upload.UploadRepository
+	deviceRepo      device.DeviceRepository
+	latestValueRepo latestvalue.LatestValueRepository
 
-	// Service used when creating an upload.
-	propertyService *PropertyService
+	// Services used when creating an upload.
+	propertyService   *PropertyService
+	deviceTypeService *DeviceTypeService
+
+	// Policy used when an uploaded measurement conflicts with a stored measurement.
+	conflictPolicy upload.ConflictPolicy
+
+	// Default clock skew settings, for devices that do not set their own.
+	clockSettings upload.ClockSettings
 }
 
 // Create a new UploadService.
 func NewUploadService(
 	repository upload.UploadRepository,
 	deviceRepo device.DeviceRepository,
+	latestValueRepo latestvalue.LatestValueRepository,
 	propertyService *PropertyService,
+	deviceTypeService *DeviceTypeService,
+	conflictPolicy upload.ConflictPolicy,
+	clockSettings upload.ClockSettings,
 ) *UploadService {
 	return &UploadService{
-		repository:      repository,
-		deviceRepo:      deviceRepo,
-		propertyService: propertyService,
+		repository:        repository,
+		deviceRepo:        deviceRepo,
+		latestValueRepo:   latestValueRepo,
+		propertyService:   propertyService,
+		deviceTypeService: deviceTypeService,
+		conflictPolicy:    conflictPolicy,
+		clockSettings:     clockSettings,
 	}
 }
 
-func (s *UploadService) Create(instanceID uint, instanceType upload.InstanceType, deviceTime needforheat.Time, measurements []measurement.Measurement) (upload.Upload, error) {
+// Create a new upload. Measurements that were already stored for the instance are not stored again.
+// The latest values of the instance are updated with the stored measurements.
+//
+// If idempotencyKey is not empty and the instance already created an upload with the same key,
+// the existing upload is returned instead.
+func (s *UploadService) Create(instanceID uint, instanceType upload.InstanceType, deviceTime needforheat.Time, measurements []measurement.Measurement, idempotencyKey string) (upload.Upload, error) {
 	if len(measurements) <= 0 {
 		return upload.Upload{}, ErrEmptyUpload
 	}
@@ -45,11 +74,230 @@ func (s *UploadService) Create(instanceID uint, instanceType upload.InstanceType
 		instanceType = upload.Device
 	}
 
-	upload := upload.MakeUpload(instanceID, instanceType, deviceTime, measurements)
+	if idempotencyKey != "" {
+		existing, err := s.repository.FindByIdempotencyKey(instanceID, instanceType, idempotencyKey)
+		if err == nil {
+			return existing, nil
+		}
+		if !helpers.IsMySQLRecordNotFoundError(err) {
+			return upload.Upload{}, err
+		}
+	}
+
+	measurements, rejected, err := s.setProperties(instanceID, instanceType, measurements)
+	if err != nil {
+		return upload.Upload{}, err
+	}
+
+	u := upload.MakeUpload(instanceID, instanceType, deviceTime, measurements, idempotencyKey)
+
+	settings, err := s.getClockSettings(instanceID, instanceType)
+	if err != nil {
+		return upload.Upload{}, err
+	}
+
+	err = settings.Apply(&u)
+	if err != nil {
+		return upload.Upload{}, err
+	}
+
+	created, err := s.repository.Create(u, s.conflictPolicy)
+	if err != nil {
+		if idempotencyKey != "" && helpers.IsMySQLDuplicateError(err) {
+			// The same upload was created concurrently.
+			return s.repository.FindByIdempotencyKey(instanceID, instanceType, idempotencyKey)
+		}
+		return upload.Upload{}, err
+	}
+
+	stored := created
+	if s.conflictPolicy == upload.ConflictPolicyKeepLast {
+		// Measurements that replaced the value of a stored measurement are not part of the created upload.
+		stored.Measurements = u.Measurements
+	}
+
+	err = s.latestValueRepo.Update(latestvalue.MakeLatestValues(stored))
+	if err != nil {
+		return upload.Upload{}, err
+	}
+
+	created.Rejected = rejected
+	return created, nil
+}
 
-	upload, err := s.repository.Create(upload)
+// Get the latest value of each property of an instance.
+func (s *UploadService) GetSnapshot(instanceID uint, instanceType upload.InstanceType) (latestvalue.Snapshot, error) {
+	values, err := s.latestValueRepo.FindByInstance(instanceID, instanceType)
+	if err != nil {
+		return latestvalue.Snapshot{}, err
+	}
 
-	return upload, err
+	return latestvalue.MakeSnapshot(instanceID, instanceType, values), nil
+}
+
+// Get the latest value of each property of the devices and energy queries of an account.
+// Instances without values are not included.
+func (s *UploadService) GetSnapshotsForAccount(accountID uint) ([]latestvalue.Snapshot, error) {
+	values, err := s.latestValueRepo.FindByAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	return latestvalue.MakeSnapshots(values), nil
+}
+
+// Get the clock skew settings that apply to uploads of the instance.
+// Devices can override the defaults of the server.
+func (s *UploadService) getClockSettings(instanceID uint, instanceType upload.InstanceType) (upload.ClockSettings, error) {
+	if instanceType != upload.Device {
+		return s.clockSettings, nil
+	}
+
+	settings, err := s.deviceRepo.FindClockSettings(instanceID)
+	if err != nil && !helpers.IsMySQLRecordNotFoundError(err) {
+		return upload.ClockSettings{}, err
+	}
+
+	return settings.Or(s.clockSettings), nil
+}
+
+// Get a report of the clock skew of the uploads of the device with id since a time.
+func (s *UploadService) GetClockHealthForDeviceWithID(id uint, since needforheat.Time) (upload.ClockHealth, error) {
+	settings, err := s.getClockSettings(id, upload.Device)
+	if err != nil {
+		return upload.ClockHealth{}, err
+	}
+
+	samples, err := s.repository.GetClockSamples(id, upload.Device, since)
+	if err != nil {
+		return upload.ClockHealth{}, err
+	}
+
+	return upload.MakeClockHealth(settings, samples), nil
+}
+
+// Set the stored property of every measurement and validate the values against the value types of the properties.
+// Names can be aliases of properties.
+// Devices of a strict device type can only upload the properties allowed by the device type.
+// For other devices and energy queries, properties that do not exist yet are created.
+//
+// Measurements with a value that does not match the value type of their property are left out.
+// The remaining measurements and the number of measurements that were left out are returned.
+// If no measurements remain, the error of the last invalid value is returned.
+func (s *UploadService) setProperties(instanceID uint, instanceType upload.InstanceType, measurements []measurement.Measurement) ([]measurement.Measurement, int, error) {
+	var deviceType *devicetype.DeviceType
+	if instanceType == upload.Device {
+		dt, err := s.deviceTypeService.GetByDeviceID(instanceID)
+		if err != nil && !helpers.IsMySQLRecordNotFoundError(err) {
+			return nil, 0, err
+		}
+		if err == nil {
+			deviceType = &dt
+		}
+	}
+
+	properties := make(map[string]property.Property)
+	valid := make([]measurement.Measurement, 0, len(measurements))
+	var invalidErr error
+
+	for _, m := range measurements {
+		p, ok := properties[m.Property.Name]
+		if !ok {
+			var err error
+			p, err = s.findProperty(m.Property.Name, deviceType)
+			if err != nil {
+				return nil, 0, err
+			}
+			properties[m.Property.Name] = p
+		}
+
+		err := p.Validate(m.Value)
+		if errors.Is(err, property.ErrInvalidValue) {
+			logrus.Warnf("rejected measurement of %s %d: %s", instanceType, instanceID, err)
+			invalidErr = err
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+
+		m.Property = p
+		valid = append(valid, m)
+	}
+
+	if len(valid) == 0 {
+		return nil, 0, invalidErr
+	}
+
+	return valid, len(measurements) - len(valid), nil
+}
+
+// Find the property with name for an upload of a device of deviceType, which is nil for energy queries.
+// Strict device types do not create properties, so names that do not exist are not allowed.
+func (s *UploadService) findProperty(name string, deviceType *devicetype.DeviceType) (property.Property, error) {
+	if deviceType == nil || !deviceType.Strict {
+		return s.propertyService.FindOrCreate(name)
+	}
+
+	p, err := s.propertyService.FindByNameOrAlias(name)
+	if helpers.IsMySQLRecordNotFoundError(err) {
+		return property.Property{}, fmt.Errorf("%w: %s does not exist", devicetype.ErrPropertyNotAllowed, name)
+	}
+	if err != nil {
+		return property.Property{}, err
+	}
+
+	return p, deviceType.Allows(p)
+}
+
+// Bind the instance of an upload to the device or account that is authenticated with auth.
+// Devices can only upload for themselves, so an empty instance is the device itself.
+// Accounts can upload for the devices and energy queries they own.
+// The instance the upload should be created for is returned.
+func (s *UploadService) BindInstance(auth *authorization.Authorization, instanceID uint, instanceType upload.InstanceType) (uint, upload.InstanceType, error) {
+	//For older firmwares
+	if instanceType == "" {
+		instanceType = upload.Device
+	}
+
+	if instanceType != upload.Device && instanceType != upload.EnergyQuery {
+		return 0, "", fmt.Errorf("%w: %q", ErrUnknownInstanceType, instanceType)
+	}
+
+	switch {
+	case auth.IsKind(authorization.DeviceToken):
+		if instanceType != upload.Device {
+			return 0, "", fmt.Errorf("%w: devices can only upload for themselves", ErrInstanceNotOwned)
+		}
+
+		if instanceID == 0 {
+			instanceID = auth.ID
+		}
+
+		if instanceID != auth.ID {
+			return 0, "", fmt.Errorf("%w: device %d can not upload for device %d", ErrInstanceNotOwned, auth.ID, instanceID)
+		}
+
+		return instanceID, instanceType, nil
+
+	case auth.IsKind(authorization.AccountToken):
+		accountID, err := s.repository.FindInstanceAccountID(instanceID, instanceType)
+		if err != nil {
+			if helpers.IsMySQLRecordNotFoundError(err) {
+				// Don't reveal if the instance exists.
+				return 0, "", fmt.Errorf("%w: %s %d does not exist", ErrInstanceNotOwned, instanceType, instanceID)
+			}
+			return 0, "", err
+		}
+
+		if accountID != auth.ID {
+			return 0, "", fmt.Errorf("%w: %s %d does not belong to account %d", ErrInstanceNotOwned, instanceType, instanceID, auth.ID)
+		}
+
+		return instanceID, instanceType, nil
+	}
+
+	return 0, "", fmt.Errorf("%w: wrong token kind", ErrInstanceNotOwned)
 }
 
 func (s *UploadService) GetLatestUploadTimeForDeviceWithID(id uint) (*needforheat.Time, bool, error) {
@@ -74,3 +322,14 @@ func (s *UploadService) getCloudFeedAuthCreationTimeForDeviceWithID(id uint) (*n
 	}
 	return creationTime, nil
 }
+
+// Permanently delete all uploads and measurements of the device with id.
+// The number of deleted measurements is returned.
+func (s *UploadService) DeleteAllForDeviceWithID(id uint) (int64, error) {
+	return s.repository.DeleteAllForDeviceWithID(id)
+}
+
+// Get the number of measurements per property per day of the device with id, from start up to end.
+func (s *UploadService) GetMeasurementCountsPerDayForDeviceWithID(id uint, start, end needforheat.Time) ([]measurement.DayCount, error) {
+	return s.deviceRepo.GetMeasurementCountsPerDay(device.Device{ID: id}, start, end)
+}